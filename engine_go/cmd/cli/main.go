@@ -12,6 +12,7 @@ import (
 	"runtime"
 	"time"
 
+	"galatea/engine/cmd/internal/demo"
 	"galatea/engine/internal/adapters/storage"
 	"galatea/engine/internal/kernel"
 	"galatea/engine/internal/kernel/formulas"
@@ -45,13 +46,15 @@ func run() error {
 	}
 	defer db.Close()
 
-	populateProject(db)
+	if err := populateProject(db); err != nil {
+		return fmt.Errorf("populate project: %w", err)
+	}
 	fmt.Println("  [OK] Project populated in DB")
 
 	// --- Phase 2: World Loader ---
 	fmt.Println("\n━━━ Phase 2: World Loader (Cold Path) ━━━")
 	startLoad := time.Now()
-	w, err := world.Load(db, 1)
+	w, err := world.Load(db, 1, rand.Uint64())
 	if err != nil {
 		return fmt.Errorf("load world: %w", err)
 	}
@@ -168,34 +171,49 @@ func run() error {
 
 	numProtos := sysCfg.NumPrototypes
 	numRes := sysCfg.NumResourceTypes
-	resRadii := make([]float64, numRes*numProtos)
-	resAttr := make([]int32, numRes*numProtos)
-	for i := range resRadii {
-		resRadii[i] = 15.0
-		resAttr[i] = 10
-	}
-	agRadii := make([]float64, numProtos*numProtos)
-	for i := range agRadii {
-		agRadii[i] = 15.0
-	}
+	numBeh := sysCfg.NumBehaviors
 
 	sysReg := formulas.NewRegistry()
 	sysEval := formulas.NewEvaluator(128)
 	sysEnv := formulas.NewEnvBuilder(sysEval, sysCfg)
 
+	// Every element is perceived at radius 15; contiguous contenders and
+	// mates weigh display 5 and escalate 3.
+	sysReg.Compile("radius", "15")
+	sysReg.Compile("attr.resource", "10")
+	sysReg.Compile("attr.agent", "5")
+	sysReg.Compile("display", "5")
+	sysReg.Compile("escalate", "3")
+	resPer := systems.NewPerceptionMatrix(numRes, numProtos, numBeh)
+	for i := range resPer.Radius {
+		resPer.Radius[i] = sysReg.Get("radius")
+		resPer.Attractiveness[i] = sysReg.Get("attr.resource")
+	}
+	agPer := systems.NewPerceptionMatrix(numProtos, numProtos, numBeh)
+	fightDisplay := 2 + numRes
+	for i := range agPer.Radius {
+		agPer.Radius[i] = sysReg.Get("radius")
+		agPer.Attractiveness[i] = sysReg.Get("attr.agent")
+		for k, key := range []string{"display", "escalate", "display", "escalate"} {
+			agPer.Interaction[i*numBeh+fightDisplay+k] = sysReg.Get(key)
+		}
+	}
+
 	pctx := &systems.PerceptionContext{
 		World: sysWorld, AgentGrid: agGrid, ResourceGrid: resGrid,
 		Formulas: sysReg, Eval: sysEval, EnvBuilder: sysEnv,
-		ResourceRadii: resRadii, ResourceAttr: resAttr, AgentRadii: agRadii,
+		Resources: resPer, Agents: agPer,
 	}
 
+	actx := &systems.ActionContext{World: sysWorld, Eval: sysEval, EnvBuilder: sysEnv}
+
 	const sysIters = 100
 	startSys := time.Now()
 	for tick := 0; tick < sysIters; tick++ {
 		for i := 0; i < sysWorld.Agents.Count; i++ {
 			systems.Perceive(pctx, i)
 			systems.Decide(sysWorld, i)
-			systems.Act(sysWorld, i)
+			systems.Act(actx, i)
 		}
 		systems.RegenerateResources(sysWorld)
 		systems.ResetAgentStates(sysWorld)
@@ -217,13 +235,9 @@ func run() error {
 		return fmt.Errorf("build engine: %w", err)
 	}
 
-	// Give loaded agents reserves so they can survive.
+	// Reserves come from the metabolism table; only movement needs a nudge.
 	a := engine.World.Agents
-	numNut := engine.World.Config.NumNutrients
 	for i := 0; i < a.Count; i++ {
-		for n := 0; n < numNut; n++ {
-			a.Reserves[i*numNut+n] = 500
-		}
 		a.Speed[i] = 1
 		if a.Direction[i] == 0 {
 			a.Direction[i] = 2
@@ -254,7 +268,7 @@ func run() error {
 	// --- Phase 7: Write Buffer Throughput ---
 	fmt.Println("\n━━━ Phase 7: Write Buffer Throughput ━━━")
 	runRepo := storage.NewSimRunRepo(db)
-	benchRunID, _ := runRepo.Create(1)
+	benchRunID, _ := runRepo.Create(1, 0)
 	wb := storage.NewWriteBuffer(db, benchRunID, storage.WriteBufferConfig{MaxRecords: 100000, TickInterval: 1000})
 
 	const writeRecords = 100000
@@ -276,11 +290,7 @@ func run() error {
 	fmt.Println("\n━━━ Phase 8: Context Cancellation ━━━")
 	engine2, _ := kernel.Build(db, kernel.DefaultEngineConfig(1))
 	a2 := engine2.World.Agents
-	numNut2 := engine2.World.Config.NumNutrients
 	for i := 0; i < a2.Count; i++ {
-		for n := 0; n < numNut2; n++ {
-			a2.Reserves[i*numNut2+n] = 999999
-		}
 		a2.Speed[i] = 1
 		if a2.Direction[i] == 0 {
 			a2.Direction[i] = 2
@@ -317,7 +327,7 @@ func run() error {
 }
 
 // populateProject creates a complete project in the database for testing.
-func populateProject(db *storage.DB) {
+func populateProject(db *storage.DB) error {
 	projRepo := storage.NewProjectInfoRepo(db)
 	projRepo.Init("Galatea Integration Test", "Full engine integration demo")
 
@@ -327,6 +337,14 @@ func populateProject(db *storage.DB) {
 	nutRepo.Create("Fat", 3)
 	nutRepo.Create("Protein", 4)
 
+	metRepo := storage.NewMetabolismRepo(db)
+	for n := int64(1); n <= 4; n++ {
+		metRepo.Set(&storage.Metabolism{
+			NutrientID: n, MinFormula: "0", CriticalFormula: "10",
+			OptimalFormula: "100", InitialFormula: "500", MaxFormula: "1000",
+		})
+	}
+
 	subRepo := storage.NewSubstrateRepo(db)
 	for i := 1; i <= 5; i++ {
 		subRepo.Create(fmt.Sprintf("Substrate%d", i), 0x111111*i, false, i)
@@ -347,8 +365,8 @@ func populateProject(db *storage.DB) {
 	stageRepo := storage.NewStageRepo(db)
 	stageRepo.Create(&storage.Stage{
 		Name: "Larva", SortOrder: 1, CyclesFormula: "50",
-		Condition1Formula: "0", Condition1Op: ">", Condition1Value: 0,
-		Condition2Formula: "0", Condition2Op: ">", Condition2Value: 0,
+		Condition1Formula: "0", Condition1Op: ">=", Condition1Value: 0,
+		Condition2Formula: "0", Condition2Op: ">=", Condition2Value: 0,
 		LogicCyclesReqs: "AND", LogicReqsConds: "AND", LogicCond1Cond2: "AND", Color: 0x00FF00,
 	})
 
@@ -372,6 +390,9 @@ func populateProject(db *storage.DB) {
 
 	envRepo := storage.NewEnvironmentRepo(db)
 	envID, _ := envRepo.Create("Arena", 80, 80, "80x80 test arena")
+	if err := demo.PopulatePerception(db, envID, 2); err != nil {
+		return err
+	}
 
 	// Resources scattered around.
 	for i := 0; i < 10; i++ {
@@ -401,4 +422,5 @@ func populateProject(db *storage.DB) {
 			PrototypeID: &protoID, Sex: sex, Age: 0,
 		})
 	}
+	return nil
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 
+	"galatea/engine/cmd/internal/demo"
 	"galatea/engine/internal/adapters/storage"
 	"galatea/engine/internal/kernel"
 	"galatea/engine/internal/kernel/world"
@@ -218,6 +219,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 
 	g.drawSubstrates(screen)
 	g.drawResources(screen)
+	g.drawClutches(screen)
 	g.drawAgents(screen)
 	g.drawHUD(screen)
 }
@@ -268,8 +270,8 @@ func (g *Game) drawResources(screen *ebiten.Image) {
 		{255, 255, 100, 200}, // Type 1: yellow (sugar).
 		{255, 180, 50, 200},  // Type 2: orange (fat).
 		{255, 80, 80, 200},   // Type 3: red (protein).
-		{100, 255, 100, 200}, // Type 4: green (oviposition).
 	}
+	siteColor := color.RGBA{100, 255, 100, 200} // Green (oviposition).
 
 	for i := 0; i < r.Count; i++ {
 		px := float32(ox + r.PosX[i]*cs)
@@ -281,7 +283,10 @@ func (g *Game) drawResources(screen *ebiten.Image) {
 
 		typeIdx := int(r.TypeID[i])
 		clr := color.RGBA{200, 200, 200, 200}
-		if typeIdx < len(resourceColors) {
+		switch {
+		case typeIdx < len(w.ResourceOviposition) && w.ResourceOviposition[typeIdx]:
+			clr = siteColor
+		case typeIdx < len(resourceColors):
 			clr = resourceColors[typeIdx]
 		}
 
@@ -293,6 +298,39 @@ func (g *Game) drawResources(screen *ebiten.Image) {
 	}
 }
 
+// drawClutches renders the eggs held by each oviposition site as a white
+// disc on the site, sized by how full the site is.
+func (g *Game) drawClutches(screen *ebiten.Image) {
+	w := g.engine.World
+	r := w.Resources
+	cs := g.cellSize
+	ox := g.offsetX
+	oy := g.offsetY
+
+	for i := 0; i < r.Count; i++ {
+		typeIdx := int(r.TypeID[i])
+		if typeIdx >= len(w.ResourceOviposition) || !w.ResourceOviposition[typeIdx] || r.Level[i] <= 0 {
+			continue
+		}
+		px := float32(ox + r.PosX[i]*cs)
+		py := float32(oy + r.PosY[i]*cs)
+
+		if px < -10 || px > windowWidth+10 || py < -10 || py > windowHeight+10 {
+			continue
+		}
+
+		fill := 1.0
+		if r.MaxLevel[i] > 0 {
+			fill = math.Min(1, float64(r.Level[i])/float64(r.MaxLevel[i]))
+		}
+		radius := float32(cs * 0.35 * math.Sqrt(fill))
+		if radius < 1.5 {
+			radius = 1.5
+		}
+		vector.FillCircle(screen, px, py, radius, color.RGBA{250, 250, 235, 230}, false)
+	}
+}
+
 // drawAgents renders agents as colored circles with direction indicators.
 func (g *Game) drawAgents(screen *ebiten.Image) {
 	w := g.engine.World
@@ -363,9 +401,31 @@ func (g *Game) drawHUD(screen *ebiten.Image) {
 		ebiten.ActualFPS(),
 	)
 
+	// Inspect the agent under the cursor.
+	if i := g.agentAt(ebiten.CursorPosition()); i >= 0 {
+		id := w.Agents.ID[i]
+		info += fmt.Sprintf("\nAgent: %s #%d | Generation: %d | Age: %d",
+			w.Names.Name(id), id, w.Names.Generation(id), w.Agents.Age[i])
+	}
+
 	ebitenutil.DebugPrint(screen, info)
 }
 
+// agentAt returns the index of the agent drawn nearest to screen position
+// (mx, my) within one cell, or -1 if none.
+func (g *Game) agentAt(mx, my int) int {
+	a := g.engine.World.Agents
+	best, bestDist := -1, g.cellSize*g.cellSize
+	for i := 0; i < a.Count; i++ {
+		dx := g.offsetX + a.PosX[i]*g.cellSize - float64(mx)
+		dy := g.offsetY + a.PosY[i]*g.cellSize - float64(my)
+		if d := dx*dx + dy*dy; d <= bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
 // --- Helpers ---
 
 // directionVector returns a normalized (dx, dy) for a direction code (1-8).
@@ -475,8 +535,8 @@ func runFromDB(dbPath string) error {
 		return fmt.Errorf("build engine: %w", err)
 	}
 
-	// Give agents reserves if they have none (bootstrap for visualization).
-	bootstrapAgentReserves(engine)
+	// Give agents a heading if they have none (bootstrap for visualization).
+	bootstrapAgentMotion(engine)
 
 	return launchVisualizer(engine)
 }
@@ -496,7 +556,9 @@ func runDemo() {
 		os.RemoveAll(wsDir)
 	}()
 
-	populateDemoProject(db)
+	if err := populateDemoProject(db); err != nil {
+		log.Fatalf("populate project: %v", err)
+	}
 
 	cfg := kernel.DefaultEngineConfig(1)
 	cfg.Longevity = 5000
@@ -505,7 +567,7 @@ func runDemo() {
 		log.Fatalf("build engine: %v", err)
 	}
 
-	bootstrapAgentReserves(engine)
+	bootstrapAgentMotion(engine)
 
 	if err := launchVisualizer(engine); err != nil {
 		log.Fatalf("visualizer: %v", err)
@@ -522,15 +584,9 @@ func launchVisualizer(engine *kernel.Engine) error {
 	return ebiten.RunGame(game)
 }
 
-func bootstrapAgentReserves(engine *kernel.Engine) {
+func bootstrapAgentMotion(engine *kernel.Engine) {
 	a := engine.World.Agents
-	numNut := engine.World.Config.NumNutrients
 	for i := 0; i < a.Count; i++ {
-		for n := 0; n < numNut; n++ {
-			if a.Reserves[i*numNut+n] <= 0 {
-				a.Reserves[i*numNut+n] = 5000 // High reserves for long survival.
-			}
-		}
 		if a.Speed[i] <= 0 {
 			a.Speed[i] = 1
 		}
@@ -540,7 +596,7 @@ func bootstrapAgentReserves(engine *kernel.Engine) {
 	}
 }
 
-func populateDemoProject(db *storage.DB) {
+func populateDemoProject(db *storage.DB) error {
 	projRepo := storage.NewProjectInfoRepo(db)
 	projRepo.Init("Visual Demo", "Self-contained demo for the visualizer")
 
@@ -549,6 +605,15 @@ func populateDemoProject(db *storage.DB) {
 	nutRepo.Create("Sugar", 2)
 	nutRepo.Create("Fat", 3)
 
+	// High starting reserves for long survival.
+	metRepo := storage.NewMetabolismRepo(db)
+	for n := int64(1); n <= 3; n++ {
+		metRepo.Set(&storage.Metabolism{
+			NutrientID: n, MinFormula: "0", CriticalFormula: "50",
+			OptimalFormula: "500", InitialFormula: "5000", MaxFormula: "10000",
+		})
+	}
+
 	subRepo := storage.NewSubstrateRepo(db)
 	subRepo.Create("Grass", 0x228B22, false, 1)
 	subRepo.Create("Sand", 0xC2B280, false, 2)
@@ -563,8 +628,8 @@ func populateDemoProject(db *storage.DB) {
 	stageRepo := storage.NewStageRepo(db)
 	stageRepo.Create(&storage.Stage{
 		Name: "Juvenile", SortOrder: 1, CyclesFormula: "100",
-		Condition1Formula: "0", Condition1Op: ">", Condition1Value: 0,
-		Condition2Formula: "0", Condition2Op: ">", Condition2Value: 0,
+		Condition1Formula: "0", Condition1Op: ">=", Condition1Value: 0,
+		Condition2Formula: "0", Condition2Op: ">=", Condition2Value: 0,
 		LogicCyclesReqs: "AND", LogicReqsConds: "AND", LogicCond1Cond2: "AND", Color: 0x00FF00,
 	})
 
@@ -587,10 +652,14 @@ func populateDemoProject(db *storage.DB) {
 	rtRepo.Create(&storage.ResourceType{Name: "WaterPool", NutrientID: &nutID1, SortOrder: 1})
 	rtRepo.Create(&storage.ResourceType{Name: "Flower", NutrientID: &nutID2, SortOrder: 2})
 	rtRepo.Create(&storage.ResourceType{Name: "FruitTree", NutrientID: &nutID3, SortOrder: 3})
+	rtRepo.Create(&storage.ResourceType{Name: "NestSite", IsOviposition: true, SortOrder: 4})
 
 	const gridSize = 60
 	envRepo := storage.NewEnvironmentRepo(db)
 	envID, _ := envRepo.Create("Demo Arena", gridSize, gridSize, "60x60 demo with substrate zones")
+	if err := demo.PopulatePerception(db, envID, 4); err != nil {
+		return err
+	}
 
 	// Paint substrate map with distinct zones.
 	// Zone layout:
@@ -649,6 +718,14 @@ func populateDemoProject(db *storage.DB) {
 		})
 	}
 
+	// Nest sites along the sand edge hold up to 20 eggs each.
+	for i := 0; i < 6; i++ {
+		envRepo.PlaceResource(&storage.EnvironmentResource{
+			EnvironmentID: envID, ResourceTypeID: 4, Name: fmt.Sprintf("nest_%d", i),
+			PosX: 34 + i*4, PosY: 24, Quality: 5, Level: 0, MaxLevel: 20, RegenRate: 1,
+		})
+	}
+
 	// Place 100 agents spread across the map.
 	for i := 0; i < 100; i++ {
 		sex := "M"
@@ -672,4 +749,5 @@ func populateDemoProject(db *storage.DB) {
 			PrototypeID: &protoID, Sex: sex, Age: 0,
 		})
 	}
+	return nil
 }
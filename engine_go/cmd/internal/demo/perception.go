@@ -0,0 +1,48 @@
+// Package demo holds the project setup shared by the demo commands.
+package demo
+
+import (
+	"fmt"
+
+	"galatea/engine/internal/adapters/storage"
+)
+
+// PopulatePerception lets the stage and both prototypes perceive every
+// resource type and every agent within 15 cells. Contiguous contenders and
+// mates weigh display 5 and escalate 3.
+func PopulatePerception(db *storage.DB, envID int64, numResourceTypes int) error {
+	perRepo := storage.NewPerceptionRepo(db)
+	stageID, maleID, femaleID := int64(1), int64(1), int64(2)
+	kinds := []struct{ stage, proto *int64 }{{&stageID, nil}, {nil, &maleID}, {nil, &femaleID}}
+	fightDisplay := 3 + numResourceTypes // 1-based behavior number.
+	for _, p := range kinds {
+		for rt := 1; rt <= numResourceTypes; rt++ {
+			if _, err := perRepo.AddResourceAttractiveness(&storage.Attractiveness{
+				EnvironmentID: envID, TargetID: int64(rt),
+				PerceiverStageID: p.stage, PerceiverPrototypeID: p.proto,
+				AttractivenessFormula: "10", RadiusFormula: "15",
+			}); err != nil {
+				return fmt.Errorf("add resource attractiveness: %w", err)
+			}
+		}
+		for _, o := range kinds {
+			if _, err := perRepo.AddAgentAttractiveness(&storage.Attractiveness{
+				EnvironmentID: envID, ObservedStageID: o.stage, ObservedPrototypeID: o.proto,
+				PerceiverStageID: p.stage, PerceiverPrototypeID: p.proto,
+				AttractivenessFormula: "5", RadiusFormula: "15",
+			}); err != nil {
+				return fmt.Errorf("add agent attractiveness: %w", err)
+			}
+			for k, f := range []string{"5", "3", "5", "3"} {
+				if _, err := perRepo.AddAgentInteraction(&storage.Interaction{
+					EnvironmentID: envID, ObservedStageID: o.stage, ObservedPrototypeID: o.proto,
+					PerceiverStageID: p.stage, PerceiverPrototypeID: p.proto,
+					BehaviorIndex: fightDisplay + k, Formula: f,
+				}); err != nil {
+					return fmt.Errorf("add agent interaction: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -2,13 +2,15 @@ module galatea/engine
 
 go 1.26.4
 
-require modernc.org/sqlite v1.53.0
+require (
+	github.com/hajimehoshi/ebiten/v2 v2.9.9
+	modernc.org/sqlite v1.53.0
+)
 
 require (
 	github.com/ebitengine/gomobile v0.0.0-20250923094054-ea854a63cce1 // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
 	github.com/ebitengine/purego v0.9.0 // indirect
-	github.com/hajimehoshi/ebiten/v2 v2.9.9 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
 	golang.org/x/sync v0.20.0 // indirect
 )
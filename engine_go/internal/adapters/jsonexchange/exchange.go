@@ -38,20 +38,33 @@ type LociSetExport struct {
 	SchemaVersion int           `json:"schema_version"`
 	Type          string        `json:"type"`
 	Loci          []LocusExport `json:"loci"`
+	Traits        []TraitExport `json:"traits,omitempty"`
 }
 
 // LocusExport represents a single locus in export format.
 type LocusExport struct {
-	Name              string  `json:"name"`
-	IsContinuous      bool    `json:"is_continuous"`
-	DominantValue     float64 `json:"dominant_value"`
-	RecessiveValue    float64 `json:"recessive_value"`
-	MutationRateDom   float64 `json:"mutation_rate_dom"`
-	MutationRateRec   float64 `json:"mutation_rate_rec"`
-	MutationRangeDom  float64 `json:"mutation_range_dom"`
-	MutationRangeRec  float64 `json:"mutation_range_rec"`
-	DefaultExpression string  `json:"default_expression"`
-	SortOrder         int     `json:"sort_order"`
+	Name                 string  `json:"name"`
+	IsContinuous         bool    `json:"is_continuous"`
+	DominantValue        float64 `json:"dominant_value"`
+	RecessiveValue       float64 `json:"recessive_value"`
+	MutationRateDom      float64 `json:"mutation_rate_dom"`
+	MutationRateRec      float64 `json:"mutation_rate_rec"`
+	MutationRangeDom     float64 `json:"mutation_range_dom"`
+	MutationRangeRec     float64 `json:"mutation_range_rec"`
+	DefaultExpression    string  `json:"default_expression"`
+	SortOrder            int     `json:"sort_order"`
+	Chromosome           int     `json:"chromosome"`
+	MapPosition          float64 `json:"map_position"`
+	SexLinkage           string  `json:"sex_linkage"`
+	Expression           string  `json:"expression"`
+	DominanceCoefficient float64 `json:"dominance_coefficient"`
+}
+
+// TraitExport represents a derived trait in export format.
+type TraitExport struct {
+	Name      string `json:"name"`
+	Formula   string `json:"formula"`
+	SortOrder int    `json:"sort_order"`
 }
 
 // PrototypeSetExport represents an exported set of prototypes.
@@ -136,7 +149,8 @@ func ImportSubstrates(db *storage.DB, filePath string) (*ImportResult, error) {
 	return result, nil
 }
 
-// ImportLoci reads a loci JSON file and inserts into the database.
+// ImportLoci reads a loci JSON file and inserts its loci and derived traits
+// into the database.
 func ImportLoci(db *storage.DB, filePath string) (*ImportResult, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -165,16 +179,21 @@ func ImportLoci(db *storage.DB, filePath string) (*ImportResult, error) {
 			continue
 		}
 		_, err := repo.Create(&storage.Locus{
-			Name:              locus.Name,
-			IsContinuous:      locus.IsContinuous,
-			DominantValue:     locus.DominantValue,
-			RecessiveValue:    locus.RecessiveValue,
-			MutationRateDom:   locus.MutationRateDom,
-			MutationRateRec:   locus.MutationRateRec,
-			MutationRangeDom:  locus.MutationRangeDom,
-			MutationRangeRec:  locus.MutationRangeRec,
-			DefaultExpression: locus.DefaultExpression,
-			SortOrder:         locus.SortOrder,
+			Name:                 locus.Name,
+			IsContinuous:         locus.IsContinuous,
+			DominantValue:        locus.DominantValue,
+			RecessiveValue:       locus.RecessiveValue,
+			MutationRateDom:      locus.MutationRateDom,
+			MutationRateRec:      locus.MutationRateRec,
+			MutationRangeDom:     locus.MutationRangeDom,
+			MutationRangeRec:     locus.MutationRangeRec,
+			DefaultExpression:    locus.DefaultExpression,
+			SortOrder:            locus.SortOrder,
+			Chromosome:           locus.Chromosome,
+			MapPosition:          locus.MapPosition,
+			SexLinkage:           locus.SexLinkage,
+			Expression:           locus.Expression,
+			DominanceCoefficient: locus.DominanceCoefficient,
 		})
 		if err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("locus %s: %v", locus.Name, err))
@@ -183,6 +202,25 @@ func ImportLoci(db *storage.DB, filePath string) (*ImportResult, error) {
 		result.Imported++
 	}
 
+	traitRepo := storage.NewTraitRepo(db)
+	existingTraits, _ := traitRepo.List()
+	traitNames := make(map[string]bool)
+	for _, t := range existingTraits {
+		traitNames[t.Name] = true
+	}
+	for _, trait := range export.Traits {
+		if traitNames[trait.Name] {
+			result.Skipped++
+			continue
+		}
+		_, err := traitRepo.Create(&storage.Trait{Name: trait.Name, Formula: trait.Formula, SortOrder: trait.SortOrder})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("trait %s: %v", trait.Name, err))
+			continue
+		}
+		result.Imported++
+	}
+
 	return result, nil
 }
 
@@ -22,8 +22,8 @@ func TestOpenMemory(t *testing.T) {
 	if err != nil {
 		t.Fatalf("SchemaVersion failed: %v", err)
 	}
-	if version != 1 {
-		t.Fatalf("expected schema version 1, got %d", version)
+	if version != 11 {
+		t.Fatalf("expected schema version 11, got %d", version)
 	}
 
 	// Verify a sample table exists.
@@ -273,6 +273,26 @@ func TestEnvironmentWithElements(t *testing.T) {
 		t.Fatalf("expected 'agent001', got %q", agents[0].Name)
 	}
 
+	// Founder genetics
+	locID, _ := NewLocusRepo(db).Create(&Locus{Name: "Size", IsContinuous: true, DefaultExpression: "0"})
+	if err := envRepo.SetAlleleFrequency(&AlleleFrequency{EnvironmentID: envID, LocusID: locID, DominantFrequency: 0.2}); err != nil {
+		t.Fatalf("SetAlleleFrequency: %v", err)
+	}
+	if err := envRepo.SetAlleleFrequency(&AlleleFrequency{EnvironmentID: envID, LocusID: locID, DominantFrequency: 0.7}); err != nil {
+		t.Fatalf("SetAlleleFrequency (replace): %v", err)
+	}
+	freqs, _ := envRepo.ListAlleleFrequencies(envID)
+	if len(freqs) != 1 || freqs[0].DominantFrequency != 0.7 {
+		t.Fatalf("expected one frequency of 0.7, got %+v", freqs)
+	}
+	if err := envRepo.SetAgentAllele(&AgentAllele{AgentID: agents[0].ID, LocusID: locID, Allele: 1, Dominant: true}); err != nil {
+		t.Fatalf("SetAgentAllele: %v", err)
+	}
+	alleles, _ := envRepo.ListAgentAlleles(envID)
+	if len(alleles) != 1 || alleles[0].Allele != 1 || !alleles[0].Dominant {
+		t.Fatalf("expected one dominant maternal allele, got %+v", alleles)
+	}
+
 	// Verify cascade delete
 	envRepo.Delete(envID)
 	resources, _ = envRepo.ListResources(envID)
@@ -281,13 +301,73 @@ func TestEnvironmentWithElements(t *testing.T) {
 	}
 }
 
+func TestLocusLinkage(t *testing.T) {
+	db := mustOpenMemory(t)
+	repo := NewLocusRepo(db)
+
+	free, _ := repo.Create(&Locus{Name: "Size", IsContinuous: true, DefaultExpression: "0"})
+	linked, err := repo.Create(&Locus{Name: "Ornament", IsContinuous: true, DefaultExpression: "0",
+		SortOrder: 1, Chromosome: 2, MapPosition: 12.5, SexLinkage: "X"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	l, err := repo.GetByID(linked)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if l.Chromosome != 2 || l.MapPosition != 12.5 || l.SexLinkage != "X" {
+		t.Fatalf("expected X chromosome 2 at 12.5 cM, got %s %d at %v", l.SexLinkage, l.Chromosome, l.MapPosition)
+	}
+	loci, _ := repo.List()
+	if len(loci) != 2 || loci[0].ID != free || loci[0].Chromosome != 0 || loci[0].SexLinkage != "autosomal" || loci[1].Chromosome != 2 {
+		t.Fatalf("expected an unlinked and a linked locus, got %+v", loci)
+	}
+
+	if _, err := repo.Create(&Locus{Name: "Bad", DefaultExpression: "0", MapPosition: -1}); err == nil {
+		t.Fatal("expected a negative map position to be rejected")
+	}
+}
+
+func TestLocusExpressionAndTraits(t *testing.T) {
+	db := mustOpenMemory(t)
+	repo := NewLocusRepo(db)
+
+	plain, _ := repo.Create(&Locus{Name: "Size", IsContinuous: true, DefaultExpression: "0"})
+	partial, err := repo.Create(&Locus{Name: "Horn", IsContinuous: true, DefaultExpression: "0",
+		SortOrder: 1, Expression: "partial", DominanceCoefficient: 0.25})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if l, _ := repo.GetByID(plain); l.Expression != "dominant" {
+		t.Fatalf("expected the dominant default, got %q", l.Expression)
+	}
+	if l, _ := repo.GetByID(partial); l.Expression != "partial" || l.DominanceCoefficient != 0.25 {
+		t.Fatalf("expected partial dominance 0.25, got %q %v", l.Expression, l.DominanceCoefficient)
+	}
+
+	traits := NewTraitRepo(db)
+	traits.Create(&Trait{Name: "Weapon", Formula: "TraitBody * CL2", SortOrder: 1})
+	body, _ := traits.Create(&Trait{Name: "Body", Formula: "CL1 + CL2"})
+	list, err := traits.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != body || list[1].Formula != "TraitBody * CL2" {
+		t.Fatalf("expected Body then Weapon, got %+v", list)
+	}
+	if _, err := traits.Create(&Trait{Name: "Body"}); err == nil {
+		t.Fatal("expected a duplicate trait name to be rejected")
+	}
+}
+
 func TestWriteBuffer(t *testing.T) {
 	db := mustOpenMemory(t)
 
 	envRepo := NewEnvironmentRepo(db)
 	envID, _ := envRepo.Create("Env", 10, 10, "")
 	runRepo := NewSimRunRepo(db)
-	runID, _ := runRepo.Create(envID)
+	runID, _ := runRepo.Create(envID, 0)
 
 	cfg := WriteBufferConfig{MaxRecords: 50, TickInterval: 10}
 	wb := NewWriteBuffer(db, runID, cfg)
@@ -307,7 +387,7 @@ func TestWriteBuffer(t *testing.T) {
 	}
 
 	// Add events.
-	wb.AddEvent(SimEvent{Tick: 1, EventType: "birth", AgentName: "a1", Details: "{}"})
+	wb.AddEvent(SimEvent{Tick: 1, EventType: "birth", AgentID: 7, AgentName: "a1", Details: "{}"})
 
 	if wb.Pending() != 3 {
 		t.Fatalf("expected 3 pending, got %d", wb.Pending())
@@ -334,6 +414,11 @@ func TestWriteBuffer(t *testing.T) {
 	if eventRows != 1 {
 		t.Fatalf("expected 1 event row, got %d", eventRows)
 	}
+	var agentID int64
+	db.Conn.QueryRow("SELECT agent_id FROM sim_events WHERE run_id = ?", runID).Scan(&agentID)
+	if agentID != 7 {
+		t.Fatalf("expected agent_id 7, got %d", agentID)
+	}
 }
 
 func TestWriteBufferAutoFlush(t *testing.T) {
@@ -342,7 +427,7 @@ func TestWriteBufferAutoFlush(t *testing.T) {
 	envRepo := NewEnvironmentRepo(db)
 	envID, _ := envRepo.Create("Env", 10, 10, "")
 	runRepo := NewSimRunRepo(db)
-	runID, _ := runRepo.Create(envID)
+	runID, _ := runRepo.Create(envID, 0)
 
 	cfg := WriteBufferConfig{MaxRecords: 5, TickInterval: 1000}
 	wb := NewWriteBuffer(db, runID, cfg)
@@ -363,6 +448,73 @@ func TestWriteBufferAutoFlush(t *testing.T) {
 	}
 }
 
+func TestLocusStatSeries(t *testing.T) {
+	db := mustOpenMemory(t)
+
+	envID, _ := NewEnvironmentRepo(db).Create("Env", 10, 10, "")
+	runID, _ := NewSimRunRepo(db).Create(envID, 0)
+	locusID, _ := NewLocusRepo(db).Create(&Locus{Name: "Color", DefaultExpression: "0"})
+	protoID, _ := NewPrototypeRepo(db).Create(&Prototype{Name: "MaleA", Sex: "M"})
+
+	wb := NewWriteBuffer(db, runID, DefaultWriteBufferConfig())
+	for _, tick := range []int{20, 10} {
+		wb.AddLocusStats(tick, []LocusStat{
+			{Tick: tick, LocusID: locusID, Individuals: 4, Alleles: 8, Mean: 1.5, ExpectedHet: 0.5,
+				Frequencies: map[int32]float64{1: 0.5, 2: 0.5}},
+			{Tick: tick, LocusID: locusID, Sex: "M", Individuals: 2, Alleles: 4},
+			{Tick: tick, LocusID: locusID, PrototypeID: &protoID, Sex: "M", Individuals: 1, Alleles: 2},
+		})
+	}
+	if err := wb.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	repo := NewLocusStatRepo(db)
+	series, err := repo.Series(runID, locusID, nil, "")
+	if err != nil {
+		t.Fatalf("Series: %v", err)
+	}
+	if len(series) != 2 || series[0].Tick != 10 || series[1].Tick != 20 {
+		t.Fatalf("expected the population at ticks 10 and 20, got %+v", series)
+	}
+	if s := series[0]; s.Individuals != 4 || s.Mean != 1.5 || s.ExpectedHet != 0.5 || s.Frequencies[2] != 0.5 {
+		t.Fatalf("population stats did not round-trip: %+v", s)
+	}
+	males, _ := repo.Series(runID, locusID, nil, "M")
+	if len(males) != 2 || males[0].Individuals != 2 || males[0].Frequencies != nil {
+		t.Fatalf("expected the male series without frequencies, got %+v", males)
+	}
+	proto, _ := repo.Series(runID, locusID, &protoID, "")
+	if len(proto) != 2 || proto[0].Individuals != 1 || *proto[0].PrototypeID != protoID {
+		t.Fatalf("expected the prototype series, got %+v", proto)
+	}
+}
+
+func TestSimRunSeed(t *testing.T) {
+	db := mustOpenMemory(t)
+
+	envID, _ := NewEnvironmentRepo(db).Create("Env", 10, 10, "")
+	runRepo := NewSimRunRepo(db)
+	// Seeds above the int64 range survive the round trip.
+	const seed = uint64(1)<<63 | 12345
+	runID, err := runRepo.Create(envID, seed)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	run, err := runRepo.GetByID(runID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if run.Seed != seed {
+		t.Fatalf("expected seed %d, got %d", seed, run.Seed)
+	}
+	runs, _ := runRepo.ListByEnvironment(envID)
+	if len(runs) != 1 || runs[0].Seed != seed {
+		t.Fatalf("expected one run with seed %d, got %+v", seed, runs)
+	}
+}
+
 func TestWriteBufferThroughput(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping throughput test in short mode")
@@ -373,7 +525,7 @@ func TestWriteBufferThroughput(t *testing.T) {
 	envRepo := NewEnvironmentRepo(db)
 	envID, _ := envRepo.Create("Env", 100, 100, "")
 	runRepo := NewSimRunRepo(db)
-	runID, _ := runRepo.Create(envID)
+	runID, _ := runRepo.Create(envID, 0)
 
 	cfg := WriteBufferConfig{MaxRecords: 50000, TickInterval: 100}
 	wb := NewWriteBuffer(db, runID, cfg)
@@ -406,3 +558,83 @@ func TestWriteBufferThroughput(t *testing.T) {
 		t.Fatalf("expected %d rows, got %d", totalRecords, rows)
 	}
 }
+
+func TestFormulaTables(t *testing.T) {
+	db := mustOpenMemory(t)
+	nutID, _ := NewNutrientRepo(db).Create("Water", 1)
+	stageID, _ := NewStageRepo(db).Create(&Stage{Name: "Larva", CyclesFormula: "50",
+		Condition1Op: ">", Condition2Op: ">", LogicCyclesReqs: "AND", LogicReqsConds: "AND", LogicCond1Cond2: "AND"})
+	protoID, _ := NewPrototypeRepo(db).Create(&Prototype{Name: "MaleA", Sex: "M"})
+	envID, _ := NewEnvironmentRepo(db).Create("Arena", 10, 10, "")
+	rtID, _ := NewResourceTypeRepo(db).Create(&ResourceType{Name: "Spring", NutrientID: &nutID})
+
+	stageRepo := NewStageRepo(db)
+	stageRepo.SetNutrientRequirement(&StageNutrientRequirement{StageID: stageID, NutrientID: nutID, RequirementFormula: "10", CostFormula: "2"})
+	stageRepo.SetTendency(&Tendency{OwnerID: stageID, Direction: 3, Formula: "1"})
+	// Setting the same direction again replaces the formula.
+	stageRepo.SetTendency(&Tendency{OwnerID: stageID, Direction: 3, Formula: "Age * 2"})
+	tendencies, err := stageRepo.ListTendencies()
+	if err != nil {
+		t.Fatalf("ListTendencies: %v", err)
+	}
+	if len(tendencies) != 1 || tendencies[0].Formula != "Age * 2" {
+		t.Fatalf("expected one replaced tendency, got %+v", tendencies)
+	}
+
+	protoRepo := NewPrototypeRepo(db)
+	protoRepo.SetCombat(&PrototypeMatrixCell{PrototypeID: protoID, Action: 1, OpponentAction: 2, Formula: "5"})
+	protoRepo.SetCourtship(&PrototypeMatrixCell{PrototypeID: protoID, Action: 2, OpponentAction: 1, Formula: "3"})
+	combat, _ := protoRepo.ListCombat()
+	courtship, _ := protoRepo.ListCourtship()
+	if len(combat) != 1 || len(courtship) != 1 || combat[0].OpponentAction != 2 {
+		t.Fatalf("unexpected matrices: combat=%+v courtship=%+v", combat, courtship)
+	}
+
+	metRepo := NewMetabolismRepo(db)
+	metRepo.Set(&Metabolism{NutrientID: nutID, MinFormula: "0", CriticalFormula: "10",
+		OptimalFormula: "50", InitialFormula: "60", MaxFormula: "100"})
+	metRepo.SetBehaviorCost(&BehaviorCost{Behavior: "move", NutrientID: nutID, CostFormula: "2"})
+	met, _ := metRepo.List()
+	costs, _ := metRepo.ListBehaviorCosts()
+	if len(met) != 1 || met[0].InitialFormula != "60" || len(costs) != 1 {
+		t.Fatalf("unexpected metabolism: %+v costs: %+v", met, costs)
+	}
+
+	reproRepo := NewReproductionRepo(db)
+	if got, _ := reproRepo.Get(); got != nil {
+		t.Fatal("expected no reproduction row before Set")
+	}
+	reproRepo.Set(&Reproduction{MaxEggsFormula: "20", PaternityFormula: "100", EggMortalityFormula: "5"})
+	got, err := reproRepo.Get()
+	if err != nil || got == nil || got.MaxEggsFormula != "20" || got.EggMortalityFormula != "5" ||
+		got.SpermUseMode != "raffle" || got.OffspringNamePattern != "G{generation}-{id}" || got.SexDetermination != "ratio" {
+		t.Fatalf("Get reproduction: %+v, %v", got, err)
+	}
+	got.SpermUseMode = "last_male"
+	got.OffspringNamePattern = "{mother}.{id}"
+	got.SexDetermination = "environmental"
+	got.SexDeterminationFormula = "R0 / 2"
+	reproRepo.Set(got)
+	if got, _ := reproRepo.Get(); got.SpermUseMode != "last_male" || got.OffspringNamePattern != "{mother}.{id}" ||
+		got.SexDetermination != "environmental" || got.SexDeterminationFormula != "R0 / 2" {
+		t.Fatalf("expected last_male sperm use, {mother}.{id} names and environmental sex, got %+v", got)
+	}
+
+	percRepo := NewPerceptionRepo(db)
+	percRepo.AddAgentAttractiveness(&Attractiveness{EnvironmentID: envID,
+		ObservedPrototypeID: &protoID, PerceiverStageID: &stageID,
+		AttractivenessFormula: "7", RadiusFormula: "12"})
+	percRepo.AddResourceInteraction(&Interaction{EnvironmentID: envID, TargetID: rtID,
+		PerceiverPrototypeID: &protoID, BehaviorIndex: 3, Formula: "4"})
+	attr, err := percRepo.ListAgentAttractiveness(envID)
+	if err != nil {
+		t.Fatalf("ListAgentAttractiveness: %v", err)
+	}
+	if len(attr) != 1 || attr[0].ObservedPrototypeID == nil || *attr[0].ObservedPrototypeID != protoID || attr[0].RadiusFormula != "12" {
+		t.Fatalf("unexpected agent attractiveness: %+v", attr)
+	}
+	inter, _ := percRepo.ListResourceInteractions(envID)
+	if len(inter) != 1 || inter[0].TargetID != rtID || inter[0].BehaviorIndex != 3 {
+		t.Fatalf("unexpected resource interactions: %+v", inter)
+	}
+}
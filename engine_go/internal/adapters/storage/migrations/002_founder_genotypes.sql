@@ -0,0 +1,26 @@
+-- Galatea Simulation Suite - Founder genotypes
+-- Founding allele frequencies per environment and explicit founder genotypes.
+
+-- =============================================================================
+-- FOUNDER GENOTYPES
+-- =============================================================================
+
+-- Frequency of the dominant allele of each locus in the founding population.
+-- Loci without a row default to 0.5. Founders without an explicit genotype
+-- draw both alleles independently (Hardy-Weinberg proportions).
+CREATE TABLE IF NOT EXISTS environment_allele_frequencies (
+    environment_id     INTEGER NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    locus_id           INTEGER NOT NULL REFERENCES loci(id) ON DELETE CASCADE,
+    dominant_frequency REAL    NOT NULL DEFAULT 0.5 CHECK(dominant_frequency BETWEEN 0 AND 1),
+    PRIMARY KEY (environment_id, locus_id)
+);
+
+-- Explicit alleles of an initial agent. Allele 0 is paternal, 1 maternal.
+-- Alleles without a row are sampled from the environment frequencies.
+CREATE TABLE IF NOT EXISTS environment_agent_genotypes (
+    agent_id  INTEGER NOT NULL REFERENCES environment_agents(id) ON DELETE CASCADE,
+    locus_id  INTEGER NOT NULL REFERENCES loci(id) ON DELETE CASCADE,
+    allele    INTEGER NOT NULL CHECK(allele IN (0, 1)),
+    dominant  INTEGER NOT NULL CHECK(dominant IN (0, 1)),
+    PRIMARY KEY (agent_id, locus_id, allele)
+);
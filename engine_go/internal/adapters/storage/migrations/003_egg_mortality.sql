@@ -0,0 +1,10 @@
+-- Galatea Simulation Suite - Egg mortality
+-- Per-tick egg mortality formula of the reproduction singleton.
+
+-- =============================================================================
+-- EGG MORTALITY
+-- =============================================================================
+
+-- Percentage chance (0-100) that an egg dies on a given tick. It is evaluated
+-- with the egg variables and becomes the egg's survive/die decision weights.
+ALTER TABLE reproduction ADD COLUMN egg_mortality_formula TEXT NOT NULL DEFAULT '0';
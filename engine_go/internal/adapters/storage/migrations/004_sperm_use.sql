@@ -0,0 +1,11 @@
+-- Galatea Simulation Suite - Sperm use
+-- Sperm-competition model of the reproduction singleton.
+
+-- =============================================================================
+-- SPERM USE
+-- =============================================================================
+
+-- Which stored sperm pack sires each egg: 'raffle' (in proportion to
+-- paternity), 'first_male', 'last_male' or 'displacement' (a copulation
+-- discards the packs already stored, then raffle).
+ALTER TABLE reproduction ADD COLUMN sperm_use_mode TEXT NOT NULL DEFAULT 'raffle';
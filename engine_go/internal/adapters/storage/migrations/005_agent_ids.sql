@@ -0,0 +1,10 @@
+-- Galatea Simulation Suite - Agent IDs
+-- Stable agent IDs in the simulation events.
+
+-- =============================================================================
+-- AGENT IDS
+-- =============================================================================
+
+-- ID of the agent an event is about, unique within its run (NULL when the
+-- event is not about an agent). Agent IDs are never reused within a run.
+ALTER TABLE sim_events ADD COLUMN agent_id INTEGER;
@@ -0,0 +1,13 @@
+-- Galatea Simulation Suite - Offspring names
+-- Name pattern of the agents hatched during a run.
+
+-- =============================================================================
+-- OFFSPRING NAMES
+-- =============================================================================
+
+-- Placeholders: {mother}, {father} (parent names), {mother_id}, {father_id}
+-- (parent agent IDs), {generation} (one more than the later parent; founders
+-- are generation 0) and {id} (the offspring's agent ID). Parent names do not
+-- nest: within a parent's name, {mother} and {father} are the grandparents'
+-- IDs, so names stay short however deep the pedigree.
+ALTER TABLE reproduction ADD COLUMN offspring_name_pattern TEXT NOT NULL DEFAULT 'G{generation}-{id}';
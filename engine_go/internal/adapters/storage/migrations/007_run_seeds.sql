@@ -0,0 +1,12 @@
+-- Galatea Simulation Suite - Run Seeds
+-- Seed of the random number streams of each simulation run.
+
+-- =============================================================================
+-- RUN SEEDS
+-- =============================================================================
+
+-- Seed the run was started with. A project run again with the same seed
+-- reproduces the run exactly. The unsigned 64-bit seed is stored with its
+-- bits reinterpreted as a signed INTEGER (NULL for runs from before seeds
+-- were recorded).
+ALTER TABLE sim_runs ADD COLUMN seed INTEGER;
@@ -0,0 +1,14 @@
+-- Galatea Simulation Suite - Linkage
+-- Chromosome maps of the genetic loci.
+
+-- =============================================================================
+-- LINKAGE
+-- =============================================================================
+
+-- Chromosome of each locus and its map position on it in centimorgans.
+-- Loci on chromosome 0 are unlinked and recombine freely. Loci sharing any
+-- other chromosome are linked: a gamete switches parental strand between
+-- neighbouring loci with the recombination fraction given by their map
+-- distance (Haldane map function).
+ALTER TABLE loci ADD COLUMN chromosome INTEGER NOT NULL DEFAULT 0 CHECK(chromosome >= 0);
+ALTER TABLE loci ADD COLUMN map_position REAL NOT NULL DEFAULT 0 CHECK(map_position >= 0);
@@ -0,0 +1,29 @@
+-- Galatea Simulation Suite - Sex determination
+-- Sex-linked loci and the sex-determination system of the project.
+
+-- =============================================================================
+-- SEX-LINKED LOCI
+-- =============================================================================
+
+-- 'autosomal', or the sex chromosome carrying the locus: 'X' or 'Y' (XY
+-- sex determination) and 'Z' or 'W' (ZW sex determination). The
+-- heterogametic sex carries a single, hemizygous copy of X- and Z-linked
+-- loci; Y-linked loci occur in males only and W-linked loci in females only.
+ALTER TABLE loci ADD COLUMN sex_linkage TEXT NOT NULL DEFAULT 'autosomal';
+
+-- =============================================================================
+-- SEX DETERMINATION
+-- =============================================================================
+
+-- How the sex of an egg is decided: 'ratio' (drawn from the sex ratio of the
+-- mother's prototype), 'xy' (the sperm carries an X or a Y), 'zw' (the egg
+-- carries a Z or a W), 'haplodiploid' (fertilized eggs become diploid
+-- females and unfertilized ones haploid males; the mother's sex ratio sets
+-- the share left unfertilized) or 'environmental' (sex_determination_formula
+-- decides at eclosion).
+ALTER TABLE reproduction ADD COLUMN sex_determination TEXT NOT NULL DEFAULT 'ratio';
+
+-- Environmental sex determination: percentage chance (0-100) that an egg
+-- develops as a male, evaluated with the egg's variables (reserves, loci and
+-- oviposition site) when it ecloses.
+ALTER TABLE reproduction ADD COLUMN sex_determination_formula TEXT NOT NULL DEFAULT '50';
@@ -0,0 +1,34 @@
+-- Galatea Simulation Suite - Expression
+-- Genotype-to-phenotype models of the loci and derived traits.
+
+-- =============================================================================
+-- LOCUS EXPRESSION
+-- =============================================================================
+
+-- How the two alleles of a locus combine into its CL/DL value:
+-- 'dominant' (an allele flagged dominant masks a recessive one; alleles of
+-- equal dominance average), 'additive' (the alleles average whatever their
+-- dominance), 'partial' (a heterozygote lies dominance_coefficient of the way
+-- from the recessive to the dominant allele: 0 recessive, 0.5 additive,
+-- 1 dominant) or 'overdominant' (the average plus dominance_coefficient times
+-- the difference between the alleles: a heterozygote equals the larger allele
+-- at 0.5 and exceeds both homozygotes only above 0.5). A single, hemizygous
+-- copy expresses its own value.
+ALTER TABLE loci ADD COLUMN expression TEXT NOT NULL DEFAULT 'dominant';
+ALTER TABLE loci ADD COLUMN dominance_coefficient REAL NOT NULL DEFAULT 0.5;
+
+-- =============================================================================
+-- DERIVED TRAITS
+-- =============================================================================
+
+-- Named formulas over the expressed loci (CL1, DL2, ...) and other agent
+-- variables. Each trait is evaluated in sort order after the loci of an agent
+-- or egg and exposed to every formula as Trait<name> (e.g. TraitSize), so a
+-- locus can feed several traits and loci can interact epistatically. A trait
+-- may use the traits before it.
+CREATE TABLE IF NOT EXISTS traits (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       TEXT    NOT NULL UNIQUE,
+    formula    TEXT    NOT NULL DEFAULT '0',
+    sort_order INTEGER NOT NULL DEFAULT 0
+);
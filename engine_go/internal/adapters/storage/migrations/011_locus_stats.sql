@@ -0,0 +1,30 @@
+-- Galatea Simulation Suite - Locus statistics
+-- Population-genetics statistics of each locus, recorded every N ticks.
+
+-- =============================================================================
+-- LOCUS STATISTICS
+-- =============================================================================
+
+-- One row per run, tick, locus and group of living agents: the whole
+-- population (prototype_id NULL, sex ''), each sex (prototype_id NULL, sex
+-- 'M' or 'F') and the adults of each prototype (prototype_id and its sex).
+-- Alleles are identified by value; a hemizygous agent contributes one allele
+-- copy and no heterozygosity, and an agent lacking a sex-linked locus none.
+CREATE TABLE IF NOT EXISTS sim_locus_stats (
+    id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id             INTEGER NOT NULL REFERENCES sim_runs(id) ON DELETE CASCADE,
+    tick               INTEGER NOT NULL,
+    locus_id           INTEGER NOT NULL REFERENCES loci(id),
+    prototype_id       INTEGER REFERENCES prototypes(id),
+    sex                TEXT    NOT NULL DEFAULT '' CHECK(sex IN ('', 'M', 'F')),
+    individuals        INTEGER NOT NULL DEFAULT 0,  -- agents carrying the locus
+    alleles            INTEGER NOT NULL DEFAULT 0,  -- allele copies
+    mean               REAL    NOT NULL DEFAULT 0,  -- mean allele value
+    variance           REAL    NOT NULL DEFAULT 0,  -- population variance of the allele values
+    dominant_fraction  REAL    NOT NULL DEFAULT 0,  -- share of allele copies flagged dominant
+    observed_het       REAL    NOT NULL DEFAULT 0,  -- share of diploid agents with two different alleles
+    expected_het       REAL    NOT NULL DEFAULT 0,  -- 1 - sum of squared allele frequencies
+    allele_frequencies TEXT                         -- discrete loci: JSON object of allele value -> frequency
+);
+
+CREATE INDEX IF NOT EXISTS idx_sim_locus_stats_run_locus ON sim_locus_stats(run_id, locus_id, tick);
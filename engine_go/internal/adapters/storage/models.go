@@ -34,17 +34,30 @@ type SubstrateComposition struct {
 
 // Locus represents a genetic locus definition.
 type Locus struct {
-	ID                int64
-	Name              string
-	IsContinuous      bool
-	DominantValue     float64
-	RecessiveValue    float64
-	MutationRateDom   float64
-	MutationRateRec   float64
-	MutationRangeDom  float64
-	MutationRangeRec  float64
-	DefaultExpression string
-	SortOrder         int
+	ID                   int64
+	Name                 string
+	IsContinuous         bool
+	DominantValue        float64
+	RecessiveValue       float64
+	MutationRateDom      float64
+	MutationRateRec      float64
+	MutationRangeDom     float64
+	MutationRangeRec     float64
+	DefaultExpression    string
+	SortOrder            int
+	Chromosome           int     // 0 = unlinked.
+	MapPosition          float64 // Centimorgans along Chromosome.
+	SexLinkage           string  // autosomal, X, Y, Z or W.
+	Expression           string  // dominant, additive, partial or overdominant.
+	DominanceCoefficient float64 // h of the partial and overdominant models.
+}
+
+// Trait is a derived trait: a named formula over the expressed loci.
+type Trait struct {
+	ID        int64
+	Name      string
+	Formula   string
+	SortOrder int
 }
 
 // Stage represents an immature life stage.
@@ -128,6 +141,22 @@ type EnvironmentAgent struct {
 	Age           int
 }
 
+// AlleleFrequency is the founding frequency of a locus's dominant allele in an environment.
+type AlleleFrequency struct {
+	EnvironmentID     int64
+	LocusID           int64
+	DominantFrequency float64
+}
+
+// AgentAllele is one explicit allele of an initial agent's genotype.
+// Allele 0 is paternal, 1 maternal.
+type AgentAllele struct {
+	AgentID  int64
+	LocusID  int64
+	Allele   int
+	Dominant bool
+}
+
 // SimRun represents a simulation execution record.
 type SimRun struct {
 	ID            int64
@@ -136,4 +165,158 @@ type SimRun struct {
 	EndedAt       *string
 	TotalTicks    int
 	Status        string
+	Seed          uint64 // Seed of the run's random number streams (0 if unrecorded).
+}
+
+// StageNutrientRequirement holds the nutrient requirement and transition cost
+// formulas for one nutrient in one stage.
+type StageNutrientRequirement struct {
+	ID                 int64
+	StageID            int64
+	NutrientID         int64
+	RequirementFormula string
+	CostFormula        string
+}
+
+// Tendency represents a movement tendency formula for one direction (1-8).
+// OwnerID is a stage ID or a prototype ID depending on the table it came from.
+type Tendency struct {
+	ID        int64
+	OwnerID   int64
+	Direction int
+	Formula   string
+}
+
+// PrototypeMorphology holds the genetic and environmental formulas that fix
+// one morphological trait (locus) of a prototype.
+type PrototypeMorphology struct {
+	ID                   int64
+	PrototypeID          int64
+	LocusID              int64
+	GeneticFormula       string
+	EnvironmentalFormula string
+}
+
+// PrototypeMatrixCell is one cell of a prototype's combat or courtship matrix:
+// the weight of Action given the opponent's last OpponentAction.
+type PrototypeMatrixCell struct {
+	ID             int64
+	PrototypeID    int64
+	Action         int
+	OpponentAction int
+	Formula        string
+}
+
+// PrototypeAssignmentCriterion is one rule used to assign a prototype to an
+// agent that reaches adulthood.
+type PrototypeAssignmentCriterion struct {
+	ID          int64
+	PrototypeID int64
+	Priority    int
+	Formula     string
+	Operator    string
+	Threshold   float64
+}
+
+// Metabolism holds the reserve level formulas for one nutrient.
+type Metabolism struct {
+	ID              int64
+	NutrientID      int64
+	MinFormula      string
+	CriticalFormula string
+	OptimalFormula  string
+	InitialFormula  string
+	MaxFormula      string
+}
+
+// BehaviorCost holds the cost formula of a behavior for one nutrient.
+// Behavior is a behavior name (e.g. "move") or a 1-based behavior number.
+type BehaviorCost struct {
+	ID          int64
+	Behavior    string
+	NutrientID  int64
+	CostFormula string
+}
+
+// FeedingGain holds the intake formula for feeding on a resource type.
+type FeedingGain struct {
+	ID             int64
+	ResourceTypeID int64
+	GainFormula    string
+}
+
+// SubstrateVelocity holds the movement speed formula on a substrate.
+type SubstrateVelocity struct {
+	ID              int64
+	SubstrateID     int64
+	VelocityFormula string
+}
+
+// Reproduction holds the project-wide reproduction formulas (singleton).
+type Reproduction struct {
+	MaxEggsFormula            string
+	MaxSpermPacksFormula      string
+	PacksTransferredFormula   string
+	FractionFertilizedFormula string
+	PaternityFormula          string
+	MaxStoredPacksFormula     string
+	ConsumptionRateFormula    string
+	EggsPerCycleFormula       string
+	EggFractionFormula        string
+	PackFractionFormula       string
+	SpermDegradationFormula   string
+	EggMortalityFormula       string
+	SpermUseMode              string // raffle, first_male, last_male or displacement.
+	OffspringNamePattern      string // e.g. G{generation}-{id}; see migration 006.
+	SexDetermination          string // ratio, xy, zw, haplodiploid or environmental.
+	SexDeterminationFormula   string // Male percentage of environmental sex determination.
+}
+
+// GameteCost holds the cost formula of producing one gamete for a sex and nutrient.
+type GameteCost struct {
+	ID          int64
+	Sex         string
+	NutrientID  int64
+	CostFormula string
+}
+
+// Interaction is one row of an interaction matrix (substrates, resources or
+// agents). TargetID is the substrate or resource type ID and is zero for the
+// agent matrix, which uses ObservedStageID/ObservedPrototypeID instead.
+type Interaction struct {
+	ID                   int64
+	EnvironmentID        int64
+	TargetID             int64
+	ObservedStageID      *int64
+	ObservedPrototypeID  *int64
+	PerceiverStageID     *int64
+	PerceiverPrototypeID *int64
+	BehaviorIndex        int
+	Formula              string
+}
+
+// Attractiveness is one row of an attractiveness matrix (substrates, resources
+// or agents). Target fields follow the same convention as Interaction.
+type Attractiveness struct {
+	ID                    int64
+	EnvironmentID         int64
+	TargetID              int64
+	ObservedStageID       *int64
+	ObservedPrototypeID   *int64
+	PerceiverStageID      *int64
+	PerceiverPrototypeID  *int64
+	AttractivenessFormula string
+	RadiusFormula         string
+}
+
+// MemoryInfluence is one row of the memory influence matrix.
+// MemoryType selects the memory array and ElementIndex the 1-based slot in it.
+type MemoryInfluence struct {
+	ID                   int64
+	EnvironmentID        int64
+	MemoryType           string
+	ElementIndex         int
+	PerceiverStageID     *int64
+	PerceiverPrototypeID *int64
+	Formula              string
 }
@@ -145,3 +145,81 @@ func (r *EnvironmentRepo) ListAgents(environmentID int64) ([]EnvironmentAgent, e
 	}
 	return agents, rows.Err()
 }
+
+// SetAlleleFrequency sets the founding dominant-allele frequency of a locus in an environment.
+func (r *EnvironmentRepo) SetAlleleFrequency(af *AlleleFrequency) error {
+	_, err := r.db.Conn.Exec(
+		`INSERT OR REPLACE INTO environment_allele_frequencies (environment_id, locus_id, dominant_frequency)
+		 VALUES (?, ?, ?)`,
+		af.EnvironmentID, af.LocusID, af.DominantFrequency,
+	)
+	if err != nil {
+		return fmt.Errorf("environment allele frequency set: %w", err)
+	}
+	return nil
+}
+
+// ListAlleleFrequencies returns the founding allele frequencies of an environment.
+func (r *EnvironmentRepo) ListAlleleFrequencies(environmentID int64) ([]AlleleFrequency, error) {
+	rows, err := r.db.Conn.Query(
+		`SELECT environment_id, locus_id, dominant_frequency
+		 FROM environment_allele_frequencies WHERE environment_id = ? ORDER BY locus_id`, environmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("environment allele frequencies list: %w", err)
+	}
+	defer rows.Close()
+
+	var freqs []AlleleFrequency
+	for rows.Next() {
+		var af AlleleFrequency
+		if err := rows.Scan(&af.EnvironmentID, &af.LocusID, &af.DominantFrequency); err != nil {
+			return nil, fmt.Errorf("environment allele frequency scan: %w", err)
+		}
+		freqs = append(freqs, af)
+	}
+	return freqs, rows.Err()
+}
+
+// SetAgentAllele sets one explicit allele of an initial agent's genotype.
+func (r *EnvironmentRepo) SetAgentAllele(aa *AgentAllele) error {
+	dominant := 0
+	if aa.Dominant {
+		dominant = 1
+	}
+	_, err := r.db.Conn.Exec(
+		`INSERT OR REPLACE INTO environment_agent_genotypes (agent_id, locus_id, allele, dominant)
+		 VALUES (?, ?, ?, ?)`,
+		aa.AgentID, aa.LocusID, aa.Allele, dominant,
+	)
+	if err != nil {
+		return fmt.Errorf("environment agent allele set: %w", err)
+	}
+	return nil
+}
+
+// ListAgentAlleles returns the explicit alleles of all initial agents in an environment.
+func (r *EnvironmentRepo) ListAgentAlleles(environmentID int64) ([]AgentAllele, error) {
+	rows, err := r.db.Conn.Query(
+		`SELECT g.agent_id, g.locus_id, g.allele, g.dominant
+		 FROM environment_agent_genotypes g
+		 JOIN environment_agents a ON a.id = g.agent_id
+		 WHERE a.environment_id = ? ORDER BY g.agent_id, g.locus_id, g.allele`, environmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("environment agent alleles list: %w", err)
+	}
+	defer rows.Close()
+
+	var alleles []AgentAllele
+	for rows.Next() {
+		var aa AgentAllele
+		var dominant int
+		if err := rows.Scan(&aa.AgentID, &aa.LocusID, &aa.Allele, &dominant); err != nil {
+			return nil, fmt.Errorf("environment agent allele scan: %w", err)
+		}
+		aa.Dominant = dominant == 1
+		alleles = append(alleles, aa)
+	}
+	return alleles, rows.Err()
+}
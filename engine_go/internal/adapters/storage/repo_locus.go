@@ -15,20 +15,31 @@ func NewLocusRepo(db *DB) *LocusRepo {
 	return &LocusRepo{db: db}
 }
 
-// Create inserts a new locus and returns its ID.
+// Create inserts a new locus and returns its ID. An empty sex linkage is
+// stored as autosomal and an empty expression as dominant.
 func (r *LocusRepo) Create(l *Locus) (int64, error) {
 	continuous := 0
 	if l.IsContinuous {
 		continuous = 1
 	}
+	linkage := l.SexLinkage
+	if linkage == "" {
+		linkage = "autosomal"
+	}
+	expression := l.Expression
+	if expression == "" {
+		expression = "dominant"
+	}
 	res, err := r.db.Conn.Exec(
 		`INSERT INTO loci (name, is_continuous, dominant_value, recessive_value,
 		 mutation_rate_dom, mutation_rate_rec, mutation_range_dom, mutation_range_rec,
-		 default_expression, sort_order)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		 default_expression, sort_order, chromosome, map_position, sex_linkage,
+		 expression, dominance_coefficient)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		l.Name, continuous, l.DominantValue, l.RecessiveValue,
 		l.MutationRateDom, l.MutationRateRec, l.MutationRangeDom, l.MutationRangeRec,
-		l.DefaultExpression, l.SortOrder,
+		l.DefaultExpression, l.SortOrder, l.Chromosome, l.MapPosition, linkage,
+		expression, l.DominanceCoefficient,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("locus create: %w", err)
@@ -43,11 +54,13 @@ func (r *LocusRepo) GetByID(id int64) (*Locus, error) {
 	err := r.db.Conn.QueryRow(
 		`SELECT id, name, is_continuous, dominant_value, recessive_value,
 		 mutation_rate_dom, mutation_rate_rec, mutation_range_dom, mutation_range_rec,
-		 default_expression, sort_order
+		 default_expression, sort_order, chromosome, map_position, sex_linkage,
+		 expression, dominance_coefficient
 		 FROM loci WHERE id = ?`, id,
 	).Scan(&l.ID, &l.Name, &continuous, &l.DominantValue, &l.RecessiveValue,
 		&l.MutationRateDom, &l.MutationRateRec, &l.MutationRangeDom, &l.MutationRangeRec,
-		&l.DefaultExpression, &l.SortOrder)
+		&l.DefaultExpression, &l.SortOrder, &l.Chromosome, &l.MapPosition, &l.SexLinkage,
+		&l.Expression, &l.DominanceCoefficient)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -63,7 +76,8 @@ func (r *LocusRepo) List() ([]Locus, error) {
 	rows, err := r.db.Conn.Query(
 		`SELECT id, name, is_continuous, dominant_value, recessive_value,
 		 mutation_rate_dom, mutation_rate_rec, mutation_range_dom, mutation_range_rec,
-		 default_expression, sort_order
+		 default_expression, sort_order, chromosome, map_position, sex_linkage,
+		 expression, dominance_coefficient
 		 FROM loci ORDER BY sort_order`,
 	)
 	if err != nil {
@@ -77,7 +91,8 @@ func (r *LocusRepo) List() ([]Locus, error) {
 		var continuous int
 		if err := rows.Scan(&l.ID, &l.Name, &continuous, &l.DominantValue, &l.RecessiveValue,
 			&l.MutationRateDom, &l.MutationRateRec, &l.MutationRangeDom, &l.MutationRangeRec,
-			&l.DefaultExpression, &l.SortOrder); err != nil {
+			&l.DefaultExpression, &l.SortOrder, &l.Chromosome, &l.MapPosition, &l.SexLinkage,
+			&l.Expression, &l.DominanceCoefficient); err != nil {
 			return nil, fmt.Errorf("locus scan: %w", err)
 		}
 		l.IsContinuous = continuous == 1
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// LocusStatRepo queries the locus statistics recorded by simulation runs.
+type LocusStatRepo struct {
+	db *DB
+}
+
+// NewLocusStatRepo creates a new LocusStatRepo.
+func NewLocusStatRepo(db *DB) *LocusStatRepo {
+	return &LocusStatRepo{db: db}
+}
+
+// Series returns the statistics of a locus in a run as a time series, in
+// tick order, for one group of agents: the adults of prototypeID, or, when
+// prototypeID is nil, the agents of sex ("M" or "F"; "" for the whole
+// population).
+func (r *LocusStatRepo) Series(runID, locusID int64, prototypeID *int64, sex string) ([]LocusStat, error) {
+	query := `SELECT tick, locus_id, prototype_id, sex, individuals, alleles, mean, variance,
+		 dominant_fraction, observed_het, expected_het, allele_frequencies
+		 FROM sim_locus_stats WHERE run_id = ? AND locus_id = ?`
+	args := []any{runID, locusID}
+	if prototypeID != nil {
+		query += " AND prototype_id = ?"
+		args = append(args, *prototypeID)
+	} else {
+		query += " AND prototype_id IS NULL AND sex = ?"
+		args = append(args, sex)
+	}
+	rows, err := r.db.Conn.Query(query+" ORDER BY tick", args...)
+	if err != nil {
+		return nil, fmt.Errorf("locus_stat series: %w", err)
+	}
+	defer rows.Close()
+
+	var series []LocusStat
+	for rows.Next() {
+		var ls LocusStat
+		var freq sql.NullString
+		if err := rows.Scan(&ls.Tick, &ls.LocusID, &ls.PrototypeID, &ls.Sex, &ls.Individuals, &ls.Alleles,
+			&ls.Mean, &ls.Variance, &ls.DominantFraction, &ls.ObservedHet, &ls.ExpectedHet, &freq); err != nil {
+			return nil, fmt.Errorf("locus_stat scan: %w", err)
+		}
+		if freq.Valid {
+			if err := json.Unmarshal([]byte(freq.String), &ls.Frequencies); err != nil {
+				return nil, fmt.Errorf("locus_stat frequencies: %w", err)
+			}
+		}
+		series = append(series, ls)
+	}
+	return series, rows.Err()
+}
@@ -0,0 +1,85 @@
+package storage
+
+import "fmt"
+
+// MetabolismRepo provides access to the metabolism and behavior cost tables.
+type MetabolismRepo struct {
+	db *DB
+}
+
+// NewMetabolismRepo creates a new MetabolismRepo.
+func NewMetabolismRepo(db *DB) *MetabolismRepo {
+	return &MetabolismRepo{db: db}
+}
+
+// Set inserts or replaces the metabolism formulas of a nutrient.
+func (r *MetabolismRepo) Set(m *Metabolism) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		`INSERT OR REPLACE INTO metabolism (nutrient_id, min_formula, critical_formula,
+		 optimal_formula, initial_formula, max_formula)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		m.NutrientID, m.MinFormula, m.CriticalFormula,
+		m.OptimalFormula, m.InitialFormula, m.MaxFormula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("metabolism set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// List returns the metabolism formulas of all nutrients.
+func (r *MetabolismRepo) List() ([]Metabolism, error) {
+	rows, err := r.db.Conn.Query(
+		`SELECT id, nutrient_id, min_formula, critical_formula,
+		 optimal_formula, initial_formula, max_formula
+		 FROM metabolism ORDER BY nutrient_id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("metabolism list: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Metabolism
+	for rows.Next() {
+		var m Metabolism
+		if err := rows.Scan(&m.ID, &m.NutrientID, &m.MinFormula, &m.CriticalFormula,
+			&m.OptimalFormula, &m.InitialFormula, &m.MaxFormula); err != nil {
+			return nil, fmt.Errorf("metabolism scan: %w", err)
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// SetBehaviorCost inserts or replaces the cost of a behavior for a nutrient.
+func (r *MetabolismRepo) SetBehaviorCost(c *BehaviorCost) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		"INSERT OR REPLACE INTO behavior_costs (behavior, nutrient_id, cost_formula) VALUES (?, ?, ?)",
+		c.Behavior, c.NutrientID, c.CostFormula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("behavior cost set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListBehaviorCosts returns all behavior cost formulas.
+func (r *MetabolismRepo) ListBehaviorCosts() ([]BehaviorCost, error) {
+	rows, err := r.db.Conn.Query(
+		"SELECT id, behavior, nutrient_id, cost_formula FROM behavior_costs ORDER BY id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("behavior costs list: %w", err)
+	}
+	defer rows.Close()
+
+	var costs []BehaviorCost
+	for rows.Next() {
+		var c BehaviorCost
+		if err := rows.Scan(&c.ID, &c.Behavior, &c.NutrientID, &c.CostFormula); err != nil {
+			return nil, fmt.Errorf("behavior cost scan: %w", err)
+		}
+		costs = append(costs, c)
+	}
+	return costs, rows.Err()
+}
@@ -0,0 +1,217 @@
+package storage
+
+import "fmt"
+
+// PerceptionRepo provides access to the per-environment perception matrices:
+// interaction and attractiveness for substrates, resources and agents, and
+// memory influence.
+type PerceptionRepo struct {
+	db *DB
+}
+
+// NewPerceptionRepo creates a new PerceptionRepo.
+func NewPerceptionRepo(db *DB) *PerceptionRepo {
+	return &PerceptionRepo{db: db}
+}
+
+// AddSubstrateInteraction inserts a row into interaction_substrates.
+func (r *PerceptionRepo) AddSubstrateInteraction(i *Interaction) (int64, error) {
+	return r.addInteraction("interaction_substrates", "substrate_id", i)
+}
+
+// ListSubstrateInteractions returns the substrate interaction matrix of an environment.
+func (r *PerceptionRepo) ListSubstrateInteractions(environmentID int64) ([]Interaction, error) {
+	return r.listInteractions("interaction_substrates", "substrate_id", environmentID)
+}
+
+// AddResourceInteraction inserts a row into interaction_resources.
+func (r *PerceptionRepo) AddResourceInteraction(i *Interaction) (int64, error) {
+	return r.addInteraction("interaction_resources", "resource_type_id", i)
+}
+
+// ListResourceInteractions returns the resource interaction matrix of an environment.
+func (r *PerceptionRepo) ListResourceInteractions(environmentID int64) ([]Interaction, error) {
+	return r.listInteractions("interaction_resources", "resource_type_id", environmentID)
+}
+
+// AddAgentInteraction inserts a row into interaction_agents.
+func (r *PerceptionRepo) AddAgentInteraction(i *Interaction) (int64, error) {
+	return r.addInteraction("interaction_agents", "", i)
+}
+
+// ListAgentInteractions returns the agent interaction matrix of an environment.
+func (r *PerceptionRepo) ListAgentInteractions(environmentID int64) ([]Interaction, error) {
+	return r.listInteractions("interaction_agents", "", environmentID)
+}
+
+// AddSubstrateAttractiveness inserts a row into attractiveness_substrates.
+func (r *PerceptionRepo) AddSubstrateAttractiveness(a *Attractiveness) (int64, error) {
+	return r.addAttractiveness("attractiveness_substrates", "substrate_id", a)
+}
+
+// ListSubstrateAttractiveness returns the substrate attractiveness matrix of an environment.
+func (r *PerceptionRepo) ListSubstrateAttractiveness(environmentID int64) ([]Attractiveness, error) {
+	return r.listAttractiveness("attractiveness_substrates", "substrate_id", environmentID)
+}
+
+// AddResourceAttractiveness inserts a row into attractiveness_resources.
+func (r *PerceptionRepo) AddResourceAttractiveness(a *Attractiveness) (int64, error) {
+	return r.addAttractiveness("attractiveness_resources", "resource_type_id", a)
+}
+
+// ListResourceAttractiveness returns the resource attractiveness matrix of an environment.
+func (r *PerceptionRepo) ListResourceAttractiveness(environmentID int64) ([]Attractiveness, error) {
+	return r.listAttractiveness("attractiveness_resources", "resource_type_id", environmentID)
+}
+
+// AddAgentAttractiveness inserts a row into attractiveness_agents.
+func (r *PerceptionRepo) AddAgentAttractiveness(a *Attractiveness) (int64, error) {
+	return r.addAttractiveness("attractiveness_agents", "", a)
+}
+
+// ListAgentAttractiveness returns the agent attractiveness matrix of an environment.
+func (r *PerceptionRepo) ListAgentAttractiveness(environmentID int64) ([]Attractiveness, error) {
+	return r.listAttractiveness("attractiveness_agents", "", environmentID)
+}
+
+// AddMemoryInfluence inserts a row into memory_influence.
+func (r *PerceptionRepo) AddMemoryInfluence(m *MemoryInfluence) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		`INSERT INTO memory_influence (environment_id, memory_type, element_index,
+		 perceiver_stage_id, perceiver_prototype_id, formula)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		m.EnvironmentID, m.MemoryType, m.ElementIndex,
+		m.PerceiverStageID, m.PerceiverPrototypeID, m.Formula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("memory_influence create: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListMemoryInfluence returns the memory influence matrix of an environment.
+func (r *PerceptionRepo) ListMemoryInfluence(environmentID int64) ([]MemoryInfluence, error) {
+	rows, err := r.db.Conn.Query(
+		`SELECT id, environment_id, memory_type, element_index,
+		 perceiver_stage_id, perceiver_prototype_id, formula
+		 FROM memory_influence WHERE environment_id = ? ORDER BY id`, environmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("memory_influence list: %w", err)
+	}
+	defer rows.Close()
+
+	var list []MemoryInfluence
+	for rows.Next() {
+		var m MemoryInfluence
+		if err := rows.Scan(&m.ID, &m.EnvironmentID, &m.MemoryType, &m.ElementIndex,
+			&m.PerceiverStageID, &m.PerceiverPrototypeID, &m.Formula); err != nil {
+			return nil, fmt.Errorf("memory_influence scan: %w", err)
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// targetColumns returns the column list that identifies the observed element.
+// An empty targetCol selects the observed stage/prototype pair of the agent tables.
+func targetColumns(targetCol string) string {
+	if targetCol == "" {
+		return "observed_stage_id, observed_prototype_id"
+	}
+	return targetCol
+}
+
+func (r *PerceptionRepo) addInteraction(table, targetCol string, i *Interaction) (int64, error) {
+	args := []any{i.EnvironmentID, i.TargetID}
+	placeholders := "?, ?, ?, ?, ?, ?"
+	if targetCol == "" {
+		args = []any{i.EnvironmentID, i.ObservedStageID, i.ObservedPrototypeID}
+		placeholders = "?, ?, ?, ?, ?, ?, ?"
+	}
+	args = append(args, i.PerceiverStageID, i.PerceiverPrototypeID, i.BehaviorIndex, i.Formula)
+
+	res, err := r.db.Conn.Exec(
+		"INSERT INTO "+table+" (environment_id, "+targetColumns(targetCol)+
+			", perceiver_stage_id, perceiver_prototype_id, behavior_index, formula) VALUES ("+placeholders+")",
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s create: %w", table, err)
+	}
+	return res.LastInsertId()
+}
+
+func (r *PerceptionRepo) listInteractions(table, targetCol string, environmentID int64) ([]Interaction, error) {
+	rows, err := r.db.Conn.Query(
+		"SELECT id, environment_id, "+targetColumns(targetCol)+
+			", perceiver_stage_id, perceiver_prototype_id, behavior_index, formula FROM "+table+
+			" WHERE environment_id = ? ORDER BY id", environmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s list: %w", table, err)
+	}
+	defer rows.Close()
+
+	var list []Interaction
+	for rows.Next() {
+		var i Interaction
+		dest := []any{&i.ID, &i.EnvironmentID, &i.TargetID}
+		if targetCol == "" {
+			dest = []any{&i.ID, &i.EnvironmentID, &i.ObservedStageID, &i.ObservedPrototypeID}
+		}
+		dest = append(dest, &i.PerceiverStageID, &i.PerceiverPrototypeID, &i.BehaviorIndex, &i.Formula)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("%s scan: %w", table, err)
+		}
+		list = append(list, i)
+	}
+	return list, rows.Err()
+}
+
+func (r *PerceptionRepo) addAttractiveness(table, targetCol string, a *Attractiveness) (int64, error) {
+	args := []any{a.EnvironmentID, a.TargetID}
+	placeholders := "?, ?, ?, ?, ?, ?"
+	if targetCol == "" {
+		args = []any{a.EnvironmentID, a.ObservedStageID, a.ObservedPrototypeID}
+		placeholders = "?, ?, ?, ?, ?, ?, ?"
+	}
+	args = append(args, a.PerceiverStageID, a.PerceiverPrototypeID, a.AttractivenessFormula, a.RadiusFormula)
+
+	res, err := r.db.Conn.Exec(
+		"INSERT INTO "+table+" (environment_id, "+targetColumns(targetCol)+
+			", perceiver_stage_id, perceiver_prototype_id, attractiveness_formula, radius_formula) VALUES ("+placeholders+")",
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s create: %w", table, err)
+	}
+	return res.LastInsertId()
+}
+
+func (r *PerceptionRepo) listAttractiveness(table, targetCol string, environmentID int64) ([]Attractiveness, error) {
+	rows, err := r.db.Conn.Query(
+		"SELECT id, environment_id, "+targetColumns(targetCol)+
+			", perceiver_stage_id, perceiver_prototype_id, attractiveness_formula, radius_formula FROM "+table+
+			" WHERE environment_id = ? ORDER BY id", environmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s list: %w", table, err)
+	}
+	defer rows.Close()
+
+	var list []Attractiveness
+	for rows.Next() {
+		var a Attractiveness
+		dest := []any{&a.ID, &a.EnvironmentID, &a.TargetID}
+		if targetCol == "" {
+			dest = []any{&a.ID, &a.EnvironmentID, &a.ObservedStageID, &a.ObservedPrototypeID}
+		}
+		dest = append(dest, &a.PerceiverStageID, &a.PerceiverPrototypeID, &a.AttractivenessFormula, &a.RadiusFormula)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("%s scan: %w", table, err)
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
@@ -99,3 +99,146 @@ func (r *PrototypeRepo) Delete(id int64) error {
 	}
 	return nil
 }
+
+// SetTendency inserts or replaces the movement tendency of a prototype for a direction (1-8).
+func (r *PrototypeRepo) SetTendency(t *Tendency) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		"INSERT OR REPLACE INTO prototype_tendencies (prototype_id, direction, formula) VALUES (?, ?, ?)",
+		t.OwnerID, t.Direction, t.Formula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("prototype tendency set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListTendencies returns the movement tendencies of all prototypes.
+func (r *PrototypeRepo) ListTendencies() ([]Tendency, error) {
+	return listTendencies(r.db, "prototype_tendencies", "prototype_id")
+}
+
+// SetMorphology inserts or replaces the morphology formulas of a prototype for a locus.
+func (r *PrototypeRepo) SetMorphology(m *PrototypeMorphology) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		`INSERT OR REPLACE INTO prototype_morphology (prototype_id, locus_id, genetic_formula, environmental_formula)
+		 VALUES (?, ?, ?, ?)`,
+		m.PrototypeID, m.LocusID, m.GeneticFormula, m.EnvironmentalFormula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("prototype morphology set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListMorphology returns the morphology formulas of all prototypes.
+func (r *PrototypeRepo) ListMorphology() ([]PrototypeMorphology, error) {
+	rows, err := r.db.Conn.Query(
+		`SELECT id, prototype_id, locus_id, genetic_formula, environmental_formula
+		 FROM prototype_morphology ORDER BY prototype_id, locus_id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("prototype morphology list: %w", err)
+	}
+	defer rows.Close()
+
+	var morphology []PrototypeMorphology
+	for rows.Next() {
+		var m PrototypeMorphology
+		if err := rows.Scan(&m.ID, &m.PrototypeID, &m.LocusID, &m.GeneticFormula, &m.EnvironmentalFormula); err != nil {
+			return nil, fmt.Errorf("prototype morphology scan: %w", err)
+		}
+		morphology = append(morphology, m)
+	}
+	return morphology, rows.Err()
+}
+
+// SetCombat inserts or replaces a cell of a prototype's combat matrix.
+func (r *PrototypeRepo) SetCombat(c *PrototypeMatrixCell) (int64, error) {
+	return setMatrixCell(r.db, "prototype_combat", c)
+}
+
+// ListCombat returns the combat matrix cells of all prototypes.
+func (r *PrototypeRepo) ListCombat() ([]PrototypeMatrixCell, error) {
+	return listMatrixCells(r.db, "prototype_combat")
+}
+
+// SetCourtship inserts or replaces a cell of a prototype's courtship matrix.
+func (r *PrototypeRepo) SetCourtship(c *PrototypeMatrixCell) (int64, error) {
+	return setMatrixCell(r.db, "prototype_courtship", c)
+}
+
+// ListCourtship returns the courtship matrix cells of all prototypes.
+func (r *PrototypeRepo) ListCourtship() ([]PrototypeMatrixCell, error) {
+	return listMatrixCells(r.db, "prototype_courtship")
+}
+
+// SetAssignmentCriterion inserts or replaces the assignment criterion of a
+// prototype at the given priority.
+func (r *PrototypeRepo) SetAssignmentCriterion(c *PrototypeAssignmentCriterion) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		`INSERT OR REPLACE INTO prototype_assignment_criteria (prototype_id, priority, formula, operator, threshold)
+		 VALUES (?, ?, ?, ?, ?)`,
+		c.PrototypeID, c.Priority, c.Formula, c.Operator, c.Threshold,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("prototype assignment set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListAssignmentCriteria returns the assignment criteria of all prototypes
+// ordered by priority.
+func (r *PrototypeRepo) ListAssignmentCriteria() ([]PrototypeAssignmentCriterion, error) {
+	rows, err := r.db.Conn.Query(
+		`SELECT id, prototype_id, priority, formula, operator, threshold
+		 FROM prototype_assignment_criteria ORDER BY priority, prototype_id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("prototype assignment list: %w", err)
+	}
+	defer rows.Close()
+
+	var criteria []PrototypeAssignmentCriterion
+	for rows.Next() {
+		var c PrototypeAssignmentCriterion
+		if err := rows.Scan(&c.ID, &c.PrototypeID, &c.Priority, &c.Formula, &c.Operator, &c.Threshold); err != nil {
+			return nil, fmt.Errorf("prototype assignment scan: %w", err)
+		}
+		criteria = append(criteria, c)
+	}
+	return criteria, rows.Err()
+}
+
+// setMatrixCell upserts a cell of a combat or courtship matrix table.
+func setMatrixCell(db *DB, table string, c *PrototypeMatrixCell) (int64, error) {
+	res, err := db.Conn.Exec(
+		"INSERT OR REPLACE INTO "+table+" (prototype_id, action, opponent_action, formula) VALUES (?, ?, ?, ?)",
+		c.PrototypeID, c.Action, c.OpponentAction, c.Formula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s set: %w", table, err)
+	}
+	return res.LastInsertId()
+}
+
+// listMatrixCells reads all cells of a combat or courtship matrix table.
+func listMatrixCells(db *DB, table string) ([]PrototypeMatrixCell, error) {
+	rows, err := db.Conn.Query(
+		"SELECT id, prototype_id, action, opponent_action, formula FROM " + table +
+			" ORDER BY prototype_id, action, opponent_action",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s list: %w", table, err)
+	}
+	defer rows.Close()
+
+	var cells []PrototypeMatrixCell
+	for rows.Next() {
+		var c PrototypeMatrixCell
+		if err := rows.Scan(&c.ID, &c.PrototypeID, &c.Action, &c.OpponentAction, &c.Formula); err != nil {
+			return nil, fmt.Errorf("%s scan: %w", table, err)
+		}
+		cells = append(cells, c)
+	}
+	return cells, rows.Err()
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ReproductionRepo provides access to the reproduction singleton and gamete costs.
+type ReproductionRepo struct {
+	db *DB
+}
+
+// NewReproductionRepo creates a new ReproductionRepo.
+func NewReproductionRepo(db *DB) *ReproductionRepo {
+	return &ReproductionRepo{db: db}
+}
+
+// Get returns the reproduction formulas, or nil if none have been saved.
+func (r *ReproductionRepo) Get() (*Reproduction, error) {
+	p := &Reproduction{}
+	err := r.db.Conn.QueryRow(
+		`SELECT max_eggs_formula, max_sperm_packs_formula, packs_transferred_formula,
+		 fraction_fertilized_formula, paternity_formula, max_stored_packs_formula,
+		 consumption_rate_formula, eggs_per_cycle_formula, egg_fraction_formula,
+		 pack_fraction_formula, sperm_degradation_formula, egg_mortality_formula,
+		 sperm_use_mode, offspring_name_pattern, sex_determination, sex_determination_formula
+		 FROM reproduction WHERE id = 1`,
+	).Scan(&p.MaxEggsFormula, &p.MaxSpermPacksFormula, &p.PacksTransferredFormula,
+		&p.FractionFertilizedFormula, &p.PaternityFormula, &p.MaxStoredPacksFormula,
+		&p.ConsumptionRateFormula, &p.EggsPerCycleFormula, &p.EggFractionFormula,
+		&p.PackFractionFormula, &p.SpermDegradationFormula, &p.EggMortalityFormula,
+		&p.SpermUseMode, &p.OffspringNamePattern, &p.SexDetermination, &p.SexDeterminationFormula)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reproduction get: %w", err)
+	}
+	return p, nil
+}
+
+// Set creates or replaces the reproduction formulas. An empty sperm use mode
+// is stored as raffle, an empty offspring name pattern as G{generation}-{id}
+// and an empty sex determination as ratio.
+func (r *ReproductionRepo) Set(p *Reproduction) error {
+	mode := p.SpermUseMode
+	if mode == "" {
+		mode = "raffle"
+	}
+	pattern := p.OffspringNamePattern
+	if pattern == "" {
+		pattern = "G{generation}-{id}"
+	}
+	sexDetermination := p.SexDetermination
+	if sexDetermination == "" {
+		sexDetermination = "ratio"
+	}
+	_, err := r.db.Conn.Exec(
+		`INSERT OR REPLACE INTO reproduction (id, max_eggs_formula, max_sperm_packs_formula,
+		 packs_transferred_formula, fraction_fertilized_formula, paternity_formula,
+		 max_stored_packs_formula, consumption_rate_formula, eggs_per_cycle_formula,
+		 egg_fraction_formula, pack_fraction_formula, sperm_degradation_formula,
+		 egg_mortality_formula, sperm_use_mode, offspring_name_pattern,
+		 sex_determination, sex_determination_formula)
+		 VALUES (1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.MaxEggsFormula, p.MaxSpermPacksFormula,
+		p.PacksTransferredFormula, p.FractionFertilizedFormula, p.PaternityFormula,
+		p.MaxStoredPacksFormula, p.ConsumptionRateFormula, p.EggsPerCycleFormula,
+		p.EggFractionFormula, p.PackFractionFormula, p.SpermDegradationFormula,
+		p.EggMortalityFormula, mode, pattern,
+		sexDetermination, p.SexDeterminationFormula,
+	)
+	if err != nil {
+		return fmt.Errorf("reproduction set: %w", err)
+	}
+	return nil
+}
+
+// SetGameteCost inserts or replaces the gamete cost of a sex for a nutrient.
+func (r *ReproductionRepo) SetGameteCost(c *GameteCost) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		"INSERT OR REPLACE INTO gamete_costs (sex, nutrient_id, cost_formula) VALUES (?, ?, ?)",
+		c.Sex, c.NutrientID, c.CostFormula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("gamete cost set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListGameteCosts returns all gamete cost formulas.
+func (r *ReproductionRepo) ListGameteCosts() ([]GameteCost, error) {
+	rows, err := r.db.Conn.Query(
+		"SELECT id, sex, nutrient_id, cost_formula FROM gamete_costs ORDER BY sex, nutrient_id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("gamete costs list: %w", err)
+	}
+	defer rows.Close()
+
+	var costs []GameteCost
+	for rows.Next() {
+		var c GameteCost
+		if err := rows.Scan(&c.ID, &c.Sex, &c.NutrientID, &c.CostFormula); err != nil {
+			return nil, fmt.Errorf("gamete cost scan: %w", err)
+		}
+		costs = append(costs, c)
+	}
+	return costs, rows.Err()
+}
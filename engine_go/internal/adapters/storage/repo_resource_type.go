@@ -82,3 +82,36 @@ func (r *ResourceTypeRepo) Delete(id int64) error {
 	}
 	return nil
 }
+
+// SetFeedingGain inserts or replaces the feeding gain formula of a resource type.
+func (r *ResourceTypeRepo) SetFeedingGain(g *FeedingGain) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		"INSERT OR REPLACE INTO feeding_gains (resource_type_id, gain_formula) VALUES (?, ?)",
+		g.ResourceTypeID, g.GainFormula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("feeding gain set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListFeedingGains returns the feeding gain formulas of all resource types.
+func (r *ResourceTypeRepo) ListFeedingGains() ([]FeedingGain, error) {
+	rows, err := r.db.Conn.Query(
+		"SELECT id, resource_type_id, gain_formula FROM feeding_gains ORDER BY resource_type_id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("feeding gains list: %w", err)
+	}
+	defer rows.Close()
+
+	var gains []FeedingGain
+	for rows.Next() {
+		var g FeedingGain
+		if err := rows.Scan(&g.ID, &g.ResourceTypeID, &g.GainFormula); err != nil {
+			return nil, fmt.Errorf("feeding gain scan: %w", err)
+		}
+		gains = append(gains, g)
+	}
+	return gains, rows.Err()
+}
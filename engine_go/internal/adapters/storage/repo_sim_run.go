@@ -15,10 +15,11 @@ func NewSimRunRepo(db *DB) *SimRunRepo {
 	return &SimRunRepo{db: db}
 }
 
-// Create inserts a new simulation run and returns its ID.
-func (r *SimRunRepo) Create(environmentID int64) (int64, error) {
+// Create inserts a new simulation run started with the given seed and
+// returns its ID.
+func (r *SimRunRepo) Create(environmentID int64, seed uint64) (int64, error) {
 	res, err := r.db.Conn.Exec(
-		"INSERT INTO sim_runs (environment_id) VALUES (?)", environmentID,
+		"INSERT INTO sim_runs (environment_id, seed) VALUES (?, ?)", environmentID, int64(seed),
 	)
 	if err != nil {
 		return 0, fmt.Errorf("sim_run create: %w", err)
@@ -29,16 +30,18 @@ func (r *SimRunRepo) Create(environmentID int64) (int64, error) {
 // GetByID retrieves a simulation run by its ID.
 func (r *SimRunRepo) GetByID(id int64) (*SimRun, error) {
 	sr := &SimRun{}
+	var seed sql.NullInt64
 	err := r.db.Conn.QueryRow(
-		`SELECT id, environment_id, started_at, ended_at, total_ticks, status
+		`SELECT id, environment_id, started_at, ended_at, total_ticks, status, seed
 		 FROM sim_runs WHERE id = ?`, id,
-	).Scan(&sr.ID, &sr.EnvironmentID, &sr.StartedAt, &sr.EndedAt, &sr.TotalTicks, &sr.Status)
+	).Scan(&sr.ID, &sr.EnvironmentID, &sr.StartedAt, &sr.EndedAt, &sr.TotalTicks, &sr.Status, &seed)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("sim_run get: %w", err)
 	}
+	sr.Seed = uint64(seed.Int64)
 	return sr, nil
 }
 
@@ -57,7 +60,7 @@ func (r *SimRunRepo) Finish(id int64, totalTicks int, status string) error {
 // ListByEnvironment returns all simulation runs for an environment.
 func (r *SimRunRepo) ListByEnvironment(environmentID int64) ([]SimRun, error) {
 	rows, err := r.db.Conn.Query(
-		`SELECT id, environment_id, started_at, ended_at, total_ticks, status
+		`SELECT id, environment_id, started_at, ended_at, total_ticks, status, seed
 		 FROM sim_runs WHERE environment_id = ? ORDER BY id`, environmentID,
 	)
 	if err != nil {
@@ -68,9 +71,11 @@ func (r *SimRunRepo) ListByEnvironment(environmentID int64) ([]SimRun, error) {
 	var runs []SimRun
 	for rows.Next() {
 		var sr SimRun
-		if err := rows.Scan(&sr.ID, &sr.EnvironmentID, &sr.StartedAt, &sr.EndedAt, &sr.TotalTicks, &sr.Status); err != nil {
+		var seed sql.NullInt64
+		if err := rows.Scan(&sr.ID, &sr.EnvironmentID, &sr.StartedAt, &sr.EndedAt, &sr.TotalTicks, &sr.Status, &seed); err != nil {
 			return nil, fmt.Errorf("sim_run scan: %w", err)
 		}
+		sr.Seed = uint64(seed.Int64)
 		runs = append(runs, sr)
 	}
 	return runs, rows.Err()
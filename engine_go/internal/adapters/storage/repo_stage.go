@@ -98,3 +98,76 @@ func (r *StageRepo) Delete(id int64) error {
 	}
 	return nil
 }
+
+// SetNutrientRequirement inserts or replaces the requirement of a stage for a nutrient.
+func (r *StageRepo) SetNutrientRequirement(sr *StageNutrientRequirement) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		`INSERT OR REPLACE INTO stage_nutrient_requirements (stage_id, nutrient_id, requirement_formula, cost_formula)
+		 VALUES (?, ?, ?, ?)`,
+		sr.StageID, sr.NutrientID, sr.RequirementFormula, sr.CostFormula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("stage requirement set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListNutrientRequirements returns the nutrient requirements of all stages.
+func (r *StageRepo) ListNutrientRequirements() ([]StageNutrientRequirement, error) {
+	rows, err := r.db.Conn.Query(
+		`SELECT id, stage_id, nutrient_id, requirement_formula, cost_formula
+		 FROM stage_nutrient_requirements ORDER BY stage_id, nutrient_id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("stage requirements list: %w", err)
+	}
+	defer rows.Close()
+
+	var reqs []StageNutrientRequirement
+	for rows.Next() {
+		var sr StageNutrientRequirement
+		if err := rows.Scan(&sr.ID, &sr.StageID, &sr.NutrientID, &sr.RequirementFormula, &sr.CostFormula); err != nil {
+			return nil, fmt.Errorf("stage requirement scan: %w", err)
+		}
+		reqs = append(reqs, sr)
+	}
+	return reqs, rows.Err()
+}
+
+// SetTendency inserts or replaces the movement tendency of a stage for a direction (1-8).
+func (r *StageRepo) SetTendency(t *Tendency) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		"INSERT OR REPLACE INTO stage_tendencies (stage_id, direction, formula) VALUES (?, ?, ?)",
+		t.OwnerID, t.Direction, t.Formula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("stage tendency set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListTendencies returns the movement tendencies of all stages.
+func (r *StageRepo) ListTendencies() ([]Tendency, error) {
+	return listTendencies(r.db, "stage_tendencies", "stage_id")
+}
+
+// listTendencies reads a tendency table whose owner column is ownerCol.
+func listTendencies(db *DB, table, ownerCol string) ([]Tendency, error) {
+	rows, err := db.Conn.Query(
+		"SELECT id, " + ownerCol + ", direction, formula FROM " + table + " ORDER BY " + ownerCol + ", direction",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s list: %w", table, err)
+	}
+	defer rows.Close()
+
+	var tendencies []Tendency
+	for rows.Next() {
+		var t Tendency
+		if err := rows.Scan(&t.ID, &t.OwnerID, &t.Direction, &t.Formula); err != nil {
+			return nil, fmt.Errorf("%s scan: %w", table, err)
+		}
+		tendencies = append(tendencies, t)
+	}
+	return tendencies, rows.Err()
+}
@@ -113,3 +113,36 @@ func (r *SubstrateRepo) GetCompositions(mixedID int64) ([]SubstrateComposition,
 	}
 	return comps, rows.Err()
 }
+
+// SetVelocity inserts or replaces the velocity formula of a substrate.
+func (r *SubstrateRepo) SetVelocity(v *SubstrateVelocity) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		"INSERT OR REPLACE INTO substrate_velocities (substrate_id, velocity_formula) VALUES (?, ?)",
+		v.SubstrateID, v.VelocityFormula,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("substrate velocity set: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListVelocities returns the velocity formulas of all substrates.
+func (r *SubstrateRepo) ListVelocities() ([]SubstrateVelocity, error) {
+	rows, err := r.db.Conn.Query(
+		"SELECT id, substrate_id, velocity_formula FROM substrate_velocities ORDER BY substrate_id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("substrate velocities list: %w", err)
+	}
+	defer rows.Close()
+
+	var velocities []SubstrateVelocity
+	for rows.Next() {
+		var v SubstrateVelocity
+		if err := rows.Scan(&v.ID, &v.SubstrateID, &v.VelocityFormula); err != nil {
+			return nil, fmt.Errorf("substrate velocity scan: %w", err)
+		}
+		velocities = append(velocities, v)
+	}
+	return velocities, rows.Err()
+}
@@ -0,0 +1,55 @@
+package storage
+
+import "fmt"
+
+// TraitRepo provides CRUD operations for derived traits.
+type TraitRepo struct {
+	db *DB
+}
+
+// NewTraitRepo creates a new TraitRepo.
+func NewTraitRepo(db *DB) *TraitRepo {
+	return &TraitRepo{db: db}
+}
+
+// Create inserts a new trait and returns its ID.
+func (r *TraitRepo) Create(t *Trait) (int64, error) {
+	res, err := r.db.Conn.Exec(
+		"INSERT INTO traits (name, formula, sort_order) VALUES (?, ?, ?)",
+		t.Name, t.Formula, t.SortOrder,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("trait create: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// List returns all traits ordered by sort_order.
+func (r *TraitRepo) List() ([]Trait, error) {
+	rows, err := r.db.Conn.Query(
+		"SELECT id, name, formula, sort_order FROM traits ORDER BY sort_order, id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("trait list: %w", err)
+	}
+	defer rows.Close()
+
+	var traits []Trait
+	for rows.Next() {
+		var t Trait
+		if err := rows.Scan(&t.ID, &t.Name, &t.Formula, &t.SortOrder); err != nil {
+			return nil, fmt.Errorf("trait scan: %w", err)
+		}
+		traits = append(traits, t)
+	}
+	return traits, rows.Err()
+}
+
+// Delete removes a trait.
+func (r *TraitRepo) Delete(id int64) error {
+	_, err := r.db.Conn.Exec("DELETE FROM traits WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("trait delete: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 )
@@ -17,10 +18,29 @@ type TickCount struct {
 type SimEvent struct {
 	Tick      int
 	EventType string
+	AgentID   uint64 // Stable ID of the agent (0 = none).
 	AgentName string
 	Details   string
 }
 
+// LocusStat holds the population-genetics statistics of one locus over a
+// group of agents at a tick: the whole population (no prototype, no sex),
+// one sex, or the adults of one prototype.
+type LocusStat struct {
+	Tick             int
+	LocusID          int64
+	PrototypeID      *int64
+	Sex              string // "M", "F" or "" for both.
+	Individuals      int    // Agents carrying the locus.
+	Alleles          int    // Allele copies.
+	Mean             float64
+	Variance         float64
+	DominantFraction float64
+	ObservedHet      float64
+	ExpectedHet      float64
+	Frequencies      map[int32]float64 // Discrete loci: allele value → frequency; nil for continuous.
+}
+
 // WriteBuffer accumulates simulation results in memory and flushes them
 // to the database in batch transactions. This avoids per-tick I/O overhead
 // in the hot simulation loop.
@@ -30,9 +50,10 @@ type WriteBuffer struct {
 	runID      int64
 	tickCounts []TickCount
 	events     []SimEvent
+	locusStats []LocusStat
 	// Flush thresholds
-	maxRecords   int
-	tickInterval int
+	maxRecords    int
+	tickInterval  int
 	lastFlushTick int
 }
 
@@ -83,6 +104,20 @@ func (wb *WriteBuffer) AddTickCounts(tick int, counts []TickCount) error {
 	return nil
 }
 
+// AddLocusStats appends the locus statistics of a tick to the buffer.
+// It automatically flushes if thresholds are exceeded.
+func (wb *WriteBuffer) AddLocusStats(tick int, stats []LocusStat) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	wb.locusStats = append(wb.locusStats, stats...)
+
+	if wb.shouldFlush(tick) {
+		return wb.flushLocked()
+	}
+	return nil
+}
+
 // AddEvent appends a simulation event to the buffer.
 func (wb *WriteBuffer) AddEvent(event SimEvent) error {
 	wb.mu.Lock()
@@ -135,7 +170,7 @@ func (wb *WriteBuffer) shouldFlush(currentTick int) bool {
 }
 
 func (wb *WriteBuffer) totalRecords() int {
-	return len(wb.tickCounts) + len(wb.events)
+	return len(wb.tickCounts) + len(wb.events) + len(wb.locusStats)
 }
 
 func (wb *WriteBuffer) flushLocked() error {
@@ -171,7 +206,7 @@ func (wb *WriteBuffer) flushLocked() error {
 	// Flush events.
 	if len(wb.events) > 0 {
 		stmt, err := tx.Prepare(
-			"INSERT INTO sim_events (run_id, tick, event_type, agent_name, details) VALUES (?, ?, ?, ?, ?)",
+			"INSERT INTO sim_events (run_id, tick, event_type, agent_id, agent_name, details) VALUES (?, ?, ?, ?, ?, ?)",
 		)
 		if err != nil {
 			tx.Rollback()
@@ -179,7 +214,12 @@ func (wb *WriteBuffer) flushLocked() error {
 		}
 
 		for _, ev := range wb.events {
-			if _, err := stmt.Exec(wb.runID, ev.Tick, ev.EventType, ev.AgentName, ev.Details); err != nil {
+			var agentID *int64
+			if ev.AgentID != 0 {
+				id := int64(ev.AgentID)
+				agentID = &id
+			}
+			if _, err := stmt.Exec(wb.runID, ev.Tick, ev.EventType, agentID, ev.AgentName, ev.Details); err != nil {
 				stmt.Close()
 				tx.Rollback()
 				return fmt.Errorf("write_buffer: insert event: %w", err)
@@ -188,6 +228,40 @@ func (wb *WriteBuffer) flushLocked() error {
 		stmt.Close()
 	}
 
+	// Flush locus statistics.
+	if len(wb.locusStats) > 0 {
+		stmt, err := tx.Prepare(
+			`INSERT INTO sim_locus_stats (run_id, tick, locus_id, prototype_id, sex, individuals, alleles,
+			 mean, variance, dominant_fraction, observed_het, expected_het, allele_frequencies)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("write_buffer: prepare locus_stats: %w", err)
+		}
+
+		for _, ls := range wb.locusStats {
+			var freq *string
+			if ls.Frequencies != nil {
+				data, err := json.Marshal(ls.Frequencies)
+				if err != nil {
+					stmt.Close()
+					tx.Rollback()
+					return fmt.Errorf("write_buffer: encode allele frequencies: %w", err)
+				}
+				text := string(data)
+				freq = &text
+			}
+			if _, err := stmt.Exec(wb.runID, ls.Tick, ls.LocusID, ls.PrototypeID, ls.Sex, ls.Individuals, ls.Alleles,
+				ls.Mean, ls.Variance, ls.DominantFraction, ls.ObservedHet, ls.ExpectedHet, freq); err != nil {
+				stmt.Close()
+				tx.Rollback()
+				return fmt.Errorf("write_buffer: insert locus_stat: %w", err)
+			}
+		}
+		stmt.Close()
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("write_buffer: commit: %w", err)
 	}
@@ -203,6 +277,7 @@ func (wb *WriteBuffer) flushLocked() error {
 	// Reset buffers, keep allocated capacity.
 	wb.tickCounts = wb.tickCounts[:0]
 	wb.events = wb.events[:0]
+	wb.locusStats = wb.locusStats[:0]
 
 	return nil
 }
@@ -0,0 +1,691 @@
+package kernel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"galatea/engine/internal/adapters/storage"
+	"galatea/engine/internal/kernel/formulas"
+	"galatea/engine/internal/kernel/systems"
+	"galatea/engine/internal/kernel/util"
+	"galatea/engine/internal/kernel/world"
+)
+
+// Formula registry key scheme.
+//
+// Every formula stored in the project database is compiled during Build under
+// a key derived from its table and row. Entity indices are the dense 0-based
+// positions assigned by world.Index (sort order); ordinal columns that are
+// already numbers in the schema (direction, behavior, action) keep their
+// 1-based value. <p> is the unified perceiver index used by the perception
+// system: stages 0..S-1, then male prototypes S..S+M-1, then female
+// prototypes S+M..S+M+F-1.
+//
+//	stages                       stage.<p>.cycles
+//	                             stage.<p>.condition1
+//	                             stage.<p>.condition2
+//	stage_nutrient_requirements  stage.<p>.requirement.<n>
+//	                             stage.<p>.cost.<n>
+//	stage_tendencies             tendency.<p>.<dir>
+//	prototype_tendencies         tendency.<p>.<dir>
+//	prototypes                   prototype.<p>.longevity
+//	                             prototype.<p>.refractory_combat
+//	                             prototype.<p>.refractory_courtship
+//	                             prototype.<p>.sex_ratio_males
+//	                             prototype.<p>.sex_ratio_females
+//	prototype_morphology         morphology.<p>.<l>.genetic
+//	                             morphology.<p>.<l>.environmental
+//	prototype_combat             combat.<p>.<action>.<opponent_action>
+//	prototype_courtship          courtship.<p>.<action>.<opponent_action>
+//	prototype_assignment_criteria assignment.<p>.<priority>
+//	loci                         locus.<l>.default
+//	traits                       trait.<t>
+//	metabolism                   metabolism.<n>.min|critical|optimal|initial|max
+//	behavior_costs               cost.<b>.<n>
+//	feeding_gains                gain.<r>
+//	substrate_velocities         velocity.<s>
+//	reproduction                 reproduction.<field> (column name without _formula)
+//	gamete_costs                 gamete.<sex>.<n> (sex is M or F)
+//	interaction_substrates       interaction.substrate.<s>.<p>.<b>
+//	interaction_resources        interaction.resource.<r>.<p>.<b>
+//	interaction_agents           interaction.agent.<o>.<p>.<b>
+//	attractiveness_substrates    attractiveness.substrate.<s>.<p>, radius.substrate.<s>.<p>
+//	attractiveness_resources     attractiveness.resource.<r>.<p>, radius.resource.<r>.<p>
+//	attractiveness_agents        attractiveness.agent.<o>.<p>, radius.agent.<o>.<p>
+//	memory_influence             memory.<memory_type>.<element_index>.<p>
+//
+// <n>, <l>, <r>, <s> and <t> are nutrient, locus, resource type, substrate
+// and trait indices, <o> is the observed agent's perceiver index and <b> a
+// 1-based behavior number. vdecision.<p>.<b> keys are reserved for
+// per-perceiver base decision weights and have no backing table yet. Environment-scoped
+// matrices only compile the rows of the environment being built. Every
+// nutrient gets metabolism keys: nutrients without a metabolism row use the
+// table's column defaults.
+//
+// memory_influence.memory_type names a memory array: last_perceived,
+// num_perceived, last_interacted, num_interacted (slots: substrates, then
+// resource types, then perceivers), last_behavior or num_behavior (slots:
+// behaviors). <element_index> is the 1-based slot. A memory formula adds to
+// the decision weight of the behavior that acts on its slot: the behavior
+// itself, rest for substrates, feeding for resource types, and courtship
+// display for perceivers of the opposite sex or combat display otherwise.
+//
+// prototype_combat and prototype_courtship give the weight of each 1-based
+// action (combat: display, escalate, retreat; courtship: display, escalate,
+// accept, reject) against the opponent's last action: 0 before the opponent
+// has acted, 1 display, 2 escalate, 3 accept. Without a column 0 the opening
+// move answers a display.
+//
+// stages.condition1_op, stages.condition2_op and
+// prototype_assignment_criteria.operator are one of >, >=, <, <=, = (==) or
+// <> (!=); the stage logic columns are AND or OR. Assignment criteria are
+// tried in ascending priority over all prototypes of the agent's sex.
+//
+// Traits are indexed in sort order and exposed to every formula as
+// Trait<name> (see formulas.EnvBuilder.AddTrait). loci.expression is one of
+// dominant, additive, partial or overdominant.
+//
+// behavior_costs.behavior holds either a 1-based behavior number or a behavior
+// name: move, rest, feed, fight_display, fight_escalate, court_display,
+// court_escalate, oviposit, die. The group names feed, fight and court expand
+// to every behavior slot of the group.
+
+// formulaCompiler compiles table rows into a Registry and records the first error.
+type formulaCompiler struct {
+	reg *formulas.Registry
+	ix  *world.Index
+	cfg world.Config
+	err error
+}
+
+// compileFormulas reads every formula table of the project and compiles it
+// into a new Registry using the key scheme documented above.
+func compileFormulas(db *storage.DB, w *world.World, environmentID int64) (*formulas.Registry, error) {
+	c := &formulaCompiler{
+		reg: formulas.NewRegistry(),
+		ix:  w.Index,
+		cfg: w.Config,
+	}
+
+	steps := []func(*storage.DB, int64) error{
+		c.compileStages,
+		c.compilePrototypes,
+		c.compileLoci,
+		c.compileTraits,
+		c.compileMetabolism,
+		c.compileFeeding,
+		c.compileReproduction,
+		c.compilePerception,
+	}
+	for _, step := range steps {
+		if err := step(db, environmentID); err != nil {
+			return nil, err
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+	}
+	return c.reg, nil
+}
+
+// compile compiles one formula. A compile failure is wrapped with the table,
+// row and column it came from; the formula text and key come from the Registry.
+func (c *formulaCompiler) compile(table string, row int64, column, key, formula string) {
+	if c.err != nil {
+		return
+	}
+	if err := c.reg.Compile(key, formula); err != nil {
+		c.err = fmt.Errorf("table %s row %d column %s: %w", table, row, column, err)
+	}
+}
+
+// fail records a row that cannot be mapped to a registry key.
+func (c *formulaCompiler) fail(table string, row int64, format string, args ...any) {
+	if c.err == nil {
+		c.err = fmt.Errorf("table %s row %d: %s", table, row, fmt.Sprintf(format, args...))
+	}
+}
+
+func (c *formulaCompiler) compileStages(db *storage.DB, _ int64) error {
+	repo := storage.NewStageRepo(db)
+
+	stages, err := repo.List()
+	if err != nil {
+		return err
+	}
+	for _, s := range stages {
+		p, _ := c.ix.Stage(s.ID)
+		prefix := "stage." + util.Itoa(p) + "."
+		c.compile("stages", s.ID, "cycles_formula", prefix+"cycles", s.CyclesFormula)
+		c.compile("stages", s.ID, "condition1_formula", prefix+"condition1", s.Condition1Formula)
+		c.compile("stages", s.ID, "condition2_formula", prefix+"condition2", s.Condition2Formula)
+		for _, op := range []string{s.Condition1Op, s.Condition2Op} {
+			if _, err := systems.ParseComparison(op); err != nil {
+				c.fail("stages", s.ID, "%v", err)
+			}
+		}
+		for _, logic := range []string{s.LogicCyclesReqs, s.LogicReqsConds, s.LogicCond1Cond2} {
+			if _, ok := parseLogic(logic); !ok {
+				c.fail("stages", s.ID, "unknown logic %q", logic)
+			}
+		}
+		if id := s.LinkedPrototypeID; id != nil {
+			if _, _, ok := c.ix.Prototype(*id); !ok {
+				c.fail("stages", s.ID, "unknown linked prototype %d", *id)
+			}
+		}
+	}
+
+	reqs, err := repo.ListNutrientRequirements()
+	if err != nil {
+		return err
+	}
+	for _, r := range reqs {
+		p, ok := c.ix.Stage(r.StageID)
+		n, okN := c.ix.Nutrient(r.NutrientID)
+		if !ok || !okN {
+			c.fail("stage_nutrient_requirements", r.ID, "unknown stage %d or nutrient %d", r.StageID, r.NutrientID)
+			continue
+		}
+		prefix := "stage." + util.Itoa(p) + "."
+		c.compile("stage_nutrient_requirements", r.ID, "requirement_formula", prefix+"requirement."+util.Itoa(n), r.RequirementFormula)
+		c.compile("stage_nutrient_requirements", r.ID, "cost_formula", prefix+"cost."+util.Itoa(n), r.CostFormula)
+	}
+
+	tendencies, err := repo.ListTendencies()
+	if err != nil {
+		return err
+	}
+	for _, t := range tendencies {
+		p, ok := c.ix.Stage(t.OwnerID)
+		if !ok {
+			c.fail("stage_tendencies", t.ID, "unknown stage %d", t.OwnerID)
+			continue
+		}
+		c.compile("stage_tendencies", t.ID, "formula", "tendency."+util.Itoa(p)+"."+util.Itoa(t.Direction), t.Formula)
+	}
+	return nil
+}
+
+func (c *formulaCompiler) compilePrototypes(db *storage.DB, _ int64) error {
+	repo := storage.NewPrototypeRepo(db)
+
+	prototypes, err := repo.List("")
+	if err != nil {
+		return err
+	}
+	for _, pr := range prototypes {
+		p, _ := c.ix.Perceiver(nil, &pr.ID)
+		prefix := "prototype." + util.Itoa(p) + "."
+		c.compile("prototypes", pr.ID, "longevity_formula", prefix+"longevity", pr.LongevityFormula)
+		c.compile("prototypes", pr.ID, "refractory_combat_formula", prefix+"refractory_combat", pr.RefractoryCombatFormula)
+		c.compile("prototypes", pr.ID, "refractory_courtship_formula", prefix+"refractory_courtship", pr.RefractoryCourtshipFormula)
+		c.compile("prototypes", pr.ID, "sex_ratio_males_formula", prefix+"sex_ratio_males", pr.SexRatioMalesFormula)
+		c.compile("prototypes", pr.ID, "sex_ratio_females_formula", prefix+"sex_ratio_females", pr.SexRatioFemalesFormula)
+	}
+
+	tendencies, err := repo.ListTendencies()
+	if err != nil {
+		return err
+	}
+	for _, t := range tendencies {
+		p, ok := c.ix.Perceiver(nil, &t.OwnerID)
+		if !ok {
+			c.fail("prototype_tendencies", t.ID, "unknown prototype %d", t.OwnerID)
+			continue
+		}
+		c.compile("prototype_tendencies", t.ID, "formula", "tendency."+util.Itoa(p)+"."+util.Itoa(t.Direction), t.Formula)
+	}
+
+	morphology, err := repo.ListMorphology()
+	if err != nil {
+		return err
+	}
+	for _, m := range morphology {
+		p, ok := c.ix.Perceiver(nil, &m.PrototypeID)
+		l, okL := c.ix.Locus(m.LocusID)
+		if !ok || !okL {
+			c.fail("prototype_morphology", m.ID, "unknown prototype %d or locus %d", m.PrototypeID, m.LocusID)
+			continue
+		}
+		prefix := "morphology." + util.Itoa(p) + "." + util.Itoa(l) + "."
+		c.compile("prototype_morphology", m.ID, "genetic_formula", prefix+"genetic", m.GeneticFormula)
+		c.compile("prototype_morphology", m.ID, "environmental_formula", prefix+"environmental", m.EnvironmentalFormula)
+	}
+
+	combat, err := repo.ListCombat()
+	if err != nil {
+		return err
+	}
+	c.compileMatrix("prototype_combat", "combat", combat, combatActions, combatOpponentActions)
+
+	courtship, err := repo.ListCourtship()
+	if err != nil {
+		return err
+	}
+	c.compileMatrix("prototype_courtship", "courtship", courtship, courtshipActions, courtshipOpponentActions)
+
+	criteria, err := repo.ListAssignmentCriteria()
+	if err != nil {
+		return err
+	}
+	for _, cr := range criteria {
+		p, ok := c.ix.Perceiver(nil, &cr.PrototypeID)
+		if !ok {
+			c.fail("prototype_assignment_criteria", cr.ID, "unknown prototype %d", cr.PrototypeID)
+			continue
+		}
+		if _, err := systems.ParseComparison(cr.Operator); err != nil {
+			c.fail("prototype_assignment_criteria", cr.ID, "%v", err)
+			continue
+		}
+		c.compile("prototype_assignment_criteria", cr.ID, "formula", "assignment."+util.Itoa(p)+"."+util.Itoa(cr.Priority), cr.Formula)
+	}
+	return nil
+}
+
+func (c *formulaCompiler) compileMatrix(table, category string, cells []storage.PrototypeMatrixCell, numActions, numOpponentActions int) {
+	for _, m := range cells {
+		p, ok := c.ix.Perceiver(nil, &m.PrototypeID)
+		if !ok {
+			c.fail(table, m.ID, "unknown prototype %d", m.PrototypeID)
+			continue
+		}
+		if m.Action < 1 || m.Action > numActions || m.OpponentAction < 0 || m.OpponentAction >= numOpponentActions {
+			c.fail(table, m.ID, "action %d or opponent action %d out of range", m.Action, m.OpponentAction)
+			continue
+		}
+		key := category + "." + util.Itoa(p) + "." + util.Itoa(m.Action) + "." + util.Itoa(m.OpponentAction)
+		c.compile(table, m.ID, "formula", key, m.Formula)
+	}
+}
+
+func (c *formulaCompiler) compileLoci(db *storage.DB, _ int64) error {
+	loci, err := storage.NewLocusRepo(db).List()
+	if err != nil {
+		return err
+	}
+	repro, err := storage.NewReproductionRepo(db).Get()
+	if err != nil {
+		return err
+	}
+	// An unknown sex determination is reported with the reproduction table.
+	var mode systems.SexDetermination
+	var modeErr error
+	if repro != nil {
+		mode, modeErr = systems.ParseSexDetermination(repro.SexDetermination)
+	}
+	for _, l := range loci {
+		idx, _ := c.ix.Locus(l.ID)
+		c.compile("loci", l.ID, "default_expression", "locus."+util.Itoa(idx)+".default", l.DefaultExpression)
+		linkage, err := systems.ParseSexLinkage(l.SexLinkage)
+		if err != nil {
+			c.fail("loci", l.ID, "%v", err)
+		} else if modeErr == nil && !mode.Supports(linkage) {
+			c.fail("loci", l.ID, "%s-linked locus %q needs a matching sex determination", l.SexLinkage, l.Name)
+		}
+		if _, err := world.ParseExpressionModel(l.Expression); err != nil {
+			c.fail("loci", l.ID, "%v", err)
+		}
+	}
+	return nil
+}
+
+// compileTraits compiles the derived traits. Trait names become part of the
+// variable Trait<name>, so they must be identifiers.
+func (c *formulaCompiler) compileTraits(db *storage.DB, _ int64) error {
+	traits, err := storage.NewTraitRepo(db).List()
+	if err != nil {
+		return err
+	}
+	for t, tr := range traits {
+		if !isIdentifier(tr.Name) {
+			c.fail("traits", tr.ID, "trait name %q is not an identifier", tr.Name)
+			continue
+		}
+		c.compile("traits", tr.ID, "formula", "trait."+util.Itoa(t), tr.Formula)
+	}
+	return nil
+}
+
+// isIdentifier reports whether name is a letter or underscore followed by
+// letters, digits and underscores.
+func isIdentifier(name string) bool {
+	for i, r := range name {
+		letter := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !letter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func (c *formulaCompiler) compileMetabolism(db *storage.DB, _ int64) error {
+	repo := storage.NewMetabolismRepo(db)
+
+	metabolism, err := repo.List()
+	if err != nil {
+		return err
+	}
+	for _, m := range metabolism {
+		n, ok := c.ix.Nutrient(m.NutrientID)
+		if !ok {
+			c.fail("metabolism", m.ID, "unknown nutrient %d", m.NutrientID)
+			continue
+		}
+		c.compileNutrientMetabolism(n, m)
+	}
+
+	// Nutrients without a metabolism row use the schema defaults.
+	for n := range c.ix.NutrientIDs {
+		if c.reg.Get("metabolism."+util.Itoa(n)+".min") == nil {
+			c.compileNutrientMetabolism(n, defaultMetabolism)
+		}
+	}
+
+	costs, err := repo.ListBehaviorCosts()
+	if err != nil {
+		return err
+	}
+	for _, bc := range costs {
+		n, ok := c.ix.Nutrient(bc.NutrientID)
+		if !ok {
+			c.fail("behavior_costs", bc.ID, "unknown nutrient %d", bc.NutrientID)
+			continue
+		}
+		behaviors := behaviorSlots(bc.Behavior, c.cfg)
+		if behaviors == nil {
+			c.fail("behavior_costs", bc.ID, "unknown behavior %q", bc.Behavior)
+			continue
+		}
+		for _, b := range behaviors {
+			c.compile("behavior_costs", bc.ID, "cost_formula", "cost."+util.Itoa(b+1)+"."+util.Itoa(n), bc.CostFormula)
+		}
+	}
+	return nil
+}
+
+// defaultMetabolism mirrors the column defaults of the metabolism table.
+var defaultMetabolism = storage.Metabolism{
+	MinFormula:      "0",
+	CriticalFormula: "10",
+	OptimalFormula:  "50",
+	InitialFormula:  "50",
+	MaxFormula:      "100",
+}
+
+func (c *formulaCompiler) compileNutrientMetabolism(n int, m storage.Metabolism) {
+	prefix := "metabolism." + util.Itoa(n) + "."
+	c.compile("metabolism", m.ID, "min_formula", prefix+"min", m.MinFormula)
+	c.compile("metabolism", m.ID, "critical_formula", prefix+"critical", m.CriticalFormula)
+	c.compile("metabolism", m.ID, "optimal_formula", prefix+"optimal", m.OptimalFormula)
+	c.compile("metabolism", m.ID, "initial_formula", prefix+"initial", m.InitialFormula)
+	c.compile("metabolism", m.ID, "max_formula", prefix+"max", m.MaxFormula)
+}
+
+func (c *formulaCompiler) compileFeeding(db *storage.DB, _ int64) error {
+	gains, err := storage.NewResourceTypeRepo(db).ListFeedingGains()
+	if err != nil {
+		return err
+	}
+	for _, g := range gains {
+		r, ok := c.ix.ResourceType(g.ResourceTypeID)
+		if !ok {
+			c.fail("feeding_gains", g.ID, "unknown resource type %d", g.ResourceTypeID)
+			continue
+		}
+		c.compile("feeding_gains", g.ID, "gain_formula", "gain."+util.Itoa(r), g.GainFormula)
+	}
+
+	velocities, err := storage.NewSubstrateRepo(db).ListVelocities()
+	if err != nil {
+		return err
+	}
+	for _, v := range velocities {
+		s, ok := c.ix.Substrate(v.SubstrateID)
+		if !ok {
+			c.fail("substrate_velocities", v.ID, "unknown substrate %d", v.SubstrateID)
+			continue
+		}
+		c.compile("substrate_velocities", v.ID, "velocity_formula", "velocity."+util.Itoa(s), v.VelocityFormula)
+	}
+	return nil
+}
+
+func (c *formulaCompiler) compileReproduction(db *storage.DB, _ int64) error {
+	repo := storage.NewReproductionRepo(db)
+
+	repro, err := repo.Get()
+	if err != nil {
+		return err
+	}
+	if repro != nil {
+		fields := []struct{ column, formula string }{
+			{"max_eggs_formula", repro.MaxEggsFormula},
+			{"max_sperm_packs_formula", repro.MaxSpermPacksFormula},
+			{"packs_transferred_formula", repro.PacksTransferredFormula},
+			{"fraction_fertilized_formula", repro.FractionFertilizedFormula},
+			{"paternity_formula", repro.PaternityFormula},
+			{"max_stored_packs_formula", repro.MaxStoredPacksFormula},
+			{"consumption_rate_formula", repro.ConsumptionRateFormula},
+			{"eggs_per_cycle_formula", repro.EggsPerCycleFormula},
+			{"egg_fraction_formula", repro.EggFractionFormula},
+			{"pack_fraction_formula", repro.PackFractionFormula},
+			{"sperm_degradation_formula", repro.SpermDegradationFormula},
+			{"egg_mortality_formula", repro.EggMortalityFormula},
+			{"sex_determination_formula", repro.SexDeterminationFormula},
+		}
+		for _, f := range fields {
+			key := "reproduction." + strings.TrimSuffix(f.column, "_formula")
+			c.compile("reproduction", 1, f.column, key, f.formula)
+		}
+		if _, err := systems.ParseSpermUse(repro.SpermUseMode); err != nil {
+			c.fail("reproduction", 1, "%v", err)
+		}
+		if _, err := world.ParseNamePattern(repro.OffspringNamePattern); err != nil {
+			c.fail("reproduction", 1, "%v", err)
+		}
+		if _, err := systems.ParseSexDetermination(repro.SexDetermination); err != nil {
+			c.fail("reproduction", 1, "%v", err)
+		}
+	}
+
+	costs, err := repo.ListGameteCosts()
+	if err != nil {
+		return err
+	}
+	for _, gc := range costs {
+		n, ok := c.ix.Nutrient(gc.NutrientID)
+		if !ok {
+			c.fail("gamete_costs", gc.ID, "unknown nutrient %d", gc.NutrientID)
+			continue
+		}
+		c.compile("gamete_costs", gc.ID, "cost_formula", "gamete."+gc.Sex+"."+util.Itoa(n), gc.CostFormula)
+	}
+	return nil
+}
+
+func (c *formulaCompiler) compilePerception(db *storage.DB, environmentID int64) error {
+	repo := storage.NewPerceptionRepo(db)
+
+	subInt, err := repo.ListSubstrateInteractions(environmentID)
+	if err != nil {
+		return err
+	}
+	c.compileInteractions("interaction_substrates", "substrate", subInt)
+
+	resInt, err := repo.ListResourceInteractions(environmentID)
+	if err != nil {
+		return err
+	}
+	c.compileInteractions("interaction_resources", "resource", resInt)
+
+	agentInt, err := repo.ListAgentInteractions(environmentID)
+	if err != nil {
+		return err
+	}
+	c.compileInteractions("interaction_agents", "agent", agentInt)
+
+	subAttr, err := repo.ListSubstrateAttractiveness(environmentID)
+	if err != nil {
+		return err
+	}
+	c.compileAttractiveness("attractiveness_substrates", "substrate", subAttr)
+
+	resAttr, err := repo.ListResourceAttractiveness(environmentID)
+	if err != nil {
+		return err
+	}
+	c.compileAttractiveness("attractiveness_resources", "resource", resAttr)
+
+	agentAttr, err := repo.ListAgentAttractiveness(environmentID)
+	if err != nil {
+		return err
+	}
+	c.compileAttractiveness("attractiveness_agents", "agent", agentAttr)
+
+	memory, err := repo.ListMemoryInfluence(environmentID)
+	if err != nil {
+		return err
+	}
+	for _, m := range memory {
+		p, ok := c.ix.Perceiver(m.PerceiverStageID, m.PerceiverPrototypeID)
+		if !ok {
+			c.fail("memory_influence", m.ID, "unknown perceiver")
+			continue
+		}
+		memoryType := strings.ToLower(m.MemoryType)
+		if slots := memorySlotCount(memoryType, c.cfg); m.ElementIndex < 1 || m.ElementIndex > slots {
+			c.fail("memory_influence", m.ID, "unknown memory %q slot %d", m.MemoryType, m.ElementIndex)
+			continue
+		}
+		key := "memory." + memoryType + "." + util.Itoa(m.ElementIndex) + "." + util.Itoa(p)
+		c.compile("memory_influence", m.ID, "formula", key, m.Formula)
+	}
+	return nil
+}
+
+// target resolves the observed element of a perception matrix row to its
+// index within kind (substrate, resource or agent).
+func (c *formulaCompiler) target(kind string, targetID int64, observedStageID, observedPrototypeID *int64) (int, bool) {
+	switch kind {
+	case "substrate":
+		return c.ix.Substrate(targetID)
+	case "resource":
+		return c.ix.ResourceType(targetID)
+	default:
+		return c.ix.Perceiver(observedStageID, observedPrototypeID)
+	}
+}
+
+func (c *formulaCompiler) compileInteractions(table, kind string, rows []storage.Interaction) {
+	for _, r := range rows {
+		t, ok := c.target(kind, r.TargetID, r.ObservedStageID, r.ObservedPrototypeID)
+		p, okP := c.ix.Perceiver(r.PerceiverStageID, r.PerceiverPrototypeID)
+		if !ok || !okP {
+			c.fail(table, r.ID, "unknown %s or perceiver", kind)
+			continue
+		}
+		key := "interaction." + kind + "." + util.Itoa(t) + "." + util.Itoa(p) + "." + util.Itoa(r.BehaviorIndex)
+		c.compile(table, r.ID, "formula", key, r.Formula)
+	}
+}
+
+func (c *formulaCompiler) compileAttractiveness(table, kind string, rows []storage.Attractiveness) {
+	for _, r := range rows {
+		t, ok := c.target(kind, r.TargetID, r.ObservedStageID, r.ObservedPrototypeID)
+		p, okP := c.ix.Perceiver(r.PerceiverStageID, r.PerceiverPrototypeID)
+		if !ok || !okP {
+			c.fail(table, r.ID, "unknown %s or perceiver", kind)
+			continue
+		}
+		suffix := kind + "." + util.Itoa(t) + "." + util.Itoa(p)
+		c.compile(table, r.ID, "attractiveness_formula", "attractiveness."+suffix, r.AttractivenessFormula)
+		c.compile(table, r.ID, "radius_formula", "radius."+suffix, r.RadiusFormula)
+	}
+}
+
+// Payoff matrix dimensions. Combat actions are display, escalate and retreat;
+// courtship actions are display, escalate, accept and reject. The opponent
+// actions are the codes stored in LastOpponentAction: 0 before the opponent
+// has acted, then display, escalate and (courtship only) accept.
+const (
+	combatActions            = 3
+	combatOpponentActions    = 3
+	courtshipActions         = 4
+	courtshipOpponentActions = 4
+)
+
+// parseLogic parses a stage logic column: AND (true) or OR (false).
+func parseLogic(logic string) (and, ok bool) {
+	switch strings.ToUpper(logic) {
+	case "AND":
+		return true, true
+	case "OR":
+		return false, true
+	}
+	return false, false
+}
+
+// memoryTypes lists the memory_influence.memory_type values, one per memory array.
+var memoryTypes = []string{
+	"last_perceived", "num_perceived", "last_interacted", "num_interacted",
+	"last_behavior", "num_behavior",
+}
+
+// memorySlotCount returns the number of slots of a memory array, or 0 for an
+// unknown memory type.
+func memorySlotCount(memoryType string, cfg world.Config) int {
+	switch memoryType {
+	case "last_perceived", "num_perceived", "last_interacted", "num_interacted":
+		return cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
+	case "last_behavior", "num_behavior":
+		return cfg.NumBehaviors
+	}
+	return 0
+}
+
+// behaviorSlots resolves a behavior_costs.behavior value to 0-based behavior
+// indices. It returns nil for unknown names or out-of-range numbers.
+func behaviorSlots(behavior string, cfg world.Config) []int {
+	feed := 2
+	fight := feed + cfg.NumResourceTypes
+	court := fight + 2
+	oviposit := court + 2
+
+	name := strings.ToLower(strings.TrimSpace(behavior))
+	switch name {
+	case "move":
+		return []int{0}
+	case "rest":
+		return []int{1}
+	case "feed":
+		slots := make([]int, cfg.NumResourceTypes)
+		for r := range slots {
+			slots[r] = feed + r
+		}
+		return slots
+	case "fight":
+		return []int{fight, fight + 1}
+	case "fight_display":
+		return []int{fight}
+	case "fight_escalate":
+		return []int{fight + 1}
+	case "court":
+		return []int{court, court + 1}
+	case "court_display":
+		return []int{court}
+	case "court_escalate":
+		return []int{court + 1}
+	case "oviposit":
+		return []int{oviposit}
+	case "die":
+		return []int{oviposit + 1}
+	}
+
+	if n, err := strconv.Atoi(name); err == nil && n >= 1 && n <= cfg.NumBehaviors {
+		return []int{n - 1}
+	}
+	return nil
+}
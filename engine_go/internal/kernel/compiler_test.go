@@ -0,0 +1,211 @@
+package kernel
+
+import (
+	"strings"
+	"testing"
+
+	"galatea/engine/internal/adapters/storage"
+)
+
+func TestBuildCompilesFormulas(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// FemaleA (ID 2) is the first female: perceiver index = 1 stage + 1 male + 0.
+	storage.NewPrototypeRepo(db).SetTendency(&storage.Tendency{OwnerID: 2, Direction: 4, Formula: "Age + 1"})
+	storage.NewStageRepo(db).SetNutrientRequirement(&storage.StageNutrientRequirement{
+		StageID: 1, NutrientID: 2, RequirementFormula: "10", CostFormula: "3"})
+	storage.NewMetabolismRepo(db).SetBehaviorCost(&storage.BehaviorCost{Behavior: "feed", NutrientID: 1, CostFormula: "2"})
+	storage.NewResourceTypeRepo(db).SetFeedingGain(&storage.FeedingGain{ResourceTypeID: 2, GainFormula: "DynamicElementQuality"})
+	storage.NewPerceptionRepo(db).AddResourceAttractiveness(&storage.Attractiveness{
+		EnvironmentID: 1, TargetID: 1, PerceiverPrototypeID: ptr(1),
+		AttractivenessFormula: "10", RadiusFormula: "15"})
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	keys := []string{
+		"stage.0.cycles",
+		"stage.0.requirement.1",
+		"stage.0.cost.1",
+		"prototype.1.longevity",
+		"prototype.2.longevity",
+		"tendency.2.4",
+		"locus.1.default",
+		"cost.3.0", // feed expands to both resource types (behaviors 3 and 4).
+		"cost.4.0",
+		"gain.1",
+		"attractiveness.resource.0.1",
+		"radius.resource.0.1",
+	}
+	for _, k := range keys {
+		if engine.Registry.Get(k) == nil {
+			t.Errorf("expected formula %q to be compiled", k)
+		}
+	}
+
+	if src := engine.Registry.Get("prototype.2.longevity").Source; src != "600" {
+		t.Fatalf("expected FemaleA longevity 600, got %q", src)
+	}
+}
+
+func TestBuildFailsOnBadFormula(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	storage.NewStageRepo(db).SetTendency(&storage.Tendency{OwnerID: 1, Direction: 2, Formula: "Age +"})
+
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil {
+		t.Fatal("expected Build to fail on an invalid formula")
+	}
+	for _, want := range []string{"stage_tendencies", "row 1", `"Age +"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	// No run may be created for a project that does not compile.
+	runs, _ := storage.NewSimRunRepo(db).ListByEnvironment(1)
+	if len(runs) != 0 {
+		t.Fatalf("expected no sim runs, got %d", len(runs))
+	}
+}
+
+func TestBuildRejectsUnknownBehaviorCost(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	storage.NewMetabolismRepo(db).SetBehaviorCost(&storage.BehaviorCost{Behavior: "sing", NutrientID: 1, CostFormula: "1"})
+
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), `unknown behavior "sing"`) {
+		t.Fatalf("expected unknown behavior error, got %v", err)
+	}
+}
+
+func TestBuildRejectsUnknownMemorySlot(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// 3 substrates + 2 resource types + 3 perceivers = 8 perception slots.
+	storage.NewPerceptionRepo(db).AddMemoryInfluence(&storage.MemoryInfluence{
+		EnvironmentID: 1, MemoryType: "Last_Perceived", ElementIndex: 9,
+		PerceiverStageID: ptr(1), Formula: "1"})
+
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), `unknown memory "Last_Perceived" slot 9`) {
+		t.Fatalf("expected unknown memory slot error, got %v", err)
+	}
+}
+
+func TestBuildRejectsCourtshipActionOutOfRange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Courtship actions are 1..4; accept (3) is the last opponent action.
+	storage.NewPrototypeRepo(db).SetCourtship(&storage.PrototypeMatrixCell{
+		PrototypeID: 1, Action: 5, OpponentAction: 3, Formula: "1"})
+
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), "action 5 or opponent action 3 out of range") {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+}
+
+func TestBuildRejectsUnknownAssignmentOperator(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	storage.NewPrototypeRepo(db).SetAssignmentCriterion(&storage.PrototypeAssignmentCriterion{
+		PrototypeID: 1, Priority: 1, Formula: "Age", Operator: "=>", Threshold: 10})
+
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), `unknown operator "=>"`) {
+		t.Fatalf("expected unknown operator error, got %v", err)
+	}
+}
+
+func TestBuildRejectsUnknownStageLogic(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	storage.NewStageRepo(db).Create(&storage.Stage{
+		Name: "Pupa", SortOrder: 2, CyclesFormula: "10",
+		Condition1Formula: "0", Condition1Op: ">", Condition2Formula: "0", Condition2Op: ">",
+		LogicCyclesReqs: "AND", LogicReqsConds: "XOR", LogicCond1Cond2: "AND",
+	})
+
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), `unknown logic "XOR"`) {
+		t.Fatalf("expected unknown logic error, got %v", err)
+	}
+}
+
+func ptr(v int64) *int64 { return &v }
+
+func TestBuildRejectsUnknownSpermUseMode(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	storage.NewReproductionRepo(db).Set(&storage.Reproduction{SpermUseMode: "second_male"})
+
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), `unknown sperm use mode "second_male"`) {
+		t.Fatalf("expected unknown sperm use mode error, got %v", err)
+	}
+}
+
+func TestBuildChecksSexLinkageAgainstSexDetermination(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	db.Conn.Exec("UPDATE loci SET sex_linkage = 'Z' WHERE name = 'Speed'")
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), `Z-linked locus "Speed" needs a matching sex determination`) {
+		t.Fatalf("expected a sex linkage error, got %v", err)
+	}
+
+	storage.NewReproductionRepo(db).Set(&storage.Reproduction{SexDetermination: "zw"})
+	if _, err := Build(db, DefaultEngineConfig(1)); err != nil {
+		t.Fatalf("Build with ZW sex determination: %v", err)
+	}
+
+	storage.NewReproductionRepo(db).Set(&storage.Reproduction{SexDetermination: "temperature"})
+	_, err = Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), `unknown sex determination "temperature"`) {
+		t.Fatalf("expected unknown sex determination error, got %v", err)
+	}
+}
+
+func TestBuildRejectsUnknownNamePlaceholder(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	storage.NewReproductionRepo(db).Set(&storage.Reproduction{OffspringNamePattern: "{mother}-{clutch}"})
+
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), "unknown placeholder {clutch}") {
+		t.Fatalf("expected unknown placeholder error, got %v", err)
+	}
+}
+
+func TestBuildChecksExpressionAndTraits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	db.Conn.Exec("UPDATE loci SET expression = 'epistatic' WHERE name = 'Speed'")
+	_, err := Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), `unknown expression model "epistatic"`) {
+		t.Fatalf("expected an unknown expression model error, got %v", err)
+	}
+	db.Conn.Exec("UPDATE loci SET expression = 'overdominant'")
+
+	storage.NewTraitRepo(db).Create(&storage.Trait{Name: "Body size", Formula: "CL1"})
+	_, err = Build(db, DefaultEngineConfig(1))
+	if err == nil || !strings.Contains(err.Error(), `trait name "Body size" is not an identifier`) {
+		t.Fatalf("expected a trait name error, got %v", err)
+	}
+}
@@ -4,12 +4,15 @@ package kernel
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand/v2"
+	"strconv"
 
 	"galatea/engine/internal/adapters/storage"
 	"galatea/engine/internal/kernel/formulas"
 	"galatea/engine/internal/kernel/spatial"
 	"galatea/engine/internal/kernel/systems"
+	"galatea/engine/internal/kernel/util"
 	"galatea/engine/internal/kernel/world"
 )
 
@@ -17,9 +20,9 @@ import (
 // builds the execution pipeline during the Cold Path, and runs the tick loop
 // during the Hot Path.
 type Engine struct {
-	World    *world.World
-	DB       *storage.DB
-	RunID    int64
+	World *world.World
+	DB    *storage.DB
+	RunID int64
 
 	// Grids for spatial queries.
 	AgentGrid    *spatial.Grid
@@ -31,14 +34,26 @@ type Engine struct {
 	EnvBuilder *formulas.EnvBuilder
 
 	// Configuration for sub-systems.
-	OntogenyCfg  systems.OntogenyConfig
-	GeneticsCfg  systems.GeneticsConfig
-	ReproCfg     systems.ReproductionConfig
-	BehaviorCosts []int32 // Flat: [behavior * numNutrients + nutrient] = cost.
-	OptimalLevels []int32 // Per nutrient: level needed for reproduction.
-	Longevity     int32   // Default adult longevity (ticks).
-	CombatTimeout int32   // Max ticks in combat before timeout.
-	CourtTimeout  int32   // Max ticks in courtship before timeout.
+	OntogenyCfg   systems.OntogenyConfig
+	GeneticsCfg   systems.GeneticsConfig
+	ReproCfg      systems.ReproductionConfig
+	Metabolism    systems.MetabolismConfig
+	BehaviorCosts systems.BehaviorCostConfig
+	FeedingGains  []*formulas.Program         // Per resource type; nil = Speed units.
+	Velocities    []*formulas.Program         // Per substrate; nil = keep Speed.
+	Substrates    *systems.PerceptionMatrix   // Nil when no substrate is perceived.
+	Resources     *systems.PerceptionMatrix   // Nil when no resource is perceived.
+	Agents        *systems.PerceptionMatrix   // Nil when no agent is perceived.
+	Memory        [][]systems.MemoryInfluence // Per perceiver; nil when none.
+	Combat        *systems.PayoffMatrix       // Nil when no combat payoff is defined.
+	Courtship     *systems.PayoffMatrix       // Nil when no courtship payoff is defined.
+	Refractory    systems.RefractoryConfig
+	Longevity     int32 // Default adult longevity (ticks) for prototypes without a formula.
+	CombatTimeout int32 // Max ticks in combat before timeout.
+	CourtTimeout  int32 // Max ticks in courtship before timeout.
+
+	// Ticks between the recorded locus statistics (0 = none).
+	LocusStatsInterval int
 
 	// Write buffer for simulation results.
 	WriteBuffer *storage.WriteBuffer
@@ -50,49 +65,87 @@ type Engine struct {
 	OnTick func(tick int64)
 }
 
+// defaultCellSize is the spatial grid cell size used when no perception
+// radius is a constant.
+const defaultCellSize = 15.0
+
 // EngineConfig holds parameters for building an engine.
 type EngineConfig struct {
-	EnvironmentID  int64
-	CellSize       float64 // Spatial grid cell size (default: 15).
-	Longevity      int32   // Default longevity if not formula-driven.
-	CombatTimeout  int32   // Default: 20.
-	CourtTimeout   int32   // Default: 30.
+	EnvironmentID int64
+	CellSize      float64 // Spatial grid cell size (0 = largest perception radius).
+	Longevity     int32   // Default longevity if not formula-driven.
+	CombatTimeout int32   // Default: 20.
+	CourtTimeout  int32   // Default: 30.
+
+	// Prototypes assigned when no assignment criterion holds
+	// (0 = first prototype of the sex).
+	FallbackPrototypeM int64
+	FallbackPrototypeF int64
+
+	// Seed of the run's random number streams (0 = draw a fresh seed). The
+	// seed is stored on the run; the same seed and project reproduce it.
+	Seed uint64
+
+	// Ticks between population-genetics statistics of the loci (0 = none).
+	LocusStatsInterval int
+
 	WriteBufferCfg storage.WriteBufferConfig
 }
 
 // DefaultEngineConfig returns sensible defaults.
 func DefaultEngineConfig(environmentID int64) EngineConfig {
 	return EngineConfig{
-		EnvironmentID:  environmentID,
-		CellSize:       15.0,
-		Longevity:      1000,
-		CombatTimeout:  20,
-		CourtTimeout:   30,
-		WriteBufferCfg: storage.DefaultWriteBufferConfig(),
+		EnvironmentID:      environmentID,
+		Longevity:          1000,
+		CombatTimeout:      20,
+		CourtTimeout:       30,
+		LocusStatsInterval: 100,
+		WriteBufferCfg:     storage.DefaultWriteBufferConfig(),
 	}
 }
 
 // Build constructs the engine from a database (Cold Path).
 // It loads the world, compiles formulas, builds spatial grids, and prepares all configs.
 func Build(db *storage.DB, cfg EngineConfig) (*Engine, error) {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+
 	// Load world from DB.
-	w, err := world.Load(db, cfg.EnvironmentID)
+	w, err := world.Load(db, cfg.EnvironmentID, seed)
 	if err != nil {
 		return nil, fmt.Errorf("engine build: load world: %w", err)
 	}
 
+	// Compile every project formula before anything is written for this run.
+	registry, err := compileFormulas(db, w, cfg.EnvironmentID)
+	if err != nil {
+		return nil, fmt.Errorf("engine build: compile formulas: %w", err)
+	}
+	registry.SetRand(w.Rand.Formulas)
+
 	// Create simulation run record.
 	runRepo := storage.NewSimRunRepo(db)
-	runID, err := runRepo.Create(cfg.EnvironmentID)
+	runID, err := runRepo.Create(cfg.EnvironmentID, seed)
 	if err != nil {
 		return nil, fmt.Errorf("engine build: create run: %w", err)
 	}
 
-	// Build spatial grids.
+	// Perception matrices. The grid cells match the largest constant radius
+	// so that a perception query spans at most 3x3 cells.
+	substrates := buildPerceptionMatrix(registry, "substrate", w.Config.NumSubstrates, w.Config)
+	resources := buildPerceptionMatrix(registry, "resource", w.Config.NumResourceTypes, w.Config)
+	agents := buildPerceptionMatrix(registry, "agent", w.Config.NumPrototypes, w.Config)
 	cellSize := cfg.CellSize
 	if cellSize <= 0 {
-		cellSize = 15.0
+		cellSize = maxConstantRadius(resources, agents)
+	}
+	if cellSize <= 0 {
+		cellSize = defaultCellSize
 	}
+
+	// Build spatial grids.
 	agentGrid := spatial.NewGrid(cellSize, w.Agents.Cap)
 	resourceGrid := spatial.NewGrid(cellSize, w.Resources.Cap)
 
@@ -104,48 +157,58 @@ func Build(db *storage.DB, cfg EngineConfig) (*Engine, error) {
 		resourceGrid.Insert(int32(i), w.Resources.PosX[i], w.Resources.PosY[i])
 	}
 
-	// Formula registry (compile formulas from DB in future; empty for now).
-	registry := formulas.NewRegistry()
+	// Formula evaluation.
 	eval := formulas.NewEvaluator(128)
 	envBuilder := formulas.NewEnvBuilder(eval, w.Config)
 
-	// Build write buffer.
-	wb := storage.NewWriteBuffer(db, runID, cfg.WriteBufferCfg)
+	// Sperm use, offspring names and sex determination of the project
+	// (validated on compile).
+	repro, err := storage.NewReproductionRepo(db).Get()
+	if err != nil {
+		return nil, fmt.Errorf("engine build: %w", err)
+	}
+	if repro == nil {
+		repro = &storage.Reproduction{}
+	}
 
-	// Build perception radii (default: all elements perceive at cellSize).
-	numProtos := w.Config.NumPrototypes
-	numResTypes := w.Config.NumResourceTypes
-	resourceRadii := make([]float64, numResTypes*numProtos)
-	resourceAttr := make([]int32, numResTypes*numProtos)
-	for i := range resourceRadii {
-		resourceRadii[i] = cellSize
-		resourceAttr[i] = 10
+	// Genetics config (defaults: no mutation). Founders are seeded as if
+	// every locus were autosomal, so their sex-linked loci are fixed first.
+	genCfg, err := buildGenetics(db, w, repro)
+	if err != nil {
+		return nil, fmt.Errorf("engine build: %w", err)
 	}
-	agentRadii := make([]float64, numProtos*numProtos)
-	for i := range agentRadii {
-		agentRadii[i] = cellSize
+	for i := 0; i < w.Agents.Count; i++ {
+		systems.FixSexLinkedGenotype(w, i, &genCfg)
 	}
 
-	// Build default behavior costs (1 per nutrient per non-rest behavior).
-	numBeh := w.Config.NumBehaviors
-	numNut := w.Config.NumNutrients
-	behaviorCosts := make([]int32, numBeh*numNut)
-	for b := 0; b < numBeh; b++ {
-		if b == 1 { // Rest has no cost.
-			continue
-		}
-		for n := 0; n < numNut; n++ {
-			behaviorCosts[b*numNut+n] = 1
-		}
+	// Expression models and derived traits of the formula variables.
+	envBuilder.SetExpression(genCfg.Expression)
+	traits, err := storage.NewTraitRepo(db).List()
+	if err != nil {
+		return nil, fmt.Errorf("engine build: %w", err)
+	}
+	for t, tr := range traits {
+		envBuilder.AddTrait(tr.Name, registry.Get("trait."+util.Itoa(t)))
 	}
 
-	// Default optimal levels for reproduction.
-	optimalLevels := make([]int32, numNut)
-	for n := range optimalLevels {
-		optimalLevels[n] = 50
+	// Build write buffer.
+	wb := storage.NewWriteBuffer(db, runID, cfg.WriteBufferCfg)
+
+	// Behavior costs: behavior_costs formulas over a default of 1 per
+	// nutrient for every non-rest behavior.
+	numNut := w.Config.NumNutrients
+	behaviorCosts := buildBehaviorCosts(registry, w.Config.NumBehaviors, numNut)
+
+	// Metabolism: reference levels and initial reserves of the founders.
+	metabolism := buildMetabolism(registry, numNut)
+	envBuilder.SetWorldVars(w)
+	for i := 0; i < w.Agents.Count; i++ {
+		envBuilder.SetAgentVars(w, i)
+		systems.EvaluateReferenceLevels(w, i, eval, &metabolism)
+		systems.InitReserves(w, i, eval, &metabolism)
 	}
 
-	// Default reproduction config.
+	// Default reproduction config, overridden by the project's formulas.
 	gameteCosts := make([]int32, numNut)
 	for n := range gameteCosts {
 		gameteCosts[n] = 5
@@ -154,7 +217,7 @@ func Build(db *storage.DB, cfg EngineConfig) (*Engine, error) {
 		MaxGametes:         10,
 		GameteCosts:        gameteCosts,
 		PacksTransferred:   2,
-		MaxStoredPacks:     5,
+		MaxStoredPacks:     int32(w.Config.MaxStoredPacks),
 		FractionFertilized: 0.5,
 		PackFraction:       0.5,
 		EggFraction:        0.1,
@@ -164,49 +227,84 @@ func Build(db *storage.DB, cfg EngineConfig) (*Engine, error) {
 		SpermDegradation:   0.05,
 		MaleRatio:          50,
 		FemaleRatio:        50,
+		NumPrototypesM:     w.Config.NumPrototypesM,
+		SexRatioMales:      buildPrototypeFormulas(registry, "sex_ratio_males", w.Config),
+		SexRatioFemales:    buildPrototypeFormulas(registry, "sex_ratio_females", w.Config),
+		Formulas:           buildReproductionFormulas(registry, numNut),
 	}
 
 	// Default ontogeny config (minimal: 1 stage then adult).
 	ontCfg := systems.OntogenyConfig{
-		NumStages:            w.Config.NumStages,
-		NumPrototypesM:       w.Config.NumPrototypesM,
-		NumPrototypesF:       w.Config.NumPrototypesF,
-		Stages:               buildDefaultStages(w.Config.NumStages, numNut),
-		AssignmentPriorityM:  buildPriorityList(w.Config.NumPrototypesM),
-		AssignmentPriorityF:  buildPriorityList(w.Config.NumPrototypesF),
-		AssignmentThresholds: make([]float64, max(w.Config.NumPrototypesM, w.Config.NumPrototypesF)),
+		NumStages:      w.Config.NumStages,
+		NumPrototypesM: w.Config.NumPrototypesM,
+		NumPrototypesF: w.Config.NumPrototypesF,
+	}
+	if ontCfg.Stages, err = buildStages(db, w, registry); err != nil {
+		return nil, fmt.Errorf("engine build: %w", err)
+	}
+	if err := buildAssignment(db, w, registry, cfg, &ontCfg); err != nil {
+		return nil, fmt.Errorf("engine build: %w", err)
+	}
+	ontCfg.NumLoci = w.Config.NumLoci
+	ontCfg.MorphologyGenetic = buildMorphology(registry, "genetic", w.Config)
+	ontCfg.MorphologyEnvironmental = buildMorphology(registry, "environmental", w.Config)
+	ontCfg.Longevity = buildPrototypeFormulas(registry, "longevity", w.Config)
+	ontCfg.EggMortality = registry.Get("reproduction.egg_mortality")
+	if genCfg.SexDetermination == systems.SexDeterminationEnvironmental {
+		ontCfg.EnvironmentalSex = registry.Get("reproduction.sex_determination")
 	}
+	ontCfg.Genetics = &genCfg
+	reproCfg.SpermUse, _ = systems.ParseSpermUse(repro.SpermUseMode)
+	offspringNames, _ := world.ParseNamePattern(repro.OffspringNamePattern)
+	w.Names.SetPattern(offspringNames)
 
-	// Genetics config (defaults: no mutation).
-	genCfg := systems.GeneticsConfig{
-		NumLoci:  w.Config.NumLoci,
-		LociCont: make([]systems.LocusConfig, w.Config.NumLoci),
-		LociDisc: make([]systems.LocusConfig, w.Config.NumLoci),
+	// Founders placed as adults fix their morphology and lifespan at once.
+	for i := 0; i < w.Agents.Count; i++ {
+		if w.Agents.PrototypeID[i] >= 0 {
+			envBuilder.SetAgentVars(w, i)
+			systems.FixMorphology(w, i, eval, ontCfg)
+			systems.FixLongevity(w, i, eval, ontCfg)
+		}
 	}
 
 	// Pre-allocate permutation slice.
 	permutation := make([]int, w.Agents.Cap)
 
 	e := &Engine{
-		World:        w,
-		DB:           db,
-		RunID:        runID,
-		AgentGrid:    agentGrid,
-		ResourceGrid: resourceGrid,
-		Registry:     registry,
-		Eval:         eval,
-		EnvBuilder:   envBuilder,
-		OntogenyCfg:  ontCfg,
-		GeneticsCfg:  genCfg,
-		ReproCfg:     reproCfg,
+		World:         w,
+		DB:            db,
+		RunID:         runID,
+		AgentGrid:     agentGrid,
+		ResourceGrid:  resourceGrid,
+		Registry:      registry,
+		Eval:          eval,
+		EnvBuilder:    envBuilder,
+		OntogenyCfg:   ontCfg,
+		GeneticsCfg:   genCfg,
+		ReproCfg:      reproCfg,
+		Metabolism:    metabolism,
 		BehaviorCosts: behaviorCosts,
-		OptimalLevels: optimalLevels,
-		Longevity:    cfg.Longevity,
-		CombatTimeout: cfg.CombatTimeout,
-		CourtTimeout:  cfg.CourtTimeout,
-		WriteBuffer:  wb,
-		permutation:  permutation,
+		FeedingGains:  buildFeedingGains(registry, w.Config.NumResourceTypes),
+		Velocities:    buildVelocities(registry, w.Config.NumSubstrates),
+		Substrates:    substrates,
+		Resources:     resources,
+		Agents:        agents,
+		Memory:        buildMemoryInfluence(registry, w.Config),
+		Combat:        buildPayoffMatrix(registry, "combat", combatActions, combatOpponentActions, w.Config),
+		Courtship:     buildPayoffMatrix(registry, "courtship", courtshipActions, courtshipOpponentActions, w.Config),
+		Refractory: systems.RefractoryConfig{
+			NumPrototypesM: w.Config.NumPrototypesM,
+			Combat:         buildPrototypeFormulas(registry, "refractory_combat", w.Config),
+			Courtship:      buildPrototypeFormulas(registry, "refractory_courtship", w.Config),
+		},
+		Longevity:          cfg.Longevity,
+		CombatTimeout:      cfg.CombatTimeout,
+		CourtTimeout:       cfg.CourtTimeout,
+		LocusStatsInterval: cfg.LocusStatsInterval,
+		WriteBuffer:        wb,
+		permutation:        permutation,
 	}
+	e.OntogenyCfg.Genetics = &e.GeneticsCfg
 
 	return e, nil
 }
@@ -219,15 +317,21 @@ func (e *Engine) Tick() {
 
 	// 1. Build perception context for this tick.
 	ctx := &systems.PerceptionContext{
-		World:         w,
-		AgentGrid:     e.AgentGrid,
-		ResourceGrid:  e.ResourceGrid,
-		Formulas:      e.Registry,
-		Eval:          e.Eval,
-		EnvBuilder:    e.EnvBuilder,
-		ResourceRadii: e.resourceRadii(),
-		ResourceAttr:  e.resourceAttr(),
-		AgentRadii:    e.agentRadii(),
+		World:        w,
+		AgentGrid:    e.AgentGrid,
+		ResourceGrid: e.ResourceGrid,
+		Formulas:     e.Registry,
+		Eval:         e.Eval,
+		EnvBuilder:   e.EnvBuilder,
+		Metabolism:   &e.Metabolism,
+		Velocities:   e.Velocities,
+		Substrates:   e.Substrates,
+		Resources:    e.Resources,
+		Agents:       e.Agents,
+		Memory:       e.Memory,
+		Combat:       e.Combat,
+		Courtship:    e.Courtship,
+		Genetics:     &e.GeneticsCfg,
 	}
 
 	// 2. Generate random permutation for agent processing order.
@@ -236,7 +340,9 @@ func (e *Engine) Tick() {
 	// 3. Perceive (in shuffled order).
 	for _, idx := range perm {
 		if a.Situation[idx] == world.SituationCombat || a.Situation[idx] == world.SituationCourtship {
-			continue // Combat/courtship agents skip perception.
+			// Combat/courtship agents weigh their next move from the payoff matrices.
+			systems.PerceiveInteraction(ctx, idx)
+			continue
 		}
 		systems.Perceive(ctx, idx)
 	}
@@ -252,47 +358,77 @@ func (e *Engine) Tick() {
 	}
 
 	// 6. Act (all agents).
+	actx := &systems.ActionContext{
+		World:        w,
+		Eval:         e.Eval,
+		EnvBuilder:   e.EnvBuilder,
+		Gains:        e.FeedingGains,
+		Reproduction: &e.ReproCfg,
+		Genetics:     &e.GeneticsCfg,
+	}
+	firstEgg := w.Eggs.Count
 	for _, idx := range perm {
-		systems.Act(w, idx)
+		systems.Act(actx, idx)
 	}
+	e.recordFertilizations(firstEgg)
 
-	// 7. Charge nutrient costs.
+	// 7. Charge nutrient costs (formulas see the agent's post-action state).
+	e.EnvBuilder.SetWorldVars(w)
 	for i := 0; i < a.Count; i++ {
-		systems.ChargeNutrients(w, i, e.BehaviorCosts)
+		if systems.NeedsCostFormulas(w, i, &e.BehaviorCosts) {
+			e.EnvBuilder.SetAgentVars(w, i)
+		}
+		systems.ChargeBehaviorCosts(w, i, e.Eval, &e.BehaviorCosts)
 	}
 
-	// 8. Physiological update (age, starvation, old age).
+	// 8. Physiological update (refractory periods, age, starvation, old age).
 	for i := 0; i < a.Count; i++ {
+		if systems.RefractoryPending(w, i) {
+			e.EnvBuilder.SetAgentVars(w, i)
+			systems.StartRefractoryPeriods(w, i, e.Eval, &e.Refractory)
+		}
 		systems.UpdateAgent(w, i, e.Longevity)
 	}
 
 	// 9. Reproduction: gametogenesis for adults at optimal reserves.
+	reproDynamic := e.ReproCfg.Formulas.Dynamic()
 	for i := 0; i < a.Count; i++ {
-		if a.StageID[i] == -1 && systems.IsOptimalForReproduction(a, i, w.Config.NumNutrients, e.OptimalLevels) {
-			systems.Gametogenesis(w, i, e.ReproCfg)
+		if a.StageID[i] == -1 && systems.IsOptimalForReproduction(a, i, w.Config.NumNutrients) {
+			if reproDynamic {
+				e.EnvBuilder.SetAgentVars(w, i)
+			}
+			systems.Gametogenesis(w, i, e.ReproCfg.For(e.Eval, a.Sex[i]))
 		}
 	}
 
 	// 10. Sperm consumption for females.
 	for i := 0; i < a.Count; i++ {
 		if a.Sex[i] == world.SexFemale && a.StageID[i] == -1 {
-			systems.SpermConsumption(w, i, e.ReproCfg)
+			if reproDynamic {
+				e.EnvBuilder.SetAgentVars(w, i)
+			}
+			systems.SpermConsumption(w, i, e.ReproCfg.For(e.Eval, world.SexFemale))
 		}
 	}
 
 	// 11. Resolve combat/courtship dynamics.
 	systems.ResolveCombatDynamics(w, e.CombatTimeout)
-	systems.ResolveCourtshipDynamics(w, e.CourtTimeout, e.ReproCfg, e.GeneticsCfg)
+	systems.ResolveCourtshipDynamics(w, e.Eval, e.EnvBuilder, e.CourtTimeout, e.ReproCfg, e.GeneticsCfg)
 
 	// 12. Ontogeny: evaluate eggs and stage transitions.
-	systems.EvaluateEggs(w, e.OntogenyCfg, e.GeneticsCfg)
+	e.EnvBuilder.SetWorldVars(w)
+	firstBorn := a.Count
+	systems.EvaluateEggs(w, e.Eval, e.EnvBuilder, e.OntogenyCfg, e.GeneticsCfg)
+	e.recordBirths(firstBorn)
 	for i := 0; i < a.Count; i++ {
 		if a.StageID[i] >= 0 {
-			systems.EvaluateStageTransition(w, i, e.OntogenyCfg)
+			e.EnvBuilder.SetAgentVars(w, i)
+			systems.EvaluateStageTransition(w, i, e.Eval, e.OntogenyCfg)
 		}
 	}
 
 	// 13. Remove dead agents and rebuild spatial grid.
+	e.recordDeaths()
 	removed := systems.RemoveDeadAgents(w)
 	if removed > 0 {
 		e.AgentGrid.Rebuild(a.Count, a.PosX, a.PosY)
@@ -364,38 +500,6 @@ func (e *Engine) Finish(status string) error {
 
 // --- Internal helpers ---
 
-func (e *Engine) resourceRadii() []float64 {
-	numProtos := e.World.Config.NumPrototypes
-	numResTypes := e.World.Config.NumResourceTypes
-	n := numResTypes * numProtos
-	radii := make([]float64, n)
-	for i := range radii {
-		radii[i] = e.AgentGrid.CellSize
-	}
-	return radii
-}
-
-func (e *Engine) resourceAttr() []int32 {
-	numProtos := e.World.Config.NumPrototypes
-	numResTypes := e.World.Config.NumResourceTypes
-	n := numResTypes * numProtos
-	attr := make([]int32, n)
-	for i := range attr {
-		attr[i] = 10
-	}
-	return attr
-}
-
-func (e *Engine) agentRadii() []float64 {
-	numProtos := e.World.Config.NumPrototypes
-	n := numProtos * numProtos
-	radii := make([]float64, n)
-	for i := range radii {
-		radii[i] = e.AgentGrid.CellSize
-	}
-	return radii
-}
-
 // shuffleAgents generates a Fisher-Yates permutation of indices [0, count).
 func (e *Engine) shuffleAgents(count int) []int {
 	if count > len(e.permutation) {
@@ -405,13 +509,88 @@ func (e *Engine) shuffleAgents(count int) []int {
 	for i := range perm {
 		perm[i] = i
 	}
-	rand.Shuffle(count, func(i, j int) {
+	e.World.Rand.Order.Shuffle(count, func(i, j int) {
 		perm[i], perm[j] = perm[j], perm[i]
 	})
 	return perm
 }
 
-// recordTick writes population counts to the write buffer.
+// recordFertilizations writes a fertilization event of the mother, naming
+// the sire, for each fertilized egg laid this tick from index firstEgg on.
+func (e *Engine) recordFertilizations(firstEgg int) {
+	eggs := e.World.Eggs
+	if e.WriteBuffer == nil || firstEgg >= eggs.Count {
+		return
+	}
+
+	tick := int(e.World.Tick)
+	events := make([]storage.SimEvent, 0, eggs.Count-firstEgg)
+	for i := firstEgg; i < eggs.Count; i++ {
+		if eggs.ParentMaleID[i] == 0 {
+			continue // Unfertilized (haploid) egg.
+		}
+		events = append(events, storage.SimEvent{
+			Tick:      tick,
+			EventType: "fertilization",
+			AgentID:   eggs.ParentFemaleID[i],
+			AgentName: e.World.Names.Name(eggs.ParentFemaleID[i]),
+			Details:   "sire=" + strconv.FormatUint(eggs.ParentMaleID[i], 10),
+		})
+	}
+	e.WriteBuffer.AddEvents(events)
+}
+
+// recordBirths writes a birth event, with the generation and the parents,
+// for each agent hatched this tick from index firstBorn on.
+func (e *Engine) recordBirths(firstBorn int) {
+	a := e.World.Agents
+	if e.WriteBuffer == nil || firstBorn >= a.Count {
+		return
+	}
+
+	names := e.World.Names
+	tick := int(e.World.Tick)
+	events := make([]storage.SimEvent, 0, a.Count-firstBorn)
+	for i := firstBorn; i < a.Count; i++ {
+		father, mother := names.Parents(a.ID[i])
+		events = append(events, storage.SimEvent{
+			Tick:      tick,
+			EventType: "birth",
+			AgentID:   a.ID[i],
+			AgentName: names.Name(a.ID[i]),
+			Details: "generation=" + strconv.Itoa(int(names.Generation(a.ID[i]))) +
+				" father=" + strconv.FormatUint(father, 10) + " mother=" + strconv.FormatUint(mother, 10),
+		})
+	}
+	e.WriteBuffer.AddEvents(events)
+}
+
+// recordDeaths writes a death event for each agent marked dead this tick.
+func (e *Engine) recordDeaths() {
+	if e.WriteBuffer == nil {
+		return
+	}
+
+	a := e.World.Agents
+	tick := int(e.World.Tick)
+	var events []storage.SimEvent
+	for i := 0; i < a.Count; i++ {
+		if a.Situation[i] == world.SituationDead {
+			events = append(events, storage.SimEvent{
+				Tick:      tick,
+				EventType: "death",
+				AgentID:   a.ID[i],
+				AgentName: e.World.Names.Name(a.ID[i]),
+			})
+		}
+	}
+	if len(events) > 0 {
+		e.WriteBuffer.AddEvents(events)
+	}
+}
+
+// recordTick writes population counts, and every LocusStatsInterval ticks
+// the locus statistics, to the write buffer.
 func (e *Engine) recordTick() {
 	if e.WriteBuffer == nil {
 		return
@@ -433,69 +612,472 @@ func (e *Engine) recordTick() {
 			}
 		}
 		if c > 0 {
-			stageID := int64(s + 1)
+			stageID := w.Index.StageIDs[s]
 			counts = append(counts, storage.TickCount{Tick: tick, StageID: &stageID, Count: c})
 		}
 	}
 
-	// Count by prototype (adults).
-	for p := 0; p < w.Config.NumPrototypesM+w.Config.NumPrototypesF; p++ {
+	// Count by prototype (adults). PrototypeID is indexed within each sex.
+	counts = e.appendPrototypeCounts(counts, tick, world.SexMale, w.Index.PrototypeIDsM)
+	counts = e.appendPrototypeCounts(counts, tick, world.SexFemale, w.Index.PrototypeIDsF)
+
+	// Total egg count.
+	if w.Eggs.Count > 0 {
+		counts = append(counts, storage.TickCount{Tick: tick, Count: w.Eggs.Count})
+	}
+
+	if len(counts) > 0 {
+		e.WriteBuffer.AddTickCounts(tick, counts)
+	}
+
+	if n := e.LocusStatsInterval; n > 0 && tick%n == 0 {
+		e.WriteBuffer.AddLocusStats(tick, e.locusStats(tick))
+	}
+}
+
+// locusStats computes the statistics of every locus over the living agents:
+// the whole population, each sex and the adults of each prototype (groups
+// without agents are left out).
+func (e *Engine) locusStats(tick int) []storage.LocusStat {
+	w := e.World
+	a := w.Agents
+	ix := w.Index
+	genCfg := &e.GeneticsCfg
+
+	// Groups: population, males, females, then the male and female prototypes.
+	numM := len(ix.PrototypeIDsM)
+	sexes := []string{"", "M", "F"}
+	prototypes := []*int64{nil, nil, nil}
+	for _, id := range ix.PrototypeIDsM {
+		sexes, prototypes = append(sexes, "M"), append(prototypes, &id)
+	}
+	for _, id := range ix.PrototypeIDsF {
+		sexes, prototypes = append(sexes, "F"), append(prototypes, &id)
+	}
+	groups := make([]systems.AlleleStats, len(sexes))
+
+	var stats []storage.LocusStat
+	for l := 0; l < w.Config.NumLoci; l++ {
+		clear(groups)
+		for i := 0; i < a.Count; i++ {
+			groups[0].AddLocus(w, i, l, genCfg)
+			sexGroup, protoGroup := 1, 3+int(a.PrototypeID[i])
+			if a.Sex[i] == world.SexFemale {
+				sexGroup, protoGroup = 2, protoGroup+numM
+			}
+			if a.Sex[i] != world.SexUndefined {
+				groups[sexGroup].AddLocus(w, i, l, genCfg)
+			}
+			if a.StageID[i] == -1 && a.PrototypeID[i] >= 0 && protoGroup < len(groups) {
+				groups[protoGroup].AddLocus(w, i, l, genCfg)
+			}
+		}
+
+		for g := range groups {
+			s := &groups[g]
+			if s.Individuals == 0 {
+				continue
+			}
+			stat := storage.LocusStat{
+				Tick:             tick,
+				LocusID:          ix.LocusIDs[l],
+				PrototypeID:      prototypes[g],
+				Sex:              sexes[g],
+				Individuals:      s.Individuals,
+				Alleles:          s.Alleles,
+				Mean:             s.Mean(),
+				Variance:         s.Variance(),
+				DominantFraction: s.DominantFraction(),
+				ObservedHet:      s.ObservedHeterozygosity(),
+				ExpectedHet:      s.ExpectedHeterozygosity(),
+			}
+			if !genCfg.IsContinuous(l) {
+				stat.Frequencies = make(map[int32]float64)
+				for value, p := range s.Frequencies() {
+					stat.Frequencies[int32(value)] = p
+				}
+			}
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+// appendPrototypeCounts appends the adult counts of one sex's prototypes.
+func (e *Engine) appendPrototypeCounts(counts []storage.TickCount, tick int, sex uint8, ids []int64) []storage.TickCount {
+	a := e.World.Agents
+	for p := range ids {
 		c := 0
 		for i := 0; i < a.Count; i++ {
-			if a.StageID[i] == -1 && a.PrototypeID[i] == int32(p) {
+			if a.StageID[i] == -1 && a.Sex[i] == sex && a.PrototypeID[i] == int32(p) {
 				c++
 			}
 		}
 		if c > 0 {
-			protoID := int64(p + 1)
+			protoID := ids[p]
 			counts = append(counts, storage.TickCount{Tick: tick, PrototypeID: &protoID, Count: c})
 		}
 	}
+	return counts
+}
 
-	// Total egg count.
-	if w.Eggs.Count > 0 {
-		counts = append(counts, storage.TickCount{Tick: tick, Count: w.Eggs.Count})
+// buildMetabolism resolves the per-nutrient metabolism programs from the registry.
+func buildMetabolism(reg *formulas.Registry, numNutrients int) systems.MetabolismConfig {
+	m := systems.MetabolismConfig{
+		Min:      make([]*formulas.Program, numNutrients),
+		Critical: make([]*formulas.Program, numNutrients),
+		Optimal:  make([]*formulas.Program, numNutrients),
+		Initial:  make([]*formulas.Program, numNutrients),
+		Max:      make([]*formulas.Program, numNutrients),
 	}
+	for n := 0; n < numNutrients; n++ {
+		prefix := "metabolism." + util.Itoa(n) + "."
+		m.Min[n] = reg.Get(prefix + "min")
+		m.Critical[n] = reg.Get(prefix + "critical")
+		m.Optimal[n] = reg.Get(prefix + "optimal")
+		m.Initial[n] = reg.Get(prefix + "initial")
+		m.Max[n] = reg.Get(prefix + "max")
+	}
+	return m
+}
 
-	if len(counts) > 0 {
-		e.WriteBuffer.AddTickCounts(tick, counts)
+// buildReproductionFormulas resolves the reproduction and gamete cost
+// programs from the registry. Missing ones keep the default parameters.
+func buildReproductionFormulas(reg *formulas.Registry, numNutrients int) systems.ReproductionFormulas {
+	f := systems.ReproductionFormulas{
+		MaxEggs:            reg.Get("reproduction.max_eggs"),
+		MaxSpermPacks:      reg.Get("reproduction.max_sperm_packs"),
+		PacksTransferred:   reg.Get("reproduction.packs_transferred"),
+		FractionFertilized: reg.Get("reproduction.fraction_fertilized"),
+		Paternity:          reg.Get("reproduction.paternity"),
+		MaxStoredPacks:     reg.Get("reproduction.max_stored_packs"),
+		ConsumptionRate:    reg.Get("reproduction.consumption_rate"),
+		EggsPerCycle:       reg.Get("reproduction.eggs_per_cycle"),
+		EggFraction:        reg.Get("reproduction.egg_fraction"),
+		PackFraction:       reg.Get("reproduction.pack_fraction"),
+		SpermDegradation:   reg.Get("reproduction.sperm_degradation"),
+		GameteCostsM:       make([]*formulas.Program, numNutrients),
+		GameteCostsF:       make([]*formulas.Program, numNutrients),
+	}
+	for n := 0; n < numNutrients; n++ {
+		f.GameteCostsM[n] = reg.Get("gamete.M." + util.Itoa(n))
+		f.GameteCostsF[n] = reg.Get("gamete.F." + util.Itoa(n))
+	}
+	return f
+}
+
+// buildBehaviorCosts resolves the behavior cost programs from the registry.
+// Behaviors without a formula for a nutrient keep the default fixed cost.
+func buildBehaviorCosts(reg *formulas.Registry, numBehaviors, numNutrients int) systems.BehaviorCostConfig {
+	c := systems.BehaviorCostConfig{
+		Fixed:    make([]int32, numBehaviors*numNutrients),
+		Formulas: make([]*formulas.Program, numBehaviors*numNutrients),
+		Dynamic:  make([]bool, numBehaviors),
 	}
+	for b := 0; b < numBehaviors; b++ {
+		prefix := "cost." + util.Itoa(b+1) + "."
+		for n := 0; n < numNutrients; n++ {
+			if b != 1 { // Rest has no default cost.
+				c.Fixed[b*numNutrients+n] = 1
+			}
+			if p := reg.Get(prefix + util.Itoa(n)); p != nil {
+				c.Formulas[b*numNutrients+n] = p
+				c.Dynamic[b] = true
+			}
+		}
+	}
+	return c
 }
 
-// buildDefaultStages creates minimal stage configs.
-func buildDefaultStages(numStages, numNutrients int) []systems.StageConfig {
-	stages := make([]systems.StageConfig, numStages)
-	for i := range stages {
-		reqs := make([]int32, numNutrients)
-		costs := make([]int32, numNutrients)
-		for n := range reqs {
-			reqs[n] = 10
-			costs[n] = 2
+// buildFeedingGains resolves the feeding gain program of each resource type.
+func buildFeedingGains(reg *formulas.Registry, numResourceTypes int) []*formulas.Program {
+	gains := make([]*formulas.Program, numResourceTypes)
+	for r := range gains {
+		gains[r] = reg.Get("gain." + util.Itoa(r))
+	}
+	return gains
+}
+
+// buildVelocities resolves the velocity program of each substrate.
+func buildVelocities(reg *formulas.Registry, numSubstrates int) []*formulas.Program {
+	velocities := make([]*formulas.Program, numSubstrates)
+	for s := range velocities {
+		velocities[s] = reg.Get("velocity." + util.Itoa(s))
+	}
+	return velocities
+}
+
+// buildPerceptionMatrix resolves the radius, attractiveness and interaction
+// programs of one perception category (substrate, resource or agent) from the
+// registry. It returns nil when the project defines none.
+func buildPerceptionMatrix(reg *formulas.Registry, kind string, numElements int, cfg world.Config) *systems.PerceptionMatrix {
+	numPerceivers := cfg.NumPrototypes
+	m := systems.NewPerceptionMatrix(numElements, numPerceivers, cfg.NumBehaviors)
+	found := false
+	for el := 0; el < numElements; el++ {
+		for p := 0; p < numPerceivers; p++ {
+			key := el*numPerceivers + p
+			suffix := kind + "." + util.Itoa(el) + "." + util.Itoa(p)
+			m.Radius[key] = reg.Get("radius." + suffix)
+			m.Attractiveness[key] = reg.Get("attractiveness." + suffix)
+			found = found || m.Radius[key] != nil
+			for b := 0; b < cfg.NumBehaviors; b++ {
+				prog := reg.Get("interaction." + suffix + "." + util.Itoa(b+1))
+				m.Interaction[key*cfg.NumBehaviors+b] = prog
+				found = found || prog != nil
+			}
 		}
-		stages[i] = systems.StageConfig{
-			CyclesRequired:  int32((i + 1) * 50),
-			NutrientReqs:    reqs,
-			NutrientCosts:   costs,
-			LogicCyclesReqs: true,
-			LogicReqsConds:  false,
-			LinkedPrototype: -1,
+	}
+	if !found {
+		return nil
+	}
+	return m
+}
+
+// buildPayoffMatrix resolves the combat or courtship payoff programs of every
+// perceiver. It returns nil when the project defines none.
+func buildPayoffMatrix(reg *formulas.Registry, kind string, numActions, numOpponentActions int, cfg world.Config) *systems.PayoffMatrix {
+	m := systems.NewPayoffMatrix(cfg.NumPrototypes, numActions, numOpponentActions)
+	found := false
+	for p := 0; p < cfg.NumPrototypes; p++ {
+		for action := 0; action < numActions; action++ {
+			for opp := 0; opp < numOpponentActions; opp++ {
+				prog := reg.Get(kind + "." + util.Itoa(p) + "." + util.Itoa(action+1) + "." + util.Itoa(opp))
+				m.Formulas[(p*numActions+action)*numOpponentActions+opp] = prog
+				found = found || prog != nil
+			}
 		}
 	}
-	return stages
+	if !found {
+		return nil
+	}
+	return m
 }
 
-// buildPriorityList creates a simple priority list [0, 1, 2, ...].
-func buildPriorityList(n int) []int {
-	list := make([]int, n)
-	for i := range list {
-		list[i] = i
+// buildMemoryInfluence resolves the memory influence programs of every
+// perceiver together with the behavior each one weights. It returns nil when
+// the project defines none.
+func buildMemoryInfluence(reg *formulas.Registry, cfg world.Config) [][]systems.MemoryInfluence {
+	memory := make([][]systems.MemoryInfluence, cfg.NumPrototypes)
+	found := false
+	for p := range memory {
+		for _, memoryType := range memoryTypes {
+			for slot := 0; slot < memorySlotCount(memoryType, cfg); slot++ {
+				prog := reg.Get("memory." + memoryType + "." + util.Itoa(slot+1) + "." + util.Itoa(p))
+				if prog == nil {
+					continue
+				}
+				memory[p] = append(memory[p], systems.MemoryInfluence{
+					Behavior: memoryBehavior(memoryType, slot, p, cfg),
+					Formula:  prog,
+				})
+				found = true
+			}
+		}
+	}
+	if !found {
+		return nil
 	}
-	return list
+	return memory
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
+// memoryBehavior returns the 0-based behavior weighted by a memory slot of
+// perceiver p, following the rules documented with the registry keys.
+func memoryBehavior(memoryType string, slot, p int, cfg world.Config) int {
+	if memoryType == "last_behavior" || memoryType == "num_behavior" {
+		return slot
+	}
+	feed := 2
+	fightDisplay := feed + cfg.NumResourceTypes
+	courtDisplay := fightDisplay + 2
+	switch {
+	case slot < cfg.NumSubstrates:
+		return 1 // Rest.
+	case slot < cfg.NumSubstrates+cfg.NumResourceTypes:
+		return feed + slot - cfg.NumSubstrates
+	}
+	observedSex := perceiverSex(slot-cfg.NumSubstrates-cfg.NumResourceTypes, cfg)
+	ownSex := perceiverSex(p, cfg)
+	if observedSex != world.SexUndefined && ownSex != world.SexUndefined && observedSex != ownSex {
+		return courtDisplay
+	}
+	return fightDisplay
+}
+
+// perceiverSex returns the sex of a unified perceiver index (undefined for stages).
+func perceiverSex(p int, cfg world.Config) uint8 {
+	switch {
+	case p < cfg.NumStages:
+		return world.SexUndefined
+	case p < cfg.NumStages+cfg.NumPrototypesM:
+		return world.SexMale
+	}
+	return world.SexFemale
+}
+
+// maxConstantRadius returns the largest literal radius over the given matrices.
+func maxConstantRadius(matrices ...*systems.PerceptionMatrix) float64 {
+	r := 0.0
+	for _, m := range matrices {
+		if m != nil {
+			r = math.Max(r, m.MaxConstantRadius())
+		}
+	}
+	return r
+}
+
+// buildAssignment resolves the prototype assignment criteria of each sex in
+// priority order and the fallback prototypes.
+func buildAssignment(db *storage.DB, w *world.World, reg *formulas.Registry, cfg EngineConfig, ont *systems.OntogenyConfig) error {
+	criteria, err := storage.NewPrototypeRepo(db).ListAssignmentCriteria()
+	if err != nil {
+		return err
+	}
+	for _, cr := range criteria {
+		sex, proto, ok := w.Index.Prototype(cr.PrototypeID)
+		if !ok {
+			continue
+		}
+		op, err := systems.ParseComparison(cr.Operator)
+		if err != nil {
+			return err
+		}
+		p, _ := w.Index.Perceiver(nil, &cr.PrototypeID)
+		c := systems.AssignmentCriterion{
+			Prototype: proto,
+			Formula:   reg.Get("assignment." + util.Itoa(p) + "." + util.Itoa(cr.Priority)),
+			Operator:  op,
+			Threshold: cr.Threshold,
+		}
+		if sex == world.SexMale {
+			ont.AssignmentM = append(ont.AssignmentM, c)
+		} else {
+			ont.AssignmentF = append(ont.AssignmentF, c)
+		}
+	}
+
+	if ont.FallbackM, err = fallbackPrototype(w.Index, cfg.FallbackPrototypeM, world.SexMale); err != nil {
+		return err
+	}
+	ont.FallbackF, err = fallbackPrototype(w.Index, cfg.FallbackPrototypeF, world.SexFemale)
+	return err
+}
+
+// buildPrototypeFormulas resolves the prototype.<p>.<name> formulas, indexed
+// by prototype with males first and females after them.
+func buildPrototypeFormulas(reg *formulas.Registry, name string, cfg world.Config) []*formulas.Program {
+	programs := make([]*formulas.Program, cfg.NumPrototypesM+cfg.NumPrototypesF)
+	for proto := range programs {
+		programs[proto] = reg.Get("prototype." + util.Itoa(cfg.NumStages+proto) + "." + name)
+	}
+	return programs
+}
+
+// buildMorphology resolves the morphology.<p>.<l>.<kind> formulas, indexed
+// [proto*NumLoci + locus] with males first and females after them.
+func buildMorphology(reg *formulas.Registry, kind string, cfg world.Config) []*formulas.Program {
+	numProtos := cfg.NumPrototypesM + cfg.NumPrototypesF
+	programs := make([]*formulas.Program, numProtos*cfg.NumLoci)
+	for proto := 0; proto < numProtos; proto++ {
+		p := util.Itoa(cfg.NumStages + proto)
+		for l := 0; l < cfg.NumLoci; l++ {
+			programs[proto*cfg.NumLoci+l] = reg.Get("morphology." + p + "." + util.Itoa(l) + "." + kind)
+		}
+	}
+	return programs
+}
+
+// fallbackPrototype returns the index of a fallback prototype ID, which must
+// belong to the given sex; 0 selects the first prototype.
+func fallbackPrototype(ix *world.Index, id int64, sex uint8) (int, error) {
+	if id == 0 {
+		return 0, nil
+	}
+	s, idx, ok := ix.Prototype(id)
+	if !ok || s != sex {
+		name := "male"
+		if sex == world.SexFemale {
+			name = "female"
+		}
+		return 0, fmt.Errorf("fallback prototype %d is not a %s prototype", id, name)
+	}
+	return idx, nil
+}
+
+// buildStages resolves the transition formulas, operators, logic and linked
+// prototype of every stage.
+func buildStages(db *storage.DB, w *world.World, reg *formulas.Registry) ([]systems.StageConfig, error) {
+	rows, err := storage.NewStageRepo(db).List()
+	if err != nil {
+		return nil, err
+	}
+	numNut := w.Config.NumNutrients
+	stages := make([]systems.StageConfig, w.Config.NumStages)
+	for _, row := range rows {
+		p, _ := w.Index.Stage(row.ID)
+		prefix := "stage." + util.Itoa(p) + "."
+		st := &stages[p]
+		st.Cycles = reg.Get(prefix + "cycles")
+		st.Condition1 = reg.Get(prefix + "condition1")
+		st.Condition2 = reg.Get(prefix + "condition2")
+		st.Condition1Op, _ = systems.ParseComparison(row.Condition1Op)
+		st.Condition2Op, _ = systems.ParseComparison(row.Condition2Op)
+		st.Condition1Value = row.Condition1Value
+		st.Condition2Value = row.Condition2Value
+		st.LogicCyclesReqs, _ = parseLogic(row.LogicCyclesReqs)
+		st.LogicReqsConds, _ = parseLogic(row.LogicReqsConds)
+		st.LogicCond1Cond2, _ = parseLogic(row.LogicCond1Cond2)
+
+		// Nutrients without a requirement row need and cost nothing.
+		st.Requirements = make([]*formulas.Program, numNut)
+		st.Costs = make([]*formulas.Program, numNut)
+		for n := 0; n < numNut; n++ {
+			st.Requirements[n] = reg.Get(prefix + "requirement." + util.Itoa(n))
+			st.Costs[n] = reg.Get(prefix + "cost." + util.Itoa(n))
+		}
+
+		st.LinkedPrototype = -1
+		if row.LinkedPrototypeID != nil {
+			sex, proto, _ := w.Index.Prototype(*row.LinkedPrototypeID)
+			if sex == world.SexFemale {
+				proto += w.Config.NumPrototypesM
+			}
+			st.LinkedPrototype = proto
+		}
+	}
+	return stages, nil
+}
+
+// buildGenetics reads the genetic architecture of the loci: their
+// chromosome map (no linkage map when no two loci are linked), sex linkage,
+// under the project's sex-determination system, and expression models.
+func buildGenetics(db *storage.DB, w *world.World, repro *storage.Reproduction) (systems.GeneticsConfig, error) {
+	numLoci := w.Config.NumLoci
+	genCfg := systems.GeneticsConfig{
+		NumLoci:    numLoci,
+		LociCont:   make([]systems.LocusConfig, numLoci),
+		LociDisc:   make([]systems.LocusConfig, numLoci),
+		SexLinkage: make([]systems.SexLinkage, numLoci),
+		Expression: make([]world.Expression, numLoci),
+		Continuous: make([]bool, numLoci),
+	}
+	genCfg.SexDetermination, _ = systems.ParseSexDetermination(repro.SexDetermination)
+
+	rows, err := storage.NewLocusRepo(db).List()
+	if err != nil {
+		return genCfg, err
+	}
+	chromosome := make([]int, numLoci)
+	position := make([]float64, numLoci)
+	for _, row := range rows {
+		l, _ := w.Index.Locus(row.ID)
+		chromosome[l] = row.Chromosome
+		position[l] = row.MapPosition
+		genCfg.SexLinkage[l], _ = systems.ParseSexLinkage(row.SexLinkage)
+		model, _ := world.ParseExpressionModel(row.Expression)
+		genCfg.Expression[l] = world.Expression{Model: model, H: row.DominanceCoefficient}
+		genCfg.Continuous[l] = row.IsContinuous
 	}
-	return b
+	genCfg.Linkage = systems.NewLinkageMap(chromosome, position)
+	return genCfg, nil
 }
@@ -2,10 +2,14 @@ package kernel
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"galatea/engine/internal/adapters/storage"
+	"galatea/engine/internal/kernel/systems"
+	"galatea/engine/internal/kernel/world"
 )
 
 // setupTestDB creates an in-memory DB with a minimal but complete project.
@@ -331,3 +335,618 @@ func TestEnginePerformance(t *testing.T) {
 		t.Logf("WARNING: TPS %.0f is below expected minimum of 1000", tps)
 	}
 }
+
+func TestBuildSeedsReservesFromMetabolism(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	storage.NewMetabolismRepo(db).Set(&storage.Metabolism{
+		NutrientID: 1, MinFormula: "5", CriticalFormula: "20",
+		OptimalFormula: "40", InitialFormula: "90", MaxFormula: "80",
+	})
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	a := engine.World.Agents
+	numNut := engine.World.Config.NumNutrients
+	for i := 0; i < a.Count; i++ {
+		// Nutrient 1: the initial value is clamped to the maximum level.
+		if got := a.Reserves[i*numNut]; got != 80 {
+			t.Errorf("agent %d: expected reserve 80, got %d", i, got)
+		}
+		if got := a.ReserveMin[i*numNut]; got != 5 {
+			t.Errorf("agent %d: expected min level 5, got %d", i, got)
+		}
+		// Nutrient 2 has no metabolism row and uses the schema defaults.
+		if got := a.Reserves[i*numNut+1]; got != 50 {
+			t.Errorf("agent %d: expected default reserve 50, got %d", i, got)
+		}
+		if got := a.ReserveMax[i*numNut+1]; got != 100 {
+			t.Errorf("agent %d: expected default max level 100, got %d", i, got)
+		}
+	}
+}
+
+func TestBuildResolvesBehaviorCostFormulas(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	storage.NewMetabolismRepo(db).SetBehaviorCost(&storage.BehaviorCost{
+		Behavior: "rest", NutrientID: 2, CostFormula: "Speed * 3"})
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	w := engine.World
+	a := w.Agents
+	a.Decision[0] = 1 // Rest.
+	a.Speed[0] = 2
+	a.Reserves[0] = 50
+	a.Reserves[1] = 50
+
+	engine.EnvBuilder.SetAgentVars(w, 0)
+	systems.ChargeBehaviorCosts(w, 0, engine.Eval, &engine.BehaviorCosts)
+
+	// Rest keeps its zero default for nutrient 1; nutrient 2 costs Speed*3.
+	if a.Reserves[0] != 50 {
+		t.Fatalf("expected reserve 50, got %d", a.Reserves[0])
+	}
+	if a.Reserves[1] != 44 {
+		t.Fatalf("expected reserve 44, got %d", a.Reserves[1])
+	}
+}
+
+func TestBuildSubstratePerceptionOnlyWhenDefined(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if engine.Substrates != nil {
+		t.Fatal("expected no substrate perception without formulas")
+	}
+
+	storage.NewPerceptionRepo(db).AddSubstrateAttractiveness(&storage.Attractiveness{
+		EnvironmentID: 1, TargetID: 1, PerceiverStageID: ptr(1),
+		AttractivenessFormula: "10", RadiusFormula: "3"})
+	engine, err = Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if engine.Substrates == nil || engine.Substrates.Radius[0] == nil {
+		t.Fatal("expected the substrate 0 radius of stage 0 to be resolved")
+	}
+}
+
+func TestBuildDerivesCellSizeFromLargestRadius(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if engine.Resources != nil || engine.Agents != nil {
+		t.Fatal("expected no resource or agent perception without formulas")
+	}
+	if engine.AgentGrid.CellSize != defaultCellSize {
+		t.Fatalf("expected default cell size %v, got %v", defaultCellSize, engine.AgentGrid.CellSize)
+	}
+
+	repo := storage.NewPerceptionRepo(db)
+	repo.AddResourceAttractiveness(&storage.Attractiveness{
+		EnvironmentID: 1, TargetID: 1, PerceiverStageID: ptr(1),
+		AttractivenessFormula: "10", RadiusFormula: "12"})
+	repo.AddAgentAttractiveness(&storage.Attractiveness{
+		EnvironmentID: 1, ObservedStageID: ptr(1), PerceiverStageID: ptr(1),
+		AttractivenessFormula: "5", RadiusFormula: "24"})
+	repo.AddAgentAttractiveness(&storage.Attractiveness{
+		EnvironmentID: 1, ObservedStageID: ptr(1), PerceiverPrototypeID: ptr(1),
+		AttractivenessFormula: "5", RadiusFormula: "Age * 100"})
+	engine, err = Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if engine.Resources == nil || engine.Agents == nil {
+		t.Fatal("expected resource and agent perception to be resolved")
+	}
+	if engine.AgentGrid.CellSize != 24 || engine.ResourceGrid.CellSize != 24 {
+		t.Fatalf("expected cell size 24, got %v / %v", engine.AgentGrid.CellSize, engine.ResourceGrid.CellSize)
+	}
+}
+
+func TestBuildMapsMemoryInfluenceToBehaviors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := storage.NewPerceptionRepo(db)
+	// Male prototype (perceiver 1): resource type 2 is slot 3+2, the female
+	// prototype (perceiver 2) is slot 3+2+3.
+	for _, m := range []storage.MemoryInfluence{
+		{MemoryType: "num_perceived", ElementIndex: 5, Formula: "2"},
+		{MemoryType: "last_interacted", ElementIndex: 8, Formula: "3"},
+		{MemoryType: "num_behavior", ElementIndex: 1, Formula: "-4"},
+	} {
+		m.EnvironmentID = 1
+		m.PerceiverPrototypeID = ptr(1)
+		if _, err := repo.AddMemoryInfluence(&m); err != nil {
+			t.Fatalf("AddMemoryInfluence: %v", err)
+		}
+	}
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	male := engine.Memory[1]
+	if len(engine.Memory[0]) != 0 || len(male) != 3 {
+		t.Fatalf("expected 3 memory formulas for the male only, got %v", engine.Memory)
+	}
+	// Feed type 2 = 3, court display = 2+2+2 = 6, move = 0.
+	want := map[string]int{"2": 3, "3": 6, "-4": 0}
+	for _, m := range male {
+		if m.Behavior != want[m.Formula.Source] {
+			t.Errorf("formula %s: expected behavior %d, got %d", m.Formula.Source, want[m.Formula.Source], m.Behavior)
+		}
+	}
+}
+
+func TestBuildResolvesCombatPayoffMatrix(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Male prototype (perceiver 1) escalates against a display.
+	storage.NewPrototypeRepo(db).SetCombat(&storage.PrototypeMatrixCell{
+		PrototypeID: 1, Action: 2, OpponentAction: 1, Formula: "8"})
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if engine.Courtship != nil {
+		t.Fatal("expected no courtship matrix without courtship rows")
+	}
+	if prog := engine.Combat.Formulas[(1*3+1)*3+1]; prog == nil || prog.Source != "8" {
+		t.Fatalf("expected the escalate-vs-display formula of perceiver 1, got %v", prog)
+	}
+}
+
+func TestBuildOrdersAssignmentCriteriaByPriority(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := storage.NewPrototypeRepo(db)
+	sneakerID, _ := repo.Create(&storage.Prototype{Name: "Sneaker", Sex: "M", SortOrder: 2})
+	repo.SetAssignmentCriterion(&storage.PrototypeAssignmentCriterion{PrototypeID: 1, Priority: 2, Formula: "Age", Operator: ">=", Threshold: 40})
+	repo.SetAssignmentCriterion(&storage.PrototypeAssignmentCriterion{PrototypeID: sneakerID, Priority: 1, Formula: "Age", Operator: "<", Threshold: 20})
+
+	cfg := DefaultEngineConfig(1)
+	cfg.FallbackPrototypeM = sneakerID
+	engine, err := Build(db, cfg)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	ont := engine.OntogenyCfg
+	if len(ont.AssignmentM) != 2 || len(ont.AssignmentF) != 0 {
+		t.Fatalf("expected 2 male criteria, got %+v", ont)
+	}
+	if c := ont.AssignmentM[0]; c.Prototype != 1 || c.Operator != systems.CompareLess || c.Threshold != 20 || c.Formula == nil {
+		t.Errorf("expected the sneaker criterion first, got %+v", c)
+	}
+	if c := ont.AssignmentM[1]; c.Prototype != 0 || c.Operator != systems.CompareGreaterEqual {
+		t.Errorf("expected the MaleA criterion second, got %+v", c)
+	}
+	if ont.FallbackM != 1 || ont.FallbackF != 0 {
+		t.Errorf("expected fallbacks 1 and 0, got %d and %d", ont.FallbackM, ont.FallbackF)
+	}
+
+	cfg.FallbackPrototypeM = 2 // FemaleA
+	if _, err := Build(db, cfg); err == nil || !strings.Contains(err.Error(), "fallback prototype 2 is not a male prototype") {
+		t.Fatalf("expected a fallback sex error, got %v", err)
+	}
+}
+
+func TestBuildResolvesStageTransitions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := storage.NewStageRepo(db)
+	femaleID := int64(2)
+	pupaID, _ := repo.Create(&storage.Stage{
+		Name: "Pupa", SortOrder: 2, CyclesFormula: "Age / 2",
+		Condition1Formula: "Reserve1", Condition1Op: "<>", Condition1Value: 3,
+		Condition2Formula: "0", Condition2Op: "<=", Condition2Value: 0,
+		LogicCyclesReqs: "OR", LogicReqsConds: "and", LogicCond1Cond2: "OR",
+		LinkedPrototypeID: &femaleID,
+	})
+	repo.SetNutrientRequirement(&storage.StageNutrientRequirement{
+		StageID: pupaID, NutrientID: 2, RequirementFormula: "15", CostFormula: "4"})
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	stages := engine.OntogenyCfg.Stages
+	if len(stages) != 2 {
+		t.Fatalf("expected 2 stages, got %d", len(stages))
+	}
+	if stages[0].LinkedPrototype != -1 || !stages[0].LogicCyclesReqs || stages[0].Cycles.Source != "50" {
+		t.Errorf("larva: unexpected config %+v", stages[0])
+	}
+	pupa := stages[1]
+	if pupa.Cycles.Source != "Age / 2" || pupa.Condition1Op != systems.CompareNotEqual || pupa.Condition1Value != 3 ||
+		pupa.Condition2Op != systems.CompareLessEqual {
+		t.Errorf("pupa: unexpected conditions %+v", pupa)
+	}
+	if pupa.LogicCyclesReqs || !pupa.LogicReqsConds || pupa.LogicCond1Cond2 {
+		t.Errorf("pupa: expected OR, AND, OR logic, got %+v", pupa)
+	}
+	// The female prototype follows the single male prototype.
+	if pupa.LinkedPrototype != 1 {
+		t.Errorf("pupa: expected linked prototype 1, got %d", pupa.LinkedPrototype)
+	}
+	if pupa.Requirements[0] != nil || pupa.Requirements[1].Source != "15" || pupa.Costs[1].Source != "4" {
+		t.Errorf("pupa: expected requirement and cost on nutrient 2 only")
+	}
+}
+
+func TestBuildFixesFounderMorphology(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// FemaleA's Speed is fixed at 10 plus an age term; males keep their genotype.
+	storage.NewPrototypeRepo(db).SetMorphology(&storage.PrototypeMorphology{
+		PrototypeID: 2, LocusID: 2, GeneticFormula: "10", EnvironmentalFormula: "Age + 2",
+	})
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	ont := engine.OntogenyCfg
+	if ont.MorphologyGenetic[3] == nil || ont.MorphologyEnvironmental[3].Source != "Age + 2" {
+		t.Fatalf("expected FemaleA Speed formulas at index 3, got %+v", ont.MorphologyGenetic)
+	}
+
+	a := engine.World.Agents
+	numLoci := engine.World.Config.NumLoci
+	for i := 0; i < a.Count; i++ {
+		if !a.MorphologyFixed[i] {
+			t.Fatalf("founder %d: expected fixed morphology", i)
+		}
+		if a.Sex[i] == world.SexFemale && a.MorphologyCont[i*numLoci+1] != 12 {
+			t.Errorf("female %d: expected Speed 12, got %f", i, a.MorphologyCont[i*numLoci+1])
+		}
+		if a.Sex[i] == world.SexMale && a.MorphologyCont[i*numLoci+1] > 1 {
+			t.Errorf("male %d: expected an expressed Speed allele, got %f", i, a.MorphologyCont[i*numLoci+1])
+		}
+	}
+}
+
+func TestBuildEvaluatesPrototypeFormulas(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	// Adult founders take the longevity of their prototype.
+	a := engine.World.Agents
+	for i := 0; i < a.Count; i++ {
+		want := int32(500)
+		if a.Sex[i] == world.SexFemale {
+			want = 600
+		}
+		if a.Longevity[i] != want {
+			t.Errorf("founder %d: expected longevity %d, got %d", i, want, a.Longevity[i])
+		}
+	}
+
+	// Formulas are indexed with males first, then females.
+	if r := engine.Refractory; len(r.Combat) != 2 || r.Combat[1].Source != "10" || r.Courtship[0].Source != "10" {
+		t.Errorf("unexpected refractory formulas %+v", r)
+	}
+	if rc := engine.ReproCfg; rc.NumPrototypesM != 1 || rc.SexRatioFemales[1].Source != "50" {
+		t.Errorf("unexpected sex-ratio formulas %+v", rc)
+	}
+
+	// An agent whose combat just ended rests for its prototype's period.
+	a.RefractoryCombat[0] = world.RefractoryPending
+	engine.Tick()
+	if r := a.RefractoryCombat[0]; r < 9 || r > 10 {
+		t.Fatalf("expected a combat refractory period of about 10, got %d", r)
+	}
+}
+
+func TestBuildReadsSpermUseAndRecordsSires(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	storage.NewReproductionRepo(db).Set(&storage.Reproduction{SpermUseMode: "last_male"})
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if engine.ReproCfg.SpermUse != systems.SpermUseLastMale {
+		t.Fatalf("expected last-male sperm use, got %d", engine.ReproCfg.SpermUse)
+	}
+
+	// Founders 0 and 2 are males, 1 is a female; the later male sires.
+	a := engine.World.Agents
+	for _, male := range []int{0, 2} {
+		a.GametesCount[male] = 1
+		a.GametesCount[1] = 2
+		systems.Copulate(engine.World, male, 1, engine.ReproCfg, engine.GeneticsCfg)
+	}
+	laid := systems.Oviposit(engine.World, 1, -1, engine.Eval, engine.ReproCfg, engine.GeneticsCfg)
+	if laid == 0 {
+		t.Fatal("expected eggs to be laid")
+	}
+	engine.recordFertilizations(0)
+	engine.WriteBuffer.Flush()
+
+	var n int
+	db.Conn.QueryRow(`SELECT COUNT(*) FROM sim_events WHERE run_id = ? AND event_type = 'fertilization'
+		AND agent_id = ? AND agent_name = ? AND details = ?`, engine.RunID, 2, "agentB", "sire=3").Scan(&n)
+	if n != laid {
+		t.Fatalf("expected %d fertilization events sired by agent 3, got %d", laid, n)
+	}
+}
+
+func TestBuildEvaluatesReproductionFormulas(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	storage.NewReproductionRepo(db).Set(&storage.Reproduction{
+		MaxSpermPacksFormula:      "10",
+		PacksTransferredFormula:   "2",
+		FractionFertilizedFormula: "0.5",
+		MaxStoredPacksFormula:     "5",
+		PaternityFormula:          "30 + Age", // The male's age.
+	})
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if p := engine.ReproCfg.Formulas.Paternity; p == nil || p.Source != "30 + Age" {
+		t.Fatalf("expected the project paternity formula, got %+v", p)
+	}
+
+	// Founders 0 and 1 are a male and a female accepting each other.
+	w := engine.World
+	a := w.Agents
+	male, female := 0, 1
+	a.Age[male], a.Age[female] = 7, 20
+	a.GametesCount[male], a.GametesCount[female] = 4, 4
+	for _, idx := range []int{male, female} {
+		a.Situation[idx] = world.SituationCourtship
+		a.LastOpponentAction[idx] = 3
+	}
+	a.InteractantIdx[male], a.InteractantIdx[female] = int32(female), int32(male)
+
+	engine.EnvBuilder.SetWorldVars(w)
+	systems.ResolveCourtshipDynamics(w, engine.Eval, engine.EnvBuilder, engine.CourtTimeout, engine.ReproCfg, engine.GeneticsCfg)
+
+	if a.SpermPacksCount[female] != 2 {
+		t.Fatalf("expected 2 packs transferred, got %d", a.SpermPacksCount[female])
+	}
+	packBase := female * w.Config.MaxStoredPacks
+	for k := 0; k < 2; k++ {
+		if got := a.SpermPaternity[packBase+k]; got != 37 {
+			t.Fatalf("pack %d: expected paternity 37 from the formula, got %d", k, got)
+		}
+	}
+	if a.FertilizedCount[female] != 2 {
+		t.Fatalf("expected half of 4 gametes fertilized, got %d", a.FertilizedCount[female])
+	}
+}
+
+func TestDeathEventsRecordAgentIDs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	// Founder 3 starves; founder 10 is moved into its slot and keeps its ID.
+	a := engine.World.Agents
+	numNut := engine.World.Config.NumNutrients
+	for i := 0; i < a.Count; i++ {
+		for n := 0; n < numNut; n++ {
+			a.Reserves[i*numNut+n] = 500
+		}
+	}
+	for n := 0; n < numNut; n++ {
+		a.Reserves[2*numNut+n] = 0
+	}
+	engine.Tick()
+	engine.Finish("finished")
+
+	if a.ID[2] != 10 {
+		t.Fatalf("expected agent 10 in slot 2, got %d", a.ID[2])
+	}
+	var id int64
+	var name string
+	db.Conn.QueryRow("SELECT agent_id, agent_name FROM sim_events WHERE run_id = ? AND event_type = 'death'",
+		engine.RunID).Scan(&id, &name)
+	if id != 3 || name != "agentC" {
+		t.Fatalf("expected a death event of agent 3 (agentC), got %d (%s)", id, name)
+	}
+}
+
+// runSeeded builds an engine with the given seed, runs it for n ticks and
+// returns its tick counts and final agent positions.
+func runSeeded(t *testing.T, db *storage.DB, seed uint64, n int) []string {
+	t.Helper()
+	cfg := DefaultEngineConfig(1)
+	cfg.Seed = seed
+	engine, err := Build(db, cfg)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	a := engine.World.Agents
+	numNut := engine.World.Config.NumNutrients
+	for i := 0; i < a.Count; i++ {
+		for n := 0; n < numNut; n++ {
+			a.Reserves[i*numNut+n] = 500
+		}
+		a.Speed[i] = 1
+	}
+	engine.RunTicks(n)
+	engine.Finish("finished")
+
+	var out []string
+	rows, err := db.Conn.Query("SELECT tick, prototype_id, count FROM sim_tick_counts WHERE run_id = ? ORDER BY id", engine.RunID)
+	if err != nil {
+		t.Fatalf("query tick counts: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tick, count int
+		var proto *int64
+		rows.Scan(&tick, &proto, &count)
+		p := int64(0)
+		if proto != nil {
+			p = *proto
+		}
+		out = append(out, fmt.Sprintf("tick %d prototype %d: %d", tick, p, count))
+	}
+	for i := 0; i < a.Count; i++ {
+		out = append(out, fmt.Sprintf("agent %d at %v,%v", a.ID[i], a.PosX[i], a.PosY[i]))
+	}
+	return out
+}
+
+func TestSeedReproducesRun(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	// Random longevities also draw from the engine's streams.
+	db.Conn.Exec("UPDATE prototypes SET longevity_formula = '60 + Dice(60)'")
+
+	first := runSeeded(t, db, 42, 150)
+	second := runSeeded(t, db, 42, 150)
+	if strings.Join(first, "\n") != strings.Join(second, "\n") {
+		t.Fatal("runs with the same seed differ")
+	}
+	other := runSeeded(t, db, 43, 150)
+	if strings.Join(first, "\n") == strings.Join(other, "\n") {
+		t.Fatal("runs with different seeds are identical")
+	}
+
+	runs, _ := storage.NewSimRunRepo(db).ListByEnvironment(1)
+	if len(runs) != 3 || runs[0].Seed != 42 || runs[2].Seed != 43 {
+		t.Fatalf("expected runs seeded 42, 42 and 43, got %+v", runs)
+	}
+}
+
+func TestBuildDrawsSeedWhenUnset(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	run, _ := storage.NewSimRunRepo(db).GetByID(engine.RunID)
+	if run.Seed == 0 || run.Seed != engine.World.Rand.Seed {
+		t.Fatalf("expected the drawn seed %d on the run, got %d", engine.World.Rand.Seed, run.Seed)
+	}
+}
+
+func TestBuildReadsLinkageMap(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if engine.GeneticsCfg.Linkage != nil {
+		t.Fatal("expected free recombination for unlinked loci")
+	}
+
+	db.Conn.Exec("UPDATE loci SET chromosome = 1, map_position = sort_order * 5")
+	engine, err = Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if engine.GeneticsCfg.Linkage == nil {
+		t.Fatal("expected a linkage map for loci sharing a chromosome")
+	}
+}
+
+func TestBuildExposesExpressionAndTraits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	db.Conn.Exec("UPDATE loci SET expression = 'partial', dominance_coefficient = 0.25 WHERE name = 'Size'")
+	traits := storage.NewTraitRepo(db)
+	traits.Create(&storage.Trait{Name: "Body", Formula: "CL1 + CL2"})
+	traits.Create(&storage.Trait{Name: "Weapon", Formula: "TraitBody * 2", SortOrder: 1})
+
+	engine, err := Build(db, DefaultEngineConfig(1))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if e := engine.GeneticsCfg.Expression[0]; e.Model != world.ExpressionPartial || e.H != 0.25 {
+		t.Fatalf("expected partial dominance 0.25 at Size, got %+v", e)
+	}
+
+	engine.EnvBuilder.SetAgentVars(engine.World, 0)
+	env := engine.Eval.Env()
+	body := env["CL1"].(float64) + env["CL2"].(float64)
+	if env["TraitBody"] != body || env["TraitWeapon"] != 2*body {
+		t.Fatalf("expected traits %v and %v, got %v and %v", body, 2*body, env["TraitBody"], env["TraitWeapon"])
+	}
+}
+
+func TestLocusStatsRecordedEveryInterval(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := DefaultEngineConfig(1)
+	cfg.Seed = 3
+	cfg.LocusStatsInterval = 5
+	engine, err := Build(db, cfg)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	engine.RunTicks(10)
+	engine.Finish("finished")
+
+	repo := storage.NewLocusStatRepo(db)
+	locusID := engine.World.Index.LocusIDs[0]
+	series, err := repo.Series(engine.RunID, locusID, nil, "")
+	if err != nil {
+		t.Fatalf("Series: %v", err)
+	}
+	if len(series) != 2 || series[0].Tick != 5 || series[1].Tick != 10 {
+		t.Fatalf("expected population stats at ticks 5 and 10, got %+v", series)
+	}
+	pop := series[0]
+	if pop.Individuals == 0 || pop.Alleles != 2*pop.Individuals || pop.Frequencies != nil {
+		t.Fatalf("expected two continuous alleles per agent, got %+v", pop)
+	}
+
+	males, _ := repo.Series(engine.RunID, locusID, nil, "M")
+	females, _ := repo.Series(engine.RunID, locusID, nil, "F")
+	if len(males) != 2 || len(females) != 2 || males[0].Individuals+females[0].Individuals != pop.Individuals {
+		t.Fatalf("expected the sexes to add up to the population, got %+v and %+v", males, females)
+	}
+	protoID := engine.World.Index.PrototypeIDsM[0]
+	proto, _ := repo.Series(engine.RunID, locusID, &protoID, "")
+	if len(proto) != 2 || proto[0].Sex != "M" || proto[0].Individuals > males[0].Individuals {
+		t.Fatalf("expected the male prototype series, got %+v", proto)
+	}
+}
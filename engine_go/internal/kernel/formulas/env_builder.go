@@ -9,13 +9,84 @@ import (
 // the current agent state. This is called once per agent per formula evaluation
 // cycle, updating values in-place to avoid map allocations.
 type EnvBuilder struct {
-	eval *Evaluator
-	cfg  world.Config
+	eval       *Evaluator
+	cfg        world.Config
+	names      varNames
+	expression []world.Expression // [locus]; nil = every locus dominant.
+	traits     []trait
+}
+
+// trait is a derived trait: a formula over the expressed loci, exposed to
+// every formula under its variable name.
+type trait struct {
+	name    string
+	program *Program
+}
+
+// varNames holds the indexed variable names (Reserve1, CL2, ...), built once
+// so the Hot Path never concatenates strings.
+type varNames struct {
+	reserve            []string
+	cl, dl             []string
+	morphology         []string
+	morphologyDisc     []string
+	memLastPer         []string
+	memNumPer          []string
+	memLastInt         []string
+	memNumInt          []string
+	memLastBehavior    []string
+	memNumBehavior     []string
+	contenderCL        []string
+	contenderMorph     []string
+	contenderMorphDisc []string
 }
 
 // NewEnvBuilder creates an EnvBuilder tied to an evaluator and world config.
 func NewEnvBuilder(eval *Evaluator, cfg world.Config) *EnvBuilder {
-	return &EnvBuilder{eval: eval, cfg: cfg}
+	memPerceptionSlots := cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
+	return &EnvBuilder{
+		eval: eval,
+		cfg:  cfg,
+		names: varNames{
+			reserve:            indexedNames("Reserve", cfg.NumNutrients),
+			cl:                 indexedNames("CL", cfg.NumLoci),
+			dl:                 indexedNames("DL", cfg.NumLoci),
+			morphology:         indexedNames("Morphology", cfg.NumLoci),
+			morphologyDisc:     indexedNames("MorphologyDisc", cfg.NumLoci),
+			memLastPer:         indexedNames("MemoryLastPer", memPerceptionSlots),
+			memNumPer:          indexedNames("MemoryNumPer", memPerceptionSlots),
+			memLastInt:         indexedNames("MemoryLastInt", memPerceptionSlots),
+			memNumInt:          indexedNames("MemoryNumInt", memPerceptionSlots),
+			memLastBehavior:    indexedNames("MemoryLastBehavior", cfg.NumBehaviors),
+			memNumBehavior:     indexedNames("MemoryNumBehavior", cfg.NumBehaviors),
+			contenderCL:        indexedNames("ContenderCL", cfg.NumLoci),
+			contenderMorph:     indexedNames("ContenderMorphology", cfg.NumLoci),
+			contenderMorphDisc: indexedNames("ContenderMorphologyDisc", cfg.NumLoci),
+		},
+	}
+}
+
+// indexedNames returns prefix1..prefixN.
+func indexedNames(prefix string, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = prefix + util.Itoa(i+1)
+	}
+	return names
+}
+
+// SetExpression sets the expression models of the loci, indexed by locus.
+// Loci without a model use the dominant one.
+func (b *EnvBuilder) SetExpression(expression []world.Expression) {
+	b.expression = expression
+}
+
+// AddTrait exposes the derived trait program to every formula as
+// Trait<name>. Traits are evaluated in the order they are added, after the
+// other variables of each agent or egg, so a trait can use the traits added
+// before it.
+func (b *EnvBuilder) AddTrait(name string, program *Program) {
+	b.traits = append(b.traits, trait{name: "Trait" + name, program: program})
 }
 
 // SetWorldVars sets global simulation variables (tick, etc).
@@ -28,6 +99,7 @@ func (b *EnvBuilder) SetWorldVars(w *world.World) {
 func (b *EnvBuilder) SetAgentVars(w *world.World, idx int) {
 	a := w.Agents
 	cfg := b.cfg
+	names := &b.names
 
 	// Time variables
 	b.eval.Set("Age", int(a.Age[idx]))
@@ -36,34 +108,24 @@ func (b *EnvBuilder) SetAgentVars(w *world.World, idx int) {
 	b.eval.Set("CyclesInCurrentInteraction", int(a.TimeInInteraction[idx]))
 
 	// Stage/prototype identity
+	b.eval.SetInt("ID", int(a.ID[idx]))
+	b.eval.SetInt("Generation", int(w.Names.Generation(a.ID[idx])))
 	b.eval.Set("NumLifeStage", int(a.StageID[idx]+1)) // 1-based for formulas
 	b.eval.Set("IsAdult", a.StageID[idx] == -1)
 	b.eval.Set("IsMale", a.Sex[idx] == world.SexMale)
 	b.eval.Set("IsFemale", a.Sex[idx] == world.SexFemale)
 
+	// Locomotion
+	b.eval.SetInt("Speed", int(a.Speed[idx]))
+
 	// Physiology: reserves
 	for n := 0; n < cfg.NumNutrients; n++ {
 		reserveIdx := idx*cfg.NumNutrients + n
-		b.eval.SetInt("Reserve"+util.Itoa(n+1), int(a.Reserves[reserveIdx]))
+		b.eval.SetInt(names.reserve[n], int(a.Reserves[reserveIdx]))
 	}
 
 	// Genetics: loci (expressed values = phenotype)
-	for l := 0; l < cfg.NumLoci; l++ {
-		locusBase := idx*cfg.NumLoci*2 + l*2
-		// Continuous loci expression (codominance/dominance)
-		expressed := expressLocusCont(
-			a.GenotypeCont[locusBase], a.GenotypeCont[locusBase+1],
-			a.DominanceCont[locusBase], a.DominanceCont[locusBase+1],
-		)
-		b.eval.SetFloat("CL"+util.Itoa(l+1), expressed)
-
-		// Discrete loci expression
-		expressedDisc := expressLocusDisc(
-			a.GenotypeDisc[locusBase], a.GenotypeDisc[locusBase+1],
-			a.DominanceDisc[locusBase], a.DominanceDisc[locusBase+1],
-		)
-		b.eval.SetInt("DL"+util.Itoa(l+1), expressedDisc)
-	}
+	b.setLoci(a.GenotypeCont, a.GenotypeDisc, a.DominanceCont, a.DominanceDisc, idx*cfg.NumLoci*2)
 
 	// Reproduction
 	b.eval.SetInt("QuantityGametes", int(a.GametesCount[idx]))
@@ -76,34 +138,138 @@ func (b *EnvBuilder) SetAgentVars(w *world.World, idx int) {
 	memPerceptionSlots := cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
 	memBase := idx * memPerceptionSlots
 	for s := 0; s < memPerceptionSlots; s++ {
-		b.eval.SetInt("MemoryLastPer"+util.Itoa(s+1), int(a.MemoryLastPerceived[memBase+s]))
-		b.eval.SetInt("MemoryNumPer"+util.Itoa(s+1), int(a.MemoryNumPerceived[memBase+s]))
-		b.eval.SetInt("MemoryLastInt"+util.Itoa(s+1), int(a.MemoryLastInteracted[memBase+s]))
-		b.eval.SetInt("MemoryNumInt"+util.Itoa(s+1), int(a.MemoryNumInteracted[memBase+s]))
+		b.eval.SetInt(names.memLastPer[s], int(a.MemoryLastPerceived[memBase+s]))
+		b.eval.SetInt(names.memNumPer[s], int(a.MemoryNumPerceived[memBase+s]))
+		b.eval.SetInt(names.memLastInt[s], int(a.MemoryLastInteracted[memBase+s]))
+		b.eval.SetInt(names.memNumInt[s], int(a.MemoryNumInteracted[memBase+s]))
 	}
 
 	// Memory: last behavior
 	memBehaviorBase := idx * cfg.NumBehaviors
 	for bh := 0; bh < cfg.NumBehaviors; bh++ {
-		b.eval.SetInt("MemoryLastBehavior"+util.Itoa(bh+1), int(a.MemoryLastBehavior[memBehaviorBase+bh]))
-		b.eval.SetInt("MemoryNumBehavior"+util.Itoa(bh+1), int(a.MemoryNumBehavior[memBehaviorBase+bh]))
+		b.eval.SetInt(names.memLastBehavior[bh], int(a.MemoryLastBehavior[memBehaviorBase+bh]))
+		b.eval.SetInt(names.memNumBehavior[bh], int(a.MemoryNumBehavior[memBehaviorBase+bh]))
 	}
 
 	// Morphology (fixed adult traits)
 	if a.MorphologyFixed[idx] {
 		for l := 0; l < cfg.NumLoci; l++ {
 			morphBase := idx*cfg.NumLoci + l
-			b.eval.SetFloat("Morphology"+util.Itoa(l+1), a.MorphologyCont[morphBase])
-			b.eval.SetInt("MorphologyDisc"+util.Itoa(l+1), int(a.MorphologyDisc[morphBase]))
+			b.eval.SetFloat(names.morphology[l], a.MorphologyCont[morphBase])
+			b.eval.SetInt(names.morphologyDisc[l], int(a.MorphologyDisc[morphBase]))
 		}
 	}
+
+	b.setTraits()
+}
+
+// SetEggVars populates the env with the variables of egg idx, for the
+// eclosion and mortality formulas. An egg is an immature agent of life
+// stage 0 whose age counts as time in stage; DynamicElementLevel and
+// DynamicElementQuality describe its oviposition site (0 when carried).
+// Its generation follows its parents. Variables without an egg counterpart
+// are neutral: 0, false, and -1 for the memory of when an element was last
+// perceived, interacted with or a behavior performed (never).
+func (b *EnvBuilder) SetEggVars(w *world.World, idx int) {
+	e := w.Eggs
+	cfg := b.cfg
+	names := &b.names
+
+	b.eval.Set("Age", int(e.Age[idx]))
+	b.eval.Set("CyclesInCurrentLifeStage", int(e.Age[idx]))
+	b.eval.Set("CyclesOnSubstrate", 0)
+	b.eval.Set("CyclesInCurrentInteraction", 0)
+	b.eval.SetInt("ID", 0)
+	generation := max(w.Names.Generation(e.ParentMaleID[idx]), w.Names.Generation(e.ParentFemaleID[idx])) + 1
+	b.eval.SetInt("Generation", int(generation))
+	b.eval.Set("NumLifeStage", 0)
+	b.eval.Set("IsAdult", false)
+	b.eval.Set("IsMale", e.Sex[idx] == world.SexMale)
+	b.eval.Set("IsFemale", e.Sex[idx] == world.SexFemale)
+	b.eval.SetInt("Speed", 0)
+
+	for n := 0; n < cfg.NumNutrients; n++ {
+		b.eval.SetInt(names.reserve[n], int(e.Reserves[idx*cfg.NumNutrients+n]))
+	}
+	b.setLoci(e.GenotypeCont, e.GenotypeDisc, e.DominanceCont, e.DominanceDisc, idx*cfg.NumLoci*2)
+
+	// The oviposition site holding the egg, if any.
+	level, quality := 0, 0
+	if site := int(e.CarrierResourceIdx[idx]); site >= 0 && site < w.Resources.Count {
+		level = int(w.Resources.Level[site])
+		quality = int(w.Resources.Quality[site])
+	}
+	b.eval.SetInt("DynamicElementLevel", level)
+	b.eval.SetInt("DynamicElementQuality", quality)
+
+	b.eval.SetInt("QuantityGametes", 0)
+	b.eval.SetInt("QuantityFertilizedEggs", 0)
+	b.eval.SetInt("QuantitySpermPacksStored", 0)
+	b.eval.SetInt("QuantityCarriedEggs", 0)
+	b.eval.Set("Virginity", false)
+	memPerceptionSlots := cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
+	for s := 0; s < memPerceptionSlots; s++ {
+		b.eval.SetInt(names.memLastPer[s], -1)
+		b.eval.SetInt(names.memNumPer[s], 0)
+		b.eval.SetInt(names.memLastInt[s], -1)
+		b.eval.SetInt(names.memNumInt[s], 0)
+	}
+	for bh := 0; bh < cfg.NumBehaviors; bh++ {
+		b.eval.SetInt(names.memLastBehavior[bh], -1)
+		b.eval.SetInt(names.memNumBehavior[bh], 0)
+	}
+	for l := 0; l < cfg.NumLoci; l++ {
+		b.eval.SetFloat(names.morphology[l], 0)
+		b.eval.SetInt(names.morphologyDisc[l], 0)
+	}
+
+	b.setTraits()
+}
+
+// setLoci sets the expressed CL and DL values of a genotype starting at base.
+// A hemizygous locus holds its single allele in both slots and so expresses
+// it under every model.
+func (b *EnvBuilder) setLoci(genoCont []float64, genoDisc []int32, domCont, domDisc []uint8, base int) {
+	for l := 0; l < b.cfg.NumLoci; l++ {
+		locusBase := base + l*2
+		e := b.locusExpression(l)
+		b.eval.SetFloat(b.names.cl[l], e.Cont(
+			genoCont[locusBase], genoCont[locusBase+1],
+			domCont[locusBase], domCont[locusBase+1],
+		))
+		b.eval.SetInt(b.names.dl[l], int(e.Disc(
+			genoDisc[locusBase], genoDisc[locusBase+1],
+			domDisc[locusBase], domDisc[locusBase+1],
+		)))
+	}
+}
+
+// locusExpression returns the expression model of locus l.
+func (b *EnvBuilder) locusExpression(l int) world.Expression {
+	if l >= len(b.expression) {
+		return world.Expression{}
+	}
+	return b.expression[l]
+}
+
+// setTraits evaluates the derived traits in order. A failing trait is 0.
+func (b *EnvBuilder) setTraits() {
+	for _, t := range b.traits {
+		val, err := b.eval.RunProgramFloat(t.program)
+		if err != nil {
+			val = 0
+		}
+		b.eval.SetFloat(t.name, val)
+	}
 }
 
 // SetContenderVars sets variables for the interacting opponent agent.
 func (b *EnvBuilder) SetContenderVars(w *world.World, contenderIdx int) {
 	a := w.Agents
 	cfg := b.cfg
+	names := &b.names
 
+	b.eval.SetInt("ContenderID", int(a.ID[contenderIdx]))
 	b.eval.SetInt("ContenderAge", int(a.Age[contenderIdx]))
 	b.eval.Set("ContenderIsMale", a.Sex[contenderIdx] == world.SexMale)
 	b.eval.Set("ContenderIsFemale", a.Sex[contenderIdx] == world.SexFemale)
@@ -112,19 +278,18 @@ func (b *EnvBuilder) SetContenderVars(w *world.World, contenderIdx int) {
 	if a.MorphologyFixed[contenderIdx] {
 		for l := 0; l < cfg.NumLoci; l++ {
 			morphBase := contenderIdx*cfg.NumLoci + l
-			b.eval.SetFloat("ContenderMorphology"+util.Itoa(l+1), a.MorphologyCont[morphBase])
-			b.eval.SetInt("ContenderMorphologyDisc"+util.Itoa(l+1), int(a.MorphologyDisc[morphBase]))
+			b.eval.SetFloat(names.contenderMorph[l], a.MorphologyCont[morphBase])
+			b.eval.SetInt(names.contenderMorphDisc[l], int(a.MorphologyDisc[morphBase]))
 		}
 	}
 
 	// Contender loci
 	for l := 0; l < cfg.NumLoci; l++ {
 		locusBase := contenderIdx*cfg.NumLoci*2 + l*2
-		expressed := expressLocusCont(
+		b.eval.SetFloat(names.contenderCL[l], b.locusExpression(l).Cont(
 			a.GenotypeCont[locusBase], a.GenotypeCont[locusBase+1],
 			a.DominanceCont[locusBase], a.DominanceCont[locusBase+1],
-		)
-		b.eval.SetFloat("ContenderCL"+util.Itoa(l+1), expressed)
+		))
 	}
 }
 
@@ -134,33 +299,3 @@ func (b *EnvBuilder) SetResourceVars(w *world.World, resourceIdx int) {
 	b.eval.SetInt("DynamicElementLevel", int(r.Level[resourceIdx]))
 	b.eval.SetInt("DynamicElementQuality", int(r.Quality[resourceIdx]))
 }
-
-// --- Genetic expression helpers ---
-
-// expressLocusCont calculates the expressed phenotype for a continuous locus.
-// Dominance rules: both dominant = codominance (average), heterozygous = dominant wins.
-func expressLocusCont(patVal, matVal float64, patDom, matDom uint8) float64 {
-	bothDom := patDom == 1 && matDom == 1
-	bothRec := patDom == 0 && matDom == 0
-	if bothDom || bothRec {
-		// Codominance: average.
-		return (patVal + matVal) / 2.0
-	}
-	if patDom == 1 {
-		return patVal // Paternal dominance.
-	}
-	return matVal // Maternal dominance.
-}
-
-// expressLocusDisc calculates the expressed phenotype for a discrete locus.
-func expressLocusDisc(patVal, matVal int32, patDom, matDom uint8) int {
-	bothDom := patDom == 1 && matDom == 1
-	bothRec := patDom == 0 && matDom == 0
-	if bothDom || bothRec {
-		return int((patVal + matVal) / 2)
-	}
-	if patDom == 1 {
-		return int(patVal)
-	}
-	return int(matVal)
-}
@@ -91,6 +91,9 @@ func (e *Evaluator) RunProgramInt(p *Program) (int, error) {
 	if p == nil {
 		return 0, nil
 	}
+	if p.constant {
+		return int(p.value), nil
+	}
 	return e.RunInt(p.Compiled)
 }
 
@@ -99,6 +102,9 @@ func (e *Evaluator) RunProgramFloat(p *Program) (float64, error) {
 	if p == nil {
 		return 0, nil
 	}
+	if p.constant {
+		return p.value, nil
+	}
 	return e.RunFloat(p.Compiled)
 }
 
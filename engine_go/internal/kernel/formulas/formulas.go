@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"math"
 	"math/rand/v2"
+	"strconv"
+	"strings"
 
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
@@ -16,24 +18,44 @@ import (
 type Program struct {
 	Source   string
 	Compiled *vm.Program
+
+	// Numeric literal formulas ("15", "-2.5") are folded at compile time so
+	// the Hot Path can skip the VM for them.
+	constant bool
+	value    float64
+}
+
+// Constant reports whether the program is a numeric literal and returns its value.
+func (p *Program) Constant() (float64, bool) {
+	return p.value, p.constant
 }
 
 // Registry holds all compiled formula programs indexed by a string key.
-// Keys follow the pattern: "category.entity.field" (e.g., "prototype.1.longevity").
+// Keys follow the pattern: "category.entity.field" (e.g., "prototype.1.longevity");
+// the full scheme used for project formulas is documented in package kernel.
 type Registry struct {
 	programs map[string]*Program
 	options  []expr.Option
+	rand     *rand.Rand // Source of Random, RandG and Dice.
 }
 
 // NewRegistry creates a new formula registry with standard custom functions registered.
+// Its random functions draw from a randomly seeded stream until SetRand is called.
 func NewRegistry() *Registry {
 	r := &Registry{
 		programs: make(map[string]*Program),
+		rand:     rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
 	}
-	r.options = buildOptions()
+	r.options = r.buildOptions()
 	return r
 }
 
+// SetRand makes the random functions of every program of the registry,
+// compiled or not, draw from src.
+func (r *Registry) SetRand(src *rand.Rand) {
+	r.rand = src
+}
+
 // Compile compiles a formula string and stores it in the registry under the given key.
 // If the formula is empty or "0", it still gets compiled (evaluates to 0).
 func (r *Registry) Compile(key, formula string) error {
@@ -46,9 +68,12 @@ func (r *Registry) Compile(key, formula string) error {
 		return fmt.Errorf("compile formula %q (key=%s): %w", formula, key, err)
 	}
 
+	value, err := strconv.ParseFloat(strings.TrimSpace(formula), 64)
 	r.programs[key] = &Program{
 		Source:   formula,
 		Compiled: program,
+		constant: err == nil && !math.IsNaN(value) && !math.IsInf(value, 0),
+		value:    value,
 	}
 	return nil
 }
@@ -82,12 +107,19 @@ func (r *Registry) Keys() []string {
 }
 
 // buildOptions returns the expr compilation options with custom functions.
-func buildOptions() []expr.Option {
+// The random functions read the registry's stream at call time.
+func (r *Registry) buildOptions() []expr.Option {
 	return []expr.Option{
 		expr.AllowUndefinedVariables(),
-		expr.Function("Random", funcRandom),
-		expr.Function("RandG", funcRandG, new(func(float64, float64) float64)),
-		expr.Function("Dice", funcDice, new(func(int) int)),
+		expr.Function("Random", func(params ...any) (any, error) {
+			return funcRandom(r.rand, params...)
+		}),
+		expr.Function("RandG", func(params ...any) (any, error) {
+			return funcRandG(r.rand, params...)
+		}, new(func(float64, float64) float64)),
+		expr.Function("Dice", func(params ...any) (any, error) {
+			return funcDice(r.rand, params...)
+		}, new(func(int) int)),
 		expr.Function("Max", funcMax, new(func(float64, float64) float64)),
 		expr.Function("Min", funcMin, new(func(float64, float64) float64)),
 		expr.Function("Abs", funcAbs, new(func(float64) float64)),
@@ -99,25 +131,25 @@ func buildOptions() []expr.Option {
 // --- Custom Functions ---
 
 // funcRandom returns a uniform random float in [0, 1).
-func funcRandom(params ...any) (any, error) {
-	return rand.Float64(), nil
+func funcRandom(src *rand.Rand, params ...any) (any, error) {
+	return src.Float64(), nil
 }
 
 // funcRandG returns a Gaussian random number with given mean and stddev.
 // Uses the Marsaglia-Bray polar method (same algorithm as the legacy system).
-func funcRandG(params ...any) (any, error) {
+func funcRandG(src *rand.Rand, params ...any) (any, error) {
 	mean := toFloat64(params[0])
 	stddev := toFloat64(params[1])
-	return rand.NormFloat64()*stddev + mean, nil
+	return src.NormFloat64()*stddev + mean, nil
 }
 
 // funcDice returns a random integer from 1 to faces (inclusive).
-func funcDice(params ...any) (any, error) {
+func funcDice(src *rand.Rand, params ...any) (any, error) {
 	faces := toInt(params[0])
 	if faces <= 0 {
 		return 1, nil
 	}
-	return rand.IntN(faces) + 1, nil
+	return src.IntN(faces) + 1, nil
 }
 
 // funcMax returns the larger of two values.
@@ -137,6 +137,28 @@ func TestCustomFuncDice(t *testing.T) {
 	}
 }
 
+func TestRegistrySetRand(t *testing.T) {
+	// Two registries drawing from equally seeded streams agree draw for draw,
+	// including programs compiled before SetRand.
+	draw := func() []float64 {
+		reg := NewRegistry()
+		reg.Compile("test.rnd", "Random() + Dice(6) + RandG(0, 1)")
+		reg.SetRand(world.NewStreams(7).Formulas)
+		eval := NewEvaluator(16)
+		out := make([]float64, 10)
+		for i := range out {
+			out[i], _ = eval.RunProgramFloat(reg.Get("test.rnd"))
+		}
+		return out
+	}
+	a, b := draw(), draw()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("draw %d: %f != %f", i, a[i], b[i])
+		}
+	}
+}
+
 func TestCustomFuncMath(t *testing.T) {
 	reg := NewRegistry()
 	eval := NewEvaluator(16)
@@ -191,6 +213,31 @@ func TestEmptyFormula(t *testing.T) {
 	}
 }
 
+func TestConstantFolding(t *testing.T) {
+	reg := NewRegistry()
+	reg.Compile("lit", " 12.5 ")
+	reg.Compile("expr", "10 + 2.5")
+	reg.Compile("nan", "NaN")
+
+	if v, ok := reg.Get("lit").Constant(); !ok || v != 12.5 {
+		t.Fatalf("expected literal 12.5 to be constant, got %v %v", v, ok)
+	}
+	if _, ok := reg.Get("expr").Constant(); ok {
+		t.Fatal("an expression should not be folded")
+	}
+	if _, ok := reg.Get("nan").Constant(); ok {
+		t.Fatal("NaN is a variable name, not a literal")
+	}
+
+	eval := NewEvaluator(16)
+	if v, _ := eval.RunProgramInt(reg.Get("lit")); v != 12 {
+		t.Fatalf("expected folded int 12, got %d", v)
+	}
+	if v, _ := eval.RunProgramFloat(reg.Get("lit")); v != 12.5 {
+		t.Fatalf("expected folded float 12.5, got %v", v)
+	}
+}
+
 func TestUndefinedVariableReturnsZero(t *testing.T) {
 	reg := NewRegistry()
 	// AllowUndefinedVariables is set, so undefined vars evaluate to nil/zero.
@@ -256,6 +303,7 @@ func TestEnvBuilderSetAgentVars(t *testing.T) {
 	}
 
 	w := world.New(cfg)
+	w.AddAgent()
 	idx := w.AddAgent()
 	w.Agents.Age[idx] = 42
 	w.Agents.Sex[idx] = world.SexMale
@@ -282,6 +330,9 @@ func TestEnvBuilderSetAgentVars(t *testing.T) {
 	if env["Age"] != 42 {
 		t.Fatalf("Age: expected 42, got %v", env["Age"])
 	}
+	if env["ID"] != 2 {
+		t.Fatalf("ID: expected 2, got %v", env["ID"])
+	}
 	if env["Cycles"] != 100 {
 		t.Fatalf("Cycles: expected 100, got %v", env["Cycles"])
 	}
@@ -294,6 +345,9 @@ func TestEnvBuilderSetAgentVars(t *testing.T) {
 	if env["Reserve2"] != 60 {
 		t.Fatalf("Reserve2: expected 60, got %v", env["Reserve2"])
 	}
+	if env["Speed"] != 1 {
+		t.Fatalf("Speed: expected 1, got %v", env["Speed"])
+	}
 	// CL1 should be paternal value (dominant), so 1.5.
 	if env["CL1"] != 1.5 {
 		t.Fatalf("CL1: expected 1.5, got %v", env["CL1"])
@@ -311,6 +365,76 @@ func TestEnvBuilderSetAgentVars(t *testing.T) {
 	}
 }
 
+func TestEnvBuilderSetEggVarsClearsAgentVars(t *testing.T) {
+	cfg := world.Config{NumNutrients: 1, NumLoci: 1, NumPrototypes: 1, NumBehaviors: 2, InitialCapacity: 4}
+	w := world.New(cfg)
+	idx := w.AddAgent()
+	w.Agents.FertilizedCount[idx] = 3
+	w.Agents.MemoryLastPerceived[0] = 5
+	w.Agents.MemoryLastBehavior[1] = 2
+	w.Agents.MorphologyFixed[idx] = true
+	w.Agents.MorphologyCont[idx] = 1.5
+	w.Names.Set(w.Agents.ID[idx], "Eve", 2)
+	w.Eggs.Count = 1
+	w.Eggs.Age[0] = 4
+	w.Eggs.ParentFemaleID[0] = w.Agents.ID[idx]
+
+	eval := NewEvaluator(64)
+	builder := NewEnvBuilder(eval, cfg)
+	builder.SetAgentVars(w, idx)
+	builder.SetEggVars(w, 0)
+
+	env := eval.Env()
+	if env["Age"] != 4 || env["ID"] != 0 || env["Speed"] != 0 {
+		t.Fatalf("Age/ID/Speed: expected 4/0/0, got %v/%v/%v", env["Age"], env["ID"], env["Speed"])
+	}
+	if env["Generation"] != 3 {
+		t.Fatalf("Generation: expected 3, got %v", env["Generation"])
+	}
+	if env["QuantityFertilizedEggs"] != 0 {
+		t.Fatalf("QuantityFertilizedEggs: expected 0, got %v", env["QuantityFertilizedEggs"])
+	}
+	if env["MemoryLastPer1"] != -1 || env["MemoryLastBehavior2"] != -1 {
+		t.Fatalf("memory: expected -1, got %v and %v", env["MemoryLastPer1"], env["MemoryLastBehavior2"])
+	}
+	if env["Morphology1"] != 0.0 {
+		t.Fatalf("Morphology1: expected 0, got %v", env["Morphology1"])
+	}
+}
+
+func TestEnvBuilderExpressionAndTraits(t *testing.T) {
+	cfg := world.Config{NumNutrients: 1, NumLoci: 2, NumPrototypes: 1, NumBehaviors: 1, InitialCapacity: 4}
+	w := world.New(cfg)
+	idx := w.AddAgent()
+	// Locus 1: dominant 4 over recessive 2. Locus 2: 1 and 3, equal dominance.
+	copy(w.Agents.GenotypeCont, []float64{4, 2, 1, 3})
+	copy(w.Agents.DominanceCont, []uint8{1, 0, 0, 0})
+
+	eval := NewEvaluator(64)
+	builder := NewEnvBuilder(eval, cfg)
+	builder.SetExpression([]world.Expression{
+		{Model: world.ExpressionPartial, H: 0.25},
+		{Model: world.ExpressionOverdominant, H: 0.5},
+	})
+	reg := NewRegistry()
+	reg.Compile("trait.0", "CL1 * CL2")
+	reg.Compile("trait.1", "TraitBody + 1")
+	builder.AddTrait("Body", reg.Get("trait.0"))
+	builder.AddTrait("Horn", reg.Get("trait.1"))
+	builder.SetAgentVars(w, idx)
+
+	env := eval.Env()
+	if env["CL1"] != 2.5 { // 2 + 0.25*(4-2)
+		t.Fatalf("CL1: expected 2.5, got %v", env["CL1"])
+	}
+	if env["CL2"] != 3.0 { // 2 + 0.5*|1-3|
+		t.Fatalf("CL2: expected 3, got %v", env["CL2"])
+	}
+	if env["TraitBody"] != 7.5 || env["TraitHorn"] != 8.5 {
+		t.Fatalf("traits: expected 7.5 and 8.5, got %v and %v", env["TraitBody"], env["TraitHorn"])
+	}
+}
+
 func TestPerformance(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping performance test in short mode")
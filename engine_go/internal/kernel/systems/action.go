@@ -1,31 +1,55 @@
 package systems
 
 import (
-	"math/rand/v2"
-
+	"galatea/engine/internal/kernel/formulas"
 	"galatea/engine/internal/kernel/world"
 )
 
+// ActionContext holds the data needed to execute behaviors.
+// It is created once per tick and reused across all agents.
+type ActionContext struct {
+	World      *world.World
+	Eval       *formulas.Evaluator
+	EnvBuilder *formulas.EnvBuilder
+
+	// Feeding gain formula per resource type (optional). A nil program
+	// takes Speed units per feeding.
+	Gains []*formulas.Program
+
+	// Reproduction and genetics parameters for oviposition (optional).
+	// Without them, the oviposit behavior lays nothing.
+	Reproduction *ReproductionConfig
+	Genetics     *GeneticsConfig
+}
+
 // Act executes the decided behavior for the agent at idx.
 // It modifies world state according to the behavior type.
-func Act(w *world.World, idx int) {
+func Act(ctx *ActionContext, idx int) {
+	w := ctx.World
 	a := w.Agents
 	a.State[idx] = world.StateActing
 	decision := int(a.Decision[idx])
 
 	switch {
+	case a.Situation[idx] == world.SituationCombat:
+		actCombatSignal(w, idx)
+	case a.Situation[idx] == world.SituationCourtship:
+		// Combat retreat and courtship accept share their code with
+		// oviposit, and courtship reject with die, so interaction
+		// signals are told apart by the agent's situation.
+		actCourtshipSignal(w, idx)
+	case decision == ovipositBehaviorIdx(w.Config):
+		actOviposit(ctx, idx)
 	case decision == behaviorMove:
 		actMove(w, idx)
 	case decision == behaviorRest:
 		// Rest: do nothing (agent stays in place).
 	case decision >= behaviorOffsetFeed && decision < behaviorOffsetFeed+w.Config.NumResourceTypes:
-		actFeed(w, idx)
+		actFeed(ctx, idx)
 	case isCombatBehavior(decision, w.Config):
 		actCombatSignal(w, idx)
 	case isCourtshipBehavior(decision, w.Config):
 		actCourtshipSignal(w, idx)
-	case decision == ovipositBehaviorIdx(w.Config):
-		actOviposit(w, idx)
 	default:
 		// Unknown or die — handled by physiology.
 	}
@@ -41,7 +65,7 @@ func actMove(w *world.World, idx int) {
 	tendencies := a.Tendencies[tendBase : tendBase+8]
 
 	// Select direction via roulette on tendencies.
-	chosenDir := Roulette(tendencies)
+	chosenDir := Roulette(w.Rand.Movement, tendencies)
 
 	// Convert relative direction to absolute.
 	absDir := absoluteDirection(a.Direction[idx], uint8(chosenDir+1))
@@ -73,10 +97,19 @@ func actMove(w *world.World, idx int) {
 
 	a.PosX[idx] = newX
 	a.PosY[idx] = newY
+
+	// Restart the substrate counter when stepping onto a different substrate.
+	if s := int32(w.Substrates.SubstrateAt(newX, newY)); s != a.Substrate[idx] {
+		a.Substrate[idx] = s
+		a.TimeOnSubstrate[idx] = 0
+	}
 }
 
-// actFeed transfers resources from the interactant resource to the agent's reserves.
-func actFeed(w *world.World, idx int) {
+// actFeed transfers resources from the interactant resource to the reserve of
+// the nutrient linked to its type. The intake comes from the type's gain formula,
+// which sees the agent and the resource (DynamicElementLevel, DynamicElementQuality).
+func actFeed(ctx *ActionContext, idx int) {
+	w := ctx.World
 	a := w.Agents
 	r := w.Resources
 	cfg := w.Config
@@ -86,29 +119,66 @@ func actFeed(w *world.World, idx int) {
 		return // No valid resource to feed from.
 	}
 
-	resourceType := int(r.TypeID[interactant])
-	if resourceType < 0 || resourceType >= cfg.NumNutrients {
-		return // Resource type doesn't map to a nutrient.
+	nutrient := resourceNutrient(w, int(r.TypeID[interactant]))
+	if nutrient < 0 {
+		return // Resource type is not feedable.
 	}
 
-	// Transfer: take up to speed units from resource, cap at available level.
-	amount := a.Speed[idx] // Use speed as a proxy for feeding rate.
+	amount := feedingIntake(ctx, idx, int(interactant))
 	if amount <= 0 {
-		amount = 1
+		return
 	}
 	available := r.Level[interactant]
 	if amount > available {
 		amount = available
 	}
 
-	// Add to reserves (simple: resource type index = nutrient index).
-	reserveIdx := idx*cfg.NumNutrients + resourceType
+	// Take no more than fits below the agent's maximum level.
+	reserveIdx := idx*cfg.NumNutrients + nutrient
+	if room := a.ReserveMax[reserveIdx] - a.Reserves[reserveIdx]; amount > room {
+		amount = max(room, 0)
+	}
 	a.Reserves[reserveIdx] += amount
 
 	// Deplete resource.
 	r.Level[interactant] -= amount
 }
 
+// feedingIntake returns the units agent idx takes from resource rIdx.
+func feedingIntake(ctx *ActionContext, idx, rIdx int) int32 {
+	w := ctx.World
+	resourceType := int(w.Resources.TypeID[rIdx])
+	if resourceType < len(ctx.Gains) {
+		if p := ctx.Gains[resourceType]; p != nil {
+			ctx.EnvBuilder.SetAgentVars(w, idx)
+			ctx.EnvBuilder.SetResourceVars(w, rIdx)
+			val, err := ctx.Eval.RunProgramInt(p)
+			if err != nil {
+				return 0
+			}
+			return int32(val)
+		}
+	}
+	// No gain formula: use speed as a proxy for feeding rate.
+	if a := w.Agents; a.Speed[idx] > 0 {
+		return a.Speed[idx]
+	}
+	return 1
+}
+
+// resourceNutrient returns the nutrient fed by a resource type, or -1.
+func resourceNutrient(w *world.World, resourceType int) int {
+	if resourceType < 0 || resourceType >= len(w.ResourceNutrient) {
+		return -1
+	}
+	return int(w.ResourceNutrient[resourceType])
+}
+
+// isOvipositionSite reports whether a resource type is an oviposition site.
+func isOvipositionSite(w *world.World, resourceType int) bool {
+	return resourceType >= 0 && resourceType < len(w.ResourceOviposition) && w.ResourceOviposition[resourceType]
+}
+
 // actCombatSignal signals the opponent with the chosen combat action.
 func actCombatSignal(w *world.World, idx int) {
 	a := w.Agents
@@ -130,6 +200,7 @@ func actCombatSignal(w *world.World, idx int) {
 		winCombat(a, int(interactant))
 		a.Situation[idx] = world.SituationRegular
 		a.InteractantIdx[idx] = -1
+		a.RefractoryCombat[idx] = world.RefractoryPending
 		return
 	}
 
@@ -168,25 +239,48 @@ func actCourtshipSignal(w *world.World, idx int) {
 	}
 }
 
-// actOviposit placeholder — actual oviposition logic is in reproduction system.
-func actOviposit(w *world.World, idx int) {
-	// Oviposition is handled in the reproduction system (Task 8).
-	// Here we just mark the behavior as executed.
-	_ = w
-	_ = idx
+// actOviposit lays the fertilized eggs of a female in the oviposition site
+// she interacts with. Without a site she keeps carrying the eggs at her
+// position until they eclose.
+func actOviposit(ctx *ActionContext, idx int) {
+	w := ctx.World
+	a := w.Agents
+	if ctx.Reproduction == nil || a.Sex[idx] != world.SexFemale || eggsReady(a, idx, ctx.Genetics) <= 0 {
+		return
+	}
+	var genCfg GeneticsConfig
+	if ctx.Genetics != nil {
+		genCfg = *ctx.Genetics
+	}
+	if ctx.EnvBuilder != nil {
+		ctx.EnvBuilder.SetAgentVars(w, idx)
+	}
+	site := -1
+	if r := int(a.InteractantIdx[idx]); r >= 0 && r < w.Resources.Count && isOvipositionSite(w, int(w.Resources.TypeID[r])) {
+		site = r
+	}
+	Oviposit(w, idx, site, ctx.Eval, ctx.Reproduction.For(ctx.Eval, world.SexFemale), genCfg)
 }
 
 // --- Combat/Courtship resolution helpers ---
 
-// winCombat resolves a combat victory for the given agent.
+// winCombat resolves a combat victory for the given agent. An agent that
+// was actually fighting starts its refractory period.
 func winCombat(a *world.AgentArrays, winnerIdx int) {
+	if a.Situation[winnerIdx] == world.SituationCombat {
+		a.RefractoryCombat[winnerIdx] = world.RefractoryPending
+	}
 	a.Situation[winnerIdx] = world.SituationRegular
 	a.InteractantIdx[winnerIdx] = -1
 	a.TimeInInteraction[winnerIdx] = 0
 }
 
 // rejectCourtship returns an agent to regular state after courtship ends.
+// An agent that was actually courting starts its refractory period.
 func rejectCourtship(a *world.AgentArrays, idx int) {
+	if a.Situation[idx] == world.SituationCourtship {
+		a.RefractoryCourtship[idx] = world.RefractoryPending
+	}
 	a.Situation[idx] = world.SituationRegular
 	a.InteractantIdx[idx] = -1
 	a.TimeInInteraction[idx] = 0
@@ -209,18 +303,75 @@ func updateBehaviorMemory(a *world.AgentArrays, idx, behavior, numBehaviors int)
 	}
 }
 
+// The perception memory of an agent has one slot per substrate, then per
+// resource type, then per perceiver index of the agents it meets.
+
+func perceptionMemorySlots(cfg world.Config) int {
+	return cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
+}
+
+func resourceMemorySlot(cfg world.Config, resourceType int) int {
+	return cfg.NumSubstrates + resourceType
+}
+
+func agentMemorySlot(cfg world.Config, perceiverIdx int) int {
+	return cfg.NumSubstrates + cfg.NumResourceTypes + perceiverIdx
+}
+
+// rememberPerceived records that agent idx perceived the element of the
+// given memory slot this tick: zero ticks ago.
+func rememberPerceived(w *world.World, idx, slot int) {
+	slots := perceptionMemorySlots(w.Config)
+	if slot < 0 || slot >= slots {
+		return
+	}
+	a := w.Agents
+	a.MemoryLastPerceived[idx*slots+slot] = 0
+	a.MemoryNumPerceived[idx*slots+slot]++
+}
+
+// rememberInteracted records that agent idx started an interaction with the
+// element of the given memory slot this tick.
+func rememberInteracted(w *world.World, idx, slot int) {
+	slots := perceptionMemorySlots(w.Config)
+	if slot < 0 || slot >= slots {
+		return
+	}
+	a := w.Agents
+	a.MemoryLastInteracted[idx*slots+slot] = 0
+	a.MemoryNumInteracted[idx*slots+slot]++
+}
+
+// agePerceptionMemory counts one more tick since agent idx last perceived
+// or interacted with each element it has met.
+func agePerceptionMemory(w *world.World, idx int) {
+	a := w.Agents
+	slots := perceptionMemorySlots(w.Config)
+	base := idx * slots
+	for s := base; s < base+slots; s++ {
+		if a.MemoryLastPerceived[s] >= 0 {
+			a.MemoryLastPerceived[s]++
+		}
+		if a.MemoryLastInteracted[s] >= 0 {
+			a.MemoryLastInteracted[s]++
+		}
+	}
+}
+
 // --- Behavior index helpers ---
 
+// isCombatBehavior reports whether a regular agent's decision starts combat
+// (display or escalate).
 func isCombatBehavior(decision int, cfg world.Config) bool {
 	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
-	retreatIdx := fightDisplayIdx + 4
-	return decision >= fightDisplayIdx && decision <= retreatIdx &&
-		decision < fightDisplayIdx+2 || decision == retreatIdx
+	return decision >= fightDisplayIdx && decision < fightDisplayIdx+2
 }
 
+// isCourtshipBehavior reports whether a regular agent's decision starts
+// courtship (display or escalate).
 func isCourtshipBehavior(decision int, cfg world.Config) bool {
 	courtDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes + 2
-	return decision >= courtDisplayIdx && decision < courtDisplayIdx+4
+	return decision >= courtDisplayIdx && decision < courtDisplayIdx+2
 }
 
 func ovipositBehaviorIdx(cfg world.Config) int {
@@ -244,9 +395,9 @@ func MovementDirection(w *world.World, idx int) uint8 {
 		}
 	}
 	if allZero {
-		return angleDirTable[rand.IntN(8)]
+		return angleDirTable[w.Rand.Movement.IntN(8)]
 	}
 
-	chosenDir := Roulette(tendencies)
+	chosenDir := Roulette(w.Rand.Movement, tendencies)
 	return absoluteDirection(a.Direction[idx], uint8(chosenDir+1))
 }
@@ -3,6 +3,7 @@ package systems
 import (
 	"testing"
 
+	"galatea/engine/internal/kernel/formulas"
 	"galatea/engine/internal/kernel/world"
 )
 
@@ -21,7 +22,7 @@ func TestActMoveChangesPosition(t *testing.T) {
 	tendBase := idx * 8
 	w.Agents.Tendencies[tendBase+DirN] = 100
 
-	Act(w, idx)
+	Act(&ActionContext{World: w}, idx)
 
 	// Agent should have moved north (Y decreases).
 	if w.Agents.PosY[idx] >= 25 {
@@ -32,6 +33,29 @@ func TestActMoveChangesPosition(t *testing.T) {
 	}
 }
 
+func TestActMoveResetsTimeOnSubstrate(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	w.Substrates.Set(25, 24, 2) // Substrate index 1 just north of the agent.
+
+	idx := w.AddAgent()
+	w.Agents.PosX[idx] = 25
+	w.Agents.PosY[idx] = 25
+	w.Agents.Direction[idx] = 2 // North
+	w.Agents.Decision[idx] = behaviorMove
+	w.Agents.Tendencies[idx*8+DirN] = 100
+	w.Agents.TimeOnSubstrate[idx] = 7
+
+	Act(&ActionContext{World: w}, idx)
+
+	if w.Agents.Substrate[idx] != 1 {
+		t.Fatalf("expected substrate 1, got %d", w.Agents.Substrate[idx])
+	}
+	if w.Agents.TimeOnSubstrate[idx] != 0 {
+		t.Fatalf("expected TimeOnSubstrate reset, got %d", w.Agents.TimeOnSubstrate[idx])
+	}
+}
+
 func TestActMoveClampsToBounds(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -47,7 +71,7 @@ func TestActMoveClampsToBounds(t *testing.T) {
 	tendBase := idx * 8
 	w.Agents.Tendencies[tendBase+DirN] = 100
 
-	Act(w, idx)
+	Act(&ActionContext{World: w}, idx)
 
 	// Should be clamped to 0.
 	if w.Agents.PosX[idx] < 0 || w.Agents.PosY[idx] < 0 {
@@ -64,7 +88,7 @@ func TestActFeedTransfersResources(t *testing.T) {
 	w.Agents.PosY[idx] = 10
 	w.Agents.Speed[idx] = 5
 	w.Agents.Decision[idx] = uint8(behaviorOffsetFeed) // Feed from resource type 0.
-	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 20    // Current water reserve.
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 20     // Current water reserve.
 
 	// Place resource of type 0 with level 50.
 	w.Resources.PosX[0] = 10
@@ -75,7 +99,7 @@ func TestActFeedTransfersResources(t *testing.T) {
 
 	w.Agents.InteractantIdx[idx] = 0
 
-	Act(w, idx)
+	Act(&ActionContext{World: w}, idx)
 
 	// Agent should have gained resources (speed=5 units transferred).
 	expectedReserve := int32(20 + 5)
@@ -106,7 +130,7 @@ func TestActFeedLimitedByResourceLevel(t *testing.T) {
 
 	w.Agents.InteractantIdx[idx] = 0
 
-	Act(w, idx)
+	Act(&ActionContext{World: w}, idx)
 
 	// Should only take 3 (limited by resource level).
 	if w.Agents.Reserves[idx*cfg.NumNutrients+0] != 3 {
@@ -117,6 +141,81 @@ func TestActFeedLimitedByResourceLevel(t *testing.T) {
 	}
 }
 
+func TestActFeedCappedByReserveMax(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	idx := w.AddAgent()
+	w.Agents.Speed[idx] = 10
+	w.Agents.Decision[idx] = uint8(behaviorOffsetFeed)
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 18
+	w.Agents.ReserveMax[idx*cfg.NumNutrients+0] = 20
+
+	w.Resources.TypeID[0] = 0
+	w.Resources.Level[0] = 50
+	w.Resources.Count = 1
+
+	w.Agents.InteractantIdx[idx] = 0
+
+	Act(&ActionContext{World: w}, idx)
+
+	// Only 2 units fit below the maximum; the rest stays in the resource.
+	if w.Agents.Reserves[idx*cfg.NumNutrients+0] != 20 {
+		t.Fatalf("expected reserve=20, got %d", w.Agents.Reserves[idx*cfg.NumNutrients+0])
+	}
+	if w.Resources.Level[0] != 48 {
+		t.Fatalf("expected resource level=48, got %d", w.Resources.Level[0])
+	}
+}
+
+func TestActFeedUsesGainFormulaAndNutrientMap(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	// Resource type 0 feeds nutrient 1; type 1 feeds nothing.
+	w.ResourceNutrient[0] = 1
+	w.ResourceNutrient[1] = -1
+
+	idx := w.AddAgent()
+	w.Agents.Decision[idx] = uint8(behaviorOffsetFeed)
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 10
+
+	w.Resources.TypeID[0] = 0
+	w.Resources.Level[0] = 50
+	w.Resources.Quality[0] = 4
+	w.Resources.Count = 1
+	w.Agents.InteractantIdx[idx] = 0
+
+	reg := formulas.NewRegistry()
+	reg.Compile("gain.0", "DynamicElementQuality * 3")
+	eval := formulas.NewEvaluator(64)
+	ctx := &ActionContext{
+		World:      w,
+		Eval:       eval,
+		EnvBuilder: formulas.NewEnvBuilder(eval, cfg),
+		Gains:      []*formulas.Program{reg.Get("gain.0"), nil},
+	}
+
+	Act(ctx, idx)
+
+	if got := w.Agents.Reserves[idx*cfg.NumNutrients+1]; got != 22 {
+		t.Fatalf("expected nutrient 1 reserve=22, got %d", got)
+	}
+	if got := w.Agents.Reserves[idx*cfg.NumNutrients+0]; got != 0 {
+		t.Fatalf("expected nutrient 0 untouched, got %d", got)
+	}
+	if w.Resources.Level[0] != 38 {
+		t.Fatalf("expected resource level=38, got %d", w.Resources.Level[0])
+	}
+
+	// A resource type without a nutrient cannot be fed from.
+	w.Resources.TypeID[0] = 1
+	w.Agents.Decision[idx] = uint8(behaviorOffsetFeed + 1)
+	Act(ctx, idx)
+	if w.Resources.Level[0] != 38 {
+		t.Fatalf("expected non-feedable resource untouched, got %d", w.Resources.Level[0])
+	}
+}
+
 func TestActCombatRetreat(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -133,7 +232,7 @@ func TestActCombatRetreat(t *testing.T) {
 	w.Agents.InteractantIdx[idx0] = int32(idx1)
 	w.Agents.InteractantIdx[idx1] = int32(idx0)
 
-	Act(w, idx0)
+	Act(&ActionContext{World: w}, idx0)
 
 	// Retreater returns to regular.
 	if w.Agents.Situation[idx0] != world.SituationRegular {
@@ -159,7 +258,7 @@ func TestActCourtshipReject(t *testing.T) {
 	w.Agents.InteractantIdx[idx0] = int32(idx1)
 	w.Agents.InteractantIdx[idx1] = int32(idx0)
 
-	Act(w, idx0)
+	Act(&ActionContext{World: w}, idx0)
 
 	if w.Agents.Situation[idx0] != world.SituationRegular {
 		t.Fatalf("rejecter should be regular, got %d", w.Agents.Situation[idx0])
@@ -169,6 +268,158 @@ func TestActCourtshipReject(t *testing.T) {
 	}
 }
 
+func TestActCourtshipAccept(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	idx0 := w.AddAgent()
+	w.Agents.Situation[idx0] = world.SituationCourtship
+	courtAcceptIdx := behaviorOffsetFeed + cfg.NumResourceTypes + 2 + courtshipAccept
+	w.Agents.Decision[idx0] = uint8(courtAcceptIdx)
+
+	idx1 := w.AddAgent()
+	w.Agents.Situation[idx1] = world.SituationCourtship
+	w.Agents.InteractantIdx[idx0] = int32(idx1)
+	w.Agents.InteractantIdx[idx1] = int32(idx0)
+
+	Act(&ActionContext{World: w}, idx0)
+
+	if w.Agents.LastOpponentAction[idx1] != 3 {
+		t.Fatalf("partner should see the accept signal 3, got %d", w.Agents.LastOpponentAction[idx1])
+	}
+	if w.Agents.Situation[idx0] != world.SituationCourtship || w.Agents.Situation[idx1] != world.SituationCourtship {
+		t.Fatal("accepting should keep both agents courting")
+	}
+	if w.Agents.RefractoryCombat[idx0] != 0 || w.Agents.RefractoryCombat[idx1] != 0 {
+		t.Fatal("accepting should not start a combat refractory period")
+	}
+}
+
+func TestCourtshipAcceptLeadsToCopulation(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	male := w.AddAgent()
+	w.Agents.Sex[male] = world.SexMale
+	w.Agents.GametesCount[male] = 4
+	female := w.AddAgent()
+	w.Agents.Sex[female] = world.SexFemale
+	w.Agents.GametesCount[female] = 10
+	for _, idx := range []int{male, female} {
+		w.Agents.Situation[idx] = world.SituationCourtship
+		w.Agents.InteractionWeights[idx*world.InteractionActions+courtshipAccept] = 100
+	}
+	w.Agents.InteractantIdx[male] = int32(female)
+	w.Agents.InteractantIdx[female] = int32(male)
+
+	reproCfg := ReproductionConfig{PacksTransferred: 2, MaxStoredPacks: 5, FractionFertilized: 0.5}
+	ctx := &ActionContext{World: w, Reproduction: &reproCfg}
+	for tick := 0; tick < 3 && w.Agents.FertilizedCount[female] == 0; tick++ {
+		for _, idx := range []int{male, female} {
+			w.Agents.State[idx] = world.StateUndecided
+			Decide(w, idx)
+			Act(ctx, idx)
+		}
+		ResolveCourtshipDynamics(w, nil, nil, 10, reproCfg, GeneticsConfig{})
+	}
+
+	if w.Agents.FertilizedCount[female] != 5 {
+		t.Fatalf("expected 5 fertilized gametes, got %d", w.Agents.FertilizedCount[female])
+	}
+	if w.Agents.SpermPacksCount[female] != 2 {
+		t.Fatalf("expected 2 stored packs, got %d", w.Agents.SpermPacksCount[female])
+	}
+	for _, idx := range []int{male, female} {
+		if w.Agents.Situation[idx] != world.SituationRegular {
+			t.Fatalf("agent %d should be regular after copulating, got %d", idx, w.Agents.Situation[idx])
+		}
+		if w.Agents.RefractoryCombat[idx] != 0 {
+			t.Fatalf("accepting should not start a combat refractory period for agent %d", idx)
+		}
+	}
+}
+
+func TestActOvipositLaysEggs(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	ovipositIdx := ovipositBehaviorIdx(cfg)
+
+	female := w.AddAgent()
+	w.Agents.Sex[female] = world.SexFemale
+	w.Agents.Situation[female] = world.SituationRegular
+	w.Agents.PosX[female] = 12
+	w.Agents.PosY[female] = 18
+	w.Agents.FertilizedCount[female] = 3
+	w.Agents.Decision[female] = uint8(ovipositIdx)
+	storeSpermPack(w, female, w.AddAgent(), 100, nil)
+
+	reproCfg := ReproductionConfig{EggsPerCycle: 2, MaleRatio: 50, FemaleRatio: 50}
+	Act(&ActionContext{World: w, Reproduction: &reproCfg}, female)
+
+	if w.Eggs.Count != 2 {
+		t.Fatalf("expected 2 eggs laid, got %d", w.Eggs.Count)
+	}
+	if w.Eggs.PosX[0] != 12 || w.Eggs.PosY[0] != 18 || w.Eggs.CarrierAgentIdx[0] != int32(female) {
+		t.Fatalf("egg should be carried by the mother at her position")
+	}
+	if w.Agents.FertilizedCount[female] != 1 || w.Agents.CarriedEggs[female] != 2 {
+		t.Fatalf("expected 1 fertilized and 2 carried eggs, got %d and %d",
+			w.Agents.FertilizedCount[female], w.Agents.CarriedEggs[female])
+	}
+
+	// The same slot is a retreat for an agent in combat: no eggs are laid.
+	w.Agents.Situation[female] = world.SituationCombat
+	Act(&ActionContext{World: w, Reproduction: &reproCfg}, female)
+	if w.Eggs.Count != 2 {
+		t.Fatalf("retreat should not lay eggs, got %d", w.Eggs.Count)
+	}
+	if w.Agents.Situation[female] != world.SituationRegular {
+		t.Fatalf("retreating agent should be regular, got %d", w.Agents.Situation[female])
+	}
+}
+
+func TestActOvipositIntoSite(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	w.ResourceOviposition[1] = true
+
+	w.Resources.PosX[0] = 13
+	w.Resources.PosY[0] = 18
+	w.Resources.TypeID[0] = 1
+	w.Resources.Level[0] = 3
+	w.Resources.MaxLevel[0] = 4
+	w.Resources.Count = 1
+
+	female := w.AddAgent()
+	w.Agents.Sex[female] = world.SexFemale
+	w.Agents.Situation[female] = world.SituationRegular
+	w.Agents.PosX[female] = 12
+	w.Agents.PosY[female] = 18
+	w.Agents.FertilizedCount[female] = 3
+	w.Agents.Decision[female] = uint8(ovipositBehaviorIdx(cfg))
+	w.Agents.InteractantIdx[female] = 0
+	storeSpermPack(w, female, w.AddAgent(), 100, nil)
+
+	// The site has room for a single egg.
+	reproCfg := ReproductionConfig{EggsPerCycle: 2, MaleRatio: 50, FemaleRatio: 50}
+	Act(&ActionContext{World: w, Reproduction: &reproCfg}, female)
+
+	if w.Eggs.Count != 1 {
+		t.Fatalf("expected 1 egg laid, got %d", w.Eggs.Count)
+	}
+	if w.Eggs.CarrierResourceIdx[0] != 0 || w.Eggs.CarrierAgentIdx[0] != -1 {
+		t.Fatalf("egg should be held by the site, got resource %d agent %d",
+			w.Eggs.CarrierResourceIdx[0], w.Eggs.CarrierAgentIdx[0])
+	}
+	if w.Eggs.PosX[0] != 13 || w.Eggs.PosY[0] != 18 {
+		t.Fatalf("egg should sit at the site, got (%f,%f)", w.Eggs.PosX[0], w.Eggs.PosY[0])
+	}
+	if w.Resources.Level[0] != 4 || w.Agents.FertilizedCount[female] != 2 || w.Agents.CarriedEggs[female] != 0 {
+		t.Fatalf("expected site level 4, 2 fertilized and 0 carried eggs, got %d, %d and %d",
+			w.Resources.Level[0], w.Agents.FertilizedCount[female], w.Agents.CarriedEggs[female])
+	}
+}
+
 func TestActRest(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -178,7 +429,7 @@ func TestActRest(t *testing.T) {
 	w.Agents.PosY[idx] = 25
 	w.Agents.Decision[idx] = behaviorRest
 
-	Act(w, idx)
+	Act(&ActionContext{World: w}, idx)
 
 	// Position unchanged.
 	if w.Agents.PosX[idx] != 25 || w.Agents.PosY[idx] != 25 {
@@ -210,6 +461,49 @@ func TestChargeNutrientsWithCosts(t *testing.T) {
 	}
 }
 
+func TestChargeBehaviorCostsFormula(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	idx := w.AddAgent()
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 50
+	w.Agents.Decision[idx] = behaviorMove
+
+	reg := formulas.NewRegistry()
+	reg.Compile("cost.move.0", "Morphology2 * 4")
+	c := BehaviorCostConfig{
+		Fixed:    make([]int32, cfg.NumBehaviors*cfg.NumNutrients),
+		Formulas: make([]*formulas.Program, cfg.NumBehaviors*cfg.NumNutrients),
+		Dynamic:  make([]bool, cfg.NumBehaviors),
+	}
+	c.Fixed[behaviorMove*cfg.NumNutrients+1] = 2
+	c.Formulas[behaviorMove*cfg.NumNutrients+0] = reg.Get("cost.move.0")
+	c.Dynamic[behaviorMove] = true
+
+	if !NeedsCostFormulas(w, idx, &c) {
+		t.Fatal("move should need cost formulas")
+	}
+	eval := formulas.NewEvaluator(64)
+	eval.SetFloat("Morphology2", 2.5)
+
+	ChargeBehaviorCosts(w, idx, eval, &c)
+
+	// Water charged by formula (2.5*4), sugar by the fixed cost.
+	if w.Agents.Reserves[idx*cfg.NumNutrients+0] != 40 {
+		t.Fatalf("expected water=40, got %d", w.Agents.Reserves[idx*cfg.NumNutrients+0])
+	}
+	if w.Agents.Reserves[idx*cfg.NumNutrients+1] != 48 {
+		t.Fatalf("expected sugar=48, got %d", w.Agents.Reserves[idx*cfg.NumNutrients+1])
+	}
+
+	// Rest has no formula: only the fixed table applies.
+	w.Agents.Decision[idx] = behaviorRest
+	if NeedsCostFormulas(w, idx, &c) {
+		t.Fatal("rest should not need cost formulas")
+	}
+}
+
 func TestChargeNutrientsDefaultCost(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -276,6 +570,22 @@ func TestUpdateAgentStarvation(t *testing.T) {
 	}
 }
 
+func TestUpdateAgentStarvationAtReserveMin(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	idx := w.AddAgent()
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 8
+	w.Agents.ReserveMin[idx*cfg.NumNutrients+1] = 10
+
+	UpdateAgent(w, idx, 1000)
+
+	if w.Agents.Situation[idx] != world.SituationDead {
+		t.Fatalf("expected dead below minimum level, got %d", w.Agents.Situation[idx])
+	}
+}
+
 func TestUpdateAgentOldAge(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -293,6 +603,69 @@ func TestUpdateAgentOldAge(t *testing.T) {
 	}
 }
 
+func TestUpdateAgentUsesOwnLongevity(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	idx := w.AddAgent()
+	w.Agents.StageID[idx] = -1 // Adult.
+	w.Agents.Age[idx] = 501
+	w.Agents.Longevity[idx] = 800
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 50
+
+	UpdateAgent(w, idx, 500) // The agent's own longevity wins.
+
+	if w.Agents.Situation[idx] == world.SituationDead {
+		t.Fatal("agent should outlive the default longevity")
+	}
+}
+
+func TestStartRefractoryPeriods(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	// The female prototype rests 2*Age ticks after combat and has no
+	// courtship period.
+	reg := formulas.NewRegistry()
+	reg.Compile("combat", "Age * 2")
+	r := &RefractoryConfig{
+		NumPrototypesM: 1,
+		Combat:         []*formulas.Program{nil, reg.Get("combat")},
+		Courtship:      []*formulas.Program{nil, nil},
+	}
+
+	idx := w.AddAgent()
+	a := w.Agents
+	a.Sex[idx] = world.SexFemale
+	a.PrototypeID[idx] = 0
+	a.Age[idx] = 3
+	a.Situation[idx] = world.SituationCombat
+	a.InteractantIdx[idx] = -1
+	a.Reserves[idx*cfg.NumNutrients+0] = 50
+	a.Reserves[idx*cfg.NumNutrients+1] = 50
+
+	// The opponent is gone: the agent wins and its period is pending.
+	a.Decision[idx] = uint8(behaviorOffsetFeed + cfg.NumResourceTypes)
+	actCombatSignal(w, idx)
+	a.RefractoryCourtship[idx] = world.RefractoryPending
+	if !RefractoryPending(w, idx) {
+		t.Fatal("expected a pending refractory period after combat")
+	}
+
+	eval := formulas.NewEvaluator(128)
+	formulas.NewEnvBuilder(eval, cfg).SetAgentVars(w, idx)
+	StartRefractoryPeriods(w, idx, eval, r)
+	if a.RefractoryCombat[idx] != 6 || a.RefractoryCourtship[idx] != 0 {
+		t.Fatalf("expected combat 6 and courtship 0, got %d and %d", a.RefractoryCombat[idx], a.RefractoryCourtship[idx])
+	}
+
+	UpdateAgent(w, idx, 1000)
+	if a.RefractoryCombat[idx] != 5 {
+		t.Fatalf("expected the combat period to count down to 5, got %d", a.RefractoryCombat[idx])
+	}
+}
+
 func TestUpdateAgentSurvives(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -325,7 +698,11 @@ func TestRegenerateResources(t *testing.T) {
 	w.Resources.Level[2] = 0
 	w.Resources.MaxLevel[2] = 100
 	w.Resources.RegenRate[2] = 1.2
-	w.Resources.Count = 3
+	w.ResourceOviposition[1] = true
+	w.Resources.TypeID[3] = 1
+	w.Resources.MaxLevel[3] = 100
+	w.Resources.RegenRate[3] = 1.2
+	w.Resources.Count = 4
 
 	RegenerateResources(w)
 
@@ -341,6 +718,10 @@ func TestRegenerateResources(t *testing.T) {
 	if w.Resources.Level[2] != 1 {
 		t.Fatalf("resource 2: expected 1 (anti-depletion), got %d", w.Resources.Level[2])
 	}
+	// Resource 3 is an empty oviposition site: its level counts eggs.
+	if w.Resources.Level[3] != 0 {
+		t.Fatalf("resource 3: expected site to stay empty, got %d", w.Resources.Level[3])
+	}
 }
 
 func TestRemoveDeadAgents(t *testing.T) {
@@ -400,6 +781,36 @@ func TestRemoveDeadNotifiesCombatPartner(t *testing.T) {
 	}
 }
 
+func TestRemoveDeadAgentsUpdatesEggCarriers(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	// Agent 0 dies carrying one egg; agent 2 carries the other and is
+	// swapped into slot 0.
+	for i := 0; i < 3; i++ {
+		idx := w.AddAgent()
+		w.Agents.CarriedEggs[idx] = 1
+	}
+	w.Agents.Situation[0] = world.SituationDead
+	w.Agents.Situation[1] = world.SituationDead
+	w.Agents.CarriedEggs[1] = 0
+	w.Eggs.Count = 2
+	w.Eggs.CarrierAgentIdx[0] = 0
+	w.Eggs.CarrierAgentIdx[1] = 2
+
+	RemoveDeadAgents(w)
+
+	if w.Agents.Count != 1 {
+		t.Fatalf("expected 1 agent remaining, got %d", w.Agents.Count)
+	}
+	if w.Eggs.Count != 1 {
+		t.Fatalf("eggs of the dead carrier should die, got %d eggs", w.Eggs.Count)
+	}
+	if w.Eggs.CarrierAgentIdx[0] != 0 {
+		t.Fatalf("egg should follow its carrier to slot 0, got %d", w.Eggs.CarrierAgentIdx[0])
+	}
+}
+
 func TestBehaviorMemoryUpdate(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -413,7 +824,7 @@ func TestBehaviorMemoryUpdate(t *testing.T) {
 
 	w.Agents.Decision[idx] = behaviorMove
 
-	Act(w, idx)
+	Act(&ActionContext{World: w}, idx)
 
 	// Move (0) should be reset to 0 and count incremented.
 	if w.Agents.MemoryLastBehavior[memBase+0] != 0 {
@@ -21,6 +21,7 @@ const (
 	combatDisplay  = 0
 	combatEscalate = 1
 	combatRetreat  = 2
+	combatActions  = 3
 )
 
 // Courtship decision indices (within VCortejos equivalent).
@@ -29,13 +30,14 @@ const (
 	courtshipEscalate = 1
 	courtshipAccept   = 2
 	courtshipReject   = 3
+	courtshipActions  = 4
 )
 
 // Roulette performs proportional random selection on a weighted slice.
 // It returns the 0-based index of the selected element.
 // If all weights are zero, all are set to 1 (uniform) before selection.
-// Negative weights are clamped to 0.
-func Roulette(weights []int32) int {
+// Negative weights are clamped to 0. Draws come from r.
+func Roulette(r *rand.Rand, weights []int32) int {
 	sum := int32(0)
 	for i := range weights {
 		if weights[i] < 0 {
@@ -46,10 +48,10 @@ func Roulette(weights []int32) int {
 
 	if sum == 0 {
 		// All zero: uniform distribution.
-		return rand.IntN(len(weights))
+		return r.IntN(len(weights))
 	}
 
-	target := rand.Int32N(sum) + 1
+	target := r.Int32N(sum) + 1
 	cumulative := int32(0)
 	for i, w := range weights {
 		cumulative += w
@@ -62,9 +64,20 @@ func Roulette(weights []int32) int {
 	return len(weights) - 1
 }
 
+// hasPositiveWeight reports whether Roulette would draw by weight rather
+// than uniformly.
+func hasPositiveWeight(weights []int32) bool {
+	for _, w := range weights {
+		if w > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // Decide selects a behavior for the agent based on its current situation.
-// It reads VDecision (for regular), VPeleas-equivalent (for combat),
-// or VCortejos-equivalent (for courtship) and sets the Decision field.
+// It reads VDecision (for regular) or InteractionWeights (for combat and
+// courtship, filled by PerceiveInteraction) and sets the Decision field.
 func Decide(w *world.World, idx int) {
 	a := w.Agents
 	if a.State[idx] == world.StateDecided {
@@ -76,39 +89,48 @@ func Decide(w *world.World, idx int) {
 
 	switch a.Situation[idx] {
 	case world.SituationImmature, world.SituationRegular:
-		decideRegular(a, idx, cfg, vdBase)
+		decideRegular(a, w.Rand.Decision, idx, cfg, vdBase)
 	case world.SituationCombat:
-		decideCombat(a, idx, cfg, vdBase)
+		decideCombat(a, w.Rand.Decision, idx, cfg)
 	case world.SituationCourtship:
-		decideCourtship(a, idx, cfg, vdBase)
+		decideCourtship(a, w.Rand.Decision, idx, cfg)
 	}
 
 	a.State[idx] = world.StateDecided
 }
 
 // decideRegular uses the full VDecision vector for behavior selection.
-func decideRegular(a *world.AgentArrays, idx int, cfg world.Config, vdBase int) {
+// Agents in a refractory period cannot start combat or courtship; if that
+// leaves no weighted behavior they rest rather than draw among all of them.
+func decideRegular(a *world.AgentArrays, r *rand.Rand, idx int, cfg world.Config, vdBase int) {
 	weights := a.VDecision[vdBase : vdBase+cfg.NumBehaviors]
-	chosen := Roulette(weights)
+	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
+	blocked := false
+	if a.RefractoryCombat[idx] != 0 {
+		weights[fightDisplayIdx] = 0
+		weights[fightDisplayIdx+1] = 0
+		blocked = true
+	}
+	if a.RefractoryCourtship[idx] != 0 {
+		weights[fightDisplayIdx+2] = 0
+		weights[fightDisplayIdx+3] = 0
+		blocked = true
+	}
+	if blocked && !hasPositiveWeight(weights) {
+		a.Decision[idx] = behaviorRest
+		return
+	}
+	chosen := Roulette(r, weights)
 	a.Decision[idx] = uint8(chosen)
 }
 
 // decideCombat selects among combat-specific behaviors: display, escalate, retreat.
-func decideCombat(a *world.AgentArrays, idx int, cfg world.Config, vdBase int) {
+func decideCombat(a *world.AgentArrays, r *rand.Rand, idx int, cfg world.Config) {
 	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
 	fightEscalateIdx := fightDisplayIdx + 1
 
-	// Build a 3-element weight vector: [display, escalate, retreat].
-	var combatWeights [3]int32
-	if fightDisplayIdx < cfg.NumBehaviors {
-		combatWeights[combatDisplay] = clampPositive(a.VDecision[vdBase+fightDisplayIdx])
-	}
-	if fightEscalateIdx < cfg.NumBehaviors {
-		combatWeights[combatEscalate] = clampPositive(a.VDecision[vdBase+fightEscalateIdx])
-	}
-	combatWeights[combatRetreat] = 1 // Always at least some chance to retreat.
-
-	chosen := Roulette(combatWeights[:])
+	base := idx * world.InteractionActions
+	chosen := Roulette(r, a.InteractionWeights[base:base+combatActions])
 
 	// Map combat choice back to the global behavior index.
 	switch chosen {
@@ -121,23 +143,13 @@ func decideCombat(a *world.AgentArrays, idx int, cfg world.Config, vdBase int) {
 	}
 }
 
-// decideCourtship selects among courtship-specific behaviors.
-func decideCourtship(a *world.AgentArrays, idx int, cfg world.Config, vdBase int) {
+// decideCourtship selects among courtship-specific behaviors:
+// display, escalate, accept, reject.
+func decideCourtship(a *world.AgentArrays, r *rand.Rand, idx int, cfg world.Config) {
 	courtDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes + 2
-	courtEscalateIdx := courtDisplayIdx + 1
 
-	// Build a 4-element weight vector: [display, escalate, accept, reject].
-	var courtWeights [4]int32
-	if courtDisplayIdx < cfg.NumBehaviors {
-		courtWeights[courtshipDisplay] = clampPositive(a.VDecision[vdBase+courtDisplayIdx])
-	}
-	if courtEscalateIdx < cfg.NumBehaviors {
-		courtWeights[courtshipEscalate] = clampPositive(a.VDecision[vdBase+courtEscalateIdx])
-	}
-	courtWeights[courtshipAccept] = 1
-	courtWeights[courtshipReject] = 1
-
-	chosen := Roulette(courtWeights[:])
+	base := idx * world.InteractionActions
+	chosen := Roulette(r, a.InteractionWeights[base:base+courtshipActions])
 
 	// Map courtship choice to decision code.
 	// Use indices relative to courtDisplay for compact representation.
@@ -174,6 +186,9 @@ func EstablishInteraction(w *world.World, idx int, agentGrid, resourceGrid *spat
 		resourceType := int32(decision - behaviorOffsetFeed)
 		rIdx := findContiguousResource(w, ax, ay, resourceType, resourceGrid)
 		a.InteractantIdx[idx] = rIdx
+		if rIdx >= 0 {
+			rememberInteracted(w, idx, resourceMemorySlot(cfg, int(resourceType)))
+		}
 		return
 	}
 
@@ -187,24 +202,66 @@ func EstablishInteraction(w *world.World, idx int, agentGrid, resourceGrid *spat
 		a.InteractantIdx[idx] = target
 		if target >= 0 {
 			initiateCombat(a, idx, int(target))
+			rememberPartners(w, idx, int(target))
 		}
 		return
 	}
 
-	if decision >= courtDisplayIdx && decision < courtDisplayIdx+4 {
+	if decision >= courtDisplayIdx && decision < courtDisplayIdx+2 {
 		// Courtship: find opposite-sex contiguous agent.
 		target := findContiguousAgent(w, idx, ax, ay, agentGrid, true)
 		a.InteractantIdx[idx] = target
 		if target >= 0 {
 			initiateCourtship(a, idx, int(target))
+			rememberPartners(w, idx, int(target))
 		}
 		return
 	}
 
-	// Oviposition or other: clear interaction.
+	if decision == ovipositBehaviorIdx(cfg) {
+		// Oviposition: find a contiguous site with room for eggs.
+		site := findOvipositionSite(w, ax, ay, resourceGrid)
+		a.InteractantIdx[idx] = site
+		if site >= 0 {
+			rememberInteracted(w, idx, resourceMemorySlot(cfg, int(w.Resources.TypeID[site])))
+		}
+		return
+	}
+
+	// Die or other: clear interaction.
 	a.InteractantIdx[idx] = -1
 }
 
+// rememberPartners records a new combat or courtship in the memory of both
+// agents.
+func rememberPartners(w *world.World, idx, target int) {
+	cfg := w.Config
+	rememberInteracted(w, idx, agentMemorySlot(cfg, getPerceiverIndex(w.Agents, target, cfg)))
+	rememberInteracted(w, target, agentMemorySlot(cfg, getPerceiverIndex(w.Agents, idx, cfg)))
+}
+
+// findOvipositionSite returns the index of the nearest oviposition site
+// within contiguous distance that can still hold eggs, or -1 if none found.
+func findOvipositionSite(w *world.World, ax, ay float64, grid *spatial.Grid) int32 {
+	r := w.Resources
+	candidates := grid.QueryRadiusExact(ax, ay, contiguousDistance, r.PosX, r.PosY)
+
+	bestIdx := int32(-1)
+	bestDist := math.MaxFloat64
+
+	for _, rIdx := range candidates {
+		if !isOvipositionSite(w, int(r.TypeID[rIdx])) || r.Level[rIdx] >= r.MaxLevel[rIdx] {
+			continue
+		}
+		dist := distance(ax, ay, r.PosX[rIdx], r.PosY[rIdx])
+		if dist < bestDist {
+			bestDist = dist
+			bestIdx = rIdx
+		}
+	}
+	return bestIdx
+}
+
 // findContiguousResource returns the index of the nearest resource of the given type
 // within contiguous distance, or -1 if none found.
 func findContiguousResource(w *world.World, ax, ay float64, resourceType int32, grid *spatial.Grid) int32 {
@@ -229,7 +286,8 @@ func findContiguousResource(w *world.World, ax, ay float64, resourceType int32,
 
 // findContiguousAgent returns the index of the nearest contiguous agent suitable
 // for interaction. If oppositeSex is true, looks for opposite sex; otherwise same sex.
-// The target must be in Regular situation and Undecided state.
+// The target must be in Regular situation and Undecided state, and out of its
+// refractory period for that interaction.
 func findContiguousAgent(w *world.World, selfIdx int, ax, ay float64, grid *spatial.Grid, oppositeSex bool) int32 {
 	a := w.Agents
 	candidates := grid.QueryRadiusExact(ax, ay, contiguousDistance, a.PosX, a.PosY)
@@ -245,6 +303,13 @@ func findContiguousAgent(w *world.World, selfIdx int, ax, ay float64, grid *spat
 		if a.Situation[cIdx] != world.SituationRegular {
 			continue
 		}
+		refractory := a.RefractoryCombat[cIdx]
+		if oppositeSex {
+			refractory = a.RefractoryCourtship[cIdx]
+		}
+		if refractory != 0 {
+			continue
+		}
 
 		otherSex := a.Sex[cIdx]
 		if oppositeSex {
@@ -273,6 +338,8 @@ func initiateCombat(a *world.AgentArrays, initiatorIdx, targetIdx int) {
 	a.InteractantIdx[targetIdx] = int32(initiatorIdx)
 	a.TimeInInteraction[initiatorIdx] = 0
 	a.TimeInInteraction[targetIdx] = 0
+	a.LastOpponentAction[initiatorIdx] = 0
+	a.LastOpponentAction[targetIdx] = 0
 }
 
 // initiateCourtship puts both agents into courtship situation.
@@ -282,6 +349,8 @@ func initiateCourtship(a *world.AgentArrays, initiatorIdx, targetIdx int) {
 	a.InteractantIdx[targetIdx] = int32(initiatorIdx)
 	a.TimeInInteraction[initiatorIdx] = 0
 	a.TimeInInteraction[targetIdx] = 0
+	a.LastOpponentAction[initiatorIdx] = 0
+	a.LastOpponentAction[targetIdx] = 0
 }
 
 // isOppositeSex returns true if the two sexes are male/female or female/male.
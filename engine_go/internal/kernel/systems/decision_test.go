@@ -9,12 +9,13 @@ import (
 )
 
 func TestRouletteBasicDistribution(t *testing.T) {
+	r := world.NewStreams(1).Decision
 	weights := []int32{50, 30, 20}
 	counts := [3]int{}
 	const iterations = 100000
 
 	for i := 0; i < iterations; i++ {
-		idx := Roulette(weights)
+		idx := Roulette(r, weights)
 		counts[idx]++
 	}
 
@@ -32,12 +33,13 @@ func TestRouletteBasicDistribution(t *testing.T) {
 }
 
 func TestRouletteAllZero(t *testing.T) {
+	r := world.NewStreams(1).Decision
 	weights := []int32{0, 0, 0, 0}
 	counts := [4]int{}
 	const iterations = 40000
 
 	for i := 0; i < iterations; i++ {
-		idx := Roulette(weights)
+		idx := Roulette(r, weights)
 		counts[idx]++
 	}
 
@@ -51,10 +53,11 @@ func TestRouletteAllZero(t *testing.T) {
 }
 
 func TestRouletteNegativesClamped(t *testing.T) {
+	r := world.NewStreams(1).Decision
 	weights := []int32{-5, 10, -3}
 	// After clamping: {0, 10, 0} → always selects index 1.
 	for i := 0; i < 100; i++ {
-		idx := Roulette(weights)
+		idx := Roulette(r, weights)
 		if idx != 1 {
 			t.Fatalf("expected index 1 (only positive weight), got %d", idx)
 		}
@@ -62,9 +65,10 @@ func TestRouletteNegativesClamped(t *testing.T) {
 }
 
 func TestRouletteSingleWeight(t *testing.T) {
+	r := world.NewStreams(1).Decision
 	weights := []int32{0, 0, 100, 0}
 	for i := 0; i < 50; i++ {
-		idx := Roulette(weights)
+		idx := Roulette(r, weights)
 		if idx != 2 {
 			t.Fatalf("expected index 2 (only nonzero), got %d", idx)
 		}
@@ -93,6 +97,58 @@ func TestDecideRegular(t *testing.T) {
 	}
 }
 
+func TestDecideRegular_RefractoryBlocksInteractions(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	idx := w.AddAgent()
+	a := w.Agents
+	a.Situation[idx] = world.SituationRegular
+	a.RefractoryCombat[idx] = 4
+	a.RefractoryCourtship[idx] = world.RefractoryPending
+
+	// Only combat and courtship are weighted, and both are refractory.
+	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
+	vdBase := idx * cfg.NumBehaviors
+	for b := fightDisplayIdx; b < fightDisplayIdx+4; b++ {
+		a.VDecision[vdBase+b] = 100
+	}
+	a.VDecision[vdBase+behaviorRest] = 1
+
+	Decide(w, idx)
+
+	if a.Decision[idx] != behaviorRest {
+		t.Fatalf("expected rest while refractory, got %d", a.Decision[idx])
+	}
+}
+
+func TestDecideRegular_RefractoryWithoutOtherWeightsRests(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	idx := w.AddAgent()
+	a := w.Agents
+	a.Situation[idx] = world.SituationRegular
+	a.RefractoryCombat[idx] = world.RefractoryPending
+	a.RefractoryCourtship[idx] = world.RefractoryPending
+
+	// Only the blocked interactions are weighted: no uniform draw may pick them.
+	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
+	vdBase := idx * cfg.NumBehaviors
+	for trial := 0; trial < 50; trial++ {
+		for b := fightDisplayIdx; b < fightDisplayIdx+4; b++ {
+			a.VDecision[vdBase+b] = 100
+		}
+		a.State[idx] = world.StateUndecided
+
+		Decide(w, idx)
+
+		if a.Decision[idx] != behaviorRest {
+			t.Fatalf("trial %d: expected rest while refractory, got %d", trial, a.Decision[idx])
+		}
+	}
+}
+
 func TestDecideAlreadyDecided(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -117,20 +173,17 @@ func TestDecideCombat(t *testing.T) {
 	w.Agents.Situation[idx] = world.SituationCombat
 	w.Agents.State[idx] = world.StateUndecided
 
-	// Set fight display weight high.
-	vdBase := idx * cfg.NumBehaviors
+	// Only escalation has weight.
 	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
-	w.Agents.VDecision[vdBase+fightDisplayIdx] = 100
+	w.Agents.InteractionWeights[idx*world.InteractionActions+combatEscalate] = 100
 
 	Decide(w, idx)
 
 	if w.Agents.State[idx] != world.StateDecided {
 		t.Fatal("expected state = Decided")
 	}
-	// Decision should be one of the combat behaviors.
-	decision := int(w.Agents.Decision[idx])
-	if decision < fightDisplayIdx {
-		t.Fatalf("expected combat decision >= %d, got %d", fightDisplayIdx, decision)
+	if decision := int(w.Agents.Decision[idx]); decision != fightDisplayIdx+1 {
+		t.Fatalf("expected fight escalate %d, got %d", fightDisplayIdx+1, decision)
 	}
 }
 
@@ -142,19 +195,17 @@ func TestDecideCourtship(t *testing.T) {
 	w.Agents.Situation[idx] = world.SituationCourtship
 	w.Agents.State[idx] = world.StateUndecided
 
-	// Set courtship display weight high.
-	vdBase := idx * cfg.NumBehaviors
+	// Only acceptance has weight.
 	courtDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes + 2
-	w.Agents.VDecision[vdBase+courtDisplayIdx] = 100
+	w.Agents.InteractionWeights[idx*world.InteractionActions+courtshipAccept] = 100
 
 	Decide(w, idx)
 
 	if w.Agents.State[idx] != world.StateDecided {
 		t.Fatal("expected state = Decided")
 	}
-	decision := int(w.Agents.Decision[idx])
-	if decision < courtDisplayIdx {
-		t.Fatalf("expected courtship decision >= %d, got %d", courtDisplayIdx, decision)
+	if decision := int(w.Agents.Decision[idx]); decision != courtDisplayIdx+courtshipAccept {
+		t.Fatalf("expected courtship accept %d, got %d", courtDisplayIdx+courtshipAccept, decision)
 	}
 }
 
@@ -298,6 +349,114 @@ func TestEstablishInteractionCourtship(t *testing.T) {
 	}
 }
 
+func TestEstablishInteractionRemembersInteractants(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	slots := cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
+	agentSlot := cfg.NumSubstrates + cfg.NumResourceTypes
+
+	male := w.AddAgent()
+	w.Agents.PosX[male] = 10
+	w.Agents.PosY[male] = 10
+	w.Agents.Sex[male] = world.SexMale
+	w.Agents.StageID[male] = -1
+	w.Agents.PrototypeID[male] = 0
+	w.Agents.Situation[male] = world.SituationRegular
+	w.Agents.Decision[male] = uint8(behaviorOffsetFeed + cfg.NumResourceTypes + 2) // court display
+
+	female := w.AddAgent()
+	w.Agents.PosX[female] = 10.5
+	w.Agents.PosY[female] = 10
+	w.Agents.Sex[female] = world.SexFemale
+	w.Agents.StageID[female] = -1
+	w.Agents.PrototypeID[female] = 0
+	w.Agents.Situation[female] = world.SituationRegular
+	w.Agents.Decision[female] = uint8(behaviorOffsetFeed + 1) // feed type 1
+
+	w.Resources.PosX[0] = 11
+	w.Resources.PosY[0] = 10
+	w.Resources.TypeID[0] = 1
+	w.Resources.Count = 1
+
+	agentGrid := spatial.NewGrid(5.0, 64)
+	agentGrid.Insert(int32(male), w.Agents.PosX[male], w.Agents.PosY[male])
+	agentGrid.Insert(int32(female), w.Agents.PosX[female], w.Agents.PosY[female])
+	resourceGrid := spatial.NewGrid(5.0, 64)
+	resourceGrid.Insert(0, w.Resources.PosX[0], w.Resources.PosY[0])
+
+	EstablishInteraction(w, female, agentGrid, resourceGrid)
+	feedSlot := female*slots + cfg.NumSubstrates + 1
+	if w.Agents.MemoryLastInteracted[feedSlot] != 0 || w.Agents.MemoryNumInteracted[feedSlot] != 1 {
+		t.Fatalf("expected resource type 1 interacted 0 ticks ago, got last=%d num=%d",
+			w.Agents.MemoryLastInteracted[feedSlot], w.Agents.MemoryNumInteracted[feedSlot])
+	}
+
+	// Courtship is remembered by both partners, each under the other's perceiver index.
+	EstablishInteraction(w, male, agentGrid, resourceGrid)
+	if w.Agents.Situation[male] != world.SituationCourtship {
+		t.Fatalf("expected courtship, got situation %d", w.Agents.Situation[male])
+	}
+	if got := w.Agents.MemoryNumInteracted[male*slots+agentSlot+2]; got != 1 {
+		t.Fatalf("expected the male to remember the female once, got %d", got)
+	}
+	if got := w.Agents.MemoryLastInteracted[female*slots+agentSlot+1]; got != 0 {
+		t.Fatalf("expected the female to remember the male 0 ticks ago, got %d", got)
+	}
+
+	UpdateAgent(w, female, 0)
+	if got := w.Agents.MemoryLastInteracted[feedSlot]; got != 1 {
+		t.Fatalf("expected resource type 1 interacted 1 tick ago, got %d", got)
+	}
+}
+
+func TestEstablishInteractionOviposition(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	w.ResourceOviposition[1] = true
+
+	idx := w.AddAgent()
+	w.Agents.PosX[idx] = 10
+	w.Agents.PosY[idx] = 10
+	w.Agents.Sex[idx] = world.SexFemale
+	w.Agents.Decision[idx] = uint8(ovipositBehaviorIdx(cfg))
+	w.Agents.Situation[idx] = world.SituationRegular
+
+	// A contiguous male must not turn oviposition into courtship.
+	male := w.AddAgent()
+	w.Agents.PosX[male] = 10.5
+	w.Agents.PosY[male] = 10
+	w.Agents.Sex[male] = world.SexMale
+	w.Agents.Situation[male] = world.SituationRegular
+
+	// The nearest site is full; the farther one has room.
+	for i, x := range []float64{10.2, 10.8} {
+		w.Resources.PosX[i] = x
+		w.Resources.PosY[i] = 10
+		w.Resources.TypeID[i] = 1
+		w.Resources.MaxLevel[i] = 5
+	}
+	w.Resources.Level[0] = 5
+	w.Resources.Count = 2
+
+	resourceGrid := spatial.NewGrid(5.0, 64)
+	for i := 0; i < w.Resources.Count; i++ {
+		resourceGrid.Insert(int32(i), w.Resources.PosX[i], w.Resources.PosY[i])
+	}
+	agentGrid := spatial.NewGrid(5.0, 64)
+	for i := 0; i < w.Agents.Count; i++ {
+		agentGrid.Insert(int32(i), w.Agents.PosX[i], w.Agents.PosY[i])
+	}
+
+	EstablishInteraction(w, idx, agentGrid, resourceGrid)
+
+	if w.Agents.InteractantIdx[idx] != 1 {
+		t.Fatalf("expected the site with room (1), got %d", w.Agents.InteractantIdx[idx])
+	}
+	if w.Agents.Situation[idx] != world.SituationRegular || w.Agents.Situation[male] != world.SituationRegular {
+		t.Fatalf("oviposition should not start a courtship")
+	}
+}
+
 func TestIsOppositeSex(t *testing.T) {
 	if !isOppositeSex(world.SexMale, world.SexFemale) {
 		t.Error("M/F should be opposite")
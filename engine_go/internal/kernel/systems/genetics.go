@@ -1,7 +1,11 @@
 package systems
 
 import (
+	"cmp"
+	"fmt"
+	"math"
 	"math/rand/v2"
+	"slices"
 
 	"galatea/engine/internal/kernel/world"
 )
@@ -16,97 +20,309 @@ type LocusConfig struct {
 
 // GeneticsConfig holds the per-locus configuration needed for crossover and mutation.
 type GeneticsConfig struct {
-	NumLoci    int
-	LociCont   []LocusConfig // len = NumLoci
-	LociDisc   []LocusConfig // len = NumLoci
+	NumLoci  int
+	LociCont []LocusConfig // len = NumLoci
+	LociDisc []LocusConfig // len = NumLoci
+	Linkage  *LinkageMap   // Nil when every locus recombines freely.
+
+	SexDetermination SexDetermination
+	SexLinkage       []SexLinkage // [locus]; nil = every locus autosomal.
+
+	Expression []world.Expression // [locus]; nil = every locus dominant.
+	Continuous []bool             // [locus]; nil = every locus continuous.
 }
 
-// ExpressLocusCont calculates the expressed continuous phenotype for an agent at a specific locus.
-// Dominance rules: both dominant or both recessive = codominance (average).
-// Heterozygous = dominant allele expressed.
-func ExpressLocusCont(genotype []float64, dominance []uint8, agentIdx, locus, numLoci int) float64 {
-	base := agentIdx*numLoci*2 + locus*2
-	patVal := genotype[base]
-	matVal := genotype[base+1]
-	patDom := dominance[base]
-	matDom := dominance[base+1]
+// SexDetermination is the system deciding the sex of each egg.
+type SexDetermination uint8
+
+const (
+	SexDeterminationRatio         SexDetermination = iota // Drawn from the sex ratio of the mother's prototype.
+	SexDeterminationXY                                    // Males XY, females XX: the sperm's sex chromosome decides.
+	SexDeterminationZW                                    // Females ZW, males ZZ: the egg's sex chromosome decides.
+	SexDeterminationHaplodiploid                          // Fertilized eggs are diploid females, unfertilized ones haploid males.
+	SexDeterminationEnvironmental                         // A formula on the egg decides at eclosion.
+)
+
+// ParseSexDetermination parses a sex-determination system as stored in the
+// reproduction table: ratio (or empty), xy, zw, haplodiploid and environmental.
+func ParseSexDetermination(mode string) (SexDetermination, error) {
+	switch mode {
+	case "", "ratio":
+		return SexDeterminationRatio, nil
+	case "xy":
+		return SexDeterminationXY, nil
+	case "zw":
+		return SexDeterminationZW, nil
+	case "haplodiploid":
+		return SexDeterminationHaplodiploid, nil
+	case "environmental":
+		return SexDeterminationEnvironmental, nil
+	}
+	return 0, fmt.Errorf("unknown sex determination %q", mode)
+}
+
+// SexLinkage is the chromosome carrying a locus, as far as sex is concerned.
+type SexLinkage uint8
 
-	if patDom == matDom {
-		return (patVal + matVal) / 2.0 // Codominance.
+const (
+	Autosomal SexLinkage = iota
+	XLinked
+	YLinked
+	ZLinked
+	WLinked
+)
+
+// ParseSexLinkage parses the sex linkage of a locus as stored in the loci
+// table: autosomal (or empty), X, Y, Z and W.
+func ParseSexLinkage(linkage string) (SexLinkage, error) {
+	switch linkage {
+	case "", "autosomal":
+		return Autosomal, nil
+	case "X":
+		return XLinked, nil
+	case "Y":
+		return YLinked, nil
+	case "Z":
+		return ZLinked, nil
+	case "W":
+		return WLinked, nil
 	}
-	if patDom == 1 {
-		return patVal
+	return 0, fmt.Errorf("unknown sex linkage %q", linkage)
+}
+
+// Supports reports whether the sex-determination system has the sex
+// chromosome carrying loci of the given linkage.
+func (d SexDetermination) Supports(l SexLinkage) bool {
+	switch l {
+	case XLinked, YLinked:
+		return d == SexDeterminationXY
+	case ZLinked, WLinked:
+		return d == SexDeterminationZW
 	}
-	return matVal
+	return true
 }
 
-// ExpressLocusDisc calculates the expressed discrete phenotype for an agent at a specific locus.
-func ExpressLocusDisc(genotype []int32, dominance []uint8, agentIdx, locus, numLoci int) int32 {
-	base := agentIdx*numLoci*2 + locus*2
-	patVal := genotype[base]
-	matVal := genotype[base+1]
-	patDom := dominance[base]
-	matDom := dominance[base+1]
+// Copies returns how many copies of locus an individual of the given sex
+// carries: two, one for hemizygous loci (X in XY males, Z in ZW females, the
+// sex-limited Y and W, and every locus of haploid males) or none for a sex
+// chromosome the sex lacks. For a single copy, allele is the slot it is
+// inherited in (0 from the father, 1 from the mother); genotypes hold it in
+// both slots. A nil config treats every locus as autosomal.
+func (g *GeneticsConfig) Copies(locus int, sex uint8) (copies, allele int) {
+	if g == nil {
+		return 2, -1
+	}
+	if g.SexDetermination == SexDeterminationHaplodiploid && sex == world.SexMale {
+		return 1, 1
+	}
+	if locus >= len(g.SexLinkage) {
+		return 2, -1
+	}
+	switch {
+	case g.SexLinkage[locus] == XLinked && sex == world.SexMale:
+		return 1, 1
+	case g.SexLinkage[locus] == YLinked && sex == world.SexMale:
+		return 1, 0
+	case g.SexLinkage[locus] == YLinked && sex == world.SexFemale:
+		return 0, -1
+	case g.SexLinkage[locus] == ZLinked && sex == world.SexFemale:
+		return 1, 0
+	case g.SexLinkage[locus] == WLinked && sex == world.SexFemale:
+		return 1, 1
+	case g.SexLinkage[locus] == WLinked && sex == world.SexMale:
+		return 0, -1
+	}
+	return 2, -1
+}
+
+// LocusExpression returns the expression model of locus. A nil config
+// or one without models gives the dominant model.
+func (g *GeneticsConfig) LocusExpression(locus int) world.Expression {
+	if g == nil || locus >= len(g.Expression) {
+		return world.Expression{}
+	}
+	return g.Expression[locus]
+}
+
+// IsContinuous reports whether locus holds continuous alleles (GenotypeCont)
+// rather than discrete ones (GenotypeDisc).
+func (g *GeneticsConfig) IsContinuous(locus int) bool {
+	return g == nil || locus >= len(g.Continuous) || g.Continuous[locus]
+}
+
+// FixSexLinkedGenotype brings the genotype of agent idx in line with its sex
+// (see fixSexLinkedLoci). Used for founders, whose alleles are drawn as if
+// every locus were autosomal.
+func FixSexLinkedGenotype(w *world.World, idx int, genCfg *GeneticsConfig) {
+	a := w.Agents
+	fixSexLinkedLoci(genCfg, a.Sex[idx], idx*w.Config.NumLoci*2,
+		a.GenotypeCont, a.GenotypeDisc, a.DominanceCont, a.DominanceDisc)
+}
+
+// fixSexLinkedLoci rewrites the genotype at base for an individual of the
+// given sex: the single copy of a hemizygous locus fills both allele slots,
+// and a locus the sex does not carry is cleared.
+func fixSexLinkedLoci(genCfg *GeneticsConfig, sex uint8, base int, genoCont []float64, genoDisc []int32, domCont, domDisc []uint8) {
+	if genCfg == nil || (genCfg.SexLinkage == nil && genCfg.SexDetermination != SexDeterminationHaplodiploid) {
+		return
+	}
+	for locus := 0; locus < genCfg.NumLoci; locus++ {
+		copies, allele := genCfg.Copies(locus, sex)
+		pair := base + locus*2
+		switch copies {
+		case 1:
+			src, dst := pair+allele, pair+1-allele
+			genoCont[dst], domCont[dst] = genoCont[src], domCont[src]
+			genoDisc[dst], domDisc[dst] = genoDisc[src], domDisc[src]
+		case 0:
+			genoCont[pair], genoCont[pair+1] = 0, 0
+			genoDisc[pair], genoDisc[pair+1] = 0, 0
+			domCont[pair], domCont[pair+1] = 0, 0
+			domDisc[pair], domDisc[pair+1] = 0, 0
+		}
+	}
+}
+
+// LinkageMap is the chromosome map of the loci. Meiosis walks the loci
+// chromosome by chromosome in map order and switches parental strand between
+// neighbours with their recombination fraction, so linked loci tend to be
+// inherited together. Unlinked loci recombine freely.
+type LinkageMap struct {
+	order  []int     // Every locus, chromosome by chromosome in map order.
+	recomb []float64 // [i] = chance of a strand switch before order[i]; 0.5 where a chromosome starts.
+}
+
+// NewLinkageMap builds the map of the loci from their chromosome (0 =
+// unlinked) and map position in centimorgans. Returns nil when no two loci
+// share a chromosome.
+func NewLinkageMap(chromosome []int, position []float64) *LinkageMap {
+	order := make([]int, len(chromosome))
+	for l := range order {
+		order[l] = l
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if c := cmp.Compare(chromosome[a], chromosome[b]); c != 0 {
+			return c
+		}
+		return cmp.Compare(position[a], position[b])
+	})
+
+	m := &LinkageMap{order: order, recomb: make([]float64, len(order))}
+	linked := false
+	for i, l := range order {
+		m.recomb[i] = 0.5
+		if i > 0 && chromosome[l] != 0 && chromosome[l] == chromosome[order[i-1]] {
+			m.recomb[i] = RecombinationFraction(position[l] - position[order[i-1]])
+			linked = true
+		}
+	}
+	if !linked {
+		return nil
+	}
+	return m
+}
+
+// RecombinationFraction returns the recombination fraction between two loci
+// d centimorgans apart (Haldane map function: crossovers without interference).
+func RecombinationFraction(d float64) float64 {
+	return 0.5 * (1 - math.Exp(-2*math.Abs(d)/100))
+}
 
-	if patDom == matDom {
-		return (patVal + matVal) / 2
+// Meiosis draws a gamete from a diploid genotype: it calls take with every
+// locus and the allele (0 paternal, 1 maternal) the gamete carries there.
+// A nil map recombines every locus freely. Draws come from r.
+func (m *LinkageMap) Meiosis(r *rand.Rand, numLoci int, take func(locus, allele int)) {
+	if m == nil {
+		for locus := 0; locus < numLoci; locus++ {
+			take(locus, r.IntN(2))
+		}
+		return
 	}
-	if patDom == 1 {
-		return patVal
+	strand := 0
+	for i, locus := range m.order {
+		if r.Float64() < m.recomb[i] {
+			strand ^= 1
+		}
+		take(locus, strand)
 	}
-	return matVal
+}
+
+// ExpressLocusCont calculates the expressed continuous phenotype for an agent
+// at a specific locus under the locus's expression model e. A hemizygous
+// locus (copies = 1) expresses its single allele whatever its dominance; a
+// locus the agent does not carry (copies = 0) expresses 0 (see
+// GeneticsConfig.Copies).
+func ExpressLocusCont(genotype []float64, dominance []uint8, agentIdx, locus, numLoci, copies int, e world.Expression) float64 {
+	base := agentIdx*numLoci*2 + locus*2
+	switch copies {
+	case 0:
+		return 0
+	case 1:
+		return genotype[base]
+	}
+	return e.Cont(genotype[base], genotype[base+1], dominance[base], dominance[base+1])
+}
+
+// ExpressLocusDisc calculates the expressed discrete phenotype for an agent at a specific locus.
+func ExpressLocusDisc(genotype []int32, dominance []uint8, agentIdx, locus, numLoci, copies int, e world.Expression) int32 {
+	base := agentIdx*numLoci*2 + locus*2
+	switch copies {
+	case 0:
+		return 0
+	case 1:
+		return genotype[base]
+	}
+	return e.Disc(genotype[base], genotype[base+1], dominance[base], dominance[base+1])
 }
 
 // Crossover performs meiotic recombination between two parent genotypes, producing
-// a child genotype. Each locus independently selects one allele from each parent.
+// a child genotype. Each parent contributes a gamete drawn along the linkage map
+// (nil = every locus selects its allele independently).
 // The child receives one allele from parent A and one from parent B.
 //
 // parentA/parentB are flat genotype arrays: [locus*2 + allele]
-// childOut must be pre-allocated with the same length.
+// childOut must be pre-allocated with the same length. Draws come from r.
 func CrossoverCont(
+	r *rand.Rand, linkage *LinkageMap,
 	parentAGeno, parentBGeno []float64,
 	parentADom, parentBDom []uint8,
 	childGenoOut []float64, childDomOut []uint8,
 	numLoci int,
 ) {
-	for locus := 0; locus < numLoci; locus++ {
-		base := locus * 2
-
-		// From parent A: randomly select paternal (0) or maternal (1) allele.
-		alleleA := rand.IntN(2)
-		childGenoOut[base] = parentAGeno[base+alleleA]
-		childDomOut[base] = parentADom[base+alleleA]
-
-		// From parent B: randomly select paternal (0) or maternal (1) allele.
-		alleleB := rand.IntN(2)
-		childGenoOut[base+1] = parentBGeno[base+alleleB]
-		childDomOut[base+1] = parentBDom[base+alleleB]
-	}
+	// From parent A: the paternal (0) or maternal (1) allele per locus.
+	linkage.Meiosis(r, numLoci, func(locus, allele int) {
+		childGenoOut[locus*2] = parentAGeno[locus*2+allele]
+		childDomOut[locus*2] = parentADom[locus*2+allele]
+	})
+	// From parent B.
+	linkage.Meiosis(r, numLoci, func(locus, allele int) {
+		childGenoOut[locus*2+1] = parentBGeno[locus*2+allele]
+		childDomOut[locus*2+1] = parentBDom[locus*2+allele]
+	})
 }
 
 // CrossoverDisc performs meiotic recombination for discrete loci.
 func CrossoverDisc(
+	r *rand.Rand, linkage *LinkageMap,
 	parentAGeno, parentBGeno []int32,
 	parentADom, parentBDom []uint8,
 	childGenoOut []int32, childDomOut []uint8,
 	numLoci int,
 ) {
-	for locus := 0; locus < numLoci; locus++ {
-		base := locus * 2
-
-		alleleA := rand.IntN(2)
-		childGenoOut[base] = parentAGeno[base+alleleA]
-		childDomOut[base] = parentADom[base+alleleA]
-
-		alleleB := rand.IntN(2)
-		childGenoOut[base+1] = parentBGeno[base+alleleB]
-		childDomOut[base+1] = parentBDom[base+alleleB]
-	}
+	linkage.Meiosis(r, numLoci, func(locus, allele int) {
+		childGenoOut[locus*2] = parentAGeno[locus*2+allele]
+		childDomOut[locus*2] = parentADom[locus*2+allele]
+	})
+	linkage.Meiosis(r, numLoci, func(locus, allele int) {
+		childGenoOut[locus*2+1] = parentBGeno[locus*2+allele]
+		childDomOut[locus*2+1] = parentBDom[locus*2+allele]
+	})
 }
 
 // MutateCont applies mutations to a continuous genotype in-place.
-// Each allele has an independent chance of mutating based on its dominance.
-func MutateCont(genotype []float64, dominance []uint8, numLoci int, lociCfg []LocusConfig) {
+// Each allele has an independent chance of mutating based on its dominance;
+// draws come from r.
+func MutateCont(r *rand.Rand, genotype []float64, dominance []uint8, numLoci int, lociCfg []LocusConfig) {
 	for locus := 0; locus < numLoci; locus++ {
 		cfg := lociCfg[locus]
 		base := locus * 2
@@ -122,9 +338,9 @@ func MutateCont(genotype []float64, dominance []uint8, numLoci int, lociCfg []Lo
 				rng = cfg.MutationRangeRec
 			}
 
-			if rate > 0 && rand.Float64() < rate {
+			if rate > 0 && r.Float64() < rate {
 				// Apply mutation: value ± random within range.
-				delta := (rand.Float64()*2 - 1) * rng
+				delta := (r.Float64()*2 - 1) * rng
 				genotype[idx] += delta
 			}
 		}
@@ -132,7 +348,7 @@ func MutateCont(genotype []float64, dominance []uint8, numLoci int, lociCfg []Lo
 }
 
 // MutateDisc applies mutations to a discrete genotype in-place.
-func MutateDisc(genotype []int32, dominance []uint8, numLoci int, lociCfg []LocusConfig) {
+func MutateDisc(r *rand.Rand, genotype []int32, dominance []uint8, numLoci int, lociCfg []LocusConfig) {
 	for locus := 0; locus < numLoci; locus++ {
 		cfg := lociCfg[locus]
 		base := locus * 2
@@ -149,9 +365,9 @@ func MutateDisc(genotype []int32, dominance []uint8, numLoci int, lociCfg []Locu
 				rng = cfg.MutationRangeRec
 			}
 
-			if rate > 0 && rand.Float64() < rate {
+			if rate > 0 && r.Float64() < rate {
 				// Apply discrete mutation: ± random int within range.
-				delta := rand.IntN(int(rng)*2+1) - int(rng)
+				delta := r.IntN(int(rng)*2+1) - int(rng)
 				genotype[idx] += int32(delta)
 			}
 		}
@@ -208,16 +424,41 @@ func WriteDominance(dst []uint8, agentIdx, numLoci int, src []uint8) {
 }
 
 // DetermineSex returns SexMale or SexFemale based on proportional probability.
-func DetermineSex(maleRatio, femaleRatio int) uint8 {
+// This is the ratio sex-determination system; see eggSex for the others.
+func DetermineSex(r *rand.Rand, maleRatio, femaleRatio int) uint8 {
 	total := maleRatio + femaleRatio
 	if total <= 0 {
-		if rand.IntN(2) == 0 {
+		if r.IntN(2) == 0 {
 			return world.SexMale
 		}
 		return world.SexFemale
 	}
-	if rand.IntN(total) < maleRatio {
+	if r.IntN(total) < maleRatio {
 		return world.SexMale
 	}
 	return world.SexFemale
 }
+
+// eggSex decides the sex of a new egg and whether a stored sperm pack
+// fertilizes it. Under XY (ZW) determination the sex follows from the sex
+// chromosome of the father's (mother's) gamete, either one with equal
+// chance. Under haplodiploidy the egg is fertilized, and so female, with the
+// female share of the sex ratio, and otherwise develops as a haploid male.
+// Environmental determination leaves the sex undefined until eclosion.
+func eggSex(r *rand.Rand, mode SexDetermination, maleRatio, femaleRatio int) (sex uint8, fertilized bool) {
+	switch mode {
+	case SexDeterminationXY, SexDeterminationZW:
+		if r.IntN(2) == 0 {
+			return world.SexFemale, true
+		}
+		return world.SexMale, true
+	case SexDeterminationHaplodiploid:
+		if DetermineSex(r, maleRatio, femaleRatio) == world.SexFemale {
+			return world.SexFemale, true
+		}
+		return world.SexMale, false
+	case SexDeterminationEnvironmental:
+		return world.SexUndefined, true
+	}
+	return DetermineSex(r, maleRatio, femaleRatio), true
+}
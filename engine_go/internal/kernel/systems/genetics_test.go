@@ -1,8 +1,11 @@
 package systems
 
 import (
+	"math"
+	"math/rand/v2"
 	"testing"
 
+	"galatea/engine/internal/kernel/formulas"
 	"galatea/engine/internal/kernel/world"
 )
 
@@ -10,7 +13,7 @@ func TestExpressLocusCont_Codominance(t *testing.T) {
 	// Both dominant → average.
 	genotype := []float64{2.0, 4.0}
 	dominance := []uint8{1, 1}
-	result := ExpressLocusCont(genotype, dominance, 0, 0, 1)
+	result := ExpressLocusCont(genotype, dominance, 0, 0, 1, 2, world.Expression{})
 	if result != 3.0 {
 		t.Fatalf("expected 3.0 (codominance), got %f", result)
 	}
@@ -19,7 +22,7 @@ func TestExpressLocusCont_Codominance(t *testing.T) {
 func TestExpressLocusCont_PaternalDominance(t *testing.T) {
 	genotype := []float64{2.0, 4.0}
 	dominance := []uint8{1, 0} // Pat dominant, mat recessive.
-	result := ExpressLocusCont(genotype, dominance, 0, 0, 1)
+	result := ExpressLocusCont(genotype, dominance, 0, 0, 1, 2, world.Expression{})
 	if result != 2.0 {
 		t.Fatalf("expected 2.0 (paternal), got %f", result)
 	}
@@ -28,7 +31,7 @@ func TestExpressLocusCont_PaternalDominance(t *testing.T) {
 func TestExpressLocusCont_MaternalDominance(t *testing.T) {
 	genotype := []float64{2.0, 4.0}
 	dominance := []uint8{0, 1} // Pat recessive, mat dominant.
-	result := ExpressLocusCont(genotype, dominance, 0, 0, 1)
+	result := ExpressLocusCont(genotype, dominance, 0, 0, 1, 2, world.Expression{})
 	if result != 4.0 {
 		t.Fatalf("expected 4.0 (maternal), got %f", result)
 	}
@@ -37,7 +40,7 @@ func TestExpressLocusCont_MaternalDominance(t *testing.T) {
 func TestExpressLocusDisc(t *testing.T) {
 	genotype := []int32{10, 20}
 	dominance := []uint8{1, 1} // Both dominant → average.
-	result := ExpressLocusDisc(genotype, dominance, 0, 0, 1)
+	result := ExpressLocusDisc(genotype, dominance, 0, 0, 1, 2, world.Expression{})
 	if result != 15 {
 		t.Fatalf("expected 15, got %d", result)
 	}
@@ -55,7 +58,7 @@ func TestCrossoverCont(t *testing.T) {
 	child := make([]float64, size)
 	childDom := make([]uint8, size)
 
-	CrossoverCont(parentA, parentB, domA, domB, child, childDom, numLoci)
+	CrossoverCont(world.NewStreams(1).Genetics, nil, parentA, parentB, domA, domB, child, childDom, numLoci)
 
 	// Each locus: allele 0 comes from A, allele 1 comes from B.
 	for locus := 0; locus < numLoci; locus++ {
@@ -83,7 +86,7 @@ func TestCrossoverDisc(t *testing.T) {
 	child := make([]int32, size)
 	childDom := make([]uint8, size)
 
-	CrossoverDisc(parentA, parentB, domA, domB, child, childDom, numLoci)
+	CrossoverDisc(world.NewStreams(1).Genetics, nil, parentA, parentB, domA, domB, child, childDom, numLoci)
 
 	for locus := 0; locus < numLoci; locus++ {
 		base := locus * 2
@@ -96,6 +99,59 @@ func TestCrossoverDisc(t *testing.T) {
 	}
 }
 
+func TestRecombinationFraction(t *testing.T) {
+	if r := RecombinationFraction(0); r != 0 {
+		t.Fatalf("expected 0 at 0 cM, got %f", r)
+	}
+	if r := RecombinationFraction(-10); math.Abs(r-0.0906) > 1e-4 {
+		t.Fatalf("expected ~0.0906 at 10 cM, got %f", r)
+	}
+	if r := RecombinationFraction(1000); math.Abs(r-0.5) > 1e-6 {
+		t.Fatalf("expected ~0.5 far apart, got %f", r)
+	}
+}
+
+func TestNewLinkageMap_NilWithoutLinkedLoci(t *testing.T) {
+	// Unlinked loci and chromosomes with a single locus recombine freely.
+	if m := NewLinkageMap([]int{0, 0, 1, 2}, []float64{0, 5, 10, 15}); m != nil {
+		t.Fatalf("expected no linkage map, got %+v", m)
+	}
+}
+
+func TestMeiosis_FollowsLinkageMap(t *testing.T) {
+	// Loci 0 and 3 share a map position on chromosome 1, locus 1 lies 10 cM
+	// away from them and locus 2 is unlinked.
+	m := NewLinkageMap([]int{1, 1, 0, 1}, []float64{0, 10, 0, 0})
+	r := world.NewStreams(1).Genetics
+
+	const iterations = 20000
+	var gamete [4]int
+	recombinant, unlinked, maternal := 0, 0, 0
+	for i := 0; i < iterations; i++ {
+		m.Meiosis(r, 4, func(locus, allele int) { gamete[locus] = allele })
+		if gamete[0] != gamete[3] {
+			t.Fatal("loci at the same map position recombined")
+		}
+		if gamete[0] != gamete[1] {
+			recombinant++
+		}
+		if gamete[0] != gamete[2] {
+			unlinked++
+		}
+		maternal += gamete[0]
+	}
+
+	if f := float64(recombinant) / iterations; math.Abs(f-RecombinationFraction(10)) > 0.01 {
+		t.Fatalf("expected ~%.3f recombinants at 10 cM, got %.3f", RecombinationFraction(10), f)
+	}
+	if f := float64(unlinked) / iterations; math.Abs(f-0.5) > 0.02 {
+		t.Fatalf("expected ~0.5 recombinants with the unlinked locus, got %.3f", f)
+	}
+	if f := float64(maternal) / iterations; math.Abs(f-0.5) > 0.02 {
+		t.Fatalf("expected either strand to start a chromosome, got %.3f maternal", f)
+	}
+}
+
 func TestMutateCont_HighRate(t *testing.T) {
 	numLoci := 2
 	genotype := []float64{1.0, 1.0, 2.0, 2.0}
@@ -108,7 +164,7 @@ func TestMutateCont_HighRate(t *testing.T) {
 	original := make([]float64, len(genotype))
 	copy(original, genotype)
 
-	MutateCont(genotype, dominance, numLoci, cfg)
+	MutateCont(world.NewStreams(1).Genetics, genotype, dominance, numLoci, cfg)
 
 	// With rate=1.0, all should mutate.
 	mutated := 0
@@ -134,7 +190,7 @@ func TestMutateCont_ZeroRate(t *testing.T) {
 	original := make([]float64, len(genotype))
 	copy(original, genotype)
 
-	MutateCont(genotype, dominance, numLoci, cfg)
+	MutateCont(world.NewStreams(1).Genetics, genotype, dominance, numLoci, cfg)
 
 	// No mutations.
 	for i := range genotype {
@@ -145,10 +201,11 @@ func TestMutateCont_ZeroRate(t *testing.T) {
 }
 
 func TestDetermineSex(t *testing.T) {
+	r := world.NewStreams(1).Genetics
 	maleCount := 0
 	const iterations = 10000
 	for i := 0; i < iterations; i++ {
-		if DetermineSex(50, 50) == world.SexMale {
+		if DetermineSex(r, 50, 50) == world.SexMale {
 			maleCount++
 		}
 	}
@@ -159,10 +216,11 @@ func TestDetermineSex(t *testing.T) {
 }
 
 func TestDetermineSex_Biased(t *testing.T) {
+	r := world.NewStreams(1).Genetics
 	maleCount := 0
 	const iterations = 10000
 	for i := 0; i < iterations; i++ {
-		if DetermineSex(80, 20) == world.SexMale {
+		if DetermineSex(r, 80, 20) == world.SexMale {
 			maleCount++
 		}
 	}
@@ -218,6 +276,45 @@ func TestGametogenesis_LimitedByReserves(t *testing.T) {
 	}
 }
 
+func TestReproductionConfigFor(t *testing.T) {
+	reg := formulas.NewRegistry()
+	reg.Compile("eggs", "4")
+	reg.Compile("packs", "Age * 2")
+	reg.Compile("cost.F", "Age")
+	eval := formulas.NewEvaluator(16)
+	eval.Set("Age", 3)
+
+	reproCfg := ReproductionConfig{
+		MaxGametes:  10,
+		GameteCosts: []int32{5, 5},
+		Formulas: ReproductionFormulas{
+			MaxEggs:      reg.Get("eggs"),
+			GameteCostsM: []*formulas.Program{nil, nil},
+		},
+	}
+	if reproCfg.Formulas.Dynamic() {
+		t.Fatal("numeric literals and nil formulas are not dynamic")
+	}
+	reproCfg.Formulas.MaxSpermPacks = reg.Get("packs")
+	reproCfg.Formulas.GameteCostsF = []*formulas.Program{nil, reg.Get("cost.F")}
+	if !reproCfg.Formulas.Dynamic() {
+		t.Fatal("expected a dynamic formula")
+	}
+
+	female := reproCfg.For(eval, world.SexFemale)
+	if female.MaxGametes != 4 || female.GameteCosts[0] != 5 || female.GameteCosts[1] != 3 {
+		t.Fatalf("unexpected female parameters: max %d, costs %v", female.MaxGametes, female.GameteCosts)
+	}
+	male := reproCfg.For(eval, world.SexMale)
+	if male.MaxGametes != 6 || male.GameteCosts[1] != 5 {
+		t.Fatalf("unexpected male parameters: max %d, costs %v", male.MaxGametes, male.GameteCosts)
+	}
+	// Without an evaluator a dynamic formula keeps the default.
+	if got := reproCfg.For(nil, world.SexMale).MaxGametes; got != 10 {
+		t.Fatalf("expected the default 10 without an evaluator, got %d", got)
+	}
+}
+
 func TestCopulate(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -289,6 +386,9 @@ func TestOviposit(t *testing.T) {
 		w.Agents.DominanceDisc[genoBase+i] = uint8((i + 1) % 2)
 	}
 
+	male := w.AddAgent()
+	storeSpermPack(w, female, male, 100, nil)
+
 	reproCfg := ReproductionConfig{
 		EggsPerCycle: 3,
 		EggFraction:  0.1,
@@ -301,7 +401,7 @@ func TestOviposit(t *testing.T) {
 		LociDisc: make([]LocusConfig, cfg.NumLoci),
 	}
 
-	laid := Oviposit(w, female, reproCfg, genCfg)
+	laid := Oviposit(w, female, -1, nil, reproCfg, genCfg)
 
 	if laid != 3 {
 		t.Fatalf("expected 3 eggs laid, got %d", laid)
@@ -328,13 +428,59 @@ func TestOviposit(t *testing.T) {
 	}
 }
 
+func TestOviposit_UsesMotherSexRatio(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	female := w.AddAgent()
+	a := w.Agents
+	a.Sex[female] = world.SexFemale
+	a.PrototypeID[female] = 0
+	a.Age[female] = 5
+	a.FertilizedCount[female] = 10
+	storeSpermPack(w, female, w.AddAgent(), 100, nil)
+
+	// The female prototype only has daughters; the defaults would mix sexes.
+	reg := formulas.NewRegistry()
+	reg.Compile("males", "0")
+	reg.Compile("females", "Age")
+	reproCfg := ReproductionConfig{
+		EggsPerCycle:    10,
+		MaleRatio:       50,
+		FemaleRatio:     50,
+		NumPrototypesM:  1,
+		SexRatioMales:   []*formulas.Program{nil, reg.Get("males")},
+		SexRatioFemales: []*formulas.Program{nil, reg.Get("females")},
+	}
+
+	eval := formulas.NewEvaluator(128)
+	formulas.NewEnvBuilder(eval, cfg).SetAgentVars(w, female)
+	if m, f := SexRatio(w, female, eval, reproCfg); m != 0 || f != 5 {
+		t.Fatalf("expected ratio 0:5, got %d:%d", m, f)
+	}
+
+	laid := Oviposit(w, female, -1, eval, reproCfg, GeneticsConfig{NumLoci: cfg.NumLoci})
+	if laid != 10 {
+		t.Fatalf("expected 10 eggs laid, got %d", laid)
+	}
+	for i := 0; i < laid; i++ {
+		if w.Eggs.Sex[i] != world.SexFemale {
+			t.Fatalf("egg %d: expected a daughter, got sex %d", i, w.Eggs.Sex[i])
+		}
+	}
+}
+
 func TestSpermConsumption(t *testing.T) {
 	cfg := testCfg()
+	cfg.MaxStoredPacks = 100
 	w := world.New(cfg)
 
 	female := w.AddAgent()
 	w.Agents.Sex[female] = world.SexFemale
-	w.Agents.SpermPacksCount[female] = 100
+	male := w.AddAgent()
+	for i := 0; i < 100; i++ {
+		storeSpermPack(w, female, male, 100, nil)
+	}
 
 	reproCfg := ReproductionConfig{
 		ConsumptionRate: 0.5, // 50% chance per pack per tick.
@@ -349,6 +495,223 @@ func TestSpermConsumption(t *testing.T) {
 	}
 }
 
+func TestSpermConsumption_DegradesPaternity(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	female := w.AddAgent()
+	w.Agents.Sex[female] = world.SexFemale
+	male := w.AddAgent()
+	storeSpermPack(w, female, male, 100, nil)
+	storeSpermPack(w, female, male, 1, nil)
+
+	reproCfg := ReproductionConfig{SpermDegradation: 0.5}
+	SpermConsumption(w, female, reproCfg)
+
+	// 100 loses 50; 1 loses Round(0.5) = 1 and is discarded.
+	if w.Agents.SpermPacksCount[female] != 1 {
+		t.Fatalf("expected 1 pack left, got %d", w.Agents.SpermPacksCount[female])
+	}
+	slot := female * cfg.MaxStoredPacks
+	if w.Agents.SpermPaternity[slot] != 50 {
+		t.Fatalf("expected paternity 50, got %d", w.Agents.SpermPaternity[slot])
+	}
+}
+
+func TestSpermConsumption_DegradesToZero(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	female := w.AddAgent()
+	w.Agents.Sex[female] = world.SexFemale
+	storeSpermPack(w, female, w.AddAgent(), 100, nil)
+
+	// Once 5% of the paternity rounds to zero, it still loses a point a tick.
+	reproCfg := ReproductionConfig{SpermDegradation: 0.05}
+	ticks := 0
+	for ; ticks < 200 && w.Agents.SpermPacksCount[female] > 0; ticks++ {
+		SpermConsumption(w, female, reproCfg)
+	}
+	if w.Agents.SpermPacksCount[female] != 0 {
+		t.Fatalf("expected the pack to decay and be removed, paternity still %d",
+			w.Agents.SpermPaternity[female*cfg.MaxStoredPacks])
+	}
+	if ticks < 20 {
+		t.Fatalf("a 5%% decay should take more than 20 ticks, took %d", ticks)
+	}
+}
+
+func TestCopulate_StoresDonorGamete(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	a := w.Agents
+
+	male := w.AddAgent()
+	a.Sex[male] = world.SexMale
+	a.GametesCount[male] = 10
+	maleBase := male * cfg.NumLoci * 2
+	for i := 0; i < cfg.NumLoci*2; i++ {
+		a.GenotypeCont[maleBase+i] = float64(i + 1)
+		a.GenotypeDisc[maleBase+i] = int32(i + 10)
+	}
+
+	female := w.AddAgent()
+	a.Sex[female] = world.SexFemale
+	a.SpermPacksCount[female] = 4
+
+	reproCfg := ReproductionConfig{PacksTransferred: 3, MaxStoredPacks: 10, Paternity: 70}
+	Copulate(w, male, female, reproCfg, GeneticsConfig{NumLoci: cfg.NumLoci})
+
+	// Only one slot is left in the world's pack storage.
+	if a.SpermPacksCount[female] != 5 || a.GametesCount[male] != 9 {
+		t.Fatalf("expected 5 packs and 9 male gametes, got %d and %d",
+			a.SpermPacksCount[female], a.GametesCount[male])
+	}
+	slot := female*cfg.MaxStoredPacks + 4
+	if a.SpermDonor[slot] != a.ID[male] || a.SpermPaternity[slot] != 70 {
+		t.Fatalf("pack should hold donor %d with paternity 70, got %d with %d",
+			a.ID[male], a.SpermDonor[slot], a.SpermPaternity[slot])
+	}
+	for locus := 0; locus < cfg.NumLoci; locus++ {
+		g := a.SpermGenotypeCont[slot*cfg.NumLoci+locus]
+		if g != float64(locus*2+1) && g != float64(locus*2+2) {
+			t.Fatalf("locus %d: gamete allele %f is not one of the male's", locus, g)
+		}
+		d := a.SpermGenotypeDisc[slot*cfg.NumLoci+locus]
+		if d != int32(locus*2+10) && d != int32(locus*2+11) {
+			t.Fatalf("locus %d: gamete allele %d is not one of the male's", locus, d)
+		}
+	}
+}
+
+func TestOviposit_DrawsSireByPaternity(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	a := w.Agents
+
+	female := w.AddAgent()
+	a.Sex[female] = world.SexFemale
+	a.FertilizedCount[female] = 20
+	rival := w.AddAgent()
+	sire := w.AddAgent()
+	for i := 0; i < cfg.NumLoci*2; i++ {
+		a.GenotypeCont[rival*cfg.NumLoci*2+i] = 1
+		a.GenotypeCont[sire*cfg.NumLoci*2+i] = 2
+	}
+	storeSpermPack(w, female, rival, 0, nil)
+	storeSpermPack(w, female, sire, 100, nil)
+
+	reproCfg := ReproductionConfig{EggsPerCycle: 20, MaleRatio: 50, FemaleRatio: 50}
+	laid := Oviposit(w, female, -1, nil, reproCfg, GeneticsConfig{NumLoci: cfg.NumLoci})
+	if laid != 20 {
+		t.Fatalf("expected 20 eggs laid, got %d", laid)
+	}
+
+	for i := 0; i < laid; i++ {
+		if w.Eggs.ParentMaleID[i] != a.ID[sire] || w.Eggs.ParentFemaleID[i] != a.ID[female] {
+			t.Fatalf("egg %d: expected parents %d x %d, got %d x %d",
+				i, a.ID[sire], a.ID[female], w.Eggs.ParentMaleID[i], w.Eggs.ParentFemaleID[i])
+		}
+		for locus := 0; locus < cfg.NumLoci; locus++ {
+			base := i*cfg.NumLoci*2 + locus*2
+			if w.Eggs.GenotypeCont[base] != 2 || w.Eggs.GenotypeCont[base+1] != 0 {
+				t.Fatalf("egg %d locus %d: expected paternal 2 and maternal 0, got %f and %f",
+					i, locus, w.Eggs.GenotypeCont[base], w.Eggs.GenotypeCont[base+1])
+			}
+		}
+	}
+}
+
+func TestSirePack_SpermUseModes(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	a := w.Agents
+
+	female := w.AddAgent()
+	a.Sex[female] = world.SexFemale
+	first, second, third := w.AddAgent(), w.AddAgent(), w.AddAgent()
+	storeSpermPack(w, female, first, 0, nil)
+	storeSpermPack(w, female, second, 100, nil)
+	storeSpermPack(w, female, third, 0, nil)
+
+	donor := func(mode SpermUse) uint64 { return a.SpermDonor[sirePack(w, female, mode)] }
+	if d := donor(SpermUseFirstMale); d != a.ID[first] {
+		t.Fatalf("first male: expected donor %d, got %d", a.ID[first], d)
+	}
+	if d := donor(SpermUseLastMale); d != a.ID[third] {
+		t.Fatalf("last male: expected donor %d, got %d", a.ID[third], d)
+	}
+	if d := donor(SpermUseRaffle); d != a.ID[second] {
+		t.Fatalf("raffle: expected donor %d, got %d", a.ID[second], d)
+	}
+
+	// Removing a pack keeps the storage order of the others.
+	removeSpermPack(w, female, 0)
+	if d := donor(SpermUseFirstMale); d != a.ID[second] {
+		t.Fatalf("after removal: expected first donor %d, got %d", a.ID[second], d)
+	}
+	if d := donor(SpermUseLastMale); d != a.ID[third] {
+		t.Fatalf("after removal: expected last donor %d, got %d", a.ID[third], d)
+	}
+}
+
+func TestCopulate_DisplacesStoredPacks(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	a := w.Agents
+
+	female := w.AddAgent()
+	a.Sex[female] = world.SexFemale
+	rival := w.AddAgent()
+	storeSpermPack(w, female, rival, 100, nil)
+	storeSpermPack(w, female, rival, 100, nil)
+
+	male := w.AddAgent()
+	a.Sex[male] = world.SexMale
+	a.GametesCount[male] = 5
+
+	reproCfg := ReproductionConfig{PacksTransferred: 1, MaxStoredPacks: 5, Paternity: 100,
+		SpermUse: SpermUseDisplacement}
+	Copulate(w, male, female, reproCfg, GeneticsConfig{NumLoci: cfg.NumLoci})
+
+	slot := female * cfg.MaxStoredPacks
+	if a.SpermPacksCount[female] != 1 || a.SpermDonor[slot] != a.ID[male] {
+		t.Fatalf("expected a single pack from donor %d, got %d packs from %d",
+			a.ID[male], a.SpermPacksCount[female], a.SpermDonor[slot])
+	}
+}
+
+func TestParseSpermUse(t *testing.T) {
+	for mode, want := range map[string]SpermUse{
+		"": SpermUseRaffle, "raffle": SpermUseRaffle, "first_male": SpermUseFirstMale,
+		"last_male": SpermUseLastMale, "displacement": SpermUseDisplacement,
+	} {
+		if got, err := ParseSpermUse(mode); err != nil || got != want {
+			t.Errorf("%q: expected %d, got %d (%v)", mode, want, got, err)
+		}
+	}
+	if _, err := ParseSpermUse("second_male"); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+}
+
+func TestOviposit_NeedsStoredPacks(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	female := w.AddAgent()
+	w.Agents.Sex[female] = world.SexFemale
+	w.Agents.FertilizedCount[female] = 3
+
+	reproCfg := ReproductionConfig{EggsPerCycle: 3, MaleRatio: 50, FemaleRatio: 50}
+	if laid := Oviposit(w, female, -1, nil, reproCfg, GeneticsConfig{NumLoci: cfg.NumLoci}); laid != 0 {
+		t.Fatalf("expected no eggs without sperm packs, got %d", laid)
+	}
+	if w.Agents.FertilizedCount[female] != 3 {
+		t.Fatalf("fertilized eggs should be kept, got %d", w.Agents.FertilizedCount[female])
+	}
+}
+
 func TestCopyAndWriteGenotype(t *testing.T) {
 	numLoci := 3
 	src := make([]float64, 10*numLoci*2) // 10 agents.
@@ -375,3 +738,309 @@ func TestCopyAndWriteGenotype(t *testing.T) {
 		t.Fatalf("write incorrect at agent 5")
 	}
 }
+
+func TestParseSexDetermination(t *testing.T) {
+	for mode, want := range map[string]SexDetermination{
+		"": SexDeterminationRatio, "ratio": SexDeterminationRatio, "xy": SexDeterminationXY,
+		"zw": SexDeterminationZW, "haplodiploid": SexDeterminationHaplodiploid,
+		"environmental": SexDeterminationEnvironmental,
+	} {
+		if got, err := ParseSexDetermination(mode); err != nil || got != want {
+			t.Errorf("%q: expected %d, got %d (%v)", mode, want, got, err)
+		}
+	}
+	if _, err := ParseSexDetermination("temperature"); err == nil {
+		t.Fatal("expected an error for an unknown system")
+	}
+	for linkage, want := range map[string]SexLinkage{
+		"": Autosomal, "autosomal": Autosomal, "X": XLinked, "Y": YLinked, "Z": ZLinked, "W": WLinked,
+	} {
+		if got, err := ParseSexLinkage(linkage); err != nil || got != want {
+			t.Errorf("%q: expected %d, got %d (%v)", linkage, want, got, err)
+		}
+	}
+	if _, err := ParseSexLinkage("x"); err == nil {
+		t.Fatal("expected an error for an unknown linkage")
+	}
+}
+
+func TestCopies(t *testing.T) {
+	xy := &GeneticsConfig{NumLoci: 3, SexDetermination: SexDeterminationXY,
+		SexLinkage: []SexLinkage{Autosomal, XLinked, YLinked}}
+	zw := &GeneticsConfig{NumLoci: 3, SexDetermination: SexDeterminationZW,
+		SexLinkage: []SexLinkage{Autosomal, ZLinked, WLinked}}
+	haplo := &GeneticsConfig{NumLoci: 3, SexDetermination: SexDeterminationHaplodiploid}
+	tests := []struct {
+		name           string
+		cfg            *GeneticsConfig
+		locus          int
+		sex            uint8
+		copies, allele int
+	}{
+		{"nil config", nil, 1, world.SexMale, 2, -1},
+		{"autosomal male", xy, 0, world.SexMale, 2, -1},
+		{"X female", xy, 1, world.SexFemale, 2, -1},
+		{"X male", xy, 1, world.SexMale, 1, 1},
+		{"Y male", xy, 2, world.SexMale, 1, 0},
+		{"Y female", xy, 2, world.SexFemale, 0, -1},
+		{"Z male", zw, 1, world.SexMale, 2, -1},
+		{"Z female", zw, 1, world.SexFemale, 1, 0},
+		{"W female", zw, 2, world.SexFemale, 1, 1},
+		{"W male", zw, 2, world.SexMale, 0, -1},
+		{"haploid male", haplo, 0, world.SexMale, 1, 1},
+		{"diploid female", haplo, 0, world.SexFemale, 2, -1},
+	}
+	for _, tt := range tests {
+		copies, allele := tt.cfg.Copies(tt.locus, tt.sex)
+		if copies != tt.copies || allele != tt.allele {
+			t.Errorf("%s: expected (%d, %d), got (%d, %d)", tt.name, tt.copies, tt.allele, copies, allele)
+		}
+	}
+}
+
+func TestExpressLocus_Hemizygous(t *testing.T) {
+	genotype := []float64{3, 7}
+	dominance := []uint8{0, 1}
+	if v := ExpressLocusCont(genotype, dominance, 0, 0, 1, 1, world.Expression{}); v != 3 {
+		t.Fatalf("single copy: expected 3, got %f", v)
+	}
+	if v := ExpressLocusCont(genotype, dominance, 0, 0, 1, 0, world.Expression{}); v != 0 {
+		t.Fatalf("no copy: expected 0, got %f", v)
+	}
+	if v := ExpressLocusDisc([]int32{4, 9}, dominance, 0, 0, 1, 1, world.Expression{}); v != 4 {
+		t.Fatalf("single copy: expected 4, got %d", v)
+	}
+}
+
+func TestEggSex(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	counts := map[uint8]int{}
+	for i := 0; i < 1000; i++ {
+		sex, fertilized := eggSex(r, SexDeterminationXY, 100, 0)
+		if !fertilized {
+			t.Fatal("XY eggs are always fertilized")
+		}
+		counts[sex]++
+	}
+	// Sex chromosomes segregate 1:1 whatever the sex ratio.
+	if counts[world.SexMale] < 400 || counts[world.SexFemale] < 400 {
+		t.Fatalf("expected about 1:1 under XY, got %v", counts)
+	}
+
+	for i := 0; i < 100; i++ {
+		sex, fertilized := eggSex(r, SexDeterminationHaplodiploid, 30, 70)
+		if fertilized != (sex == world.SexFemale) {
+			t.Fatalf("haplodiploid: sex %d with fertilized=%v", sex, fertilized)
+		}
+	}
+	if sex, fertilized := eggSex(r, SexDeterminationEnvironmental, 100, 0); sex != world.SexUndefined || !fertilized {
+		t.Fatalf("environmental: expected an undefined fertilized egg, got %d, %v", sex, fertilized)
+	}
+	if sex, _ := eggSex(r, SexDeterminationRatio, 0, 100); sex != world.SexFemale {
+		t.Fatalf("ratio: expected female, got %d", sex)
+	}
+}
+
+func TestOviposit_XLinkedAndYLinkedInheritance(t *testing.T) {
+	cfg := testCfg() // Locus 0 X-linked, locus 1 Y-linked.
+	w := world.New(cfg)
+	a := w.Agents
+	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci, SexDetermination: SexDeterminationXY,
+		SexLinkage: []SexLinkage{XLinked, YLinked}}
+
+	female := w.AddAgent()
+	a.Sex[female] = world.SexFemale
+	a.FertilizedCount[female] = 50
+	base := female * cfg.NumLoci * 2
+	a.GenotypeCont[base], a.GenotypeCont[base+1] = 1, 2
+
+	male := w.AddAgent()
+	a.Sex[male] = world.SexMale
+	base = male * cfg.NumLoci * 2
+	a.GenotypeCont[base], a.GenotypeCont[base+1] = 7, 7   // Hemizygous X.
+	a.GenotypeCont[base+2], a.GenotypeCont[base+3] = 9, 9 // Y.
+	storeSpermPack(w, female, male, 100, nil)
+
+	reproCfg := ReproductionConfig{EggsPerCycle: 50, MaleRatio: 50, FemaleRatio: 50}
+	laid := Oviposit(w, female, -1, nil, reproCfg, genCfg)
+	if laid != 50 {
+		t.Fatalf("expected 50 eggs laid, got %d", laid)
+	}
+
+	sexes := map[uint8]int{}
+	for i := 0; i < laid; i++ {
+		g := w.Eggs.GenotypeCont[i*cfg.NumLoci*2 : (i+1)*cfg.NumLoci*2]
+		maternal := g[1]
+		if maternal != 1 && maternal != 2 {
+			t.Fatalf("egg %d: maternal X allele %f not from the mother", i, maternal)
+		}
+		switch w.Eggs.Sex[i] {
+		case world.SexMale: // X from the mother, Y from the father.
+			if g[0] != maternal || g[2] != 9 || g[3] != 9 {
+				t.Fatalf("son %d: expected X %f twice and Y 9, got %v", i, maternal, g)
+			}
+		case world.SexFemale: // X from each parent, no Y.
+			if g[0] != 7 || g[2] != 0 || g[3] != 0 {
+				t.Fatalf("daughter %d: expected paternal X 7 and no Y, got %v", i, g)
+			}
+		}
+		sexes[w.Eggs.Sex[i]]++
+	}
+	if sexes[world.SexMale] == 0 || sexes[world.SexFemale] == 0 {
+		t.Fatalf("expected sons and daughters, got %v", sexes)
+	}
+}
+
+func TestOviposit_HaplodiploidMalesAreUnfertilized(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	a := w.Agents
+	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci, SexDetermination: SexDeterminationHaplodiploid}
+
+	female := w.AddAgent()
+	a.Sex[female] = world.SexFemale
+	a.FertilizedCount[female] = 10
+	for i := 0; i < cfg.NumLoci*2; i++ {
+		a.GenotypeCont[female*cfg.NumLoci*2+i] = 1
+	}
+	male := w.AddAgent()
+	for i := 0; i < cfg.NumLoci*2; i++ {
+		a.GenotypeCont[male*cfg.NumLoci*2+i] = 2
+	}
+	storeSpermPack(w, female, male, 100, nil)
+
+	reproCfg := ReproductionConfig{EggsPerCycle: 10, MaleRatio: 100, FemaleRatio: 0}
+	if laid := Oviposit(w, female, -1, nil, reproCfg, genCfg); laid != 10 {
+		t.Fatalf("expected 10 eggs laid, got %d", laid)
+	}
+	for i := 0; i < 10; i++ {
+		if w.Eggs.Sex[i] != world.SexMale || w.Eggs.ParentMaleID[i] != 0 {
+			t.Fatalf("egg %d: expected an unsired male, got sex %d sired by %d", i, w.Eggs.Sex[i], w.Eggs.ParentMaleID[i])
+		}
+		for j := 0; j < cfg.NumLoci*2; j++ {
+			if v := w.Eggs.GenotypeCont[i*cfg.NumLoci*2+j]; v != 1 {
+				t.Fatalf("egg %d: expected only maternal alleles, got %f", i, v)
+			}
+		}
+	}
+}
+
+func TestOviposit_HaplodiploidVirginLaysMales(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	a := w.Agents
+	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci, SexDetermination: SexDeterminationHaplodiploid}
+
+	// A virgin female: unfertilized gametes and no stored packs.
+	female := w.AddAgent()
+	a.Sex[female] = world.SexFemale
+	a.GametesCount[female] = 6
+	for i := 0; i < cfg.NumLoci*2; i++ {
+		a.GenotypeCont[female*cfg.NumLoci*2+i] = 1
+	}
+
+	// The sex ratio would ask for daughters, which need a sire.
+	reproCfg := ReproductionConfig{EggsPerCycle: 4, MaleRatio: 0, FemaleRatio: 100}
+	if laid := Oviposit(w, female, -1, nil, reproCfg, genCfg); laid != 4 {
+		t.Fatalf("expected 4 eggs laid, got %d", laid)
+	}
+	for i := 0; i < 4; i++ {
+		if w.Eggs.Sex[i] != world.SexMale || w.Eggs.ParentMaleID[i] != 0 {
+			t.Fatalf("egg %d: expected an unsired male, got sex %d sired by %d", i, w.Eggs.Sex[i], w.Eggs.ParentMaleID[i])
+		}
+	}
+	if a.GametesCount[female] != 2 || a.FertilizedCount[female] != 0 {
+		t.Fatalf("expected 2 gametes left and none fertilized, got %d and %d", a.GametesCount[female], a.FertilizedCount[female])
+	}
+
+	// Under other systems a virgin female lays nothing.
+	genCfg.SexDetermination = SexDeterminationRatio
+	if laid := Oviposit(w, female, -1, nil, reproCfg, genCfg); laid != 0 {
+		t.Fatalf("expected no eggs without stored packs, got %d", laid)
+	}
+
+	// The oviposit behavior is open to her only under haplodiploidy.
+	ovipositIdx := ovipositBehaviorIdx(cfg)
+	a.Decision[female] = uint8(ovipositIdx)
+	Act(&ActionContext{World: w, Reproduction: &reproCfg, Genetics: &genCfg}, female)
+	if w.Eggs.Count != 4 {
+		t.Fatalf("expected no eggs from Act without stored packs, got %d", w.Eggs.Count-4)
+	}
+	genCfg.SexDetermination = SexDeterminationHaplodiploid
+	Act(&ActionContext{World: w, Reproduction: &reproCfg, Genetics: &genCfg}, female)
+	if w.Eggs.Count != 6 {
+		t.Fatalf("expected Act to lay the 2 remaining gametes, got %d eggs", w.Eggs.Count)
+	}
+
+	a.GametesCount[female] = 3
+	ctx := setupPerceptionContext(w)
+	ctx.Genetics = &genCfg
+	vdBase := female * cfg.NumBehaviors
+	a.VDecision[vdBase+ovipositIdx] = 100
+	applyFilters(ctx, female)
+	if a.VDecision[vdBase+ovipositIdx] != 100 {
+		t.Fatalf("expected oviposit kept for a virgin haplodiploid female, got %d", a.VDecision[vdBase+ovipositIdx])
+	}
+	ctx.Genetics = nil
+	applyFilters(ctx, female)
+	if a.VDecision[vdBase+ovipositIdx] != 0 {
+		t.Fatalf("expected oviposit zeroed without fertilized eggs, got %d", a.VDecision[vdBase+ovipositIdx])
+	}
+}
+
+func TestAlleleStats(t *testing.T) {
+	var s AlleleStats
+	s.Add(1, 1, 1, 1, 2) // Homozygote.
+	s.Add(1, 3, 1, 0, 2) // Heterozygote.
+	s.Add(3, 3, 0, 0, 1) // Hemizygous: one copy.
+	s.Add(5, 5, 1, 1, 0) // Lacks the locus.
+
+	if s.Individuals != 3 || s.Alleles != 5 {
+		t.Fatalf("expected 3 individuals with 5 alleles, got %d and %d", s.Individuals, s.Alleles)
+	}
+	// Alleles 1, 1, 1, 3, 3.
+	if s.Mean() != 1.8 || math.Abs(s.Variance()-0.96) > 1e-9 {
+		t.Fatalf("expected mean 1.8 and variance 0.96, got %v and %v", s.Mean(), s.Variance())
+	}
+	if s.DominantFraction() != 0.6 {
+		t.Fatalf("expected dominant fraction 0.6, got %v", s.DominantFraction())
+	}
+	if s.ObservedHeterozygosity() != 0.5 {
+		t.Fatalf("expected one heterozygote of two diploids, got %v", s.ObservedHeterozygosity())
+	}
+	if f := s.Frequencies(); f[1] != 0.6 || f[3] != 0.4 {
+		t.Fatalf("expected frequencies 0.6 and 0.4, got %v", f)
+	}
+	if math.Abs(s.ExpectedHeterozygosity()-0.48) > 1e-9 { // 1 - 0.36 - 0.16
+		t.Fatalf("expected heterozygosity 0.48, got %v", s.ExpectedHeterozygosity())
+	}
+
+	var empty AlleleStats
+	if empty.Mean() != 0 || empty.ExpectedHeterozygosity() != 0 || empty.ObservedHeterozygosity() != 0 {
+		t.Fatal("expected zero statistics without alleles")
+	}
+}
+
+func TestAlleleStats_AddLocusReadsSingleCopy(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	a := w.Agents
+	genCfg := &GeneticsConfig{NumLoci: cfg.NumLoci, SexDetermination: SexDeterminationZW,
+		SexLinkage: []SexLinkage{WLinked, Autosomal}, Continuous: []bool{true, false}}
+
+	female := w.AddAgent()
+	a.Sex[female] = world.SexFemale
+	base := female * cfg.NumLoci * 2
+	a.GenotypeCont[base], a.GenotypeCont[base+1] = 0, 4 // W from the mother, slot 1.
+	a.GenotypeDisc[base+2], a.GenotypeDisc[base+3] = 2, 7
+
+	var w0, d1 AlleleStats
+	w0.AddLocus(w, female, 0, genCfg)
+	d1.AddLocus(w, female, 1, genCfg)
+	if w0.Alleles != 1 || w0.Mean() != 4 {
+		t.Fatalf("expected the single W copy 4, got %d alleles of mean %v", w0.Alleles, w0.Mean())
+	}
+	if d1.Alleles != 2 || d1.Mean() != 4.5 || d1.ObservedHeterozygosity() != 1 {
+		t.Fatalf("expected discrete alleles 2 and 7, got %d alleles of mean %v", d1.Alleles, d1.Mean())
+	}
+}
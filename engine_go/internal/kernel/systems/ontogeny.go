@@ -1,45 +1,160 @@
 package systems
 
 import (
+	"fmt"
+	"math/rand/v2"
+
+	"galatea/engine/internal/kernel/formulas"
 	"galatea/engine/internal/kernel/world"
 )
 
 // StageConfig holds transition parameters for a single life stage.
 type StageConfig struct {
-	CyclesRequired   int32   // Minimum cycles in stage before transition.
-	NutrientReqs     []int32 // Required reserve level per nutrient.
-	NutrientCosts    []int32 // Cost deducted on transition per nutrient.
-	Condition1Value  float64 // Custom condition 1 threshold.
-	Condition2Value  float64 // Custom condition 2 threshold.
-	LogicCyclesReqs  bool    // true=AND, false=OR between cycles and requirements.
-	LogicReqsConds   bool    // true=AND, false=OR between requirements and conditions.
-	LogicCond1Cond2  bool    // true=AND, false=OR between condition1 and condition2.
-	LinkedPrototype  int     // Linked prototype index (-1 = unlinked).
+	CyclesRequired  int32   // Minimum cycles in stage before transition.
+	NutrientReqs    []int32 // Required reserve level per nutrient.
+	NutrientCosts   []int32 // Cost deducted on transition per nutrient.
+	Condition1Value float64 // Custom condition 1 threshold.
+	Condition2Value float64 // Custom condition 2 threshold.
+	LogicCyclesReqs bool    // true=AND, false=OR between cycles and requirements.
+	LogicReqsConds  bool    // true=AND, false=OR between requirements and conditions.
+	LogicCond1Cond2 bool    // true=AND, false=OR between condition1 and condition2.
+	LinkedPrototype int     // Linked prototype index (-1 = unlinked); females follow the males.
+
+	// Formulas evaluated per agent (per egg for the first stage). A non-nil
+	// program overrides the fixed value above; a nil condition is left out.
+	Cycles       *formulas.Program
+	Requirements []*formulas.Program // Per nutrient.
+	Costs        []*formulas.Program // Per nutrient.
+	Condition1   *formulas.Program
+	Condition2   *formulas.Program
+	Condition1Op Comparison
+	Condition2Op Comparison
 }
 
 // OntogenyConfig holds all stage configurations and prototype assignment criteria.
 type OntogenyConfig struct {
-	Stages             []StageConfig
-	NumStages          int
-	NumPrototypesM     int
-	NumPrototypesF     int
-	// AssignmentCriteria: indexed [prototypeIdx] = threshold value.
-	// Evaluated in priority order; first match wins.
-	AssignmentPriorityM []int // Prototype indices in evaluation order (males).
-	AssignmentPriorityF []int // Prototype indices in evaluation order (females).
-	AssignmentThresholds []float64 // Threshold per prototype index.
+	Stages         []StageConfig
+	NumStages      int
+	NumPrototypesM int
+	NumPrototypesF int
+	// Assignment criteria per sex in priority order; the first criterion
+	// that holds assigns its prototype, otherwise the fallback applies.
+	AssignmentM []AssignmentCriterion
+	AssignmentF []AssignmentCriterion
+	FallbackM   int // Prototype index assigned to males when no criterion holds.
+	FallbackF   int // Prototype index assigned to females when no criterion holds.
+	// Morphology formulas indexed [proto*NumLoci + locus], where proto
+	// counts males first and females from NumPrototypesM. A nil genetic
+	// formula falls back to the expressed genotype; a nil environmental
+	// formula adds nothing.
+	NumLoci                 int
+	MorphologyGenetic       []*formulas.Program
+	MorphologyEnvironmental []*formulas.Program
+	// Longevity formula per prototype (same indexing), evaluated once at
+	// maturation. A nil formula keeps the engine default.
+	Longevity []*formulas.Program
+	// Egg mortality formula: percentage chance (0-100) that an egg dies on
+	// a given tick, evaluated with the egg's variables. Nil keeps every egg.
+	EggMortality *formulas.Program
+	// Environmental sex determination: percentage chance (0-100) that an
+	// egg of undefined sex ecloses as a male, evaluated with the egg's
+	// variables. Nil keeps the sex decided at laying.
+	EnvironmentalSex *formulas.Program
+	// Genetic architecture, for the expression of sex-linked loci; nil
+	// expresses every locus as autosomal.
+	Genetics *GeneticsConfig
+}
+
+// AssignmentCriterion is one prototype assignment rule: the agent receives
+// Prototype when Formula Operator Threshold holds.
+type AssignmentCriterion struct {
+	Prototype int // 0-based prototype index within the sex.
+	Formula   *formulas.Program
+	Operator  Comparison
+	Threshold float64
 }
 
-// EvaluateEggs checks each egg for eclosion conditions and converts them to agents.
+// Comparison is the relational operator of an assignment criterion or a
+// stage condition.
+type Comparison uint8
+
+const (
+	CompareGreater Comparison = iota
+	CompareGreaterEqual
+	CompareLess
+	CompareLessEqual
+	CompareEqual
+	CompareNotEqual
+)
+
+// ParseComparison parses an operator as stored in the project tables:
+// >, >=, <, <=, = (or ==) and <> (or !=).
+func ParseComparison(op string) (Comparison, error) {
+	switch op {
+	case ">":
+		return CompareGreater, nil
+	case ">=":
+		return CompareGreaterEqual, nil
+	case "<":
+		return CompareLess, nil
+	case "<=":
+		return CompareLessEqual, nil
+	case "=", "==":
+		return CompareEqual, nil
+	case "<>", "!=":
+		return CompareNotEqual, nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", op)
+}
+
+// Holds reports whether value compares to threshold as the operator requires.
+func (c Comparison) Holds(value, threshold float64) bool {
+	switch c {
+	case CompareGreater:
+		return value > threshold
+	case CompareGreaterEqual:
+		return value >= threshold
+	case CompareLess:
+		return value < threshold
+	case CompareLessEqual:
+		return value <= threshold
+	case CompareEqual:
+		return value == threshold
+	case CompareNotEqual:
+		return value != threshold
+	}
+	return false
+}
+
+// EvaluateEggs ages every egg by one tick, culls the eggs whose survive/die
+// decision comes out as die, and converts the eggs that meet the eclosion
+// conditions into agents (legacy Actualiza + EvaluaHuevo).
 // Returns the number of eggs that eclosed.
-func EvaluateEggs(w *world.World, ontCfg OntogenyConfig, genCfg GeneticsConfig) int {
+// The evaluator environment must already hold the world variables.
+func EvaluateEggs(w *world.World, eval *formulas.Evaluator, env *formulas.EnvBuilder, ontCfg OntogenyConfig, genCfg GeneticsConfig) int {
 	eggs := w.Eggs
+	a := w.Agents
 	eclosed := 0
 
 	// Process in reverse to safely remove during iteration.
 	for i := eggs.Count - 1; i >= 0; i-- {
-		if shouldEclose(eggs, i, ontCfg, w.Config) {
-			ecloseEgg(w, i, ontCfg, genCfg)
+		// Carried eggs travel with their carrier.
+		if carrier := eggs.CarrierAgentIdx[i]; carrier >= 0 && int(carrier) < a.Count {
+			eggs.PosX[i] = a.PosX[carrier]
+			eggs.PosY[i] = a.PosY[carrier]
+		}
+		eggs.Age[i]++
+
+		env.SetEggVars(w, i)
+		if !eggSurvives(eggs, w.Rand.Ontogeny, i, eval, ontCfg) {
+			removeEgg(w, i)
+			continue
+		}
+		if ontCfg.NumStages == 0 || len(ontCfg.Stages) == 0 {
+			continue
+		}
+		if shouldEclose(eggs, i, eval, ontCfg, w.Config) {
+			ecloseEgg(w, i, eval, env, ontCfg, genCfg)
 			removeEgg(w, i)
 			eclosed++
 		}
@@ -47,77 +162,69 @@ func EvaluateEggs(w *world.World, ontCfg OntogenyConfig, genCfg GeneticsConfig)
 	return eclosed
 }
 
-// shouldEclose evaluates whether an egg meets the first stage's transition conditions.
-func shouldEclose(eggs *world.EggArrays, idx int, ontCfg OntogenyConfig, cfg world.Config) bool {
-	if ontCfg.NumStages == 0 || len(ontCfg.Stages) == 0 {
-		return false
+// eggSurvives fills the egg's survive/die decision weights from the
+// mortality formula and draws from them (legacy ProveePercepciones(Huevo)).
+// The evaluator holds the egg's variables.
+func eggSurvives(eggs *world.EggArrays, r *rand.Rand, idx int, eval *formulas.Evaluator, ontCfg OntogenyConfig) bool {
+	if ontCfg.EggMortality == nil {
+		return true
 	}
-
-	stage := ontCfg.Stages[0] // Eclosion uses the first stage's conditions.
-	age := eggs.Age[idx]
-
-	// Condition: cycles in egg >= required.
-	cyclesMet := age >= stage.CyclesRequired
-
-	// Condition: reserves meet requirements.
-	reqsMet := true
-	numNut := cfg.NumNutrients
-	resBase := idx * numNut
-	for n := 0; n < numNut && n < len(stage.NutrientReqs); n++ {
-		if eggs.Reserves[resBase+n] < stage.NutrientReqs[n] {
-			reqsMet = false
-			break
-		}
+	mortality := int32(0)
+	if val, err := eval.RunProgramInt(ontCfg.EggMortality); err == nil {
+		mortality = int32(max(0, min(100, val)))
 	}
+	vd := eggs.VDecision[idx*2 : idx*2+2]
+	vd[0] = 100 - mortality
+	vd[1] = mortality
+	return Roulette(r, vd) == 0
+}
 
-	// Combine with logic operators (simplified: conditions always true for eggs).
-	if stage.LogicCyclesReqs {
-		return cyclesMet && reqsMet
-	}
-	return cyclesMet || reqsMet
+// shouldEclose evaluates whether an egg meets the first stage's transition
+// conditions (legacy EvaluaHuevo). The evaluator holds the egg's variables.
+func shouldEclose(eggs *world.EggArrays, idx int, eval *formulas.Evaluator, ontCfg OntogenyConfig, cfg world.Config) bool {
+	numNut := cfg.NumNutrients
+	reserves := eggs.Reserves[idx*numNut : (idx+1)*numNut]
+	// Eclosion uses the first stage's conditions.
+	return stageReady(&ontCfg.Stages[0], eval, eggs.Age[idx], reserves)
 }
 
-// ecloseEgg converts an egg into a new agent (immature, first stage).
-func ecloseEgg(w *world.World, eggIdx int, ontCfg OntogenyConfig, genCfg GeneticsConfig) {
+// ecloseEgg converts an egg into a new agent (immature, first stage after
+// the egg). The evaluator holds the egg's variables.
+func ecloseEgg(w *world.World, eggIdx int, eval *formulas.Evaluator, env *formulas.EnvBuilder, ontCfg OntogenyConfig, genCfg GeneticsConfig) {
 	eggs := w.Eggs
 	cfg := w.Config
 	numLoci := cfg.NumLoci
 	numNut := cfg.NumNutrients
 
-	// Create new agent.
+	// Create new agent, recording its parents for its name and generation.
 	agentIdx := w.AddAgent()
 	a := w.Agents
+	w.Names.AddOffspring(a.ID[agentIdx], eggs.ParentMaleID[eggIdx], eggs.ParentFemaleID[eggIdx])
 
 	// Transfer position.
 	a.PosX[agentIdx] = eggs.PosX[eggIdx]
 	a.PosY[agentIdx] = eggs.PosY[eggIdx]
 
-	// Set identity: immature, first stage after egg.
-	startStage := int32(0)
-	if ontCfg.NumStages > 1 {
-		startStage = 1 // Stage 0 = egg (just eclosed), start at stage 1.
-	}
-	a.StageID[agentIdx] = startStage
+	// Set identity: immature, in the egg stage until it advances below.
+	a.StageID[agentIdx] = 0
 	a.PrototypeID[agentIdx] = -1
 	a.Sex[agentIdx] = eggs.Sex[eggIdx]
+	if a.Sex[agentIdx] == world.SexUndefined && ontCfg.EnvironmentalSex != nil {
+		males := 50
+		if val, err := eval.RunProgramInt(ontCfg.EnvironmentalSex); err == nil {
+			males = max(0, min(100, val))
+		}
+		a.Sex[agentIdx] = DetermineSex(w.Rand.Ontogeny, males, 100-males)
+	}
 	a.Age[agentIdx] = 0
 	a.Situation[agentIdx] = world.SituationImmature
 	a.Direction[agentIdx] = uint8(1 + eggs.Age[eggIdx]%8) // Pseudo-random direction.
 	a.Speed[agentIdx] = 1
 
 	// Transfer reserves (minus eclosion costs).
-	eggResBase := eggIdx * numNut
 	agentResBase := agentIdx * numNut
-	for n := 0; n < numNut; n++ {
-		reserve := eggs.Reserves[eggResBase+n]
-		if len(ontCfg.Stages) > 0 && n < len(ontCfg.Stages[0].NutrientCosts) {
-			reserve -= ontCfg.Stages[0].NutrientCosts[n]
-		}
-		if reserve < 0 {
-			reserve = 0
-		}
-		a.Reserves[agentResBase+n] = reserve
-	}
+	copy(a.Reserves[agentResBase:agentResBase+numNut], eggs.Reserves[eggIdx*numNut:(eggIdx+1)*numNut])
+	chargeStageCosts(&ontCfg.Stages[0], eval, a.Reserves[agentResBase:agentResBase+numNut])
 
 	// Transfer genotype.
 	genoSize := numLoci * 2
@@ -127,11 +234,25 @@ func ecloseEgg(w *world.World, eggIdx int, ontCfg OntogenyConfig, genCfg Genetic
 	copy(a.GenotypeDisc[agentGenoBase:agentGenoBase+genoSize], eggs.GenotypeDisc[eggGenoBase:eggGenoBase+genoSize])
 	copy(a.DominanceCont[agentGenoBase:agentGenoBase+genoSize], eggs.DominanceCont[eggGenoBase:eggGenoBase+genoSize])
 	copy(a.DominanceDisc[agentGenoBase:agentGenoBase+genoSize], eggs.DominanceDisc[eggGenoBase:eggGenoBase+genoSize])
+
+	// Stage 0 is the egg: the new agent starts at the next stage of its pathway.
+	if ontCfg.NumStages > 1 {
+		env.SetAgentVars(w, agentIdx)
+		advanceStage(w, agentIdx, eval, ontCfg)
+	}
 }
 
 // removeEgg removes an egg by swapping with the last and decrementing Count.
+// The carrier, if any, no longer counts the egg among its carried eggs, and
+// a site's level drops by one (legacy THuevo.Destroy).
 func removeEgg(w *world.World, idx int) {
 	eggs := w.Eggs
+	if carrier := eggs.CarrierAgentIdx[idx]; carrier >= 0 && int(carrier) < w.Agents.Count && w.Agents.CarriedEggs[carrier] > 0 {
+		w.Agents.CarriedEggs[carrier]--
+	}
+	if site := eggs.CarrierResourceIdx[idx]; site >= 0 && int(site) < w.Resources.Count && w.Resources.Level[site] > 0 {
+		w.Resources.Level[site]--
+	}
 	last := eggs.Count - 1
 	if idx != last {
 		swapEggs(eggs, idx, last, w.Config)
@@ -151,8 +272,8 @@ func swapEggs(eggs *world.EggArrays, i, j int, cfg world.Config) {
 	eggs.Sex[i], eggs.Sex[j] = eggs.Sex[j], eggs.Sex[i]
 	eggs.CarrierAgentIdx[i], eggs.CarrierAgentIdx[j] = eggs.CarrierAgentIdx[j], eggs.CarrierAgentIdx[i]
 	eggs.CarrierResourceIdx[i], eggs.CarrierResourceIdx[j] = eggs.CarrierResourceIdx[j], eggs.CarrierResourceIdx[i]
-	eggs.ParentMale[i], eggs.ParentMale[j] = eggs.ParentMale[j], eggs.ParentMale[i]
-	eggs.ParentFemale[i], eggs.ParentFemale[j] = eggs.ParentFemale[j], eggs.ParentFemale[i]
+	eggs.ParentMaleID[i], eggs.ParentMaleID[j] = eggs.ParentMaleID[j], eggs.ParentMaleID[i]
+	eggs.ParentFemaleID[i], eggs.ParentFemaleID[j] = eggs.ParentFemaleID[j], eggs.ParentFemaleID[i]
 
 	// Reserves.
 	for n := 0; n < numNut; n++ {
@@ -172,7 +293,8 @@ func swapEggs(eggs *world.EggArrays, i, j int, cfg world.Config) {
 
 // EvaluateStageTransition checks if an immature agent should advance to the next stage
 // or become an adult. Returns true if a transition occurred.
-func EvaluateStageTransition(w *world.World, idx int, ontCfg OntogenyConfig) bool {
+// The evaluator environment must already hold the agent's variables.
+func EvaluateStageTransition(w *world.World, idx int, eval *formulas.Evaluator, ontCfg OntogenyConfig) bool {
 	a := w.Agents
 	cfg := w.Config
 	currentStage := int(a.StageID[idx])
@@ -184,116 +306,231 @@ func EvaluateStageTransition(w *world.World, idx int, ontCfg OntogenyConfig) boo
 		return false
 	}
 
-	stage := ontCfg.Stages[currentStage]
+	stage := &ontCfg.Stages[currentStage]
+	numNut := cfg.NumNutrients
+	reserves := a.Reserves[idx*numNut : (idx+1)*numNut]
+
+	if !stageReady(stage, eval, a.TimeInStage[idx], reserves) {
+		return false
+	}
 
-	// Evaluate transition conditions.
-	cyclesMet := a.TimeInStage[idx] >= stage.CyclesRequired
+	// Deduct transition costs, then advance to the next stage or become adult.
+	chargeStageCosts(stage, eval, reserves)
+	advanceStage(w, idx, eval, ontCfg)
+	return true
+}
+
+// stageReady evaluates the transition conditions of a stage (legacy
+// EvaluaPasoEstadio): cycles spent in the stage, nutrient requirements and
+// the two custom conditions, combined through the stage's logic operators.
+// The evaluator holds the variables of the agent or egg.
+func stageReady(stage *StageConfig, eval *formulas.Evaluator, timeInStage int32, reserves []int32) bool {
+	cyclesMet := timeInStage >= evalStageInt(eval, stage.Cycles, stage.CyclesRequired)
 
 	reqsMet := true
-	numNut := cfg.NumNutrients
-	resBase := idx * numNut
-	for n := 0; n < numNut && n < len(stage.NutrientReqs); n++ {
-		if a.Reserves[resBase+n] < stage.NutrientReqs[n] {
+	for n := range reserves {
+		if reserves[n] < stageNutrientValue(eval, stage.Requirements, stage.NutrientReqs, n) {
 			reqsMet = false
 			break
 		}
 	}
 
-	// Conditions (simplified: always met for now; full formula eval in engine).
-	condsMet := true
+	condsMet, hasConds := stageConditions(stage, eval)
+	if !hasConds {
+		return combineLogic(cyclesMet, reqsMet, true, stage.LogicCyclesReqs, true)
+	}
+	return combineLogic(cyclesMet, reqsMet, condsMet, stage.LogicCyclesReqs, stage.LogicReqsConds)
+}
 
-	shouldTransition := combineLogic(cyclesMet, reqsMet, condsMet, stage.LogicCyclesReqs, stage.LogicReqsConds)
-	if !shouldTransition {
-		return false
+// stageConditions evaluates the custom conditions of a stage. A missing
+// condition is left out, so a stage without conditions reports false for
+// hasConds. A condition whose formula fails does not hold.
+func stageConditions(stage *StageConfig, eval *formulas.Evaluator) (met, hasConds bool) {
+	has1, has2 := stage.Condition1 != nil, stage.Condition2 != nil
+	var cond1, cond2 bool
+	if has1 {
+		cond1 = stageCondition(eval, stage.Condition1, stage.Condition1Op, stage.Condition1Value)
+	}
+	if has2 {
+		cond2 = stageCondition(eval, stage.Condition2, stage.Condition2Op, stage.Condition2Value)
+	}
+	switch {
+	case has1 && has2 && stage.LogicCond1Cond2:
+		return cond1 && cond2, true
+	case has1 && has2:
+		return cond1 || cond2, true
+	case has1:
+		return cond1, true
+	case has2:
+		return cond2, true
 	}
+	return false, false
+}
 
-	// Deduct transition costs.
-	for n := 0; n < numNut && n < len(stage.NutrientCosts); n++ {
-		a.Reserves[resBase+n] -= stage.NutrientCosts[n]
-		if a.Reserves[resBase+n] < 0 {
-			a.Reserves[resBase+n] = 0
+// chargeStageCosts deducts the transition costs of a stage from reserves.
+func chargeStageCosts(stage *StageConfig, eval *formulas.Evaluator, reserves []int32) {
+	for n := range reserves {
+		reserves[n] -= stageNutrientValue(eval, stage.Costs, stage.NutrientCosts, n)
+		if reserves[n] < 0 {
+			reserves[n] = 0
 		}
 	}
+}
 
-	// Advance to next stage or become adult.
-	nextStage := currentStage + 1
-	if nextStage >= ontCfg.NumStages {
-		// Become adult.
-		becomeAdult(w, idx, ontCfg)
-	} else {
-		a.StageID[idx] = int32(nextStage)
-		a.TimeInStage[idx] = 0
+// advanceStage moves an agent out of its current stage (legacy
+// SiguienteEstadio). Stages linked to a prototype form alternative
+// developmental pathways: the prototype is assigned when the next stage is
+// linked, and from then on the agent skips stages linked to other
+// prototypes. An agent with no stage left becomes an adult.
+func advanceStage(w *world.World, idx int, eval *formulas.Evaluator, ontCfg OntogenyConfig) {
+	a := w.Agents
+	next := int(a.StageID[idx]) + 1
+	if a.PrototypeID[idx] < 0 && next < len(ontCfg.Stages) && ontCfg.Stages[next].LinkedPrototype >= 0 {
+		a.PrototypeID[idx] = int32(AssignPrototype(w, idx, eval, ontCfg))
+	}
+	for next < ontCfg.NumStages && next < len(ontCfg.Stages) && !onPathway(a, idx, ontCfg.Stages[next].LinkedPrototype, ontCfg) {
+		next++
 	}
 
-	return true
+	if next >= ontCfg.NumStages {
+		becomeAdult(w, idx, eval, ontCfg)
+		return
+	}
+	a.StageID[idx] = int32(next)
+	a.TimeInStage[idx] = 0
+}
+
+// onPathway reports whether a stage linked to the given prototype belongs to
+// the developmental pathway of agent idx.
+func onPathway(a *world.AgentArrays, idx, linked int, ontCfg OntogenyConfig) bool {
+	if linked < 0 {
+		return true
+	}
+	return prototypeSlot(a, idx, ontCfg.NumPrototypesM) == linked
+}
+
+// evalStageInt returns the value of a stage formula, or fixed when the
+// formula is missing or fails.
+func evalStageInt(eval *formulas.Evaluator, p *formulas.Program, fixed int32) int32 {
+	if p != nil {
+		if val, err := eval.RunProgramInt(p); err == nil {
+			return int32(val)
+		}
+	}
+	return fixed
+}
+
+// stageNutrientValue returns the requirement or cost of nutrient n.
+func stageNutrientValue(eval *formulas.Evaluator, programs []*formulas.Program, fixed []int32, n int) int32 {
+	value := int32(0)
+	if n < len(fixed) {
+		value = fixed[n]
+	}
+	if n < len(programs) {
+		value = evalStageInt(eval, programs[n], value)
+	}
+	return value
+}
+
+// stageCondition evaluates one custom stage condition.
+func stageCondition(eval *formulas.Evaluator, p *formulas.Program, op Comparison, value float64) bool {
+	val, err := eval.RunProgramFloat(p)
+	return err == nil && op.Holds(val, value)
 }
 
 // becomeAdult transitions an agent from immature to adult status.
-func becomeAdult(w *world.World, idx int, ontCfg OntogenyConfig) {
+func becomeAdult(w *world.World, idx int, eval *formulas.Evaluator, ontCfg OntogenyConfig) {
 	a := w.Agents
 
-	// Assign prototype.
-	protoIdx := AssignPrototype(w, idx, ontCfg)
-	a.PrototypeID[idx] = int32(protoIdx)
+	// Assign prototype, unless a linked stage already did.
+	if a.PrototypeID[idx] < 0 {
+		a.PrototypeID[idx] = int32(AssignPrototype(w, idx, eval, ontCfg))
+	}
 	a.StageID[idx] = -1
 	a.Situation[idx] = world.SituationRegular
 	a.TimeInStage[idx] = 0
 
-	// Fix morphology.
-	FixMorphology(w, idx)
+	// Fix morphology and lifespan.
+	FixMorphology(w, idx, eval, ontCfg)
+	FixLongevity(w, idx, eval, ontCfg)
 }
 
-// AssignPrototype determines which adult prototype an agent receives based on
-// hierarchical criteria evaluation. Returns the 0-based prototype index.
-func AssignPrototype(w *world.World, idx int, ontCfg OntogenyConfig) int {
-	a := w.Agents
-	sex := a.Sex[idx]
-
-	var priorities []int
-	if sex == world.SexMale {
-		priorities = ontCfg.AssignmentPriorityM
-	} else {
-		priorities = ontCfg.AssignmentPriorityF
-	}
-
-	// If no priorities defined, assign first available prototype.
-	if len(priorities) == 0 {
-		return 0
+// AssignPrototype determines which adult prototype an agent receives by
+// evaluating the assignment criteria of its sex in priority order; the first
+// criterion that holds wins. A criterion whose formula fails does not hold.
+// Returns the 0-based prototype index.
+// The evaluator environment must already hold the agent's variables.
+func AssignPrototype(w *world.World, idx int, eval *formulas.Evaluator, ontCfg OntogenyConfig) int {
+	criteria, fallback := ontCfg.AssignmentM, ontCfg.FallbackM
+	if w.Agents.Sex[idx] == world.SexFemale {
+		criteria, fallback = ontCfg.AssignmentF, ontCfg.FallbackF
 	}
 
-	// Evaluate criteria in priority order (simplified: use genetic expression as criteria).
-	// In full implementation, this evaluates formula from AssignmentCriteria table.
-	// For now, assign based on the first locus expressed value vs threshold.
-	numLoci := w.Config.NumLoci
-	if numLoci > 0 && len(ontCfg.AssignmentThresholds) > 0 {
-		expressed := ExpressLocusCont(a.GenotypeCont, a.DominanceCont, idx, 0, numLoci)
-		for _, protoIdx := range priorities {
-			if protoIdx < len(ontCfg.AssignmentThresholds) {
-				if expressed >= ontCfg.AssignmentThresholds[protoIdx] {
-					return protoIdx
-				}
-			}
+	for _, c := range criteria {
+		if c.Formula == nil {
+			continue
+		}
+		if val, err := eval.RunProgramFloat(c.Formula); err == nil && c.Operator.Holds(val, c.Threshold) {
+			return c.Prototype
 		}
 	}
-
-	// Default: first in priority list.
-	return priorities[0]
+	return fallback
 }
 
-// FixMorphology freezes the genetically-determined morphological values for an adult agent.
+// FixMorphology freezes the morphology of an adult agent as the sum of the
+// genetic and environmental formulas of its prototype for each locus.
 // After this, morphology no longer changes (congenital traits fixed at maturity).
-func FixMorphology(w *world.World, idx int) {
+// The evaluator environment must already hold the agent's variables, so that
+// the formulas see the reserves, age and time in stage at maturation.
+func FixMorphology(w *world.World, idx int, eval *formulas.Evaluator, ontCfg OntogenyConfig) {
 	a := w.Agents
 	numLoci := w.Config.NumLoci
 	morphBase := idx * numLoci
 
+	proto := prototypeSlot(a, idx, ontCfg.NumPrototypesM)
 	for locus := 0; locus < numLoci; locus++ {
-		a.MorphologyCont[morphBase+locus] = ExpressLocusCont(a.GenotypeCont, a.DominanceCont, idx, locus, numLoci)
-		a.MorphologyDisc[morphBase+locus] = ExpressLocusDisc(a.GenotypeDisc, a.DominanceDisc, idx, locus, numLoci)
+		copies, _ := ontCfg.Genetics.Copies(locus, a.Sex[idx])
+		expression := ontCfg.Genetics.LocusExpression(locus)
+		cont := ExpressLocusCont(a.GenotypeCont, a.DominanceCont, idx, locus, numLoci, copies, expression)
+		disc := ExpressLocusDisc(a.GenotypeDisc, a.DominanceDisc, idx, locus, numLoci, copies, expression)
+		if proto >= 0 {
+			k := proto*ontCfg.NumLoci + locus
+			if val, ok := prototypeValue(eval, ontCfg.MorphologyGenetic, k); ok {
+				cont, disc = val, int32(val)
+			}
+			if val, ok := prototypeValue(eval, ontCfg.MorphologyEnvironmental, k); ok {
+				cont += val
+				disc += int32(val)
+			}
+		}
+		a.MorphologyCont[morphBase+locus] = cont
+		a.MorphologyDisc[morphBase+locus] = disc
 	}
 	a.MorphologyFixed[idx] = true
 }
 
+// FixLongevity sets the lifespan of an adult agent from the longevity formula
+// of its prototype. A missing or failing formula keeps the engine default.
+// The evaluator environment must already hold the agent's variables.
+func FixLongevity(w *world.World, idx int, eval *formulas.Evaluator, ontCfg OntogenyConfig) {
+	a := w.Agents
+	a.Longevity[idx] = 0
+	proto := prototypeSlot(a, idx, ontCfg.NumPrototypesM)
+	if val, ok := prototypeValue(eval, ontCfg.Longevity, proto); ok && val > 0 {
+		a.Longevity[idx] = int32(val)
+	}
+}
+
+// prototypeValue evaluates programs[k], reporting false when the formula is
+// missing or fails.
+func prototypeValue(eval *formulas.Evaluator, programs []*formulas.Program, k int) (float64, bool) {
+	if k < 0 || k >= len(programs) || programs[k] == nil {
+		return 0, false
+	}
+	val, err := eval.RunProgramFloat(programs[k])
+	return val, err == nil
+}
+
 // --- Combat/Courtship dynamics ---
 
 // ResolveCombatDynamics checks combat interactions and resolves timeouts.
@@ -313,13 +550,15 @@ func ResolveCombatDynamics(w *world.World, maxTicks int32) {
 			a.Situation[i] = world.SituationRegular
 			a.InteractantIdx[i] = -1
 			a.TimeInInteraction[i] = 0
+			a.RefractoryCombat[i] = world.RefractoryPending
 		}
 	}
 }
 
 // ResolveCourtshipDynamics checks courtship interactions and resolves mutual acceptance
-// into copulation, or timeouts into rejection.
-func ResolveCourtshipDynamics(w *world.World, maxTicks int32, reproCfg ReproductionConfig, genCfg GeneticsConfig) int {
+// into copulation, or timeouts into rejection. Copulations evaluate the
+// reproduction formulas with the partners' variables (see copulationConfig).
+func ResolveCourtshipDynamics(w *world.World, eval *formulas.Evaluator, envBuilder *formulas.EnvBuilder, maxTicks int32, reproCfg ReproductionConfig, genCfg GeneticsConfig) int {
 	a := w.Agents
 	copulations := 0
 
@@ -341,7 +580,7 @@ func ResolveCourtshipDynamics(w *world.World, maxTicks int32, reproCfg Reproduct
 			if a.Sex[i] == world.SexFemale {
 				maleIdx, femaleIdx = int(interactant), i
 			}
-			Copulate(w, maleIdx, femaleIdx, reproCfg, genCfg)
+			Copulate(w, maleIdx, femaleIdx, copulationConfig(w, eval, envBuilder, &reproCfg, maleIdx, femaleIdx), genCfg)
 			copulations++
 			continue
 		}
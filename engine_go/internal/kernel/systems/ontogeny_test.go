@@ -3,6 +3,7 @@ package systems
 import (
 	"testing"
 
+	"galatea/engine/internal/kernel/formulas"
 	"galatea/engine/internal/kernel/world"
 )
 
@@ -31,9 +32,6 @@ func testOntogenyCfg() OntogenyConfig {
 				LinkedPrototype: -1,
 			},
 		},
-		AssignmentPriorityM:  []int{0},
-		AssignmentPriorityF:  []int{0},
-		AssignmentThresholds: []float64{0.5},
 	}
 }
 
@@ -52,8 +50,14 @@ func TestEvaluateEggs_Eclosion(t *testing.T) {
 	eggs.Sex[0] = world.SexMale
 	eggs.Reserves[0*cfg.NumNutrients+0] = 10 // >= 5 required.
 	eggs.Reserves[0*cfg.NumNutrients+1] = 10
+	eggs.ParentMaleID[0] = 40
+	eggs.ParentFemaleID[0] = 41
+	w.Names.Set(41, "Queen", 1)
+	pattern, _ := world.ParseNamePattern("{mother}.{id}")
+	w.Names.SetPattern(pattern)
 
-	eclosed := EvaluateEggs(w, ontCfg, genCfg)
+	eval := formulas.NewEvaluator(128)
+	eclosed := EvaluateEggs(w, eval, formulas.NewEnvBuilder(eval, cfg), ontCfg, genCfg)
 
 	if eclosed != 1 {
 		t.Fatalf("expected 1 eclosion, got %d", eclosed)
@@ -73,6 +77,9 @@ func TestEvaluateEggs_Eclosion(t *testing.T) {
 	if a.Sex[0] != world.SexMale {
 		t.Fatalf("expected male, got %d", a.Sex[0])
 	}
+	if id := a.ID[0]; w.Names.Name(id) != "Queen.1" || w.Names.Generation(id) != 2 {
+		t.Fatalf("expected Queen.1 of generation 2, got %q of %d", w.Names.Name(id), w.Names.Generation(id))
+	}
 	if a.Situation[0] != world.SituationImmature {
 		t.Fatalf("expected immature, got %d", a.Situation[0])
 	}
@@ -82,6 +89,46 @@ func TestEvaluateEggs_Eclosion(t *testing.T) {
 	}
 }
 
+func TestEvaluateEggs_EnvironmentalSex(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci, SexDetermination: SexDeterminationEnvironmental}
+
+	// Eggs with a first continuous locus of 1 become males, the rest females.
+	reg := formulas.NewRegistry()
+	reg.Compile("sex", "CL1 == 1 ? 100 : 0")
+	ontCfg := testOntogenyCfg()
+	ontCfg.EnvironmentalSex = reg.Get("sex")
+
+	eggs := w.Eggs
+	eggs.Count = 2
+	for i, cl := range []float64{1, 2} {
+		genoBase := i * cfg.NumLoci * 2
+		eggs.GenotypeCont[genoBase] = cl
+		eggs.GenotypeCont[genoBase+1] = cl
+		eggs.Age[i] = 15
+		eggs.Sex[i] = world.SexUndefined
+		eggs.Reserves[i*cfg.NumNutrients+0] = 10
+		eggs.Reserves[i*cfg.NumNutrients+1] = 10
+		eggs.CarrierAgentIdx[i] = -1
+	}
+
+	eval := formulas.NewEvaluator(128)
+	if eclosed := EvaluateEggs(w, eval, formulas.NewEnvBuilder(eval, cfg), ontCfg, genCfg); eclosed != 2 {
+		t.Fatalf("expected 2 eclosions, got %d", eclosed)
+	}
+	a := w.Agents
+	for i := 0; i < a.Count; i++ {
+		want := uint8(world.SexFemale)
+		if a.GenotypeCont[i*cfg.NumLoci*2] == 1 {
+			want = world.SexMale
+		}
+		if a.Sex[i] != want {
+			t.Fatalf("agent %d: expected sex %d, got %d", i, want, a.Sex[i])
+		}
+	}
+}
+
 func TestEvaluateEggs_NotReady(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -95,7 +142,8 @@ func TestEvaluateEggs_NotReady(t *testing.T) {
 	eggs.Reserves[0*cfg.NumNutrients+0] = 10
 	eggs.Reserves[0*cfg.NumNutrients+1] = 10
 
-	eclosed := EvaluateEggs(w, ontCfg, genCfg)
+	eval := formulas.NewEvaluator(128)
+	eclosed := EvaluateEggs(w, eval, formulas.NewEnvBuilder(eval, cfg), ontCfg, genCfg)
 
 	if eclosed != 0 {
 		t.Fatalf("expected 0 eclosions (age not met), got %d", eclosed)
@@ -105,6 +153,113 @@ func TestEvaluateEggs_NotReady(t *testing.T) {
 	}
 }
 
+func TestEvaluateEggs_AgesCarriedEggs(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	ontCfg := testOntogenyCfg()
+	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci}
+
+	mother := w.AddAgent()
+	w.Agents.PosX[mother] = 30
+	w.Agents.PosY[mother] = 40
+	w.Agents.CarriedEggs[mother] = 1
+
+	eggs := w.Eggs
+	eggs.Count = 1
+	eggs.Age[0] = 9
+	eggs.CarrierAgentIdx[0] = int32(mother)
+	eggs.CarrierResourceIdx[0] = -1
+	eggs.Reserves[0*cfg.NumNutrients+0] = 10
+	eggs.Reserves[0*cfg.NumNutrients+1] = 10
+
+	// Aging to 10 cycles is enough to eclose at the mother's position.
+	eval := formulas.NewEvaluator(128)
+	if eclosed := EvaluateEggs(w, eval, formulas.NewEnvBuilder(eval, cfg), ontCfg, genCfg); eclosed != 1 {
+		t.Fatalf("expected the aged egg to eclose, got %d", eclosed)
+	}
+	if w.Agents.Count != 2 {
+		t.Fatalf("expected a new agent, got %d agents", w.Agents.Count)
+	}
+	if w.Agents.PosX[1] != 30 || w.Agents.PosY[1] != 40 {
+		t.Fatalf("hatchling should appear at the carrier, got (%f,%f)", w.Agents.PosX[1], w.Agents.PosY[1])
+	}
+	if w.Agents.CarriedEggs[mother] != 0 {
+		t.Fatalf("carrier should no longer count the egg, got %d", w.Agents.CarriedEggs[mother])
+	}
+}
+
+func TestEvaluateEggs_MortalityFormula(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci}
+
+	// Eggs with a first continuous locus of 1 always die, the rest survive.
+	reg := formulas.NewRegistry()
+	reg.Compile("mortality", "CL1 == 1 ? 100 : 0")
+	ontCfg := testOntogenyCfg()
+	ontCfg.EggMortality = reg.Get("mortality")
+
+	eggs := w.Eggs
+	eggs.Count = 2
+	for i, cl := range []float64{1, 2} {
+		genoBase := i * cfg.NumLoci * 2
+		eggs.GenotypeCont[genoBase] = cl
+		eggs.GenotypeCont[genoBase+1] = cl
+		eggs.CarrierAgentIdx[i] = -1
+	}
+
+	eval := formulas.NewEvaluator(128)
+	EvaluateEggs(w, eval, formulas.NewEnvBuilder(eval, cfg), ontCfg, genCfg)
+
+	if eggs.Count != 1 || eggs.GenotypeCont[0] != 2 {
+		t.Fatalf("expected only the CL1=2 egg to survive, got %d eggs", eggs.Count)
+	}
+	if eggs.Age[0] != 1 {
+		t.Fatalf("surviving egg should be 1 tick old, got %d", eggs.Age[0])
+	}
+	if eggs.VDecision[0] != 100 || eggs.VDecision[1] != 0 {
+		t.Fatalf("expected survive/die weights 100/0, got %d/%d", eggs.VDecision[0], eggs.VDecision[1])
+	}
+}
+
+func TestEvaluateEggs_SiteQuality(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci}
+	w.ResourceOviposition[1] = true
+
+	// Eggs in poor sites die; the site level drops with each lost egg.
+	reg := formulas.NewRegistry()
+	reg.Compile("mortality", "DynamicElementQuality < 5 ? 100 : 0")
+	ontCfg := testOntogenyCfg()
+	ontCfg.EggMortality = reg.Get("mortality")
+
+	for s, quality := range []int32{2, 8} {
+		w.Resources.TypeID[s] = 1
+		w.Resources.Quality[s] = quality
+		w.Resources.Level[s] = 1
+		w.Resources.MaxLevel[s] = 10
+	}
+	w.Resources.Count = 2
+
+	eggs := w.Eggs
+	eggs.Count = 2
+	for i := 0; i < 2; i++ {
+		eggs.CarrierAgentIdx[i] = -1
+		eggs.CarrierResourceIdx[i] = int32(i)
+	}
+
+	eval := formulas.NewEvaluator(128)
+	EvaluateEggs(w, eval, formulas.NewEnvBuilder(eval, cfg), ontCfg, genCfg)
+
+	if eggs.Count != 1 || eggs.CarrierResourceIdx[0] != 1 {
+		t.Fatalf("expected only the egg in the good site to survive, got %d eggs", eggs.Count)
+	}
+	if w.Resources.Level[0] != 0 || w.Resources.Level[1] != 1 {
+		t.Fatalf("expected site levels 0 and 1, got %d and %d", w.Resources.Level[0], w.Resources.Level[1])
+	}
+}
+
 func TestEvaluateStageTransition_Advances(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -124,7 +279,7 @@ func TestEvaluateStageTransition_Advances(t *testing.T) {
 	a.GenotypeCont[genoBase+1] = 0.8
 	a.DominanceCont[genoBase+1] = 1
 
-	transitioned := EvaluateStageTransition(w, idx, ontCfg)
+	transitioned := EvaluateStageTransition(w, idx, formulas.NewEvaluator(16), ontCfg)
 
 	if !transitioned {
 		t.Fatal("expected transition")
@@ -160,9 +315,6 @@ func TestEvaluateStageTransition_NotReady(t *testing.T) {
 			{CyclesRequired: 10, NutrientReqs: []int32{5, 5}, NutrientCosts: []int32{2, 2}, LogicCyclesReqs: true, LogicReqsConds: true},
 			{CyclesRequired: 20, NutrientReqs: []int32{10, 10}, NutrientCosts: []int32{3, 3}, LogicCyclesReqs: true, LogicReqsConds: true},
 		},
-		AssignmentPriorityM:  []int{0},
-		AssignmentPriorityF:  []int{0},
-		AssignmentThresholds: []float64{0.5},
 	}
 
 	idx := w.AddAgent()
@@ -172,7 +324,7 @@ func TestEvaluateStageTransition_NotReady(t *testing.T) {
 	a.Reserves[idx*cfg.NumNutrients+0] = 50
 	a.Reserves[idx*cfg.NumNutrients+1] = 50
 
-	transitioned := EvaluateStageTransition(w, idx, ontCfg)
+	transitioned := EvaluateStageTransition(w, idx, formulas.NewEvaluator(16), ontCfg)
 
 	if transitioned {
 		t.Fatal("should not transition (cycles not met with AND logic)")
@@ -192,13 +344,10 @@ func TestEvaluateStageTransition_AdvancesToNextStage(t *testing.T) {
 		NumPrototypesM: 1,
 		NumPrototypesF: 1,
 		Stages: []StageConfig{
-			{CyclesRequired: 5, NutrientReqs: []int32{0, 0}, NutrientCosts: []int32{1, 1}, LogicCyclesReqs: true, LogicReqsConds: false},
-			{CyclesRequired: 10, NutrientReqs: []int32{0, 0}, NutrientCosts: []int32{1, 1}, LogicCyclesReqs: true, LogicReqsConds: false},
-			{CyclesRequired: 15, NutrientReqs: []int32{0, 0}, NutrientCosts: []int32{1, 1}, LogicCyclesReqs: true, LogicReqsConds: false},
+			{CyclesRequired: 5, NutrientReqs: []int32{0, 0}, NutrientCosts: []int32{1, 1}, LogicCyclesReqs: true, LogicReqsConds: false, LinkedPrototype: -1},
+			{CyclesRequired: 10, NutrientReqs: []int32{0, 0}, NutrientCosts: []int32{1, 1}, LogicCyclesReqs: true, LogicReqsConds: false, LinkedPrototype: -1},
+			{CyclesRequired: 15, NutrientReqs: []int32{0, 0}, NutrientCosts: []int32{1, 1}, LogicCyclesReqs: true, LogicReqsConds: false, LinkedPrototype: -1},
 		},
-		AssignmentPriorityM:  []int{0},
-		AssignmentPriorityF:  []int{0},
-		AssignmentThresholds: []float64{0},
 	}
 
 	idx := w.AddAgent()
@@ -208,7 +357,7 @@ func TestEvaluateStageTransition_AdvancesToNextStage(t *testing.T) {
 	a.Reserves[idx*cfg.NumNutrients+0] = 50
 	a.Reserves[idx*cfg.NumNutrients+1] = 50
 
-	transitioned := EvaluateStageTransition(w, idx, ontCfg)
+	transitioned := EvaluateStageTransition(w, idx, formulas.NewEvaluator(16), ontCfg)
 
 	if !transitioned {
 		t.Fatal("expected transition")
@@ -221,6 +370,195 @@ func TestEvaluateStageTransition_AdvancesToNextStage(t *testing.T) {
 	}
 }
 
+func TestEvaluateEggs_EvaluatesEggFormulas(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci}
+
+	// Eggs hatch after 10 ticks per unit of their first continuous locus.
+	reg := formulas.NewRegistry()
+	reg.Compile("cycles", "CL1 * 10")
+	ontCfg := testOntogenyCfg()
+	ontCfg.Stages[0].Cycles = reg.Get("cycles")
+
+	eggs := w.Eggs
+	eggs.Count = 2
+	for i, cl := range []float64{1, 2} {
+		eggs.Age[i] = 12
+		eggs.Sex[i] = world.SexFemale
+		eggs.Reserves[i*cfg.NumNutrients+0] = 10
+		eggs.Reserves[i*cfg.NumNutrients+1] = 10
+		genoBase := i * cfg.NumLoci * 2
+		eggs.GenotypeCont[genoBase] = cl
+		eggs.GenotypeCont[genoBase+1] = cl
+	}
+
+	eval := formulas.NewEvaluator(128)
+	if eclosed := EvaluateEggs(w, eval, formulas.NewEnvBuilder(eval, cfg), ontCfg, genCfg); eclosed != 1 {
+		t.Fatalf("expected only the CL1=1 egg to eclose, got %d", eclosed)
+	}
+	if eggs.Count != 1 || eggs.GenotypeCont[0] != 2 {
+		t.Fatalf("expected the CL1=2 egg to remain, got %d eggs", eggs.Count)
+	}
+}
+
+func TestEvaluateStageTransition_EvaluatesFormulasAndConditions(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	// Larvae need 2*5 cycles and 20 of nutrient 1, and either 40 of
+	// nutrient 1 or an age under 3; leaving costs a tenth of nutrient 1.
+	reg := formulas.NewRegistry()
+	for key, src := range map[string]string{
+		"cycles": "2 * 5", "req": "20", "cost": "Reserve1 / 10",
+		"cond1": "Reserve1", "cond2": "Age",
+	} {
+		reg.Compile(key, src)
+	}
+	ontCfg := OntogenyConfig{
+		NumStages:      3,
+		NumPrototypesM: 1,
+		NumPrototypesF: 1,
+		Stages: []StageConfig{
+			{LinkedPrototype: -1},
+			{
+				Cycles:          reg.Get("cycles"),
+				Requirements:    []*formulas.Program{reg.Get("req"), nil},
+				Costs:           []*formulas.Program{reg.Get("cost"), nil},
+				Condition1:      reg.Get("cond1"),
+				Condition1Op:    CompareGreaterEqual,
+				Condition1Value: 40,
+				Condition2:      reg.Get("cond2"),
+				Condition2Op:    CompareLess,
+				Condition2Value: 3,
+				LogicCyclesReqs: true,
+				LogicReqsConds:  true,
+				LinkedPrototype: -1,
+			},
+			{LinkedPrototype: -1},
+		},
+	}
+
+	idx := w.AddAgent()
+	a := w.Agents
+	a.StageID[idx] = 1
+	a.TimeInStage[idx] = 12
+	a.Age[idx] = 30
+	a.Reserves[idx*cfg.NumNutrients+0] = 30
+
+	eval := formulas.NewEvaluator(128)
+	env := formulas.NewEnvBuilder(eval, cfg)
+	env.SetAgentVars(w, idx)
+	if EvaluateStageTransition(w, idx, eval, ontCfg) {
+		t.Fatal("expected no transition while neither condition holds")
+	}
+
+	a.Reserves[idx*cfg.NumNutrients+0] = 50
+	env.SetAgentVars(w, idx)
+	if !EvaluateStageTransition(w, idx, eval, ontCfg) {
+		t.Fatal("expected a transition once condition 1 holds")
+	}
+	if a.StageID[idx] != 2 {
+		t.Fatalf("expected stage 2, got %d", a.StageID[idx])
+	}
+	if got := a.Reserves[idx*cfg.NumNutrients+0]; got != 45 {
+		t.Fatalf("expected reserve 50-5=45 after the cost formula, got %d", got)
+	}
+}
+
+func TestEvaluateStageTransition_FollowsLinkedPathway(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	// Stage 1 is the pathway of male prototype 1, stage 2 that of prototype 0.
+	ontCfg := OntogenyConfig{
+		NumStages:      3,
+		NumPrototypesM: 2,
+		NumPrototypesF: 1,
+		Stages: []StageConfig{
+			{LogicCyclesReqs: true, LinkedPrototype: -1},
+			{LogicCyclesReqs: true, LinkedPrototype: 1},
+			{LogicCyclesReqs: true, LinkedPrototype: 0},
+		},
+	}
+	eval := formulas.NewEvaluator(16)
+
+	a := w.Agents
+	for _, tc := range []struct {
+		fallback int
+		want     int32
+	}{{0, 2}, {1, 1}} {
+		idx := w.AddAgent()
+		a.Sex[idx] = world.SexMale
+		a.StageID[idx] = 0
+		ontCfg.FallbackM = tc.fallback
+
+		EvaluateStageTransition(w, idx, eval, ontCfg)
+		if a.PrototypeID[idx] != int32(tc.fallback) || a.StageID[idx] != tc.want {
+			t.Fatalf("prototype %d: expected stage %d, got prototype %d stage %d",
+				tc.fallback, tc.want, a.PrototypeID[idx], a.StageID[idx])
+		}
+	}
+
+	// Prototype 1 skips stage 2 and keeps its prototype as an adult.
+	EvaluateStageTransition(w, 1, eval, ontCfg)
+	if a.StageID[1] != -1 || a.PrototypeID[1] != 1 {
+		t.Fatalf("expected adult of prototype 1, got stage %d prototype %d", a.StageID[1], a.PrototypeID[1])
+	}
+}
+
+func TestAssignPrototypeFirstMatchingCriterion(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	idx := w.AddAgent()
+	w.Agents.Sex[idx] = world.SexMale
+
+	// Sneakers (prototype 1) are small, guards (prototype 2) are large;
+	// everything else falls back to prototype 0.
+	reg := formulas.NewRegistry()
+	reg.Compile("size", "Size")
+	ontCfg := OntogenyConfig{
+		AssignmentM: []AssignmentCriterion{
+			{Prototype: 1, Formula: reg.Get("size"), Operator: CompareLess, Threshold: 3},
+			{Prototype: 2, Formula: reg.Get("size"), Operator: CompareGreaterEqual, Threshold: 8},
+		},
+		FallbackM: 0,
+		FallbackF: 2,
+	}
+
+	eval := formulas.NewEvaluator(16)
+	for _, tc := range []struct {
+		size float64
+		want int
+	}{{1, 1}, {9, 2}, {5, 0}} {
+		eval.SetFloat("Size", tc.size)
+		if got := AssignPrototype(w, idx, eval, ontCfg); got != tc.want {
+			t.Errorf("size %v: expected prototype %d, got %d", tc.size, tc.want, got)
+		}
+	}
+
+	w.Agents.Sex[idx] = world.SexFemale
+	if got := AssignPrototype(w, idx, eval, ontCfg); got != 2 {
+		t.Fatalf("expected the female fallback 2, got %d", got)
+	}
+}
+
+func TestParseComparison(t *testing.T) {
+	for op, want := range map[string]bool{">": false, ">=": true, "<": false, "<=": true, "=": true, "==": true, "<>": false, "!=": false} {
+		c, err := ParseComparison(op)
+		if err != nil {
+			t.Fatalf("ParseComparison(%q): %v", op, err)
+		}
+		if got := c.Holds(2, 2); got != want {
+			t.Errorf("2 %s 2: expected %v, got %v", op, want, got)
+		}
+	}
+	if _, err := ParseComparison("=>"); err == nil {
+		t.Fatal("expected an error for an unknown operator")
+	}
+}
+
 func TestFixMorphology(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -242,7 +580,7 @@ func TestFixMorphology(t *testing.T) {
 	a.DominanceCont[genoBase+2] = 1
 	a.DominanceCont[genoBase+3] = 0
 
-	FixMorphology(w, idx)
+	FixMorphology(w, idx, nil, OntogenyConfig{})
 
 	morphBase := idx * numLoci
 	if a.MorphologyCont[morphBase+0] != 2.0 {
@@ -256,6 +594,50 @@ func TestFixMorphology(t *testing.T) {
 	}
 }
 
+func TestFixMorphology_EvaluatesPrototypeFormulas(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	// The female prototype grows locus 0 with larval reserves and time in
+	// stage; locus 1 keeps its expressed genotype plus a fixed bonus.
+	reg := formulas.NewRegistry()
+	reg.Compile("genetic", "Reserve1 / 10 + CyclesInCurrentLifeStage")
+	reg.Compile("environmental", "Age / 2")
+	reg.Compile("bonus", "3")
+	ontCfg := OntogenyConfig{
+		NumPrototypesM:          1,
+		NumPrototypesF:          1,
+		NumLoci:                 cfg.NumLoci,
+		MorphologyGenetic:       []*formulas.Program{nil, nil, reg.Get("genetic"), nil},
+		MorphologyEnvironmental: []*formulas.Program{nil, nil, reg.Get("environmental"), reg.Get("bonus")},
+	}
+
+	idx := w.AddAgent()
+	a := w.Agents
+	a.Sex[idx] = world.SexFemale
+	a.PrototypeID[idx] = 0
+	a.Age[idx] = 8
+	a.TimeInStage[idx] = 5
+	a.Reserves[idx*cfg.NumNutrients] = 70
+	genoBase := idx * cfg.NumLoci * 2
+	a.GenotypeCont[genoBase+2] = 2
+	a.GenotypeCont[genoBase+3] = 4
+	a.GenotypeDisc[genoBase+2] = 2
+	a.GenotypeDisc[genoBase+3] = 4
+
+	eval := formulas.NewEvaluator(128)
+	formulas.NewEnvBuilder(eval, cfg).SetAgentVars(w, idx)
+	FixMorphology(w, idx, eval, ontCfg)
+
+	morphBase := idx * cfg.NumLoci
+	if a.MorphologyCont[morphBase] != 16 || a.MorphologyDisc[morphBase] != 16 {
+		t.Fatalf("expected locus 0 = 7+5+4 = 16, got %f / %d", a.MorphologyCont[morphBase], a.MorphologyDisc[morphBase])
+	}
+	if a.MorphologyCont[morphBase+1] != 6 || a.MorphologyDisc[morphBase+1] != 6 {
+		t.Fatalf("expected locus 1 = 3+3 = 6, got %f / %d", a.MorphologyCont[morphBase+1], a.MorphologyDisc[morphBase+1])
+	}
+}
+
 func TestResolveCombatDynamics_Timeout(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
@@ -310,7 +692,7 @@ func TestResolveCourtshipDynamics_MutualAcceptance(t *testing.T) {
 	}
 	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci}
 
-	copulations := ResolveCourtshipDynamics(w, 100, reproCfg, genCfg)
+	copulations := ResolveCourtshipDynamics(w, nil, nil, 100, reproCfg, genCfg)
 
 	if copulations != 1 {
 		t.Fatalf("expected 1 copulation, got %d", copulations)
@@ -343,7 +725,7 @@ func TestResolveCourtshipDynamics_Timeout(t *testing.T) {
 	reproCfg := ReproductionConfig{}
 	genCfg := GeneticsConfig{NumLoci: cfg.NumLoci}
 
-	ResolveCourtshipDynamics(w, 30, reproCfg, genCfg) // maxTicks=30, both exceed.
+	ResolveCourtshipDynamics(w, nil, nil, 30, reproCfg, genCfg) // maxTicks=30, both exceed.
 
 	if a.Situation[idx0] != world.SituationRegular {
 		t.Fatalf("idx0 should be regular after timeout, got %d", a.Situation[idx0])
@@ -26,15 +26,9 @@ const (
 
 // Behavioral tuning constants.
 const (
-	contiguousDistance   = 1.5 // Max distance to consider elements "adjacent".
-	defaultAgentAttr     = 5   // Default base attractiveness for agents.
-	fightBoostDisplay    = 5   // VDecision boost for fight when contender detected.
-	fightBoostEscalate   = 3   // VDecision boost for escalate when contender detected.
-	courtBoostDisplay    = 5   // VDecision boost for courtship when mate detected.
-	courtBoostEscalate   = 3   // VDecision boost for courtship escalate.
-	criticalReserveLevel = 5   // Reserve level below which agent is in critical state.
-	behaviorOffsetFeed   = 2   // First feed behavior index (0=move, 1=rest, 2+=feed).
-	contiguousBoost      = 1   // Extra VDecision weight for contiguous resources.
+	contiguousDistance = 1.5 // Max distance to consider elements "adjacent".
+	behaviorOffsetFeed = 2   // First feed behavior index (0=move, 1=rest, 2+=feed).
+	contiguousBoost    = 1   // Extra VDecision weight for contiguous resources.
 )
 
 // Lookup tables for direction conversions (replace switch statements).
@@ -61,13 +55,137 @@ type PerceptionContext struct {
 	Eval         *formulas.Evaluator
 	EnvBuilder   *formulas.EnvBuilder
 
-	// Precomputed attractiveness radii per (resource_type, perceiver_prototype).
-	// Index: [resourceType * numPerceivers + perceiverIdx]
-	ResourceRadii []float64
-	ResourceAttr  []int32
+	// Metabolism formulas for the per-tick reserve reference levels (optional).
+	Metabolism *MetabolismConfig
 
-	// Agent attractiveness radii: [observed * numPerceivers + perceiverIdx]
-	AgentRadii []float64
+	// Velocity formula per substrate (optional). Agents on a substrate
+	// without a formula keep their current Speed.
+	Velocities []*formulas.Program
+
+	// Perception matrices (optional). A nil matrix perceives nothing of its
+	// category. Resource elements are resource types; agent elements are the
+	// observed agents' perceiver indices.
+	Substrates *PerceptionMatrix
+	Resources  *PerceptionMatrix
+	Agents     *PerceptionMatrix
+
+	// Memory influence formulas per perceiver (optional).
+	Memory [][]MemoryInfluence
+
+	// Combat and courtship payoff matrices (optional).
+	Combat    *PayoffMatrix
+	Courtship *PayoffMatrix
+
+	// Genetics parameters (optional). Under haplodiploidy, virgin females
+	// may oviposit.
+	Genetics *GeneticsConfig
+}
+
+// MemoryInfluence is one memory_influence formula of a perceiver and the
+// behavior whose decision weight it adds to.
+type MemoryInfluence struct {
+	Behavior int
+	Formula  *formulas.Program
+}
+
+// PerceptionMatrix holds the radius, attractiveness and interaction formulas
+// of one category of perceived elements (substrates, resource types or agents)
+// for every perceiver. The formulas are evaluated per agent because they may
+// depend on the perceiver's state; numeric literals skip the VM.
+// Radius and Attractiveness are indexed [element * NumPerceivers + perceiver];
+// Interaction is indexed [(element * NumPerceivers + perceiver) * numBehaviors + behavior].
+type PerceptionMatrix struct {
+	NumPerceivers  int
+	Radius         []*formulas.Program
+	Attractiveness []*formulas.Program
+	Interaction    []*formulas.Program
+
+	numElements  int
+	numBehaviors int
+
+	// Per-agent scratch, reused across agents.
+	radius    []float64
+	attr      []int32
+	count     []int32
+	influence []int32 // [element * numBehaviors + behavior], valid when evaluated.
+	evaluated []bool
+	weights   []int32
+}
+
+// NewPerceptionMatrix allocates empty formula tables and scratch buffers.
+func NewPerceptionMatrix(numElements, numPerceivers, numBehaviors int) *PerceptionMatrix {
+	return &PerceptionMatrix{
+		NumPerceivers:  numPerceivers,
+		Radius:         make([]*formulas.Program, numElements*numPerceivers),
+		Attractiveness: make([]*formulas.Program, numElements*numPerceivers),
+		Interaction:    make([]*formulas.Program, numElements*numPerceivers*numBehaviors),
+		numElements:    numElements,
+		numBehaviors:   numBehaviors,
+		radius:         make([]float64, numElements),
+		attr:           make([]int32, numElements),
+		count:          make([]int32, numElements),
+		influence:      make([]int32, numElements*numBehaviors),
+		evaluated:      make([]bool, numElements),
+		weights:        make([]int32, numBehaviors),
+	}
+}
+
+// MaxConstantRadius returns the largest radius given as a numeric literal, or
+// 0 when every radius depends on state.
+func (m *PerceptionMatrix) MaxConstantRadius() float64 {
+	maxRadius := 0.0
+	for _, p := range m.Radius {
+		if p == nil {
+			continue
+		}
+		if v, ok := p.Constant(); ok {
+			maxRadius = math.Max(maxRadius, v)
+		}
+	}
+	return maxRadius
+}
+
+// evaluate fills the radius and attractiveness scratch of perceiver p, resets
+// the element counts and returns the largest radius.
+func (m *PerceptionMatrix) evaluate(eval *formulas.Evaluator, p int) float64 {
+	maxRadius := 0.0
+	for e := 0; e < m.numElements; e++ {
+		key := e*m.NumPerceivers + p
+		m.radius[e] = evalFloat(eval, m.Radius[key])
+		m.attr[e] = int32(evalFloat(eval, m.Attractiveness[key]))
+		m.count[e] = 0
+		m.evaluated[e] = false
+		maxRadius = math.Max(maxRadius, m.radius[e])
+	}
+	return maxRadius
+}
+
+// perceives reports whether element e lies within the evaluated radius.
+func (m *PerceptionMatrix) perceives(e int, dist float64) bool {
+	return e >= 0 && e < m.numElements && dist <= m.radius[e]
+}
+
+// attractiveness returns the evaluated attractiveness of element e, decaying with distance.
+func (m *PerceptionMatrix) attractiveness(e int, dist float64) int32 {
+	attr := m.attr[e]
+	if dist > 0 && attr != 0 {
+		attr /= int32(math.Max(1, dist))
+	}
+	return attr
+}
+
+// influences returns the interaction weights of element e for perceiver p,
+// evaluating them on first use since the last evaluate.
+func (m *PerceptionMatrix) influences(eval *formulas.Evaluator, e, p int) []int32 {
+	weights := m.influence[e*m.numBehaviors : (e+1)*m.numBehaviors]
+	if !m.evaluated[e] {
+		base := (e*m.NumPerceivers + p) * m.numBehaviors
+		for b := range weights {
+			weights[b] = int32(evalFloat(eval, m.Interaction[base+b]))
+		}
+		m.evaluated[e] = true
+	}
+	return weights
 }
 
 // Perceive runs the full perception pipeline for agent at idx.
@@ -83,15 +201,142 @@ func Perceive(ctx *PerceptionContext, idx int) {
 
 	ctx.EnvBuilder.SetWorldVars(w)
 	ctx.EnvBuilder.SetAgentVars(w, idx)
+	EvaluateReferenceLevels(w, idx, ctx.Eval, ctx.Metabolism)
+	evaluateVelocity(ctx, idx)
 
+	perceiveSubstrates(ctx, idx)
 	perceiveResources(ctx, idx)
 	perceiveAgents(ctx, idx)
 	applyBaseTendencies(ctx, idx)
+	applyMemoryInfluence(ctx, idx)
 	applyFilters(ctx, idx)
 	applyBoundaryAvoidance(ctx, idx)
 	ensureNonZeroDecision(ctx, idx)
 }
 
+// PerceiveInteraction prepares an agent in combat or courtship for its
+// decision (legacy DinamicaCombate/DinamicaCortejo): it refreshes the reserve
+// reference levels and fills InteractionWeights from the payoff matrix of the
+// agent's perceiver, using the column of the opponent's last action. The
+// contender's variables are visible to the formulas. Perceivers without
+// payoff formulas fall back to the fight or courtship weights of their last
+// VDecision.
+func PerceiveInteraction(ctx *PerceptionContext, idx int) {
+	w := ctx.World
+	a := w.Agents
+	ctx.EnvBuilder.SetWorldVars(w)
+	ctx.EnvBuilder.SetAgentVars(w, idx)
+	EvaluateReferenceLevels(w, idx, ctx.Eval, ctx.Metabolism)
+
+	m, numActions := ctx.Combat, combatActions
+	if a.Situation[idx] == world.SituationCourtship {
+		m, numActions = ctx.Courtship, courtshipActions
+	}
+	base := idx * world.InteractionActions
+	weights := a.InteractionWeights[base : base+numActions]
+
+	if interactant := int(a.InteractantIdx[idx]); interactant >= 0 && interactant < a.Count {
+		ctx.EnvBuilder.SetContenderVars(w, interactant)
+	}
+	p := getPerceiverIndex(a, idx, w.Config)
+	if m == nil || !m.evaluate(ctx.Eval, p, int(a.LastOpponentAction[idx]), weights) {
+		defaultInteractionWeights(a, idx, w.Config, weights)
+	}
+}
+
+// PayoffMatrix holds the combat or courtship formulas of every perceiver
+// (prototype_combat, prototype_courtship). Each formula gives the weight of
+// one action in response to the opponent's last action. Formulas is indexed
+// [(perceiver * NumActions + action) * NumOpponentActions + opponentAction];
+// opponent action 0 means the opponent has not acted yet.
+type PayoffMatrix struct {
+	NumActions         int
+	NumOpponentActions int
+	Formulas           []*formulas.Program
+}
+
+// NewPayoffMatrix allocates an empty payoff matrix.
+func NewPayoffMatrix(numPerceivers, numActions, numOpponentActions int) *PayoffMatrix {
+	return &PayoffMatrix{
+		NumActions:         numActions,
+		NumOpponentActions: numOpponentActions,
+		Formulas:           make([]*formulas.Program, numPerceivers*numActions*numOpponentActions),
+	}
+}
+
+// evaluate writes the action weights of perceiver p against opponent action
+// opp into dst. An opponent that has not acted yet counts as displaying when
+// the matrix has no column for it. Missing cells weigh 1, the table's
+// default. It returns false when the perceiver has no formulas for opp.
+func (m *PayoffMatrix) evaluate(eval *formulas.Evaluator, p, opp int, dst []int32) bool {
+	if opp == 0 && !m.definesColumn(p, opp) {
+		opp = 1
+	}
+	if opp >= m.NumOpponentActions || !m.definesColumn(p, opp) {
+		return false
+	}
+	for action := range dst {
+		dst[action] = 1
+		prog := m.Formulas[(p*m.NumActions+action)*m.NumOpponentActions+opp]
+		if prog == nil {
+			continue
+		}
+		if val, err := eval.RunProgramInt(prog); err == nil {
+			dst[action] = int32(val)
+		}
+	}
+	return true
+}
+
+// definesColumn reports whether perceiver p has any formula for opponent action opp.
+func (m *PayoffMatrix) definesColumn(p, opp int) bool {
+	for action := 0; action < m.NumActions; action++ {
+		if m.Formulas[(p*m.NumActions+action)*m.NumOpponentActions+opp] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultInteractionWeights derives the combat or courtship weights from the
+// agent's last VDecision, with fixed weights of 1 for retreat, accept and reject.
+func defaultInteractionWeights(a *world.AgentArrays, idx int, cfg world.Config, dst []int32) {
+	displayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
+	if a.Situation[idx] == world.SituationCourtship {
+		displayIdx += 2
+	}
+	vdBase := idx * cfg.NumBehaviors
+	for action := range dst {
+		dst[action] = 1
+	}
+	if displayIdx < cfg.NumBehaviors {
+		dst[0] = clampPositive(a.VDecision[vdBase+displayIdx])
+	}
+	if displayIdx+1 < cfg.NumBehaviors {
+		dst[1] = clampPositive(a.VDecision[vdBase+displayIdx+1])
+	}
+}
+
+// evaluateVelocity sets the agent's Speed for this tick from the velocity
+// formula of the substrate under it. Fractional velocities accumulate in
+// SpeedCarry, so a velocity of 0.5 moves one cell every other tick.
+func evaluateVelocity(ctx *PerceptionContext, idx int) {
+	w := ctx.World
+	a := w.Agents
+	s := w.Substrates.SubstrateAt(a.PosX[idx], a.PosY[idx])
+	if s < 0 || s >= len(ctx.Velocities) || ctx.Velocities[s] == nil {
+		return
+	}
+	v, err := ctx.Eval.RunProgramFloat(ctx.Velocities[s])
+	if err != nil {
+		return
+	}
+	total := a.SpeedCarry[idx] + math.Max(v, 0)
+	steps := math.Floor(total)
+	a.Speed[idx] = int32(steps)
+	a.SpeedCarry[idx] = total - steps
+}
+
 // resetVectors zeroes out tendencies and VDecision for an agent.
 func resetVectors(a *world.AgentArrays, idx int, numBehaviors int) {
 	tendBase := idx * 8
@@ -104,8 +349,98 @@ func resetVectors(a *world.AgentArrays, idx int, numBehaviors int) {
 	}
 }
 
+// perceiveSubstrates scans the substrate cells within the perceiver's radii
+// (legacy ProveePercepcionesSustratos); the cell under the agent is always
+// perceived. Tendencies and behavior weights are averaged over the perceived
+// cells, so large radii do not swamp other stimuli.
+func perceiveSubstrates(ctx *PerceptionContext, idx int) {
+	m := ctx.Substrates
+	if m == nil {
+		return
+	}
+	w := ctx.World
+	a := w.Agents
+	cfg := w.Config
+	numBeh := cfg.NumBehaviors
+	perceiverIdx := getPerceiverIndex(a, idx, cfg)
+	maxRadius := m.evaluate(ctx.Eval, perceiverIdx)
+
+	ax := a.PosX[idx]
+	ay := a.PosY[idx]
+	aDir := a.Direction[idx]
+	var tendSum, tendCount [8]int32
+	total := int32(0)
+
+	x0 := max(int(math.Floor(ax-maxRadius)), 0)
+	x1 := min(int(math.Ceil(ax+maxRadius)), w.Substrates.Width-1)
+	y0 := max(int(math.Floor(ay-maxRadius)), 0)
+	y1 := min(int(math.Ceil(ay+maxRadius)), w.Substrates.Height-1)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			s := int(w.Substrates.Get(x, y)) - 1
+			dist := distance(ax, ay, float64(x), float64(y))
+			if !m.perceives(s, dist) {
+				continue
+			}
+			m.count[s]++
+			total++
+
+			if dir := relativeDirection(aDir, ax, ay, float64(x), float64(y)); dir >= 0 {
+				tendSum[dir] += m.attractiveness(s, dist)
+				tendCount[dir]++
+			}
+		}
+	}
+	if total == 0 {
+		return
+	}
+
+	tendBase := idx * 8
+	for d := 0; d < 8; d++ {
+		if tendCount[d] > 0 {
+			a.Tendencies[tendBase+d] += tendSum[d] / tendCount[d]
+		}
+	}
+
+	// Behavior weights: influences averaged over the perceived cells.
+	vdBase := idx * numBeh
+	weights := m.weights
+	clear(weights)
+	for s := 0; s < cfg.NumSubstrates; s++ {
+		if m.count[s] == 0 {
+			continue
+		}
+		for b, v := range m.influences(ctx.Eval, s, perceiverIdx) {
+			weights[b] += m.count[s] * v
+		}
+		rememberPerceived(w, idx, s)
+	}
+	for b := 0; b < numBeh; b++ {
+		a.VDecision[vdBase+b] += weights[b] / total
+	}
+}
+
+// evalFloat evaluates an optional program, returning 0 when it is missing or fails.
+func evalFloat(eval *formulas.Evaluator, p *formulas.Program) float64 {
+	if p == nil {
+		return 0
+	}
+	v, err := eval.RunProgramFloat(p)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
 // perceiveResources queries the resource grid and accumulates tendencies + VDecision.
+// Every perceived resource adds its interaction weights; feeding on a resource
+// type is additionally weighted by its attractiveness, as is oviposition on
+// an oviposition site with room for eggs.
 func perceiveResources(ctx *PerceptionContext, idx int) {
+	m := ctx.Resources
+	if m == nil {
+		return
+	}
 	w := ctx.World
 	a := w.Agents
 	r := w.Resources
@@ -116,7 +451,7 @@ func perceiveResources(ctx *PerceptionContext, idx int) {
 	aDir := a.Direction[idx]
 	perceiverIdx := getPerceiverIndex(a, idx, cfg)
 
-	maxRadius := maxFloat64(ctx.ResourceRadii)
+	maxRadius := m.evaluate(ctx.Eval, perceiverIdx)
 	if maxRadius <= 0 {
 		return
 	}
@@ -130,27 +465,45 @@ func perceiveResources(ctx *PerceptionContext, idx int) {
 		ry := r.PosY[rIdx]
 		dist := distance(ax, ay, rx, ry)
 		resourceType := int(r.TypeID[rIdx])
-
-		radiusKey := resourceType*cfg.NumPrototypes + perceiverIdx
-		if radiusKey >= len(ctx.ResourceRadii) || dist > ctx.ResourceRadii[radiusKey] {
+		if !m.perceives(resourceType, dist) {
 			continue
 		}
+		m.count[resourceType]++
 
-		attractiveness := getResourceAttractiveness(ctx, radiusKey, dist)
+		attractiveness := m.attractiveness(resourceType, dist)
 		accumulateTendency(a, tendBase, aDir, ax, ay, rx, ry, attractiveness)
 
-		feedBehavior := behaviorOffsetFeed + resourceType
-		if feedBehavior < cfg.NumBehaviors {
-			a.VDecision[vdBase+feedBehavior] += clampPositive(attractiveness)
+		behavior := behaviorOffsetFeed + resourceType
+		if isOvipositionSite(w, resourceType) {
+			behavior = -1
+			if r.Level[rIdx] < r.MaxLevel[rIdx] {
+				behavior = ovipositBehaviorIdx(cfg)
+			}
+		}
+		if behavior >= 0 && behavior < cfg.NumBehaviors {
+			a.VDecision[vdBase+behavior] += clampPositive(attractiveness)
 			if dist <= contiguousDistance {
-				a.VDecision[vdBase+feedBehavior] += contiguousBoost
+				a.VDecision[vdBase+behavior] += contiguousBoost
 			}
 		}
 	}
+
+	for t := 0; t < m.numElements; t++ {
+		if m.count[t] > 0 {
+			rememberPerceived(w, idx, resourceMemorySlot(cfg, t))
+		}
+	}
+	addInfluences(ctx, m, idx, perceiverIdx)
 }
 
 // perceiveAgents queries the agent grid and accumulates tendencies + VDecision.
+// Interaction weights on fight behaviors only count for contiguous contenders,
+// and those on courtship behaviors only for contiguous mates.
 func perceiveAgents(ctx *PerceptionContext, idx int) {
+	m := ctx.Agents
+	if m == nil {
+		return
+	}
 	w := ctx.World
 	a := w.Agents
 	cfg := w.Config
@@ -160,16 +513,15 @@ func perceiveAgents(ctx *PerceptionContext, idx int) {
 	aDir := a.Direction[idx]
 	perceiverIdx := getPerceiverIndex(a, idx, cfg)
 
-	maxRadius := maxFloat64(ctx.AgentRadii)
+	maxRadius := m.evaluate(ctx.Eval, perceiverIdx)
 	if maxRadius <= 0 {
 		return
 	}
 
 	candidates := ctx.AgentGrid.QueryRadiusExact(ax, ay, maxRadius, a.PosX, a.PosY)
 	tendBase := idx * 8
-
-	hasContender := false
-	hasMate := false
+	vdBase := idx * cfg.NumBehaviors
+	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
 
 	for _, cIdx := range candidates {
 		if cIdx == int32(idx) || int(cIdx) >= a.Count {
@@ -181,22 +533,53 @@ func perceiveAgents(ctx *PerceptionContext, idx int) {
 		dist := distance(ax, ay, cx, cy)
 
 		observedIdx := getPerceiverIndex(a, int(cIdx), cfg)
-		radiusKey := observedIdx*cfg.NumPrototypes + perceiverIdx
-		if radiusKey >= len(ctx.AgentRadii) || dist > ctx.AgentRadii[radiusKey] {
+		if !m.perceives(observedIdx, dist) {
 			continue
 		}
+		m.count[observedIdx]++
 
-		attractiveness := computeAgentAttractiveness(dist)
+		attractiveness := m.attractiveness(observedIdx, dist)
 		accumulateTendency(a, tendBase, aDir, ax, ay, cx, cy, attractiveness)
 
+		contender, mate := false, false
 		if dist <= contiguousDistance {
-			c, m := classifyNeighbor(a.Sex[idx], a.Sex[cIdx], a.Situation[cIdx])
-			hasContender = hasContender || c
-			hasMate = hasMate || m
+			contender, mate = classifyNeighbor(a.Sex[idx], a.Sex[cIdx], a.Situation[cIdx])
+		}
+		for b, v := range m.influences(ctx.Eval, observedIdx, perceiverIdx) {
+			switch {
+			case b == fightDisplayIdx || b == fightDisplayIdx+1:
+				if !contender {
+					continue
+				}
+			case b == fightDisplayIdx+2 || b == fightDisplayIdx+3:
+				if !mate {
+					continue
+				}
+			}
+			a.VDecision[vdBase+b] += v
 		}
 	}
+	for p := 0; p < m.numElements; p++ {
+		if m.count[p] > 0 {
+			rememberPerceived(w, idx, agentMemorySlot(cfg, p))
+		}
+	}
+}
 
-	applyAgentDetectionBoosts(a, idx, cfg, hasContender, hasMate)
+// addInfluences adds the interaction weights of every perceived element,
+// once per perceived instance, to the agent's VDecision.
+func addInfluences(ctx *PerceptionContext, m *PerceptionMatrix, idx, perceiverIdx int) {
+	a := ctx.World.Agents
+	numBeh := ctx.World.Config.NumBehaviors
+	vdBase := idx * numBeh
+	for e := 0; e < m.numElements; e++ {
+		if m.count[e] == 0 {
+			continue
+		}
+		for b, v := range m.influences(ctx.Eval, e, perceiverIdx) {
+			a.VDecision[vdBase+b] += m.count[e] * v
+		}
+	}
 }
 
 // classifyNeighbor determines if a contiguous agent is a contender, a mate, or neither.
@@ -210,32 +593,6 @@ func classifyNeighbor(agentSex, otherSex, otherSituation uint8) (contender, mate
 	return contender, mate
 }
 
-// applyAgentDetectionBoosts adds fight/court weights when contenders/mates are found.
-func applyAgentDetectionBoosts(a *world.AgentArrays, idx int, cfg world.Config, hasContender, hasMate bool) {
-	vdBase := idx * cfg.NumBehaviors
-	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
-	fightEscalateIdx := fightDisplayIdx + 1
-	courtDisplayIdx := fightDisplayIdx + 2
-	courtEscalateIdx := fightDisplayIdx + 3
-
-	if hasContender {
-		if fightDisplayIdx < cfg.NumBehaviors {
-			a.VDecision[vdBase+fightDisplayIdx] += fightBoostDisplay
-		}
-		if fightEscalateIdx < cfg.NumBehaviors {
-			a.VDecision[vdBase+fightEscalateIdx] += fightBoostEscalate
-		}
-	}
-	if hasMate {
-		if courtDisplayIdx < cfg.NumBehaviors {
-			a.VDecision[vdBase+courtDisplayIdx] += courtBoostDisplay
-		}
-		if courtEscalateIdx < cfg.NumBehaviors {
-			a.VDecision[vdBase+courtEscalateIdx] += courtBoostEscalate
-		}
-	}
-}
-
 // applyBaseTendencies evaluates the base tendency formulas for the agent's prototype/stage.
 func applyBaseTendencies(ctx *PerceptionContext, idx int) {
 	w := ctx.World
@@ -269,6 +626,24 @@ func applyBaseTendencies(ctx *PerceptionContext, idx int) {
 	}
 }
 
+// applyMemoryInfluence adds the memory influence formulas of the agent's
+// perceiver to its VDecision, so that past perceptions, interactions and
+// behaviors (e.g. habituation, site fidelity) bias the decision.
+func applyMemoryInfluence(ctx *PerceptionContext, idx int) {
+	a := ctx.World.Agents
+	cfg := ctx.World.Config
+	perceiverIdx := getPerceiverIndex(a, idx, cfg)
+	if perceiverIdx >= len(ctx.Memory) {
+		return
+	}
+	vdBase := idx * cfg.NumBehaviors
+	for _, m := range ctx.Memory[perceiverIdx] {
+		if val, err := ctx.Eval.RunProgramInt(m.Formula); err == nil {
+			a.VDecision[vdBase+m.Behavior] += int32(val)
+		}
+	}
+}
+
 // applyFilters zeroes out behaviors that are unavailable given current state.
 func applyFilters(ctx *PerceptionContext, idx int) {
 	w := ctx.World
@@ -290,13 +665,20 @@ func applyFilters(ctx *PerceptionContext, idx int) {
 		a.VDecision[vdBase+courtEscalateIdx] = 0
 	}
 
-	// Disable oviposition for males or agents with no fertilized eggs.
-	if a.Sex[idx] == world.SexMale || a.FertilizedCount[idx] == 0 {
+	// Disable oviposition for males or agents with no eggs to lay.
+	if a.Sex[idx] == world.SexMale || eggsReady(a, idx, ctx.Genetics) <= 0 {
 		if ovipositIdx < cfg.NumBehaviors {
 			a.VDecision[vdBase+ovipositIdx] = 0
 		}
 	}
 
+	// Disable feeding on resource types that feed no nutrient.
+	for t := 0; t < cfg.NumResourceTypes; t++ {
+		if resourceNutrient(w, t) < 0 {
+			zeroIfValid(a.VDecision, vdBase+behaviorOffsetFeed+t, cfg.NumBehaviors)
+		}
+	}
+
 	// Disable fight and courtship when reserves are critical.
 	if isReserveCritical(a, idx, cfg) {
 		zeroIfValid(a.VDecision, vdBase+fightDisplayIdx, cfg.NumBehaviors)
@@ -446,35 +828,6 @@ func distance(x1, y1, x2, y2 float64) float64 {
 	return math.Sqrt(dx*dx + dy*dy)
 }
 
-func maxFloat64(s []float64) float64 {
-	m := 0.0
-	for _, v := range s {
-		if v > m {
-			m = v
-		}
-	}
-	return m
-}
-
-func getResourceAttractiveness(ctx *PerceptionContext, radiusKey int, dist float64) int32 {
-	if radiusKey >= len(ctx.ResourceAttr) {
-		return 0
-	}
-	attr := ctx.ResourceAttr[radiusKey]
-	if dist > 0 && attr != 0 {
-		attr /= int32(math.Max(1, dist))
-	}
-	return attr
-}
-
-func computeAgentAttractiveness(dist float64) int32 {
-	attr := int32(defaultAgentAttr)
-	if dist > 0 {
-		attr /= int32(math.Max(1, dist))
-	}
-	return attr
-}
-
 func accumulateTendency(a *world.AgentArrays, tendBase int, aDir uint8, ax, ay, tx, ty float64, attr int32) {
 	dir := relativeDirection(aDir, ax, ay, tx, ty)
 	if dir >= 0 && dir < 8 {
@@ -491,7 +844,7 @@ func clampPositive(v int32) int32 {
 
 func isReserveCritical(a *world.AgentArrays, idx int, cfg world.Config) bool {
 	for n := 0; n < cfg.NumNutrients; n++ {
-		if a.Reserves[idx*cfg.NumNutrients+n] <= criticalReserveLevel {
+		if a.Reserves[idx*cfg.NumNutrients+n] <= a.ReserveCritical[idx*cfg.NumNutrients+n] {
 			return true
 		}
 	}
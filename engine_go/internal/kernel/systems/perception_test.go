@@ -23,6 +23,7 @@ func testCfg() world.Config {
 		GridWidth:        50,
 		GridHeight:       50,
 		InitialCapacity:  32,
+		MaxStoredPacks:   5,
 	}
 }
 
@@ -45,31 +46,37 @@ func setupPerceptionContext(w *world.World) *PerceptionContext {
 	eval := formulas.NewEvaluator(128)
 	envBuilder := formulas.NewEnvBuilder(eval, cfg)
 
-	// Set up radii: all resource types have radius 10 for all perceivers.
-	numRadii := cfg.NumResourceTypes * cfg.NumPrototypes
-	resourceRadii := make([]float64, numRadii)
-	resourceAttr := make([]int32, numRadii)
-	for i := range resourceRadii {
-		resourceRadii[i] = 10.0
-		resourceAttr[i] = 10
+	// All resource types and agents are perceived at radius 10 by every
+	// perceiver; contiguous contenders and mates weigh display 5, escalate 3.
+	reg.Compile("radius", "10")
+	reg.Compile("attr.resource", "10")
+	reg.Compile("attr.agent", "5")
+	reg.Compile("display", "5")
+	reg.Compile("escalate", "3")
+	resources := NewPerceptionMatrix(cfg.NumResourceTypes, cfg.NumPrototypes, cfg.NumBehaviors)
+	for i := range resources.Radius {
+		resources.Radius[i] = reg.Get("radius")
+		resources.Attractiveness[i] = reg.Get("attr.resource")
 	}
-
-	// Agent radii: all agent types perceive each other at radius 10.
-	agentRadii := make([]float64, cfg.NumPrototypes*cfg.NumPrototypes)
-	for i := range agentRadii {
-		agentRadii[i] = 10.0
+	agents := NewPerceptionMatrix(cfg.NumPrototypes, cfg.NumPrototypes, cfg.NumBehaviors)
+	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
+	for i := range agents.Radius {
+		agents.Radius[i] = reg.Get("radius")
+		agents.Attractiveness[i] = reg.Get("attr.agent")
+		for k, key := range []string{"display", "escalate", "display", "escalate"} {
+			agents.Interaction[i*cfg.NumBehaviors+fightDisplayIdx+k] = reg.Get(key)
+		}
 	}
 
 	return &PerceptionContext{
-		World:         w,
-		AgentGrid:     agentGrid,
-		ResourceGrid:  resourceGrid,
-		Formulas:      reg,
-		Eval:          eval,
-		EnvBuilder:    envBuilder,
-		ResourceRadii: resourceRadii,
-		ResourceAttr:  resourceAttr,
-		AgentRadii:    agentRadii,
+		World:        w,
+		AgentGrid:    agentGrid,
+		ResourceGrid: resourceGrid,
+		Formulas:     reg,
+		Eval:         eval,
+		EnvBuilder:   envBuilder,
+		Resources:    resources,
+		Agents:       agents,
 	}
 }
 
@@ -113,6 +120,344 @@ func TestPerceiveResourceAccumulatesTendency(t *testing.T) {
 	}
 }
 
+func TestPerceiveOvipositionSite(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	w.ResourceNutrient[0] = -1
+	w.ResourceOviposition[0] = true
+
+	w.Resources.PosX[0] = 25
+	w.Resources.PosY[0] = 20
+	w.Resources.TypeID[0] = 0
+	w.Resources.MaxLevel[0] = 10
+	w.Resources.Count = 1
+
+	idx := w.AddAgent()
+	w.Agents.PosX[idx] = 25
+	w.Agents.PosY[idx] = 25
+	w.Agents.Direction[idx] = 2 // North
+	w.Agents.Sex[idx] = world.SexFemale
+	w.Agents.FertilizedCount[idx] = 2
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 50
+
+	ctx := setupPerceptionContext(w)
+	ovipositIdx := idx*cfg.NumBehaviors + ovipositBehaviorIdx(cfg)
+	Perceive(ctx, idx)
+	if got := w.Agents.VDecision[ovipositIdx]; got <= 0 {
+		t.Fatalf("expected a positive oviposit weight towards the site, got %d", got)
+	}
+
+	// A full site no longer draws oviposition.
+	w.Resources.Level[0] = 10
+	Perceive(ctx, idx)
+	if got := w.Agents.VDecision[ovipositIdx]; got != 0 {
+		t.Fatalf("expected no oviposit weight for a full site, got %d", got)
+	}
+}
+
+func TestFilterDisablesFeedingOnNonFeedableType(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	w.ResourceNutrient[0] = -1 // E.g. an oviposition site.
+
+	w.Resources.PosX[0] = 25
+	w.Resources.PosY[0] = 20
+	w.Resources.TypeID[0] = 0
+	w.Resources.Level[0] = 50
+	w.Resources.Count = 1
+
+	idx := w.AddAgent()
+	w.Agents.PosX[idx] = 25
+	w.Agents.PosY[idx] = 25
+	w.Agents.Direction[idx] = 2 // North
+	w.Agents.StageID[idx] = 0
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 50
+
+	ctx := setupPerceptionContext(w)
+	Perceive(ctx, idx)
+
+	// The site still attracts movement but cannot be fed from.
+	if w.Agents.Tendencies[idx*8+DirN] <= 0 {
+		t.Fatalf("expected positive tendency towards the site, got %d", w.Agents.Tendencies[idx*8+DirN])
+	}
+	if got := w.Agents.VDecision[idx*cfg.NumBehaviors+behaviorOffsetFeed]; got != 0 {
+		t.Fatalf("expected feed VDecision 0, got %d", got)
+	}
+}
+
+func TestPerceiveAccumulatesFractionalVelocity(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	w.Substrates.Set(25, 25, 1) // Substrate index 0 under the agent.
+
+	idx := w.AddAgent()
+	w.Agents.PosX[idx] = 25
+	w.Agents.PosY[idx] = 25
+	w.Agents.StageID[idx] = 0
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 50
+
+	ctx := setupPerceptionContext(w)
+	ctx.Formulas.Compile("velocity.0", "0.5")
+	ctx.Velocities = []*formulas.Program{ctx.Formulas.Get("velocity.0")}
+
+	// Half a cell per tick: no step, then one step.
+	Perceive(ctx, idx)
+	if w.Agents.Speed[idx] != 0 {
+		t.Fatalf("tick 1: expected speed 0, got %d", w.Agents.Speed[idx])
+	}
+	Perceive(ctx, idx)
+	if w.Agents.Speed[idx] != 1 {
+		t.Fatalf("tick 2: expected speed 1, got %d", w.Agents.Speed[idx])
+	}
+	if w.Agents.SpeedCarry[idx] != 0 {
+		t.Fatalf("expected carry consumed, got %f", w.Agents.SpeedCarry[idx])
+	}
+}
+
+func TestPerceiveSubstrates(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+	w.Tick = 9
+	// A strip of substrate 0 just north of the agent.
+	for y := 22; y <= 24; y++ {
+		w.Substrates.Set(25, y, 1)
+	}
+
+	idx := w.AddAgent()
+	w.Agents.PosX[idx] = 25
+	w.Agents.PosY[idx] = 25
+	w.Agents.Direction[idx] = 2 // North
+	w.Agents.StageID[idx] = 0
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 50
+
+	ctx := setupPerceptionContext(w)
+	reg := ctx.Formulas
+	reg.Compile("radius", "5")
+	reg.Compile("attr", "30")
+	reg.Compile("rest", "6")
+	sp := NewPerceptionMatrix(cfg.NumSubstrates, cfg.NumPrototypes, cfg.NumBehaviors)
+	sp.Radius[0] = reg.Get("radius") // Substrate 0, perceiver 0 (stage 0).
+	sp.Attractiveness[0] = reg.Get("attr")
+	sp.Interaction[behaviorRest] = reg.Get("rest")
+	ctx.Substrates = sp
+
+	Perceive(ctx, idx)
+
+	if got := w.Agents.Tendencies[idx*8+DirN]; got <= 0 {
+		t.Fatalf("expected positive forward tendency towards the substrate, got %d", got)
+	}
+	if got := w.Agents.VDecision[idx*cfg.NumBehaviors+behaviorRest]; got != 6 {
+		t.Fatalf("expected rest VDecision 6, got %d", got)
+	}
+	memBase := idx * (cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes)
+	if w.Agents.MemoryLastPerceived[memBase] != 0 || w.Agents.MemoryNumPerceived[memBase] != 1 {
+		t.Fatalf("expected substrate 0 perceived 0 ticks ago, got last=%d num=%d",
+			w.Agents.MemoryLastPerceived[memBase], w.Agents.MemoryNumPerceived[memBase])
+	}
+	if w.Agents.MemoryLastPerceived[memBase+1] != -1 {
+		t.Fatalf("substrate 1 was not perceived, got last=%d", w.Agents.MemoryLastPerceived[memBase+1])
+	}
+}
+
+func TestPerceiveResourcesEvaluatesPerceiverRadius(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	w.Resources.PosX[0] = 25
+	w.Resources.PosY[0] = 20
+	w.Resources.TypeID[0] = 1
+	w.Resources.Level[0] = 50
+	w.Resources.Count = 1
+
+	idx := w.AddAgent()
+	w.Agents.PosX[idx] = 25
+	w.Agents.PosY[idx] = 25
+	w.Agents.Direction[idx] = 2 // North
+	w.Agents.StageID[idx] = 0
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 50
+
+	ctx := setupPerceptionContext(w)
+	reg := ctx.Formulas
+	reg.Compile("radius.age", "Age")
+	reg.Compile("rest", "4")
+	ctx.Resources.Radius[1*cfg.NumPrototypes+0] = reg.Get("radius.age")
+	ctx.Resources.Interaction[(1*cfg.NumPrototypes+0)*cfg.NumBehaviors+behaviorRest] = reg.Get("rest")
+
+	// Too young to perceive a resource 5 cells away.
+	w.Agents.Age[idx] = 3
+	Perceive(ctx, idx)
+	if got := w.Agents.VDecision[idx*cfg.NumBehaviors+behaviorRest]; got != 0 {
+		t.Fatalf("expected no rest weight at radius 3, got %d", got)
+	}
+
+	w.Agents.Age[idx] = 6
+	Perceive(ctx, idx)
+	if got := w.Agents.VDecision[idx*cfg.NumBehaviors+behaviorRest]; got != 4 {
+		t.Fatalf("expected rest weight 4 from the perceived resource, got %d", got)
+	}
+	if w.Agents.Tendencies[idx*8+DirN] <= 0 {
+		t.Fatalf("expected positive tendency towards the resource, got %d", w.Agents.Tendencies[idx*8+DirN])
+	}
+}
+
+func TestPerceiveAgentIgnoresDistantContenderFightWeights(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	for _, x := range []float64{25, 30} {
+		i := w.AddAgent()
+		w.Agents.PosX[i] = x
+		w.Agents.PosY[i] = 25
+		w.Agents.Direction[i] = 2
+		w.Agents.Sex[i] = world.SexMale
+		w.Agents.StageID[i] = -1
+		w.Agents.PrototypeID[i] = 0
+		w.Agents.Situation[i] = world.SituationRegular
+		w.Agents.Reserves[i*cfg.NumNutrients+0] = 50
+		w.Agents.Reserves[i*cfg.NumNutrients+1] = 50
+	}
+
+	ctx := setupPerceptionContext(w)
+	Perceive(ctx, 0)
+
+	// The contender is perceived (radius 10) but not contiguous.
+	fightDisplayIdx := behaviorOffsetFeed + cfg.NumResourceTypes
+	if got := w.Agents.VDecision[fightDisplayIdx]; got != 0 {
+		t.Fatalf("expected no fight_display weight for a distant contender, got %d", got)
+	}
+	if w.Agents.Tendencies[DirE] <= 0 {
+		t.Fatalf("expected positive tendency towards the contender, got %d", w.Agents.Tendencies[DirE])
+	}
+}
+
+func TestPerceiveAppliesMemoryInfluence(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	idx := w.AddAgent()
+	w.Agents.PosX[idx] = 25
+	w.Agents.PosY[idx] = 25
+	w.Agents.StageID[idx] = 0
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 50
+	w.Agents.MemoryNumBehavior[idx*cfg.NumBehaviors+behaviorRest] = 3
+
+	ctx := setupPerceptionContext(w)
+	ctx.Formulas.Compile("habit", "MemoryNumBehavior2 * 2")
+	ctx.Memory = [][]MemoryInfluence{
+		{{Behavior: behaviorRest, Formula: ctx.Formulas.Get("habit")}},
+	}
+	Perceive(ctx, idx)
+
+	if got := w.Agents.VDecision[idx*cfg.NumBehaviors+behaviorRest]; got != 6 {
+		t.Fatalf("expected rest weight 6 from memory, got %d", got)
+	}
+}
+
+func TestPerceiveRemembersResourcesAndAgents(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	// A resource of type 1 and an adult male near a larva.
+	w.Resources.PosX[0] = 25
+	w.Resources.PosY[0] = 22
+	w.Resources.TypeID[0] = 1
+	w.Resources.Level[0] = 50
+	w.Resources.Count = 1
+
+	idx := w.AddAgent()
+	w.Agents.PosX[idx] = 25
+	w.Agents.PosY[idx] = 25
+	w.Agents.StageID[idx] = 0
+	w.Agents.Reserves[idx*cfg.NumNutrients+0] = 50
+	w.Agents.Reserves[idx*cfg.NumNutrients+1] = 50
+
+	male := w.AddAgent()
+	w.Agents.PosX[male] = 28
+	w.Agents.PosY[male] = 25
+	w.Agents.Sex[male] = world.SexMale
+	w.Agents.StageID[male] = -1
+	w.Agents.PrototypeID[male] = 0
+
+	ctx := setupPerceptionContext(w)
+	Perceive(ctx, idx)
+
+	memBase := idx * (cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes)
+	resourceSlot := memBase + cfg.NumSubstrates + 1
+	if w.Agents.MemoryLastPerceived[resourceSlot] != 0 || w.Agents.MemoryNumPerceived[resourceSlot] != 1 {
+		t.Fatalf("expected resource type 1 perceived 0 ticks ago, got last=%d num=%d",
+			w.Agents.MemoryLastPerceived[resourceSlot], w.Agents.MemoryNumPerceived[resourceSlot])
+	}
+	if w.Agents.MemoryLastPerceived[resourceSlot-1] != -1 {
+		t.Fatal("resource type 0 was not perceived")
+	}
+	// The male adult is perceiver 1: after the single stage.
+	maleSlot := memBase + cfg.NumSubstrates + cfg.NumResourceTypes + 1
+	if w.Agents.MemoryLastPerceived[maleSlot] != 0 || w.Agents.MemoryNumPerceived[maleSlot] != 1 {
+		t.Fatalf("expected the male perceived 0 ticks ago, got last=%d num=%d",
+			w.Agents.MemoryLastPerceived[maleSlot], w.Agents.MemoryNumPerceived[maleSlot])
+	}
+	if w.Agents.MemoryNumPerceived[maleSlot+1] != 0 {
+		t.Fatal("no female was perceived")
+	}
+
+	// Memory ages every tick; unperceived elements stay at -1.
+	UpdateAgent(w, idx, 0)
+	UpdateAgent(w, idx, 0)
+	if w.Agents.MemoryLastPerceived[resourceSlot] != 2 || w.Agents.MemoryLastPerceived[maleSlot] != 2 {
+		t.Fatalf("expected both perceived 2 ticks ago, got %d and %d",
+			w.Agents.MemoryLastPerceived[resourceSlot], w.Agents.MemoryLastPerceived[maleSlot])
+	}
+	if w.Agents.MemoryLastPerceived[resourceSlot-1] != -1 {
+		t.Fatalf("never-perceived memory should stay -1, got %d", w.Agents.MemoryLastPerceived[resourceSlot-1])
+	}
+}
+
+func TestPerceiveInteractionEvaluatesPayoffMatrix(t *testing.T) {
+	cfg := testCfg()
+	w := world.New(cfg)
+
+	a := w.Agents
+	self, contender := w.AddAgent(), w.AddAgent()
+	for _, idx := range []int{self, contender} {
+		a.Sex[idx] = world.SexMale
+		a.PrototypeID[idx] = 0
+		a.Situation[idx] = world.SituationCombat
+	}
+	a.InteractantIdx[self] = int32(contender)
+	a.InteractantIdx[contender] = int32(self)
+	a.MorphologyFixed[contender] = true
+	a.MorphologyCont[contender*cfg.NumLoci] = 4
+
+	// Male prototype 0 (perceiver 1) displays in proportion to the contender's
+	// size when the contender escalated, and only escalates on the opening move.
+	ctx := setupPerceptionContext(w)
+	ctx.Formulas.Compile("size", "ContenderMorphology1 * 10")
+	ctx.Formulas.Compile("escalate", "7")
+	ctx.Combat = NewPayoffMatrix(cfg.NumPrototypes, combatActions, 3)
+	cell := func(action, opp int) int { return (1*combatActions+action)*3 + opp }
+	ctx.Combat.Formulas[cell(combatDisplay, 2)] = ctx.Formulas.Get("size")
+	ctx.Combat.Formulas[cell(combatEscalate, 1)] = ctx.Formulas.Get("escalate")
+
+	weights := a.InteractionWeights[self*world.InteractionActions:]
+	a.LastOpponentAction[self] = 2
+	PerceiveInteraction(ctx, self)
+	if weights[combatDisplay] != 40 || weights[combatEscalate] != 1 || weights[combatRetreat] != 1 {
+		t.Fatalf("expected weights [40 1 1] against escalation, got %v", weights[:combatActions])
+	}
+
+	a.LastOpponentAction[self] = 0
+	PerceiveInteraction(ctx, self)
+	if weights[combatDisplay] != 1 || weights[combatEscalate] != 7 {
+		t.Fatalf("expected the opening move to answer a display, got %v", weights[:combatActions])
+	}
+}
+
 func TestPerceiveAgentDetectsContender(t *testing.T) {
 	cfg := testCfg()
 	w := world.New(cfg)
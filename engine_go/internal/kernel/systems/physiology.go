@@ -1,9 +1,53 @@
 package systems
 
 import (
+	"galatea/engine/internal/kernel/formulas"
 	"galatea/engine/internal/kernel/world"
 )
 
+// MetabolismConfig holds the compiled metabolism formulas, one program per
+// nutrient (indexed by nutrient). A nil program leaves the level unchanged.
+type MetabolismConfig struct {
+	Min      []*formulas.Program // Starvation threshold.
+	Critical []*formulas.Program // Critical state threshold.
+	Optimal  []*formulas.Program // Reproduction threshold.
+	Initial  []*formulas.Program // Starting reserves of founder agents.
+	Max      []*formulas.Program // Reserve cap after feeding.
+}
+
+// InitReserves sets the reserves of agent idx from the initial formulas.
+// The evaluator environment must already hold the agent's variables.
+func InitReserves(w *world.World, idx int, eval *formulas.Evaluator, m *MetabolismConfig) {
+	if m == nil {
+		return
+	}
+	a := w.Agents
+	base := idx * w.Config.NumNutrients
+	for n, p := range m.Initial {
+		if p == nil {
+			continue
+		}
+		if val, err := eval.RunProgramInt(p); err == nil {
+			a.Reserves[base+n] = clampReserve(int32(val), a.ReserveMax[base+n])
+		}
+	}
+}
+
+// EvaluateReferenceLevels evaluates the min, critical, optimal and max
+// reserve levels of agent idx (legacy ProveeValoresReferencia).
+// The evaluator environment must already hold the agent's variables.
+func EvaluateReferenceLevels(w *world.World, idx int, eval *formulas.Evaluator, m *MetabolismConfig) {
+	if m == nil {
+		return
+	}
+	a := w.Agents
+	base := idx * w.Config.NumNutrients
+	evalLevels(eval, m.Min, a.ReserveMin[base:])
+	evalLevels(eval, m.Critical, a.ReserveCritical[base:])
+	evalLevels(eval, m.Optimal, a.ReserveOptimal[base:])
+	evalLevels(eval, m.Max, a.ReserveMax[base:])
+}
+
 // ChargeNutrients deducts the metabolic cost of the executed behavior from the agent's reserves.
 // costs is a flat array: [behavior * numNutrients + nutrient] = cost amount.
 // If costs is nil, a default cost of 1 per nutrient is applied for non-rest behaviors.
@@ -36,18 +80,123 @@ func ChargeNutrients(w *world.World, idx int, costs []int32) {
 	}
 }
 
+// BehaviorCostConfig holds the nutrient cost of every behavior. Both tables
+// are flat: [behavior * numNutrients + nutrient]. A non-nil formula overrides
+// the fixed cost and is evaluated per agent.
+type BehaviorCostConfig struct {
+	Fixed    []int32
+	Formulas []*formulas.Program
+	Dynamic  []bool // Per behavior: true if any nutrient cost has a formula.
+}
+
+// NeedsCostFormulas reports whether charging the agent's decided behavior
+// evaluates formulas, i.e. whether the caller must set the agent variables first.
+func NeedsCostFormulas(w *world.World, idx int, c *BehaviorCostConfig) bool {
+	decision := int(w.Agents.Decision[idx])
+	return decision < len(c.Dynamic) && c.Dynamic[decision]
+}
+
+// ChargeBehaviorCosts deducts the cost of the executed behavior from the
+// agent's reserves, evaluating its cost formulas where present. The evaluator
+// environment must already hold the agent's variables when NeedsCostFormulas
+// is true. A formula that fails falls back to the fixed cost.
+func ChargeBehaviorCosts(w *world.World, idx int, eval *formulas.Evaluator, c *BehaviorCostConfig) {
+	if !NeedsCostFormulas(w, idx, c) {
+		ChargeNutrients(w, idx, c.Fixed)
+		return
+	}
+	a := w.Agents
+	numNut := w.Config.NumNutrients
+	reserveBase := idx * numNut
+	costBase := int(a.Decision[idx]) * numNut
+	for n := 0; n < numNut; n++ {
+		cost := c.Fixed[costBase+n]
+		if p := c.Formulas[costBase+n]; p != nil {
+			if val, err := eval.RunProgramInt(p); err == nil {
+				cost = int32(val)
+			}
+		}
+		a.Reserves[reserveBase+n] -= cost
+		if a.Reserves[reserveBase+n] < 0 {
+			a.Reserves[reserveBase+n] = 0
+		}
+	}
+}
+
+// RefractoryConfig holds the refractory period formulas per prototype, with
+// males first and females from NumPrototypesM. A nil program gives no
+// refractory period.
+type RefractoryConfig struct {
+	NumPrototypesM int
+	Combat         []*formulas.Program
+	Courtship      []*formulas.Program
+}
+
+// RefractoryPending reports whether an interaction of agent idx has just
+// ended, i.e. whether StartRefractoryPeriods must evaluate its formulas.
+func RefractoryPending(w *world.World, idx int) bool {
+	a := w.Agents
+	return a.RefractoryCombat[idx] == world.RefractoryPending ||
+		a.RefractoryCourtship[idx] == world.RefractoryPending
+}
+
+// StartRefractoryPeriods replaces the pending refractory counters of agent
+// idx with the periods of its prototype. A formula that fails gives no period.
+// The evaluator environment must already hold the agent's variables.
+func StartRefractoryPeriods(w *world.World, idx int, eval *formulas.Evaluator, r *RefractoryConfig) {
+	a := w.Agents
+	proto := prototypeSlot(a, idx, r.NumPrototypesM)
+	startRefractory(&a.RefractoryCombat[idx], eval, r.Combat, proto)
+	startRefractory(&a.RefractoryCourtship[idx], eval, r.Courtship, proto)
+}
+
+// startRefractory sets a pending counter to the value of programs[proto].
+func startRefractory(counter *int32, eval *formulas.Evaluator, programs []*formulas.Program, proto int) {
+	if *counter != world.RefractoryPending {
+		return
+	}
+	*counter = 0
+	if val, ok := prototypeValue(eval, programs, proto); ok && val > 0 {
+		*counter = int32(val)
+	}
+}
+
+// prototypeSlot returns the prototype of agent idx counting males first and
+// females from numPrototypesM, or -1 if it has none.
+func prototypeSlot(a *world.AgentArrays, idx, numPrototypesM int) int {
+	proto := int(a.PrototypeID[idx])
+	if proto >= 0 && a.Sex[idx] == world.SexFemale {
+		proto += numPrototypesM
+	}
+	return proto
+}
+
 // UpdateAgent performs end-of-tick physiological updates for an agent:
-// - Increments age and time counters
-// - Checks for death by starvation (any reserve at 0)
+// - Increments age and time counters (stage, substrate)
+// - Counts down the refractory periods
+// - Ages the perception memory
+// - Checks for death by starvation (any reserve at or below its minimum level)
 // - Checks for death by old age (if adult and age > longevity)
+// The agent's own longevity, set at maturation, overrides the default longevity.
 func UpdateAgent(w *world.World, idx int, longevity int32) {
 	a := w.Agents
 	cfg := w.Config
 
 	a.Age[idx]++
 	a.TimeInStage[idx]++
+	a.TimeOnSubstrate[idx]++
+	if a.RefractoryCombat[idx] > 0 {
+		a.RefractoryCombat[idx]--
+	}
+	if a.RefractoryCourtship[idx] > 0 {
+		a.RefractoryCourtship[idx]--
+	}
+	agePerceptionMemory(w, idx)
+	if a.Longevity[idx] > 0 {
+		longevity = a.Longevity[idx]
+	}
 
-	// Check starvation: if any reserve is at 0, agent dies.
+	// Check starvation: if any reserve is at its minimum level, agent dies.
 	if isStarving(a, idx, cfg) {
 		markDead(a, idx)
 		return
@@ -65,6 +214,10 @@ func UpdateAgent(w *world.World, idx int, longevity int32) {
 func RegenerateResources(w *world.World) {
 	r := w.Resources
 	for i := 0; i < r.Count; i++ {
+		if isOvipositionSite(w, int(r.TypeID[i])) {
+			// A site's level is its egg count, not a regenerating stock.
+			continue
+		}
 		newLevel := int32(float64(r.Level[i]) * r.RegenRate[i])
 		if newLevel > r.MaxLevel[i] {
 			newLevel = r.MaxLevel[i]
@@ -98,6 +251,7 @@ func RemoveDeadAgents(w *world.World) int {
 		if a.Situation[i] == world.SituationDead {
 			// Notify interactant (if in combat/courtship, partner wins/is rejected).
 			notifyInteractantOfDeath(a, i)
+			dropCarriedEggs(w, i)
 			w.RemoveAgent(i)
 			removed++
 			// Don't increment i — the swapped-in agent needs to be checked too.
@@ -110,11 +264,11 @@ func RemoveDeadAgents(w *world.World) int {
 
 // --- Internal helpers ---
 
-// isStarving returns true if any reserve has reached 0.
+// isStarving returns true if any reserve has reached its minimum level.
 func isStarving(a *world.AgentArrays, idx int, cfg world.Config) bool {
 	base := idx * cfg.NumNutrients
 	for n := 0; n < cfg.NumNutrients; n++ {
-		if a.Reserves[base+n] <= 0 {
+		if a.Reserves[base+n] <= a.ReserveMin[base+n] {
 			return true
 		}
 	}
@@ -142,3 +296,38 @@ func notifyInteractantOfDeath(a *world.AgentArrays, idx int) {
 		}
 	}
 }
+
+// dropCarriedEggs removes the eggs carried by agent idx: eggs die with
+// their carrier (legacy THuevo without Acarreador).
+func dropCarriedEggs(w *world.World, idx int) {
+	eggs := w.Eggs
+	for e := eggs.Count - 1; e >= 0; e-- {
+		if eggs.CarrierAgentIdx[e] == int32(idx) {
+			removeEgg(w, e)
+		}
+	}
+}
+
+// evalLevels evaluates one program per nutrient into dst, keeping the current
+// value when a program is missing or fails.
+func evalLevels(eval *formulas.Evaluator, programs []*formulas.Program, dst []int32) {
+	for n, p := range programs {
+		if p == nil {
+			continue
+		}
+		if val, err := eval.RunProgramInt(p); err == nil {
+			dst[n] = int32(val)
+		}
+	}
+}
+
+// clampReserve caps a reserve value to [0, max].
+func clampReserve(v, max int32) int32 {
+	if v > max {
+		v = max
+	}
+	if v < 0 {
+		v = 0
+	}
+	return v
+}
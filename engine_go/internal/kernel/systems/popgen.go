@@ -0,0 +1,124 @@
+package systems
+
+import "galatea/engine/internal/kernel/world"
+
+// AlleleStats accumulates the alleles of one locus over a group of
+// individuals for population-genetics statistics. Alleles are identified by
+// value: heterozygotes carry two different values. A hemizygous individual
+// contributes its single copy to the allele statistics but not to
+// heterozygosity.
+type AlleleStats struct {
+	Individuals int // Individuals carrying the locus.
+	Alleles     int // Allele copies.
+
+	sum, sumSq    float64
+	dominant      int
+	diploids      int
+	heterozygotes int
+	counts        map[float64]int // Allele value → copies.
+}
+
+// Add adds the locus of one individual with the given number of copies (see
+// GeneticsConfig.Copies) and its paternal and maternal alleles; a single copy
+// is read from pat. Individuals without the locus are ignored.
+func (s *AlleleStats) Add(pat, mat float64, patDom, matDom uint8, copies int) {
+	if copies == 0 {
+		return
+	}
+	s.Individuals++
+	s.add(pat, patDom)
+	if copies == 1 {
+		return
+	}
+	s.add(mat, matDom)
+	s.diploids++
+	if pat != mat {
+		s.heterozygotes++
+	}
+}
+
+func (s *AlleleStats) add(value float64, dominance uint8) {
+	if s.counts == nil {
+		s.counts = make(map[float64]int)
+	}
+	s.Alleles++
+	s.sum += value
+	s.sumSq += value * value
+	s.counts[value]++
+	if dominance == 1 {
+		s.dominant++
+	}
+}
+
+// Mean returns the mean allele value.
+func (s *AlleleStats) Mean() float64 {
+	if s.Alleles == 0 {
+		return 0
+	}
+	return s.sum / float64(s.Alleles)
+}
+
+// Variance returns the (population) variance of the allele values.
+func (s *AlleleStats) Variance() float64 {
+	if s.Alleles == 0 {
+		return 0
+	}
+	mean := s.Mean()
+	return max(0, s.sumSq/float64(s.Alleles)-mean*mean)
+}
+
+// DominantFraction returns the fraction of allele copies flagged dominant.
+func (s *AlleleStats) DominantFraction() float64 {
+	if s.Alleles == 0 {
+		return 0
+	}
+	return float64(s.dominant) / float64(s.Alleles)
+}
+
+// ObservedHeterozygosity returns the fraction of diploid individuals whose
+// two alleles differ.
+func (s *AlleleStats) ObservedHeterozygosity() float64 {
+	if s.diploids == 0 {
+		return 0
+	}
+	return float64(s.heterozygotes) / float64(s.diploids)
+}
+
+// ExpectedHeterozygosity returns the heterozygosity expected under
+// Hardy-Weinberg proportions, 1 - Σ p², over the allele frequencies.
+func (s *AlleleStats) ExpectedHeterozygosity() float64 {
+	if s.Alleles == 0 {
+		return 0
+	}
+	h := 1.0
+	for _, p := range s.Frequencies() {
+		h -= p * p
+	}
+	return max(0, h)
+}
+
+// Frequencies returns the frequency of each allele value.
+func (s *AlleleStats) Frequencies() map[float64]float64 {
+	freq := make(map[float64]float64, len(s.counts))
+	for value, n := range s.counts {
+		freq[value] = float64(n) / float64(s.Alleles)
+	}
+	return freq
+}
+
+// AddLocus adds locus of agent idx to s, reading the continuous or discrete
+// genotype as the locus is.
+func (s *AlleleStats) AddLocus(w *world.World, idx, locus int, genCfg *GeneticsConfig) {
+	a := w.Agents
+	copies, allele := genCfg.Copies(locus, a.Sex[idx])
+	base := idx*w.Config.NumLoci*2 + locus*2
+	pat, mat := base, base+1
+	if copies == 1 && allele == 1 {
+		pat, mat = mat, pat
+	}
+	if genCfg.IsContinuous(locus) {
+		s.Add(a.GenotypeCont[pat], a.GenotypeCont[mat], a.DominanceCont[pat], a.DominanceCont[mat], copies)
+		return
+	}
+	s.Add(float64(a.GenotypeDisc[pat]), float64(a.GenotypeDisc[mat]), a.DominanceDisc[pat], a.DominanceDisc[mat], copies)
+}
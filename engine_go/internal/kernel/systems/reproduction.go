@@ -1,26 +1,186 @@
 package systems
 
 import (
-	"math/rand/v2"
+	"fmt"
+	"math"
 
+	"galatea/engine/internal/kernel/formulas"
 	"galatea/engine/internal/kernel/world"
 )
 
 // ReproductionConfig holds the parameters for reproduction mechanics.
 type ReproductionConfig struct {
-	MaxGametes          int32   // Maximum gametes an agent can produce.
-	GameteCosts         []int32 // Cost per gamete per nutrient: [nutrient] = cost.
-	PacksTransferred    int32   // Sperm packs transferred per copulation.
-	MaxStoredPacks      int32   // Max sperm packs a female can store.
-	FractionFertilized  float64 // Fraction of eggs fertilized after copulation.
-	PackFraction        float64 // Fraction of gamete reserves in each sperm pack.
-	EggFraction         float64 // Fraction of gamete reserves allocated to egg.
-	EggsPerCycle        int32   // Eggs oviposited per cycle.
-	Paternity           int32   // Initial paternity weight for sperm packs.
-	ConsumptionRate     float64 // Rate at which females consume stored sperm packs.
-	SpermDegradation    float64 // Rate of paternity degradation per tick.
-	MaleRatio           int     // Proportion for sex determination.
-	FemaleRatio         int     // Proportion for sex determination.
+	MaxGametes         int32    // Maximum gametes an agent can produce.
+	GameteCosts        []int32  // Cost per gamete per nutrient: [nutrient] = cost.
+	PacksTransferred   int32    // Sperm packs transferred per copulation.
+	MaxStoredPacks     int32    // Max sperm packs a female can store.
+	FractionFertilized float64  // Fraction of eggs fertilized after copulation.
+	PackFraction       float64  // Fraction of gamete reserves in each sperm pack.
+	EggFraction        float64  // Fraction of gamete reserves allocated to egg.
+	EggsPerCycle       int32    // Eggs oviposited per cycle.
+	Paternity          int32    // Initial paternity weight for sperm packs.
+	ConsumptionRate    float64  // Rate at which females consume stored sperm packs.
+	SpermDegradation   float64  // Rate of paternity degradation per tick.
+	MaleRatio          int      // Default proportion for sex determination.
+	FemaleRatio        int      // Default proportion for sex determination.
+	SpermUse           SpermUse // How stored sperm packs sire the eggs.
+
+	// Sex-ratio formulas of the mother's prototype, with males first and
+	// females from NumPrototypesM. A nil formula keeps the default ratio.
+	NumPrototypesM  int
+	SexRatioMales   []*formulas.Program
+	SexRatioFemales []*formulas.Program
+
+	// Project formulas overriding the parameters above per agent (see For).
+	Formulas ReproductionFormulas
+
+	costs []int32 // GameteCosts returned by For.
+}
+
+// ReproductionFormulas are the reproduction formulas of a project. Each one
+// present overrides its ReproductionConfig field for the agent whose
+// variables the evaluator holds; missing ones keep the field.
+type ReproductionFormulas struct {
+	MaxEggs            *formulas.Program // MaxGametes of females.
+	MaxSpermPacks      *formulas.Program // MaxGametes of males.
+	PacksTransferred   *formulas.Program
+	FractionFertilized *formulas.Program
+	Paternity          *formulas.Program
+	MaxStoredPacks     *formulas.Program
+	ConsumptionRate    *formulas.Program
+	EggsPerCycle       *formulas.Program
+	EggFraction        *formulas.Program
+	PackFraction       *formulas.Program
+	SpermDegradation   *formulas.Program
+	GameteCostsM       []*formulas.Program // [nutrient]
+	GameteCostsF       []*formulas.Program // [nutrient]
+}
+
+// Dynamic reports whether any formula is more than a numeric literal, i.e.
+// whether the caller must set the agent variables before calling For.
+func (f *ReproductionFormulas) Dynamic() bool {
+	programs := [...]*formulas.Program{f.MaxEggs, f.MaxSpermPacks, f.PacksTransferred,
+		f.FractionFertilized, f.Paternity, f.MaxStoredPacks, f.ConsumptionRate,
+		f.EggsPerCycle, f.EggFraction, f.PackFraction, f.SpermDegradation}
+	for _, p := range programs {
+		if isDynamic(p) {
+			return true
+		}
+	}
+	for _, costs := range [...][]*formulas.Program{f.GameteCostsM, f.GameteCostsF} {
+		for _, p := range costs {
+			if isDynamic(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isDynamic(p *formulas.Program) bool {
+	if p == nil {
+		return false
+	}
+	_, constant := p.Constant()
+	return !constant
+}
+
+// For returns the reproduction parameters of an agent of the given sex whose
+// variables the evaluator holds (eval may be nil when no formula is
+// Dynamic). A formula that fails keeps its field. The returned GameteCosts
+// is only valid until the next call.
+func (cfg *ReproductionConfig) For(eval *formulas.Evaluator, sex uint8) ReproductionConfig {
+	f := &cfg.Formulas
+	out := *cfg
+	maxGametes, costs := f.MaxSpermPacks, f.GameteCostsM
+	if sex == world.SexFemale {
+		maxGametes, costs = f.MaxEggs, f.GameteCostsF
+	}
+	out.MaxGametes = int32(formulaOr(eval, maxGametes, float64(cfg.MaxGametes)))
+	out.PacksTransferred = int32(formulaOr(eval, f.PacksTransferred, float64(cfg.PacksTransferred)))
+	out.FractionFertilized = formulaOr(eval, f.FractionFertilized, cfg.FractionFertilized)
+	out.Paternity = int32(formulaOr(eval, f.Paternity, float64(cfg.Paternity)))
+	out.MaxStoredPacks = int32(formulaOr(eval, f.MaxStoredPacks, float64(cfg.MaxStoredPacks)))
+	out.ConsumptionRate = formulaOr(eval, f.ConsumptionRate, cfg.ConsumptionRate)
+	out.EggsPerCycle = int32(formulaOr(eval, f.EggsPerCycle, float64(cfg.EggsPerCycle)))
+	out.EggFraction = formulaOr(eval, f.EggFraction, cfg.EggFraction)
+	out.PackFraction = formulaOr(eval, f.PackFraction, cfg.PackFraction)
+	out.SpermDegradation = formulaOr(eval, f.SpermDegradation, cfg.SpermDegradation)
+	if len(costs) > 0 {
+		cfg.costs = append(cfg.costs[:0], cfg.GameteCosts...)
+		for n, p := range costs {
+			if n < len(cfg.costs) {
+				cfg.costs[n] = int32(formulaOr(eval, p, float64(cfg.costs[n])))
+			}
+		}
+		out.GameteCosts = cfg.costs
+	}
+	return out
+}
+
+// formulaOr evaluates an optional program, returning def when it is missing
+// or fails. Numeric literals need no evaluator.
+func formulaOr(eval *formulas.Evaluator, p *formulas.Program, def float64) float64 {
+	if p == nil {
+		return def
+	}
+	if v, ok := p.Constant(); ok {
+		return v
+	}
+	if eval == nil {
+		return def
+	}
+	v, err := eval.RunProgramFloat(p)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// copulationConfig returns the parameters of a copulation: the packs
+// transferred and their paternity follow the male's formulas, the storage
+// capacity and the fraction fertilized the female's. envBuilder may be nil
+// when no formula is Dynamic.
+func copulationConfig(w *world.World, eval *formulas.Evaluator, envBuilder *formulas.EnvBuilder, cfg *ReproductionConfig, maleIdx, femaleIdx int) ReproductionConfig {
+	dynamic := envBuilder != nil && cfg.Formulas.Dynamic()
+	if dynamic {
+		envBuilder.SetAgentVars(w, maleIdx)
+	}
+	out := cfg.For(eval, world.SexMale)
+	if dynamic {
+		envBuilder.SetAgentVars(w, femaleIdx)
+	}
+	female := cfg.For(eval, world.SexFemale)
+	out.MaxStoredPacks = female.MaxStoredPacks
+	out.FractionFertilized = female.FractionFertilized
+	return out
+}
+
+// SpermUse is the sperm-competition model deciding which stored pack sires
+// each egg.
+type SpermUse uint8
+
+const (
+	SpermUseRaffle       SpermUse = iota // Packs are drawn in proportion to paternity.
+	SpermUseFirstMale                    // The oldest stored pack sires the eggs.
+	SpermUseLastMale                     // The newest stored pack sires the eggs.
+	SpermUseDisplacement                 // A copulation discards the stored packs; then raffle.
+)
+
+// ParseSpermUse parses a sperm-use mode as stored in the reproduction table:
+// raffle (or empty), first_male, last_male and displacement.
+func ParseSpermUse(mode string) (SpermUse, error) {
+	switch mode {
+	case "", "raffle":
+		return SpermUseRaffle, nil
+	case "first_male":
+		return SpermUseFirstMale, nil
+	case "last_male":
+		return SpermUseLastMale, nil
+	case "displacement":
+		return SpermUseDisplacement, nil
+	}
+	return 0, fmt.Errorf("unknown sperm use mode %q", mode)
 }
 
 // Gametogenesis produces gametes when the agent has optimal reserves.
@@ -70,6 +230,9 @@ func Gametogenesis(w *world.World, idx int, cfg ReproductionConfig) {
 }
 
 // Copulate transfers sperm packs from male to female and triggers fertilization.
+// Each pack carries a haploid gamete of the male, his ID and the initial
+// paternity weight. Under SpermUseDisplacement the packs the female already
+// stores are discarded first.
 // maleIdx and femaleIdx must be valid agents in courtship that have both accepted.
 func Copulate(w *world.World, maleIdx, femaleIdx int, cfg ReproductionConfig, genCfg GeneticsConfig) {
 	a := w.Agents
@@ -81,8 +244,12 @@ func Copulate(w *world.World, maleIdx, femaleIdx int, cfg ReproductionConfig, ge
 		transfer = available
 	}
 
+	if cfg.SpermUse == SpermUseDisplacement && transfer > 0 {
+		a.SpermPacksCount[femaleIdx] = 0
+	}
+
 	// Cap by female storage capacity.
-	freeSlots := cfg.MaxStoredPacks - a.SpermPacksCount[femaleIdx]
+	freeSlots := min(cfg.MaxStoredPacks, int32(w.Config.MaxStoredPacks)) - a.SpermPacksCount[femaleIdx]
 	if transfer > freeSlots {
 		transfer = freeSlots
 	}
@@ -93,7 +260,9 @@ func Copulate(w *world.World, maleIdx, femaleIdx int, cfg ReproductionConfig, ge
 
 	// Transfer packs: deduct from male gametes, add to female sperm packs.
 	a.GametesCount[maleIdx] -= transfer
-	a.SpermPacksCount[femaleIdx] += transfer
+	for p := int32(0); p < transfer; p++ {
+		storeSpermPack(w, femaleIdx, maleIdx, cfg.Paternity, genCfg.Linkage)
+	}
 
 	// Fertilize a fraction of the female's unfertilized gametes.
 	fertilizeCount := int32(float64(a.GametesCount[femaleIdx]) * cfg.FractionFertilized)
@@ -111,36 +280,38 @@ func Copulate(w *world.World, maleIdx, femaleIdx int, cfg ReproductionConfig, ge
 	a.InteractantIdx[femaleIdx] = -1
 	a.TimeInInteraction[maleIdx] = 0
 	a.TimeInInteraction[femaleIdx] = 0
+	a.RefractoryCourtship[maleIdx] = world.RefractoryPending
+	a.RefractoryCourtship[femaleIdx] = world.RefractoryPending
 }
 
 // Oviposit deposits fertilized eggs into the world's EggArrays.
-// Creates new egg entries with genotype from crossover of parents.
-func Oviposit(w *world.World, femaleIdx int, cfg ReproductionConfig, genCfg GeneticsConfig) int {
+// Each egg is sired by a stored sperm pack chosen by cfg.SpermUse (see
+// sirePack): it takes the pack's gamete as its paternal alleles and a gamete
+// of the mother as its maternal ones. Its sex follows the sex-determination
+// system of genCfg (see eggSex); haploid males are not sired and carry the
+// mother's gamete alone. The eggs go to the
+// oviposition site siteIdx, up to its MaxLevel, or stay carried by the mother
+// when siteIdx is -1 (legacy TAgente.Ovipositar). Without stored packs no
+// egg can be sired and none is laid, except under haplodiploidy, where the
+// female lays her unfertilized gametes as males (see virginLaying).
+// The evaluator environment must already hold the mother's variables.
+func Oviposit(w *world.World, femaleIdx, siteIdx int, eval *formulas.Evaluator, cfg ReproductionConfig, genCfg GeneticsConfig) int {
 	a := w.Agents
+	r := w.Resources
 	wcfg := w.Config
 	numLoci := wcfg.NumLoci
 	numNut := wcfg.NumNutrients
 
-	eggsToLay := cfg.EggsPerCycle
-	if eggsToLay > a.FertilizedCount[femaleIdx] {
-		eggsToLay = a.FertilizedCount[femaleIdx]
+	virgin := virginLaying(a, femaleIdx, &genCfg)
+	eggsToLay := min(cfg.EggsPerCycle, eggsReady(a, femaleIdx, &genCfg))
+	if siteIdx >= 0 && eggsToLay > r.MaxLevel[siteIdx]-r.Level[siteIdx] {
+		eggsToLay = r.MaxLevel[siteIdx] - r.Level[siteIdx]
 	}
-	if eggsToLay <= 0 {
+	if eggsToLay <= 0 || a.SpermPacksCount[femaleIdx] <= 0 && !virgin {
 		return 0
 	}
 
-	// Get mother's genotype for crossover.
-	motherContGeno := CopyGenotypeCont(a.GenotypeCont, femaleIdx, numLoci)
-	motherDiscGeno := CopyGenotypeDisc(a.GenotypeDisc, femaleIdx, numLoci)
-	motherContDom := CopyDominance(a.DominanceCont, femaleIdx, numLoci)
-	motherDiscDom := CopyDominance(a.DominanceDisc, femaleIdx, numLoci)
-
-	// For simplicity, use mother's own genotype as "father" placeholder.
-	// In a full implementation, sperm packs would carry the father's genotype.
-	fatherContGeno := motherContGeno
-	fatherDiscGeno := motherDiscGeno
-	fatherContDom := motherContDom
-	fatherDiscDom := motherDiscDom
+	maleRatio, femaleRatio := SexRatio(w, femaleIdx, eval, cfg)
 
 	laid := 0
 	for i := int32(0); i < eggsToLay; i++ {
@@ -151,34 +322,47 @@ func Oviposit(w *world.World, femaleIdx int, cfg ReproductionConfig, genCfg Gene
 
 		eggs := w.Eggs
 
-		// Position at mother's location.
+		// Position at the site or the mother's location.
 		eggs.PosX[eggIdx] = a.PosX[femaleIdx]
 		eggs.PosY[eggIdx] = a.PosY[femaleIdx]
+		if siteIdx >= 0 {
+			eggs.PosX[eggIdx] = r.PosX[siteIdx]
+			eggs.PosY[eggIdx] = r.PosY[siteIdx]
+		}
 		eggs.Age[eggIdx] = 0
 
 		// Determine sex.
-		eggs.Sex[eggIdx] = DetermineSex(cfg.MaleRatio, cfg.FemaleRatio)
+		sex, fertilized := world.SexMale, false
+		if !virgin {
+			sex, fertilized = eggSex(w.Rand.Genetics, genCfg.SexDetermination, maleRatio, femaleRatio)
+		}
+		eggs.Sex[eggIdx] = sex
+
+		// Draw the sire and join its gamete with one of the mother's.
+		slot := -1
+		eggs.ParentMaleID[eggIdx] = 0
+		if fertilized {
+			slot = sirePack(w, femaleIdx, cfg.SpermUse)
+			eggs.ParentMaleID[eggIdx] = a.SpermDonor[slot]
+		}
+		fertilize(w, femaleIdx, slot, eggIdx, genCfg.Linkage)
+		eggs.ParentFemaleID[eggIdx] = a.ID[femaleIdx]
 
-		// Crossover to produce egg genotype.
 		eggGenoSize := numLoci * 2
-		eggContBase := eggIdx * eggGenoSize
-		eggDiscBase := eggIdx * eggGenoSize
-
-		childCont := eggs.GenotypeCont[eggContBase : eggContBase+eggGenoSize]
-		childContDom := eggs.DominanceCont[eggContBase : eggContBase+eggGenoSize]
-		CrossoverCont(motherContGeno, fatherContGeno, motherContDom, fatherContDom, childCont, childContDom, numLoci)
-
-		childDisc := eggs.GenotypeDisc[eggDiscBase : eggDiscBase+eggGenoSize]
-		childDiscDom := eggs.DominanceDisc[eggDiscBase : eggDiscBase+eggGenoSize]
-		CrossoverDisc(motherDiscGeno, fatherDiscGeno, motherDiscDom, fatherDiscDom, childDisc, childDiscDom, numLoci)
+		eggGenoBase := eggIdx * eggGenoSize
+		childCont := eggs.GenotypeCont[eggGenoBase : eggGenoBase+eggGenoSize]
+		childContDom := eggs.DominanceCont[eggGenoBase : eggGenoBase+eggGenoSize]
+		childDisc := eggs.GenotypeDisc[eggGenoBase : eggGenoBase+eggGenoSize]
+		childDiscDom := eggs.DominanceDisc[eggGenoBase : eggGenoBase+eggGenoSize]
 
 		// Apply mutations.
 		if len(genCfg.LociCont) >= numLoci {
-			MutateCont(childCont, childContDom, numLoci, genCfg.LociCont)
+			MutateCont(w.Rand.Genetics, childCont, childContDom, numLoci, genCfg.LociCont)
 		}
 		if len(genCfg.LociDisc) >= numLoci {
-			MutateDisc(childDisc, childDiscDom, numLoci, genCfg.LociDisc)
+			MutateDisc(w.Rand.Genetics, childDisc, childDiscDom, numLoci, genCfg.LociDisc)
 		}
+		fixSexLinkedLoci(&genCfg, sex, eggGenoBase, eggs.GenotypeCont, eggs.GenotypeDisc, eggs.DominanceCont, eggs.DominanceDisc)
 
 		// Allocate fraction of mother's reserves to egg.
 		eggResBase := eggIdx * numNut
@@ -188,21 +372,67 @@ func Oviposit(w *world.World, femaleIdx int, cfg ReproductionConfig, genCfg Gene
 			eggs.Reserves[eggResBase+n] = eggReserve
 		}
 
-		// Set carrier to mother agent index.
+		// Set carrier to the site or the mother.
 		eggs.CarrierAgentIdx[eggIdx] = int32(femaleIdx)
 		eggs.CarrierResourceIdx[eggIdx] = -1
+		if siteIdx >= 0 {
+			eggs.CarrierAgentIdx[eggIdx] = -1
+			eggs.CarrierResourceIdx[eggIdx] = int32(siteIdx)
+		}
 
 		laid++
 	}
 
-	a.FertilizedCount[femaleIdx] -= int32(laid)
-	a.CarriedEggs[femaleIdx] += int32(laid)
+	if virgin {
+		a.GametesCount[femaleIdx] -= int32(laid)
+	} else {
+		a.FertilizedCount[femaleIdx] -= int32(laid)
+	}
+	if siteIdx >= 0 {
+		r.Level[siteIdx] += int32(laid)
+	} else {
+		a.CarriedEggs[femaleIdx] += int32(laid)
+	}
 
 	return laid
 }
 
+// virginLaying reports whether female idx lays her unfertilized gametes:
+// under haplodiploidy a female without stored packs can still lay haploid
+// males. genCfg may be nil.
+func virginLaying(a *world.AgentArrays, idx int, genCfg *GeneticsConfig) bool {
+	return genCfg != nil && genCfg.SexDetermination == SexDeterminationHaplodiploid && a.SpermPacksCount[idx] <= 0
+}
+
+// eggsReady returns how many eggs female idx can lay: her fertilized
+// gametes, or her unfertilized ones when she lays as a virgin.
+func eggsReady(a *world.AgentArrays, idx int, genCfg *GeneticsConfig) int32 {
+	if virginLaying(a, idx, genCfg) {
+		return a.GametesCount[idx]
+	}
+	return a.FertilizedCount[idx]
+}
+
+// SexRatio returns the male and female proportions of the offspring of
+// agent idx from its prototype's formulas, falling back per formula to the
+// default ratio. The evaluator environment must already hold the agent's variables.
+func SexRatio(w *world.World, idx int, eval *formulas.Evaluator, cfg ReproductionConfig) (int, int) {
+	males, females := cfg.MaleRatio, cfg.FemaleRatio
+	proto := prototypeSlot(w.Agents, idx, cfg.NumPrototypesM)
+	if val, ok := prototypeValue(eval, cfg.SexRatioMales, proto); ok {
+		males = int(val)
+	}
+	if val, ok := prototypeValue(eval, cfg.SexRatioFemales, proto); ok {
+		females = int(val)
+	}
+	return males, females
+}
+
 // SpermConsumption degrades stored sperm packs in a female agent.
-// Reduces pack count based on consumption rate (simplified model).
+// Each pack has a ConsumptionRate chance of being consumed this tick (simplified
+// model); the others lose a SpermDegradation fraction of their paternity, at
+// least one point so that low paternities keep decaying (legacy
+// ConsumoPaquetesEspermaticos). Packs without paternity are discarded.
 func SpermConsumption(w *world.World, femaleIdx int, cfg ReproductionConfig) {
 	a := w.Agents
 	if a.Sex[femaleIdx] != world.SexFemale {
@@ -212,27 +442,119 @@ func SpermConsumption(w *world.World, femaleIdx int, cfg ReproductionConfig) {
 		return
 	}
 
-	// Probabilistic consumption: each pack has a chance of being consumed this tick.
-	consumed := int32(0)
-	for p := int32(0); p < a.SpermPacksCount[femaleIdx]; p++ {
-		if rand.Float64() < cfg.ConsumptionRate {
-			consumed++
+	packBase := femaleIdx * w.Config.MaxStoredPacks
+	for k := int(a.SpermPacksCount[femaleIdx]) - 1; k >= 0; k-- {
+		slot := packBase + k
+		if w.Rand.Reproduction.Float64() < cfg.ConsumptionRate {
+			removeSpermPack(w, femaleIdx, k)
+			continue
+		}
+		if cfg.SpermDegradation > 0 {
+			p := a.SpermPaternity[slot]
+			a.SpermPaternity[slot] = max(0, p-max(1, int32(math.Round(float64(p)*cfg.SpermDegradation))))
+		}
+		if a.SpermPaternity[slot] == 0 {
+			removeSpermPack(w, femaleIdx, k)
 		}
 	}
-	a.SpermPacksCount[femaleIdx] -= consumed
-	if a.SpermPacksCount[femaleIdx] < 0 {
-		a.SpermPacksCount[femaleIdx] = 0
+}
+
+// sirePack returns the slot of the stored pack of femaleIdx that sires her
+// next egg. Packs are kept in storage order, so the first-male and last-male
+// modes take the first and last slots; otherwise the pack is drawn in
+// proportion to paternity (legacy FertilizaCantidad).
+// femaleIdx must store at least one pack.
+func sirePack(w *world.World, femaleIdx int, mode SpermUse) int {
+	a := w.Agents
+	packBase := femaleIdx * w.Config.MaxStoredPacks
+	count := int(a.SpermPacksCount[femaleIdx])
+	switch mode {
+	case SpermUseFirstMale:
+		return packBase
+	case SpermUseLastMale:
+		return packBase + count - 1
 	}
+
+	// Roulette clamps the weights in place, so draw from a copy.
+	var buf [16]int32
+	paternity := buf[:0]
+	paternity = append(paternity, a.SpermPaternity[packBase:packBase+count]...)
+	return packBase + Roulette(w.Rand.Reproduction, paternity)
 }
 
-// IsOptimalForReproduction returns true if all reserves are at or above optimal level.
-func IsOptimalForReproduction(a *world.AgentArrays, idx int, numNutrients int, optimalLevels []int32) bool {
-	if len(optimalLevels) < numNutrients {
-		return false
+// storeSpermPack stores a new sperm pack from maleIdx in the next free slot
+// of femaleIdx: a haploid gamete drawn from the male's genotype along the
+// linkage map, one allele per locus, along with his ID and the given
+// paternity weight.
+func storeSpermPack(w *world.World, femaleIdx, maleIdx int, paternity int32, linkage *LinkageMap) {
+	a := w.Agents
+	numLoci := w.Config.NumLoci
+	slot := femaleIdx*w.Config.MaxStoredPacks + int(a.SpermPacksCount[femaleIdx])
+	a.SpermDonor[slot] = a.ID[maleIdx]
+	a.SpermPaternity[slot] = paternity
+
+	genoBase := maleIdx * numLoci * 2
+	gameteBase := slot * numLoci
+	linkage.Meiosis(w.Rand.Genetics, numLoci, func(locus, allele int) {
+		src := genoBase + locus*2 + allele
+		a.SpermGenotypeCont[gameteBase+locus] = a.GenotypeCont[src]
+		a.SpermDominanceCont[gameteBase+locus] = a.DominanceCont[src]
+		a.SpermGenotypeDisc[gameteBase+locus] = a.GenotypeDisc[src]
+		a.SpermDominanceDisc[gameteBase+locus] = a.DominanceDisc[src]
+	})
+	a.SpermPacksCount[femaleIdx]++
+}
+
+// removeSpermPack discards pack k of femaleIdx, shifting her later packs
+// down so that storage order is kept.
+func removeSpermPack(w *world.World, femaleIdx, k int) {
+	a := w.Agents
+	numLoci := w.Config.NumLoci
+	packBase := femaleIdx * w.Config.MaxStoredPacks
+	slot := packBase + k
+	end := packBase + int(a.SpermPacksCount[femaleIdx])
+	copy(a.SpermDonor[slot:end], a.SpermDonor[slot+1:end])
+	copy(a.SpermPaternity[slot:end], a.SpermPaternity[slot+1:end])
+	copy(a.SpermGenotypeCont[slot*numLoci:end*numLoci], a.SpermGenotypeCont[(slot+1)*numLoci:end*numLoci])
+	copy(a.SpermGenotypeDisc[slot*numLoci:end*numLoci], a.SpermGenotypeDisc[(slot+1)*numLoci:end*numLoci])
+	copy(a.SpermDominanceCont[slot*numLoci:end*numLoci], a.SpermDominanceCont[(slot+1)*numLoci:end*numLoci])
+	copy(a.SpermDominanceDisc[slot*numLoci:end*numLoci], a.SpermDominanceDisc[(slot+1)*numLoci:end*numLoci])
+	a.SpermPacksCount[femaleIdx]--
+}
+
+// fertilize writes the genotype of egg eggIdx: the paternal allele of each
+// locus comes from the gamete in sperm pack slot, the maternal one from a
+// gamete of the mother drawn along the linkage map. An unfertilized egg
+// (slot -1) takes the mother's gamete alone, in its maternal alleles.
+func fertilize(w *world.World, femaleIdx, slot, eggIdx int, linkage *LinkageMap) {
+	a := w.Agents
+	eggs := w.Eggs
+	numLoci := w.Config.NumLoci
+	motherBase := femaleIdx * numLoci * 2
+	gameteBase := slot * numLoci
+	eggBase := eggIdx * numLoci * 2
+	for locus := 0; locus < numLoci && slot >= 0; locus++ {
+		pat := eggBase + locus*2
+		eggs.GenotypeCont[pat] = a.SpermGenotypeCont[gameteBase+locus]
+		eggs.DominanceCont[pat] = a.SpermDominanceCont[gameteBase+locus]
+		eggs.GenotypeDisc[pat] = a.SpermGenotypeDisc[gameteBase+locus]
+		eggs.DominanceDisc[pat] = a.SpermDominanceDisc[gameteBase+locus]
 	}
+	linkage.Meiosis(w.Rand.Genetics, numLoci, func(locus, allele int) {
+		mat := eggBase + locus*2 + 1
+		src := motherBase + locus*2 + allele
+		eggs.GenotypeCont[mat] = a.GenotypeCont[src]
+		eggs.DominanceCont[mat] = a.DominanceCont[src]
+		eggs.GenotypeDisc[mat] = a.GenotypeDisc[src]
+		eggs.DominanceDisc[mat] = a.DominanceDisc[src]
+	})
+}
+
+// IsOptimalForReproduction returns true if all reserves are at or above their optimal level.
+func IsOptimalForReproduction(a *world.AgentArrays, idx int, numNutrients int) bool {
 	base := idx * numNutrients
 	for n := 0; n < numNutrients; n++ {
-		if a.Reserves[base+n] < optimalLevels[n] {
+		if a.Reserves[base+n] < a.ReserveOptimal[base+n] {
 			return false
 		}
 	}
@@ -282,8 +604,8 @@ func growEggs(w *world.World) {
 	e.CarrierAgentIdx = growI32Slice(e.CarrierAgentIdx, newCap)
 	e.CarrierResourceIdx = growI32Slice(e.CarrierResourceIdx, newCap)
 	e.VDecision = growI32Slice(e.VDecision, newCap*2)
-	e.ParentMale = growStringSlice(e.ParentMale, newCap)
-	e.ParentFemale = growStringSlice(e.ParentFemale, newCap)
+	e.ParentMaleID = growU64Slice(e.ParentMaleID, newCap)
+	e.ParentFemaleID = growU64Slice(e.ParentFemaleID, newCap)
 
 	// Initialize new carrier slots.
 	for i := e.Cap; i < newCap; i++ {
@@ -312,8 +634,8 @@ func growU8Slice(old []uint8, newLen int) []uint8 {
 	return s
 }
 
-func growStringSlice(old []string, newLen int) []string {
-	s := make([]string, newLen)
+func growU64Slice(old []uint64, newLen int) []uint64 {
+	s := make([]uint64, newLen)
 	copy(s, old)
 	return s
 }
@@ -1,5 +1,7 @@
 package world
 
+import "math"
+
 // Agent states.
 const (
 	StateUndecided uint8 = iota
@@ -23,34 +25,64 @@ const (
 	SexFemale
 )
 
+// InteractionActions is the number of actions available in combat (3) or
+// courtship (4), i.e. the stride of AgentArrays.InteractionWeights.
+const InteractionActions = 4
+
+// RefractoryPending marks a refractory counter whose interaction has just
+// ended; the engine replaces it with the period from the prototype formula.
+const RefractoryPending int32 = -1
+
+// Default reserve reference levels, used until the metabolism formulas have
+// been evaluated for an agent (e.g. in worlds built without an engine).
+const (
+	DefaultReserveMin      int32 = 0
+	DefaultReserveCritical int32 = 5
+	DefaultReserveOptimal  int32 = 50
+	DefaultReserveMax      int32 = math.MaxInt32
+)
+
 // AgentArrays holds all mutable agent state in parallel slices (SoA layout).
-// An agent is identified solely by its index i into these slices.
+// An agent is addressed by its index i into these slices, which changes when
+// agents are removed; ID identifies it for the whole run.
 // Count tracks the number of active agents; indices >= Count are inactive.
 type AgentArrays struct {
-	Count int // Number of active agents.
-	Cap   int // Allocated capacity of all slices.
+	Count  int    // Number of active agents.
+	Cap    int    // Allocated capacity of all slices.
+	NextID uint64 // ID given to the next agent added (IDs start at 1).
 
 	// Spatial
-	PosX      []float64 // X position in world coordinates.
-	PosY      []float64 // Y position in world coordinates.
-	Direction []uint8   // Facing direction (1-8, mapping to NW,N,NE,W,E,SW,S,SE).
-	Speed     []int32   // Movement speed (cells per tick).
+	PosX       []float64 // X position in world coordinates.
+	PosY       []float64 // Y position in world coordinates.
+	Direction  []uint8   // Facing direction (1-8, mapping to NW,N,NE,W,E,SW,S,SE).
+	Speed      []int32   // Movement speed (cells per tick).
+	SpeedCarry []float64 // Fractional movement carried over from substrate velocities.
+	Substrate  []int32   // Substrate index under the agent (-1 = none).
 
 	// Identity
-	Sex         []uint8 // SexUndefined, SexMale, SexFemale.
-	StageID     []int32 // Current stage index (0-based), -1 if adult.
-	PrototypeID []int32 // Prototype index (0-based), -1 if immature.
-	Age         []int32 // Age in ticks.
+	ID          []uint64 // Unique agent ID, never reused within a run.
+	Sex         []uint8  // SexUndefined, SexMale, SexFemale.
+	StageID     []int32  // Current stage index (0-based), -1 if adult.
+	PrototypeID []int32  // Prototype index (0-based), -1 if immature.
+	Age         []int32  // Age in ticks.
+	Longevity   []int32  // Adult lifespan in ticks set at maturation (0 = engine default).
 
 	// Behavioral state
-	State         []uint8 // StateUndecided, StateDecided, StateActing.
-	Situation     []uint8 // SituationImmature, Regular, Combat, Courtship, Dead.
-	Decision      []uint8 // Decided behavior index.
+	State          []uint8 // StateUndecided, StateDecided, StateActing.
+	Situation      []uint8 // SituationImmature, Regular, Combat, Courtship, Dead.
+	Decision       []uint8 // Decided behavior index.
 	InteractantIdx []int32 // Index of the agent/resource being interacted with (-1 = none).
 
 	// Physiology: Reserves[i*NumNutrients + n] = reserve of nutrient n for agent i.
 	Reserves []int32
 
+	// Reference levels from the metabolism formulas, same layout as Reserves.
+	// Re-evaluated every tick since formulas may depend on the agent's state.
+	ReserveMin      []int32 // At or below: death by starvation.
+	ReserveCritical []int32 // At or below: critical state (feeding takes priority).
+	ReserveOptimal  []int32 // All at or above: ready for gametogenesis.
+	ReserveMax      []int32 // Upper bound applied after feeding.
+
 	// Genetics: flat arrays indexed [i*NumLoci*2 + locus*2 + allele].
 	// Each locus has 2 alleles (paternal=0, maternal=1).
 	// For continuous loci: values stored as float64 bits cast to int64 in GenotypeCont.
@@ -64,36 +96,55 @@ type AgentArrays struct {
 
 	// Memory: tracks perception and interaction history.
 	// Flat: [i * memorySlots + slot]
-	// Slots are organized as pairs (last_tick, count) for each trackable element.
-	MemoryLastPerceived []int32 // Last tick each element was perceived.
-	MemoryNumPerceived  []int32 // Number of times perceived.
-	MemoryLastInteracted []int32 // Last tick each element was interacted with.
+	// Slots are organized as pairs (ticks since, count) for each trackable element.
+	MemoryLastPerceived  []int32 // Ticks since each element was last perceived (-1 = never).
+	MemoryNumPerceived   []int32 // Number of times perceived.
+	MemoryLastInteracted []int32 // Ticks since each element was last interacted with (-1 = never).
 	MemoryNumInteracted  []int32 // Number of times interacted.
-	MemoryLastBehavior  []int32 // Last tick each behavior was performed.
-	MemoryNumBehavior   []int32 // Number of times each behavior performed.
-	LastOpponentAction  []uint8 // Last action by opponent in combat/courtship.
+	MemoryLastBehavior   []int32 // Ticks since each behavior was last performed (-1 = never).
+	MemoryNumBehavior    []int32 // Number of times each behavior performed.
+	LastOpponentAction   []uint8 // Last action by opponent in combat/courtship.
 
 	// Decision vectors: computed per tick by the perception system.
 	// Tendencies[i*8 + dir] = movement tendency in direction dir.
 	Tendencies []int32
 	// VDecision[i*NumBehaviors + b] = probability weight for behavior b.
 	VDecision []int32
+	// InteractionWeights[i*InteractionActions + k] = weight of combat action k
+	// (display, escalate, retreat) or courtship action k (display, escalate,
+	// accept, reject) while in combat or courtship.
+	InteractionWeights []int32
 
 	// Reproduction
-	GametesCount       []int32 // Number of gametes in gonad.
-	FertilizedCount    []int32 // Number of fertilized eggs carried.
-	SpermPacksCount    []int32 // Number of sperm packs stored (females).
-	CarriedEggs        []int32 // Number of eggs being carried.
+	GametesCount    []int32 // Number of gametes in gonad.
+	FertilizedCount []int32 // Number of fertilized eggs carried.
+	SpermPacksCount []int32 // Number of sperm packs stored (females).
+	CarriedEggs     []int32 // Number of eggs being carried.
+
+	// Sperm packs stored by a female: slots [i*MaxStoredPacks + k] for
+	// k < SpermPacksCount[i]. Each pack keeps its donor's ID, its paternity
+	// weight and one haploid gamete of the donor, [slot*NumLoci + locus].
+	SpermDonor         []uint64
+	SpermPaternity     []int32
+	SpermGenotypeCont  []float64
+	SpermGenotypeDisc  []int32
+	SpermDominanceCont []uint8
+	SpermDominanceDisc []uint8
 
 	// Time counters
 	TimeInStage       []int32 // Ticks spent in current stage.
 	TimeOnSubstrate   []int32 // Ticks on current substrate.
 	TimeInInteraction []int32 // Ticks in current interaction.
 
+	// Refractory counters: ticks left before the agent may start another
+	// combat or courtship (0 = free, RefractoryPending = interaction just ended).
+	RefractoryCombat    []int32
+	RefractoryCourtship []int32
+
 	// Fixed morphology (set when becoming adult, then constant)
-	MorphologyCont []float64 // [i * NumLoci + locus]
-	MorphologyDisc []int32   // [i * NumLoci + locus]
-	MorphologyFixed []bool   // Whether morphology has been fixed for this agent.
+	MorphologyCont  []float64 // [i * NumLoci + locus]
+	MorphologyDisc  []int32   // [i * NumLoci + locus]
+	MorphologyFixed []bool    // Whether morphology has been fixed for this agent.
 }
 
 // NewAgentArrays allocates all slices with the given capacity and dimensional parameters.
@@ -101,30 +152,40 @@ func NewAgentArrays(cap int, cfg Config) *AgentArrays {
 	numLoci := cfg.NumLoci
 	numNutrients := cfg.NumNutrients
 	numBehaviors := cfg.NumBehaviors
+	numPacks := cfg.MaxStoredPacks
 	// Memory slots: substrates + resource_types + prototypes_total (for perception/interaction)
 	memPerceptionSlots := cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
 	memBehaviorSlots := numBehaviors
 
 	a := &AgentArrays{
-		Count: 0,
-		Cap:   cap,
+		Count:  0,
+		Cap:    cap,
+		NextID: 1,
 
-		PosX:      make([]float64, cap),
-		PosY:      make([]float64, cap),
-		Direction: make([]uint8, cap),
-		Speed:     make([]int32, cap),
+		PosX:       make([]float64, cap),
+		PosY:       make([]float64, cap),
+		Direction:  make([]uint8, cap),
+		Speed:      make([]int32, cap),
+		SpeedCarry: make([]float64, cap),
+		Substrate:  make([]int32, cap),
 
+		ID:          make([]uint64, cap),
 		Sex:         make([]uint8, cap),
 		StageID:     make([]int32, cap),
 		PrototypeID: make([]int32, cap),
 		Age:         make([]int32, cap),
+		Longevity:   make([]int32, cap),
 
 		State:          make([]uint8, cap),
 		Situation:      make([]uint8, cap),
 		Decision:       make([]uint8, cap),
 		InteractantIdx: make([]int32, cap),
 
-		Reserves: make([]int32, cap*numNutrients),
+		Reserves:        make([]int32, cap*numNutrients),
+		ReserveMin:      make([]int32, cap*numNutrients),
+		ReserveCritical: make([]int32, cap*numNutrients),
+		ReserveOptimal:  make([]int32, cap*numNutrients),
+		ReserveMax:      make([]int32, cap*numNutrients),
 
 		GenotypeCont:  make([]float64, cap*numLoci*2),
 		GenotypeDisc:  make([]int32, cap*numLoci*2),
@@ -142,15 +203,27 @@ func NewAgentArrays(cap int, cfg Config) *AgentArrays {
 		Tendencies: make([]int32, cap*8),
 		VDecision:  make([]int32, cap*numBehaviors),
 
+		InteractionWeights: make([]int32, cap*InteractionActions),
+
 		GametesCount:    make([]int32, cap),
 		FertilizedCount: make([]int32, cap),
 		SpermPacksCount: make([]int32, cap),
 		CarriedEggs:     make([]int32, cap),
 
+		SpermDonor:         make([]uint64, cap*numPacks),
+		SpermPaternity:     make([]int32, cap*numPacks),
+		SpermGenotypeCont:  make([]float64, cap*numPacks*numLoci),
+		SpermGenotypeDisc:  make([]int32, cap*numPacks*numLoci),
+		SpermDominanceCont: make([]uint8, cap*numPacks*numLoci),
+		SpermDominanceDisc: make([]uint8, cap*numPacks*numLoci),
+
 		TimeInStage:       make([]int32, cap),
 		TimeOnSubstrate:   make([]int32, cap),
 		TimeInInteraction: make([]int32, cap),
 
+		RefractoryCombat:    make([]int32, cap),
+		RefractoryCourtship: make([]int32, cap),
+
 		MorphologyCont:  make([]float64, cap*numLoci),
 		MorphologyDisc:  make([]int32, cap*numLoci),
 		MorphologyFixed: make([]bool, cap),
@@ -167,6 +240,9 @@ func NewAgentArrays(cap int, cfg Config) *AgentArrays {
 	for i := range a.PrototypeID {
 		a.PrototypeID[i] = -1
 	}
+	for i := range a.Substrate {
+		a.Substrate[i] = -1
+	}
 	// Initialize memory "last" values to -1 (never perceived/interacted).
 	for i := range a.MemoryLastPerceived {
 		a.MemoryLastPerceived[i] = -1
@@ -177,6 +253,20 @@ func NewAgentArrays(cap int, cfg Config) *AgentArrays {
 	for i := range a.MemoryLastBehavior {
 		a.MemoryLastBehavior[i] = -1
 	}
+	for i := 0; i < cap; i++ {
+		resetReferenceLevels(a, i, numNutrients)
+	}
 
 	return a
 }
+
+// resetReferenceLevels sets the reserve reference levels of slot idx to the defaults.
+func resetReferenceLevels(a *AgentArrays, idx, numNutrients int) {
+	base := idx * numNutrients
+	for n := 0; n < numNutrients; n++ {
+		a.ReserveMin[base+n] = DefaultReserveMin
+		a.ReserveCritical[base+n] = DefaultReserveCritical
+		a.ReserveOptimal[base+n] = DefaultReserveOptimal
+		a.ReserveMax[base+n] = DefaultReserveMax
+	}
+}
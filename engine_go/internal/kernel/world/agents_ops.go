@@ -12,6 +12,8 @@ func (w *World) AddAgent() int {
 	a.Count++
 
 	// Initialize defaults for the new slot.
+	a.ID[idx] = a.NextID
+	a.NextID++
 	a.InteractantIdx[idx] = -1
 	a.StageID[idx] = -1
 	a.PrototypeID[idx] = -1
@@ -19,14 +21,59 @@ func (w *World) AddAgent() int {
 	a.Situation[idx] = SituationImmature
 	a.Direction[idx] = 1
 	a.Speed[idx] = 1
+	a.SpeedCarry[idx] = 0
+	a.Substrate[idx] = -1
+	a.TimeOnSubstrate[idx] = 0
 	a.MorphologyFixed[idx] = false
+	a.Longevity[idx] = 0
+	a.RefractoryCombat[idx] = 0
+	a.RefractoryCourtship[idx] = 0
+	a.SpermPacksCount[idx] = 0
+	resetReferenceLevels(a, idx, w.Config.NumNutrients)
+
+	// A slot freed by RemoveAgent still holds the removed agent's state.
+	a.Sex[idx] = SexUndefined
+	a.Age[idx] = 0
+	a.Decision[idx] = 0
+	a.LastOpponentAction[idx] = 0
+	a.GametesCount[idx] = 0
+	a.FertilizedCount[idx] = 0
+	a.CarriedEggs[idx] = 0
+	a.TimeInStage[idx] = 0
+	a.TimeInInteraction[idx] = 0
+	resetMemory(a, idx, w.Config)
+	numLoci := w.Config.NumLoci
+	clear(a.MorphologyCont[idx*numLoci : (idx+1)*numLoci])
+	clear(a.MorphologyDisc[idx*numLoci : (idx+1)*numLoci])
+	clear(a.Tendencies[idx*8 : (idx+1)*8])
+	clear(a.VDecision[idx*w.Config.NumBehaviors : (idx+1)*w.Config.NumBehaviors])
+	clear(a.InteractionWeights[idx*InteractionActions : (idx+1)*InteractionActions])
 
 	return idx
 }
 
+// resetMemory clears the memory of agent idx: nothing perceived, interacted
+// with or performed yet.
+func resetMemory(a *AgentArrays, idx int, cfg Config) {
+	perception := cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
+	for i := idx * perception; i < (idx+1)*perception; i++ {
+		a.MemoryLastPerceived[i] = -1
+		a.MemoryNumPerceived[i] = 0
+		a.MemoryLastInteracted[i] = -1
+		a.MemoryNumInteracted[i] = 0
+	}
+	for i := idx * cfg.NumBehaviors; i < (idx+1)*cfg.NumBehaviors; i++ {
+		a.MemoryLastBehavior[i] = -1
+		a.MemoryNumBehavior[i] = 0
+	}
+}
+
 // RemoveAgent removes the agent at the given index using swap-and-pop.
 // The last active agent is moved to the vacated slot to maintain contiguity.
-// Returns the index of the agent that was swapped in (or -1 if it was the last).
+// References to the removed agent (partners' InteractantIdx, carried eggs)
+// are cleared and references to the moved one are rewritten; the agent ID
+// stays with the agent. Returns the former index of the agent moved into
+// idx (idx itself if it was the last, -1 if idx is out of range).
 func (w *World) RemoveAgent(idx int) int {
 	a := w.Agents
 	last := a.Count - 1
@@ -34,6 +81,7 @@ func (w *World) RemoveAgent(idx int) int {
 		return -1
 	}
 
+	w.relinkAgent(idx, last)
 	if idx != last {
 		w.swapAgents(idx, last)
 	}
@@ -42,6 +90,36 @@ func (w *World) RemoveAgent(idx int) int {
 	return last
 }
 
+// relinkAgent rewrites the index references to the agents at removed and
+// moved before moved takes the slot of removed: references to removed
+// become -1 and references to moved become removed. Only agents in combat
+// or courtship hold an agent in InteractantIdx; the others hold a resource
+// or a target chosen again every tick.
+func (w *World) relinkAgent(removed, moved int) {
+	a := w.Agents
+	for i := 0; i < a.Count; i++ {
+		if a.Situation[i] != SituationCombat && a.Situation[i] != SituationCourtship {
+			continue
+		}
+		switch int(a.InteractantIdx[i]) {
+		case removed:
+			a.InteractantIdx[i] = -1
+		case moved:
+			a.InteractantIdx[i] = int32(removed)
+		}
+	}
+
+	eggs := w.Eggs
+	for e := 0; e < eggs.Count; e++ {
+		switch int(eggs.CarrierAgentIdx[e]) {
+		case removed:
+			eggs.CarrierAgentIdx[e] = -1
+		case moved:
+			eggs.CarrierAgentIdx[e] = int32(removed)
+		}
+	}
+}
+
 // swapAgents swaps all SoA data between indices i and j.
 func (w *World) swapAgents(i, j int) {
 	a := w.Agents
@@ -53,14 +131,18 @@ func (w *World) swapAgents(i, j int) {
 	memBehaviorSlots := numBehaviors
 
 	// Scalar fields
+	a.ID[i], a.ID[j] = a.ID[j], a.ID[i]
 	a.PosX[i], a.PosX[j] = a.PosX[j], a.PosX[i]
 	a.PosY[i], a.PosY[j] = a.PosY[j], a.PosY[i]
 	a.Direction[i], a.Direction[j] = a.Direction[j], a.Direction[i]
 	a.Speed[i], a.Speed[j] = a.Speed[j], a.Speed[i]
+	a.SpeedCarry[i], a.SpeedCarry[j] = a.SpeedCarry[j], a.SpeedCarry[i]
+	a.Substrate[i], a.Substrate[j] = a.Substrate[j], a.Substrate[i]
 	a.Sex[i], a.Sex[j] = a.Sex[j], a.Sex[i]
 	a.StageID[i], a.StageID[j] = a.StageID[j], a.StageID[i]
 	a.PrototypeID[i], a.PrototypeID[j] = a.PrototypeID[j], a.PrototypeID[i]
 	a.Age[i], a.Age[j] = a.Age[j], a.Age[i]
+	a.Longevity[i], a.Longevity[j] = a.Longevity[j], a.Longevity[i]
 	a.State[i], a.State[j] = a.State[j], a.State[i]
 	a.Situation[i], a.Situation[j] = a.Situation[j], a.Situation[i]
 	a.Decision[i], a.Decision[j] = a.Decision[j], a.Decision[i]
@@ -72,11 +154,17 @@ func (w *World) swapAgents(i, j int) {
 	a.TimeInStage[i], a.TimeInStage[j] = a.TimeInStage[j], a.TimeInStage[i]
 	a.TimeOnSubstrate[i], a.TimeOnSubstrate[j] = a.TimeOnSubstrate[j], a.TimeOnSubstrate[i]
 	a.TimeInInteraction[i], a.TimeInInteraction[j] = a.TimeInInteraction[j], a.TimeInInteraction[i]
+	a.RefractoryCombat[i], a.RefractoryCombat[j] = a.RefractoryCombat[j], a.RefractoryCombat[i]
+	a.RefractoryCourtship[i], a.RefractoryCourtship[j] = a.RefractoryCourtship[j], a.RefractoryCourtship[i]
 	a.LastOpponentAction[i], a.LastOpponentAction[j] = a.LastOpponentAction[j], a.LastOpponentAction[i]
 	a.MorphologyFixed[i], a.MorphologyFixed[j] = a.MorphologyFixed[j], a.MorphologyFixed[i]
 
 	// Reserves: numNutrients elements per agent.
 	swapSlice(a.Reserves, i*numNutrients, j*numNutrients, numNutrients)
+	swapSlice(a.ReserveMin, i*numNutrients, j*numNutrients, numNutrients)
+	swapSlice(a.ReserveCritical, i*numNutrients, j*numNutrients, numNutrients)
+	swapSlice(a.ReserveOptimal, i*numNutrients, j*numNutrients, numNutrients)
+	swapSlice(a.ReserveMax, i*numNutrients, j*numNutrients, numNutrients)
 
 	// Genotype: numLoci*2 elements per agent.
 	locusStride := numLoci * 2
@@ -96,10 +184,21 @@ func (w *World) swapAgents(i, j int) {
 	// Tendencies and VDecision
 	swapSlice(a.Tendencies, i*8, j*8, 8)
 	swapSlice(a.VDecision, i*numBehaviors, j*numBehaviors, numBehaviors)
+	swapSlice(a.InteractionWeights, i*InteractionActions, j*InteractionActions, InteractionActions)
 
 	// Morphology
 	swapSliceF64(a.MorphologyCont, i*numLoci, j*numLoci, numLoci)
 	swapSlice(a.MorphologyDisc, i*numLoci, j*numLoci, numLoci)
+
+	// Sperm packs
+	numPacks := cfg.MaxStoredPacks
+	packStride := numPacks * numLoci
+	swapSliceU64(a.SpermDonor, i*numPacks, j*numPacks, numPacks)
+	swapSlice(a.SpermPaternity, i*numPacks, j*numPacks, numPacks)
+	swapSliceF64(a.SpermGenotypeCont, i*packStride, j*packStride, packStride)
+	swapSlice(a.SpermGenotypeDisc, i*packStride, j*packStride, packStride)
+	swapSliceU8(a.SpermDominanceCont, i*packStride, j*packStride, packStride)
+	swapSliceU8(a.SpermDominanceDisc, i*packStride, j*packStride, packStride)
 }
 
 // growAgents doubles the capacity of all agent slices.
@@ -114,22 +213,31 @@ func (w *World) growAgents() {
 	numNutrients := cfg.NumNutrients
 	numLoci := cfg.NumLoci
 	numBehaviors := cfg.NumBehaviors
+	numPacks := cfg.MaxStoredPacks
 	memPerceptionSlots := cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
 	memBehaviorSlots := numBehaviors
 
+	a.ID = growU64(a.ID, newCap)
 	a.PosX = growF64(a.PosX, newCap)
 	a.PosY = growF64(a.PosY, newCap)
 	a.Direction = growU8(a.Direction, newCap)
 	a.Speed = growI32(a.Speed, newCap)
+	a.SpeedCarry = growF64(a.SpeedCarry, newCap)
+	a.Substrate = growI32(a.Substrate, newCap)
 	a.Sex = growU8(a.Sex, newCap)
 	a.StageID = growI32(a.StageID, newCap)
 	a.PrototypeID = growI32(a.PrototypeID, newCap)
 	a.Age = growI32(a.Age, newCap)
+	a.Longevity = growI32(a.Longevity, newCap)
 	a.State = growU8(a.State, newCap)
 	a.Situation = growU8(a.Situation, newCap)
 	a.Decision = growU8(a.Decision, newCap)
 	a.InteractantIdx = growI32(a.InteractantIdx, newCap)
 	a.Reserves = growI32(a.Reserves, newCap*numNutrients)
+	a.ReserveMin = growI32(a.ReserveMin, newCap*numNutrients)
+	a.ReserveCritical = growI32(a.ReserveCritical, newCap*numNutrients)
+	a.ReserveOptimal = growI32(a.ReserveOptimal, newCap*numNutrients)
+	a.ReserveMax = growI32(a.ReserveMax, newCap*numNutrients)
 	a.GenotypeCont = growF64(a.GenotypeCont, newCap*numLoci*2)
 	a.GenotypeDisc = growI32(a.GenotypeDisc, newCap*numLoci*2)
 	a.DominanceCont = growU8(a.DominanceCont, newCap*numLoci*2)
@@ -143,13 +251,22 @@ func (w *World) growAgents() {
 	a.LastOpponentAction = growU8(a.LastOpponentAction, newCap)
 	a.Tendencies = growI32(a.Tendencies, newCap*8)
 	a.VDecision = growI32(a.VDecision, newCap*numBehaviors)
+	a.InteractionWeights = growI32(a.InteractionWeights, newCap*InteractionActions)
 	a.GametesCount = growI32(a.GametesCount, newCap)
 	a.FertilizedCount = growI32(a.FertilizedCount, newCap)
 	a.SpermPacksCount = growI32(a.SpermPacksCount, newCap)
 	a.CarriedEggs = growI32(a.CarriedEggs, newCap)
+	a.SpermDonor = growU64(a.SpermDonor, newCap*numPacks)
+	a.SpermPaternity = growI32(a.SpermPaternity, newCap*numPacks)
+	a.SpermGenotypeCont = growF64(a.SpermGenotypeCont, newCap*numPacks*numLoci)
+	a.SpermGenotypeDisc = growI32(a.SpermGenotypeDisc, newCap*numPacks*numLoci)
+	a.SpermDominanceCont = growU8(a.SpermDominanceCont, newCap*numPacks*numLoci)
+	a.SpermDominanceDisc = growU8(a.SpermDominanceDisc, newCap*numPacks*numLoci)
 	a.TimeInStage = growI32(a.TimeInStage, newCap)
 	a.TimeOnSubstrate = growI32(a.TimeOnSubstrate, newCap)
 	a.TimeInInteraction = growI32(a.TimeInInteraction, newCap)
+	a.RefractoryCombat = growI32(a.RefractoryCombat, newCap)
+	a.RefractoryCourtship = growI32(a.RefractoryCourtship, newCap)
 	a.MorphologyCont = growF64(a.MorphologyCont, newCap*numLoci)
 	a.MorphologyDisc = growI32(a.MorphologyDisc, newCap*numLoci)
 	a.MorphologyFixed = growBool(a.MorphologyFixed, newCap)
@@ -159,6 +276,7 @@ func (w *World) growAgents() {
 		a.InteractantIdx[i] = -1
 		a.StageID[i] = -1
 		a.PrototypeID[i] = -1
+		a.Substrate[i] = -1
 	}
 	for i := a.Cap * memPerceptionSlots; i < newCap*memPerceptionSlots; i++ {
 		a.MemoryLastPerceived[i] = -1
@@ -191,6 +309,12 @@ func swapSliceU8(s []uint8, offI, offJ, count int) {
 	}
 }
 
+func swapSliceU64(s []uint64, offI, offJ, count int) {
+	for k := 0; k < count; k++ {
+		s[offI+k], s[offJ+k] = s[offJ+k], s[offI+k]
+	}
+}
+
 func growI32(old []int32, newLen int) []int32 {
 	s := make([]int32, newLen)
 	copy(s, old)
@@ -214,3 +338,9 @@ func growBool(old []bool, newLen int) []bool {
 	copy(s, old)
 	return s
 }
+
+func growU64(old []uint64, newLen int) []uint64 {
+	s := make([]uint64, newLen)
+	copy(s, old)
+	return s
+}
@@ -50,6 +50,13 @@ type Config struct {
 
 	// InitialCapacity is the pre-allocated capacity for agent/egg slices.
 	InitialCapacity int
+
+	// MaxStoredPacks is the number of sperm pack slots per agent, i.e. the
+	// largest number of packs a female can store.
+	MaxStoredPacks int
+
+	// Seed is the seed of the run's random number streams.
+	Seed uint64
 }
 
 // DefaultConfig returns a Config with sensible defaults for unset fields.
@@ -57,5 +64,6 @@ func DefaultConfig() Config {
 	return Config{
 		NumDirections:   8,
 		InitialCapacity: 1024,
+		MaxStoredPacks:  5,
 	}
 }
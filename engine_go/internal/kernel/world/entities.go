@@ -28,9 +28,10 @@ type EggArrays struct {
 	// Decision for egg viability (survive=1, die=2).
 	VDecision []int32 // [i*2 + 0]=survive weight, [i*2 + 1]=die weight
 
-	// Parentage (stored as string names for traceability).
-	ParentMale   []string
-	ParentFemale []string
+	// Parentage by agent ID (the sire is the donor of the sperm pack); the
+	// names are looked up in World.Names.
+	ParentMaleID   []uint64
+	ParentFemaleID []uint64
 }
 
 // NewEggArrays allocates egg slices with the given capacity.
@@ -59,8 +60,8 @@ func NewEggArrays(cap int, cfg Config) *EggArrays {
 
 		VDecision: make([]int32, cap*2),
 
-		ParentMale:   make([]string, cap),
-		ParentFemale: make([]string, cap),
+		ParentMaleID:   make([]uint64, cap),
+		ParentFemaleID: make([]uint64, cap),
 	}
 
 	for i := range e.CarrierAgentIdx {
@@ -81,10 +82,10 @@ type ResourceArrays struct {
 	PosX []float64
 	PosY []float64
 
-	TypeID   []int32   // Index into the resource type definitions.
-	Level    []int32   // Current resource level.
-	MaxLevel []int32   // Maximum capacity.
-	Quality  []int32   // Quality metric.
+	TypeID    []int32   // Index into the resource type definitions.
+	Level     []int32   // Current resource level.
+	MaxLevel  []int32   // Maximum capacity.
+	Quality   []int32   // Quality metric.
 	RegenRate []float64 // Multiplicative regeneration rate per tick.
 }
 
@@ -108,7 +109,7 @@ func NewResourceArrays(cap int) *ResourceArrays {
 type SubstrateMap struct {
 	Width  int
 	Height int
-	Grid   []int32 // Flat 2D array: Grid[y*Width + x] = substrate type index.
+	Grid   []int32 // Flat 2D array: Grid[y*Width + x] = substrate index + 1 (0 = none).
 }
 
 // NewSubstrateMap allocates a substrate grid of the given dimensions.
@@ -125,6 +126,16 @@ func (m *SubstrateMap) Get(x, y int) int32 {
 	return m.Grid[y*m.Width+x]
 }
 
+// SubstrateAt returns the 0-based substrate index at world position (x, y),
+// or -1 when the cell has no substrate or lies outside the map.
+func (m *SubstrateMap) SubstrateAt(x, y float64) int {
+	cx, cy := int(x), int(y)
+	if cx < 0 || cy < 0 || cx >= m.Width || cy >= m.Height {
+		return -1
+	}
+	return int(m.Grid[cy*m.Width+cx]) - 1
+}
+
 // Set assigns a substrate ID at position (x, y).
 func (m *SubstrateMap) Set(x, y int, substrateID int32) {
 	m.Grid[y*m.Width+x] = substrateID
@@ -0,0 +1,78 @@
+package world
+
+import (
+	"fmt"
+	"math"
+)
+
+// ExpressionModel is how the two alleles of a locus combine into its
+// expressed value.
+type ExpressionModel uint8
+
+const (
+	ExpressionDominant     ExpressionModel = iota // A dominant allele masks a recessive one; equal dominance averages.
+	ExpressionAdditive                            // The alleles average whatever their dominance.
+	ExpressionPartial                             // A heterozygote lies H of the way from the recessive to the dominant allele.
+	ExpressionOverdominant                        // The average plus H times the difference between the alleles.
+)
+
+// ParseExpressionModel parses an expression model as stored in the loci
+// table: dominant (or empty), additive, partial and overdominant.
+func ParseExpressionModel(model string) (ExpressionModel, error) {
+	switch model {
+	case "", "dominant":
+		return ExpressionDominant, nil
+	case "additive":
+		return ExpressionAdditive, nil
+	case "partial":
+		return ExpressionPartial, nil
+	case "overdominant":
+		return ExpressionOverdominant, nil
+	}
+	return 0, fmt.Errorf("unknown expression model %q", model)
+}
+
+// Expression is the genotype-to-phenotype map of one locus. The zero value
+// is the dominant model. Every model expresses a homozygote (or a single
+// copy held in both allele slots) as its allele.
+type Expression struct {
+	Model ExpressionModel
+	H     float64 // Dominance coefficient of the partial and overdominant models.
+}
+
+// Cont returns the expressed value of a continuous locus with paternal and
+// maternal alleles pat and mat of dominance patDom and matDom (1 = dominant).
+func (e Expression) Cont(pat, mat float64, patDom, matDom uint8) float64 {
+	switch e.Model {
+	case ExpressionAdditive:
+		return (pat + mat) / 2
+	case ExpressionOverdominant:
+		return (pat+mat)/2 + e.H*math.Abs(pat-mat)
+	}
+	if patDom == matDom {
+		return (pat + mat) / 2 // Codominance.
+	}
+	dom, rec := pat, mat
+	if matDom == 1 {
+		dom, rec = mat, pat
+	}
+	if e.Model == ExpressionPartial {
+		return rec + e.H*(dom-rec)
+	}
+	return dom
+}
+
+// Disc returns the expressed value of a discrete locus, truncated toward
+// zero like the integer average of the dominant model.
+func (e Expression) Disc(pat, mat int32, patDom, matDom uint8) int32 {
+	if e.Model == ExpressionDominant {
+		switch {
+		case patDom == matDom:
+			return (pat + mat) / 2
+		case patDom == 1:
+			return pat
+		}
+		return mat
+	}
+	return int32(e.Cont(float64(pat), float64(mat), patDom, matDom))
+}
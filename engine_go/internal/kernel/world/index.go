@@ -0,0 +1,119 @@
+package world
+
+// Index maps database primary keys to the dense 0-based indices used by the
+// kernel arrays. It is built once during the Cold Path from the project tables
+// in their sort order. Prototypes are indexed within their sex, so the first
+// male and the first female prototype both have index 0 (matching
+// AgentArrays.PrototypeID).
+type Index struct {
+	NutrientIDs     []int64
+	LocusIDs        []int64
+	StageIDs        []int64
+	PrototypeIDsM   []int64
+	PrototypeIDsF   []int64
+	ResourceTypeIDs []int64
+	SubstrateIDs    []int64
+
+	nutrients     map[int64]int
+	loci          map[int64]int
+	stages        map[int64]int
+	prototypesM   map[int64]int
+	prototypesF   map[int64]int
+	resourceTypes map[int64]int
+	substrates    map[int64]int
+}
+
+// NewIndex builds an Index from ordered ID lists.
+func NewIndex(nutrients, loci, stages, prototypesM, prototypesF, resourceTypes, substrates []int64) *Index {
+	return &Index{
+		NutrientIDs:     nutrients,
+		LocusIDs:        loci,
+		StageIDs:        stages,
+		PrototypeIDsM:   prototypesM,
+		PrototypeIDsF:   prototypesF,
+		ResourceTypeIDs: resourceTypes,
+		SubstrateIDs:    substrates,
+		nutrients:       positions(nutrients),
+		loci:            positions(loci),
+		stages:          positions(stages),
+		prototypesM:     positions(prototypesM),
+		prototypesF:     positions(prototypesF),
+		resourceTypes:   positions(resourceTypes),
+		substrates:      positions(substrates),
+	}
+}
+
+// Nutrient returns the index of a nutrient ID.
+func (ix *Index) Nutrient(id int64) (int, bool) { return lookup(ix.nutrients, id) }
+
+// Locus returns the index of a locus ID.
+func (ix *Index) Locus(id int64) (int, bool) { return lookup(ix.loci, id) }
+
+// Stage returns the index of a stage ID.
+func (ix *Index) Stage(id int64) (int, bool) { return lookup(ix.stages, id) }
+
+// ResourceType returns the index of a resource type ID.
+func (ix *Index) ResourceType(id int64) (int, bool) { return lookup(ix.resourceTypes, id) }
+
+// Substrate returns the index of a substrate ID.
+func (ix *Index) Substrate(id int64) (int, bool) { return lookup(ix.substrates, id) }
+
+// Prototype returns the sex and within-sex index of a prototype ID.
+func (ix *Index) Prototype(id int64) (sex uint8, idx int, ok bool) {
+	if i, found := ix.prototypesM[id]; found {
+		return SexMale, i, true
+	}
+	if i, found := ix.prototypesF[id]; found {
+		return SexFemale, i, true
+	}
+	return SexUndefined, -1, false
+}
+
+// PrototypeDBID returns the database ID of a within-sex prototype index.
+func (ix *Index) PrototypeDBID(sex uint8, idx int) int64 {
+	ids := ix.PrototypeIDsM
+	if sex == SexFemale {
+		ids = ix.PrototypeIDsF
+	}
+	if idx < 0 || idx >= len(ids) {
+		return 0
+	}
+	return ids[idx]
+}
+
+// Perceiver returns the unified perceiver index of a stage or prototype ID:
+// stages first, then male prototypes, then female prototypes. Exactly one of
+// stageID and prototypeID is expected to be non-nil.
+func (ix *Index) Perceiver(stageID, prototypeID *int64) (int, bool) {
+	if stageID != nil {
+		return ix.Stage(*stageID)
+	}
+	if prototypeID != nil {
+		sex, i, ok := ix.Prototype(*prototypeID)
+		if !ok {
+			return -1, false
+		}
+		base := len(ix.StageIDs)
+		if sex == SexFemale {
+			base += len(ix.PrototypeIDsM)
+		}
+		return base + i, true
+	}
+	return -1, false
+}
+
+func positions(ids []int64) map[int64]int {
+	m := make(map[int64]int, len(ids))
+	for i, id := range ids {
+		m[id] = i
+	}
+	return m
+}
+
+func lookup(m map[int64]int, id int64) (int, bool) {
+	i, ok := m[id]
+	if !ok {
+		return -1, false
+	}
+	return i, true
+}
@@ -2,20 +2,28 @@ package world
 
 import (
 	"fmt"
+	"math"
 
 	"galatea/engine/internal/adapters/storage"
 )
 
 // Load reads the project configuration and an environment from the database,
 // constructs a fully allocated World, and populates it with initial agents
-// and resources. This is the Cold Path entry point.
-func Load(db *storage.DB, environmentID int64) (*World, error) {
-	cfg, err := loadConfig(db, environmentID)
+// and resources. seed seeds the world's random number streams, which also
+// draw the founder genotypes. This is the Cold Path entry point.
+func Load(db *storage.DB, environmentID int64, seed uint64) (*World, error) {
+	cfg, index, err := loadConfig(db, environmentID)
 	if err != nil {
 		return nil, fmt.Errorf("load config: %w", err)
 	}
+	cfg.Seed = seed
 
 	w := New(cfg)
+	w.Index = index
+
+	if err := loadResourceTypes(db, w); err != nil {
+		return nil, fmt.Errorf("load resource types: %w", err)
+	}
 
 	if err := loadSubstrateMap(db, w, environmentID); err != nil {
 		return nil, fmt.Errorf("load substrate map: %w", err)
@@ -32,15 +40,16 @@ func Load(db *storage.DB, environmentID int64) (*World, error) {
 	return w, nil
 }
 
-// loadConfig reads dimensional information from the database to build Config.
-func loadConfig(db *storage.DB, environmentID int64) (Config, error) {
+// loadConfig reads dimensional information from the database to build Config
+// and the Index that maps DB IDs to array indices.
+func loadConfig(db *storage.DB, environmentID int64) (Config, *Index, error) {
 	cfg := DefaultConfig()
 
 	// Project name.
 	projRepo := storage.NewProjectInfoRepo(db)
 	proj, err := projRepo.Get()
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 	if proj != nil {
 		cfg.ProjectName = proj.Name
@@ -50,7 +59,7 @@ func loadConfig(db *storage.DB, environmentID int64) (Config, error) {
 	nutRepo := storage.NewNutrientRepo(db)
 	nutrients, err := nutRepo.List()
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 	cfg.NumNutrients = len(nutrients)
 
@@ -58,7 +67,7 @@ func loadConfig(db *storage.DB, environmentID int64) (Config, error) {
 	locRepo := storage.NewLocusRepo(db)
 	loci, err := locRepo.List()
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 	cfg.NumLoci = len(loci)
 
@@ -66,7 +75,7 @@ func loadConfig(db *storage.DB, environmentID int64) (Config, error) {
 	stageRepo := storage.NewStageRepo(db)
 	stages, err := stageRepo.List()
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 	cfg.NumStages = len(stages)
 
@@ -74,11 +83,11 @@ func loadConfig(db *storage.DB, environmentID int64) (Config, error) {
 	protoRepo := storage.NewPrototypeRepo(db)
 	males, err := protoRepo.List("M")
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 	females, err := protoRepo.List("F")
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 	cfg.NumPrototypesM = len(males)
 	cfg.NumPrototypesF = len(females)
@@ -88,7 +97,7 @@ func loadConfig(db *storage.DB, environmentID int64) (Config, error) {
 	rtRepo := storage.NewResourceTypeRepo(db)
 	resourceTypes, err := rtRepo.List()
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 	cfg.NumResourceTypes = len(resourceTypes)
 
@@ -96,7 +105,7 @@ func loadConfig(db *storage.DB, environmentID int64) (Config, error) {
 	subRepo := storage.NewSubstrateRepo(db)
 	substrates, err := subRepo.List()
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 	cfg.NumSubstrates = len(substrates)
 
@@ -104,10 +113,10 @@ func loadConfig(db *storage.DB, environmentID int64) (Config, error) {
 	envRepo := storage.NewEnvironmentRepo(db)
 	env, err := envRepo.GetByID(environmentID)
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 	if env == nil {
-		return cfg, fmt.Errorf("environment %d not found", environmentID)
+		return cfg, nil, fmt.Errorf("environment %d not found", environmentID)
 	}
 	cfg.GridWidth = env.Width
 	cfg.GridHeight = env.Height
@@ -119,7 +128,36 @@ func loadConfig(db *storage.DB, environmentID int64) (Config, error) {
 		cfg.NumBehaviors = 12
 	}
 
-	return cfg, nil
+	index := NewIndex(
+		nutrientIDs(nutrients), locusIDs(loci), stageIDs(stages),
+		prototypeIDs(males), prototypeIDs(females),
+		resourceTypeIDs(resourceTypes), substrateIDs(substrates),
+	)
+
+	return cfg, index, nil
+}
+
+// loadResourceTypes maps each resource type to the nutrient it feeds and
+// flags the oviposition sites. Types without a nutrient (e.g. oviposition
+// sites) are not feedable.
+func loadResourceTypes(db *storage.DB, w *World) error {
+	types, err := storage.NewResourceTypeRepo(db).List()
+	if err != nil {
+		return err
+	}
+	for t, rt := range types {
+		w.ResourceOviposition[t] = rt.IsOviposition
+		w.ResourceNutrient[t] = -1
+		if rt.NutrientID == nil {
+			continue
+		}
+		n, ok := w.Index.Nutrient(*rt.NutrientID)
+		if !ok {
+			return fmt.Errorf("resource type %q: unknown nutrient %d", rt.Name, *rt.NutrientID)
+		}
+		w.ResourceNutrient[t] = int32(n)
+	}
+	return nil
 }
 
 // loadSubstrateMap reads the substrate map rows from the DB and fills the grid.
@@ -141,26 +179,44 @@ func loadSubstrateMap(db *storage.DB, w *World, environmentID int64) error {
 		}
 		// Parse comma-separated substrate IDs.
 		x := 0
-		num := int32(0)
+		num := int64(0)
 		for _, ch := range data {
 			if ch == ',' {
-				if x < w.Substrates.Width {
-					w.Substrates.Set(x, y, num)
+				if err := setSubstrateCell(w, x, y, num); err != nil {
+					return err
 				}
 				x++
 				num = 0
 			} else if ch >= '0' && ch <= '9' {
-				num = num*10 + int32(ch-'0')
+				num = num*10 + int64(ch-'0')
 			}
 		}
 		// Handle last value (no trailing comma).
-		if x < w.Substrates.Width {
-			w.Substrates.Set(x, y, num)
+		if err := setSubstrateCell(w, x, y, num); err != nil {
+			return err
 		}
 	}
 	return rows.Err()
 }
 
+// setSubstrateCell stores the substrate with DB ID id at (x, y) as its
+// index + 1. ID 0 marks a cell without substrate.
+func setSubstrateCell(w *World, x, y int, id int64) error {
+	if x >= w.Substrates.Width || y < 0 || y >= w.Substrates.Height {
+		return nil
+	}
+	if id == 0 {
+		w.Substrates.Set(x, y, 0)
+		return nil
+	}
+	s, ok := w.Index.Substrate(id)
+	if !ok {
+		return fmt.Errorf("cell (%d,%d): unknown substrate %d", x, y, id)
+	}
+	w.Substrates.Set(x, y, int32(s+1))
+	return nil
+}
+
 // loadResources reads environment_resources and populates ResourceArrays.
 func loadResources(db *storage.DB, w *World, environmentID int64) error {
 	envRepo := storage.NewEnvironmentRepo(db)
@@ -189,8 +245,16 @@ func loadResources(db *storage.DB, w *World, environmentID int64) error {
 
 		w.Resources.PosX[idx] = float64(r.PosX)
 		w.Resources.PosY[idx] = float64(r.PosY)
-		w.Resources.TypeID[idx] = int32(r.ResourceTypeID - 1) // Convert 1-based DB ID to 0-based index.
+		typeIdx, ok := w.Index.ResourceType(r.ResourceTypeID)
+		if !ok {
+			return fmt.Errorf("resource %q: unknown resource type %d", r.Name, r.ResourceTypeID)
+		}
+		w.Resources.TypeID[idx] = int32(typeIdx)
 		w.Resources.Level[idx] = int32(r.Level)
+		if w.ResourceOviposition[typeIdx] {
+			// Sites start empty: their level counts the eggs they hold.
+			w.Resources.Level[idx] = 0
+		}
 		w.Resources.MaxLevel[idx] = int32(r.MaxLevel)
 		w.Resources.Quality[idx] = int32(r.Quality)
 		w.Resources.RegenRate[idx] = r.RegenRate
@@ -207,12 +271,18 @@ func loadAgents(db *storage.DB, w *World, environmentID int64) error {
 	if err != nil {
 		return err
 	}
+	founders, err := loadFounderGenetics(db, w, environmentID)
+	if err != nil {
+		return err
+	}
 
 	for _, a := range agents {
 		idx := w.AddAgent()
+		w.Names.Set(w.Agents.ID[idx], a.Name, 0)
 
 		w.Agents.PosX[idx] = float64(a.PosX)
 		w.Agents.PosY[idx] = float64(a.PosY)
+		w.Agents.Substrate[idx] = int32(w.Substrates.SubstrateAt(w.Agents.PosX[idx], w.Agents.PosY[idx]))
 		w.Agents.Age[idx] = int32(a.Age)
 
 		switch a.Sex {
@@ -225,16 +295,170 @@ func loadAgents(db *storage.DB, w *World, environmentID int64) error {
 		}
 
 		if a.StageID != nil {
-			w.Agents.StageID[idx] = int32(*a.StageID - 1) // 1-based to 0-based.
+			stageIdx, ok := w.Index.Stage(*a.StageID)
+			if !ok {
+				return fmt.Errorf("agent %q: unknown stage %d", a.Name, *a.StageID)
+			}
+			w.Agents.StageID[idx] = int32(stageIdx)
 			w.Agents.Situation[idx] = SituationImmature
 		}
 		if a.PrototypeID != nil {
-			w.Agents.PrototypeID[idx] = int32(*a.PrototypeID - 1)
+			_, protoIdx, ok := w.Index.Prototype(*a.PrototypeID)
+			if !ok {
+				return fmt.Errorf("agent %q: unknown prototype %d", a.Name, *a.PrototypeID)
+			}
+			w.Agents.PrototypeID[idx] = int32(protoIdx) // Index within the agent's sex.
 			w.Agents.Situation[idx] = SituationRegular
 		}
 
+		founders.seed(w, idx, founders.explicit[a.ID])
+
 		// Initialize reserves to 0 (will be set by formula evaluation in the engine setup).
 	}
 
 	return nil
 }
+
+// founderGenetics holds what is needed to seed the genotypes of initial agents.
+type founderGenetics struct {
+	loci      []storage.Locus
+	frequency []float64        // Dominant-allele frequency per locus index.
+	explicit  map[int64][]int8 // Agent DB ID → [locus*2 + allele]: -1 sampled, 0 recessive, 1 dominant.
+}
+
+// loadFounderGenetics reads the loci, the environment's founding allele
+// frequencies (0.5 when unset) and the explicit founder alleles.
+func loadFounderGenetics(db *storage.DB, w *World, environmentID int64) (*founderGenetics, error) {
+	loci, err := storage.NewLocusRepo(db).List()
+	if err != nil {
+		return nil, err
+	}
+	fg := &founderGenetics{
+		loci:      loci,
+		frequency: make([]float64, len(loci)),
+		explicit:  make(map[int64][]int8),
+	}
+	for l := range fg.frequency {
+		fg.frequency[l] = 0.5
+	}
+
+	envRepo := storage.NewEnvironmentRepo(db)
+	freqs, err := envRepo.ListAlleleFrequencies(environmentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range freqs {
+		l, ok := w.Index.Locus(f.LocusID)
+		if !ok {
+			return nil, fmt.Errorf("allele frequency: unknown locus %d", f.LocusID)
+		}
+		fg.frequency[l] = f.DominantFrequency
+	}
+
+	alleles, err := envRepo.ListAgentAlleles(environmentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, aa := range alleles {
+		l, ok := w.Index.Locus(aa.LocusID)
+		if !ok {
+			return nil, fmt.Errorf("agent %d genotype: unknown locus %d", aa.AgentID, aa.LocusID)
+		}
+		g := fg.explicit[aa.AgentID]
+		if g == nil {
+			g = make([]int8, len(loci)*2)
+			for i := range g {
+				g[i] = -1
+			}
+			fg.explicit[aa.AgentID] = g
+		}
+		var dom int8
+		if aa.Dominant {
+			dom = 1
+		}
+		g[l*2+aa.Allele] = dom
+	}
+
+	return fg, nil
+}
+
+// seed writes the founder genotype of agent idx. Each allele is taken from
+// explicit when set and otherwise drawn as dominant with the locus frequency,
+// so unset founders follow Hardy-Weinberg proportions. Continuous loci fill
+// GenotypeCont/DominanceCont and discrete loci GenotypeDisc/DominanceDisc.
+func (fg *founderGenetics) seed(w *World, idx int, explicit []int8) {
+	a := w.Agents
+	numLoci := w.Config.NumLoci
+	for l, locus := range fg.loci {
+		for allele := 0; allele < 2; allele++ {
+			dominant := w.Rand.Genetics.Float64() < fg.frequency[l]
+			if explicit != nil && explicit[l*2+allele] >= 0 {
+				dominant = explicit[l*2+allele] == 1
+			}
+
+			value := locus.RecessiveValue
+			var dom uint8
+			if dominant {
+				value = locus.DominantValue
+				dom = 1
+			}
+
+			off := idx*numLoci*2 + l*2 + allele
+			if locus.IsContinuous {
+				a.GenotypeCont[off] = value
+				a.DominanceCont[off] = dom
+			} else {
+				a.GenotypeDisc[off] = int32(math.Round(value))
+				a.DominanceDisc[off] = dom
+			}
+		}
+	}
+}
+
+func nutrientIDs(list []storage.Nutrient) []int64 {
+	ids := make([]int64, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	return ids
+}
+
+func locusIDs(list []storage.Locus) []int64 {
+	ids := make([]int64, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	return ids
+}
+
+func stageIDs(list []storage.Stage) []int64 {
+	ids := make([]int64, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	return ids
+}
+
+func prototypeIDs(list []storage.Prototype) []int64 {
+	ids := make([]int64, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	return ids
+}
+
+func resourceTypeIDs(list []storage.ResourceType) []int64 {
+	ids := make([]int64, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	return ids
+}
+
+func substrateIDs(list []storage.Substrate) []int64 {
+	ids := make([]int64, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	return ids
+}
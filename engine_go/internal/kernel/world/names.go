@@ -0,0 +1,209 @@
+package world
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Names holds the agent names of a run. Agents refer to their name through
+// their ID, so the SoA arrays hold no strings, and the names and generations
+// of dead parents stay available to their offspring and to the results.
+// Founder names are interned; offspring keep only their generation and
+// parents, and their names are built from the offspring name pattern when
+// asked for.
+type Names struct {
+	names   []string         // Interned founder names; index 0 is the empty name.
+	index   map[string]int32 // Name → index into names.
+	byID    []nameRecord     // [agent ID]
+	pattern NamePattern      // Offspring names; nil leaves offspring unnamed.
+}
+
+// nameRecord is the interned name (founders only), the generation and the
+// parents of one agent ID (no parents for founders).
+type nameRecord struct {
+	name           int32
+	generation     int32
+	father, mother uint64
+}
+
+// NewNames creates an empty name table.
+func NewNames() *Names {
+	return &Names{names: []string{""}, index: map[string]int32{"": 0}}
+}
+
+// Set records the name and generation of agent id (founders are generation 0).
+func (n *Names) Set(id uint64, name string, generation int32) {
+	n.grow(id)
+	n.byID[id] = nameRecord{name: n.intern(name), generation: generation}
+}
+
+// SetPattern sets the pattern that names the offspring.
+func (n *Names) SetPattern(p NamePattern) {
+	n.pattern = p
+}
+
+// AddOffspring records agent id as the offspring of father and mother, one
+// generation after the later of its parents.
+func (n *Names) AddOffspring(id, father, mother uint64) {
+	generation := max(n.Generation(father), n.Generation(mother)) + 1
+	n.grow(id)
+	n.byID[id] = nameRecord{generation: generation, father: father, mother: mother}
+}
+
+// Name returns the name of agent id, or "" if it has none. Offspring names
+// are built from the pattern on each call.
+func (n *Names) Name(id uint64) string {
+	return n.name(id, false)
+}
+
+// name returns the name of agent id. A nested name is a parent's name inside
+// its offspring's name; its own {mother} and {father} are written as IDs, so
+// names stay bounded however deep the pedigree.
+func (n *Names) name(id uint64, nested bool) string {
+	if id >= uint64(len(n.byID)) {
+		return ""
+	}
+	rec := n.byID[id]
+	if rec.name != 0 || n.pattern == nil || rec.father == 0 && rec.mother == 0 {
+		return n.names[rec.name]
+	}
+	return n.pattern.name(n, id, rec, nested)
+}
+
+// Parents returns the IDs of the father and mother of agent id (0 for
+// founders and unknown agents).
+func (n *Names) Parents(id uint64) (father, mother uint64) {
+	if id >= uint64(len(n.byID)) {
+		return 0, 0
+	}
+	return n.byID[id].father, n.byID[id].mother
+}
+
+// Generation returns the generation of agent id (0 if unknown).
+func (n *Names) Generation(id uint64) int32 {
+	if id >= uint64(len(n.byID)) {
+		return 0
+	}
+	return n.byID[id].generation
+}
+
+// grow makes room for agent id in byID.
+func (n *Names) grow(id uint64) {
+	if id >= uint64(len(n.byID)) {
+		grown := make([]nameRecord, max(id+1, uint64(2*len(n.byID))))
+		copy(grown, n.byID)
+		n.byID = grown
+	}
+}
+
+// intern returns the index of name, adding it to the table if new.
+func (n *Names) intern(name string) int32 {
+	if i, ok := n.index[name]; ok {
+		return i
+	}
+	i := int32(len(n.names))
+	n.names = append(n.names, name)
+	n.index[name] = i
+	return i
+}
+
+// DefaultNamePattern is the offspring name pattern of projects without one.
+const DefaultNamePattern = "G{generation}-{id}"
+
+// NamePattern is a compiled offspring name pattern. The placeholders
+// {mother} and {father} expand to the parents' names, {mother_id} and
+// {father_id} to their IDs, {generation} to the offspring's generation and
+// {id} to its own ID; any other text is kept as is. Parent names do not nest:
+// within a parent's name, {mother} and {father} expand to the grandparents'
+// IDs, as {mother_id} and {father_id} do.
+type NamePattern []namePart
+
+// namePart is a literal text or, when field is not fieldLiteral, a placeholder.
+type namePart struct {
+	text  string
+	field uint8
+}
+
+const (
+	fieldLiteral uint8 = iota
+	fieldMother
+	fieldFather
+	fieldMotherID
+	fieldFatherID
+	fieldGeneration
+	fieldID
+)
+
+var nameFields = map[string]uint8{
+	"mother":     fieldMother,
+	"father":     fieldFather,
+	"mother_id":  fieldMotherID,
+	"father_id":  fieldFatherID,
+	"generation": fieldGeneration,
+	"id":         fieldID,
+}
+
+// ParseNamePattern compiles an offspring name pattern. An empty pattern is
+// DefaultNamePattern.
+func ParseNamePattern(pattern string) (NamePattern, error) {
+	if pattern == "" {
+		pattern = DefaultNamePattern
+	}
+	var p NamePattern
+	for rest := pattern; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			p = append(p, namePart{text: rest})
+			break
+		}
+		if open > 0 {
+			p = append(p, namePart{text: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("name pattern %q: unclosed placeholder", pattern)
+		}
+		key := rest[open+1 : open+end]
+		field, ok := nameFields[key]
+		if !ok {
+			return nil, fmt.Errorf("name pattern %q: unknown placeholder {%s}", pattern, key)
+		}
+		p = append(p, namePart{field: field})
+		rest = rest[open+end+1:]
+	}
+	return p, nil
+}
+
+// name builds the name of the offspring id recorded as rec; nested writes
+// its parents as IDs.
+func (p NamePattern) name(n *Names, id uint64, rec nameRecord, nested bool) string {
+	var b strings.Builder
+	for _, part := range p {
+		switch part.field {
+		case fieldLiteral:
+			b.WriteString(part.text)
+		case fieldMother:
+			if nested {
+				b.WriteString(strconv.FormatUint(rec.mother, 10))
+			} else {
+				b.WriteString(n.name(rec.mother, true))
+			}
+		case fieldFather:
+			if nested {
+				b.WriteString(strconv.FormatUint(rec.father, 10))
+			} else {
+				b.WriteString(n.name(rec.father, true))
+			}
+		case fieldMotherID:
+			b.WriteString(strconv.FormatUint(rec.mother, 10))
+		case fieldFatherID:
+			b.WriteString(strconv.FormatUint(rec.father, 10))
+		case fieldGeneration:
+			b.WriteString(strconv.Itoa(int(rec.generation)))
+		case fieldID:
+			b.WriteString(strconv.FormatUint(id, 10))
+		}
+	}
+	return b.String()
+}
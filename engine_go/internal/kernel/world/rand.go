@@ -0,0 +1,46 @@
+package world
+
+import "math/rand/v2"
+
+// Streams holds the random number streams of a run. Each system draws from
+// its own PCG stream derived from the run seed, so identical seeds give
+// identical runs and a change in how often one system draws leaves the
+// sequences of the others untouched.
+type Streams struct {
+	Seed         uint64
+	Order        *rand.Rand // Agent processing order.
+	Decision     *rand.Rand // Behavior and interaction roulettes.
+	Movement     *rand.Rand // Movement directions.
+	Genetics     *rand.Rand // Founder alleles, gametes, mutation and sex.
+	Reproduction *rand.Rand // Sperm consumption and sire draws.
+	Ontogeny     *rand.Rand // Egg survival.
+	Formulas     *rand.Rand // Random, RandG and Dice.
+}
+
+// Stream identifiers of the PCG sequences derived from a seed.
+const (
+	streamOrder uint64 = iota + 1
+	streamDecision
+	streamMovement
+	streamGenetics
+	streamReproduction
+	streamOntogeny
+	streamFormulas
+)
+
+// NewStreams creates the streams of a run with the given seed.
+func NewStreams(seed uint64) *Streams {
+	stream := func(id uint64) *rand.Rand {
+		return rand.New(rand.NewPCG(seed, id))
+	}
+	return &Streams{
+		Seed:         seed,
+		Order:        stream(streamOrder),
+		Decision:     stream(streamDecision),
+		Movement:     stream(streamMovement),
+		Genetics:     stream(streamGenetics),
+		Reproduction: stream(streamReproduction),
+		Ontogeny:     stream(streamOntogeny),
+		Formulas:     stream(streamFormulas),
+	}
+}
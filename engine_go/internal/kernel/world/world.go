@@ -3,12 +3,22 @@ package world
 // World is the top-level container for all simulation state.
 // It is constructed once during the Cold Path and mutated in-place during ticks.
 type World struct {
-	Config    Config
-	Agents    *AgentArrays
-	Eggs      *EggArrays
-	Resources *ResourceArrays
+	Config     Config
+	Index      *Index // DB ID <-> array index mapping (nil for worlds not loaded from a DB).
+	Agents     *AgentArrays
+	Eggs       *EggArrays
+	Resources  *ResourceArrays
 	Substrates *SubstrateMap
-	Tick      int64
+	Names      *Names   // Name and generation of every agent ID of the run.
+	Rand       *Streams // Random number streams, seeded with Config.Seed.
+	Tick       int64
+
+	// ResourceNutrient maps each resource type to the nutrient index its
+	// feeding credits, or -1 when the type is not feedable.
+	ResourceNutrient []int32
+	// ResourceOviposition marks the resource types that are oviposition
+	// sites. A site's Level counts the eggs it holds, up to its MaxLevel.
+	ResourceOviposition []bool
 }
 
 // New creates a fully allocated World based on the given configuration.
@@ -20,12 +30,26 @@ func New(cfg Config) *World {
 	}
 	resCap := 256 // Reasonable default for resource instances.
 
+	// Until a project says otherwise, resource type i feeds nutrient i.
+	resourceNutrient := make([]int32, cfg.NumResourceTypes)
+	for t := range resourceNutrient {
+		resourceNutrient[t] = -1
+		if t < cfg.NumNutrients {
+			resourceNutrient[t] = int32(t)
+		}
+	}
+
 	return &World{
-		Config:     cfg,
-		Agents:     NewAgentArrays(agentCap, cfg),
-		Eggs:       NewEggArrays(eggCap, cfg),
-		Resources:  NewResourceArrays(resCap),
-		Substrates: NewSubstrateMap(cfg.GridWidth, cfg.GridHeight),
-		Tick:       0,
+		Config:           cfg,
+		Agents:           NewAgentArrays(agentCap, cfg),
+		Eggs:             NewEggArrays(eggCap, cfg),
+		Resources:        NewResourceArrays(resCap),
+		Substrates:       NewSubstrateMap(cfg.GridWidth, cfg.GridHeight),
+		Names:            NewNames(),
+		Rand:             NewStreams(cfg.Seed),
+		Tick:             0,
+		ResourceNutrient: resourceNutrient,
+
+		ResourceOviposition: make([]bool, cfg.NumResourceTypes),
 	}
 }
@@ -135,6 +135,142 @@ func TestRemoveAgentSwapAndPop(t *testing.T) {
 	}
 }
 
+func TestAgentIDsAndSpermPacksFollowAgents(t *testing.T) {
+	cfg := testConfig()
+	cfg.InitialCapacity = 2
+	cfg.MaxStoredPacks = 2
+	w := New(cfg)
+	a := w.Agents
+
+	// Add past capacity so the pack slots are grown as well.
+	for i := 0; i < 3; i++ {
+		idx := w.AddAgent()
+		slot := idx*cfg.MaxStoredPacks + 1
+		a.SpermPacksCount[idx] = 2
+		a.SpermDonor[slot] = uint64(100 + i)
+		a.SpermPaternity[slot] = int32(10 * i)
+		a.SpermGenotypeCont[slot*cfg.NumLoci+4] = float64(i)
+	}
+	if a.ID[0] != 1 || a.ID[1] != 2 || a.ID[2] != 3 {
+		t.Fatalf("expected IDs 1,2,3, got %v", a.ID[:3])
+	}
+
+	// The last agent moves into slot 0 with its packs; IDs are never reused.
+	w.RemoveAgent(0)
+	slot := 0*cfg.MaxStoredPacks + 1
+	if a.ID[0] != 3 || a.SpermDonor[slot] != 102 || a.SpermPaternity[slot] != 20 ||
+		a.SpermGenotypeCont[slot*cfg.NumLoci+4] != 2 {
+		t.Fatalf("agent 0 should hold ID 3 and its packs, got ID %d donor %d paternity %d",
+			a.ID[0], a.SpermDonor[slot], a.SpermPaternity[slot])
+	}
+	if idx := w.AddAgent(); a.ID[idx] != 4 || a.SpermPacksCount[idx] != 0 {
+		t.Fatalf("new agent should get ID 4 and no packs, got %d with %d packs",
+			a.ID[idx], a.SpermPacksCount[idx])
+	}
+}
+
+func TestRemoveAgentRewritesCrossReferences(t *testing.T) {
+	cfg := testConfig()
+	w := New(cfg)
+	a := w.Agents
+
+	for i := 0; i < 5; i++ {
+		w.AddAgent()
+	}
+	// 0 courts 4 (the agent moved into slot 1); 2 fights 1 (the removed
+	// agent); 3 is about to feed on resource 4, which is not an agent.
+	a.Situation[0], a.InteractantIdx[0] = SituationCourtship, 4
+	a.Situation[4], a.InteractantIdx[4] = SituationCourtship, 0
+	a.Situation[2], a.InteractantIdx[2] = SituationCombat, 1
+	a.Situation[3], a.InteractantIdx[3] = SituationRegular, 4
+	w.Eggs.Count = 2
+	w.Eggs.CarrierAgentIdx[0] = 4
+	w.Eggs.CarrierAgentIdx[1] = 1
+
+	w.RemoveAgent(1)
+
+	if a.ID[1] != 5 || a.InteractantIdx[0] != 1 || a.InteractantIdx[1] != 0 {
+		t.Fatalf("courtship should follow agent 5 to slot 1, got ID %d and partners %d, %d",
+			a.ID[1], a.InteractantIdx[0], a.InteractantIdx[1])
+	}
+	if a.InteractantIdx[2] != -1 {
+		t.Fatalf("reference to the removed agent should be cleared, got %d", a.InteractantIdx[2])
+	}
+	if a.InteractantIdx[3] != 4 {
+		t.Fatalf("resource reference should be kept, got %d", a.InteractantIdx[3])
+	}
+	if w.Eggs.CarrierAgentIdx[0] != 1 || w.Eggs.CarrierAgentIdx[1] != -1 {
+		t.Fatalf("expected egg carriers 1 and -1, got %d and %d",
+			w.Eggs.CarrierAgentIdx[0], w.Eggs.CarrierAgentIdx[1])
+	}
+}
+
+func TestNamePatternNamesOffspring(t *testing.T) {
+	n := NewNames()
+	n.Set(1, "Eve", 0)
+	n.Set(2, "Adam", 2)
+
+	p, err := ParseNamePattern("{mother}+{father}/{mother_id}x{father_id} G{generation} #{id}")
+	if err != nil {
+		t.Fatalf("ParseNamePattern: %v", err)
+	}
+	n.AddOffspring(7, 2, 1)
+	if n.Name(7) != "" || n.Generation(7) != 3 {
+		t.Fatalf("offspring should be recorded unnamed without a pattern, got %q of generation %d", n.Name(7), n.Generation(7))
+	}
+	n.SetPattern(p)
+	if name := n.Name(7); name != "Eve+Adam/1x2 G3 #7" {
+		t.Fatalf("unexpected offspring name %q", name)
+	}
+	if len(n.names) != 3 {
+		t.Fatalf("only founder names should be interned, got %d names", len(n.names))
+	}
+	if f, m := n.Parents(7); f != 2 || m != 1 {
+		t.Fatalf("expected parents 2 and 1, got %d and %d", f, m)
+	}
+	if n.Name(99) != "" || n.Generation(99) != 0 {
+		t.Fatal("unknown IDs should have no name and generation 0")
+	}
+
+	// The default pattern only needs the offspring's own data.
+	p, _ = ParseNamePattern("")
+	n.SetPattern(p)
+	n.AddOffspring(8, 0, 7)
+	if name := n.Name(8); name != "G4-8" {
+		t.Fatalf("expected default name G4-8, got %q", name)
+	}
+
+	// Parent names do not nest: a parent's own parents are written as IDs.
+	p, _ = ParseNamePattern("{mother}.{id}")
+	n.SetPattern(p)
+	n.AddOffspring(9, 0, 1)
+	n.AddOffspring(10, 0, 9)
+	if name := n.Name(9); name != "Eve.9" {
+		t.Fatalf("expected Eve.9, got %q", name)
+	}
+	if name := n.Name(10); name != "1.9.10" {
+		t.Fatalf("expected 1.9.10, got %q", name)
+	}
+
+	// Names stay bounded however deep the pedigree.
+	p, _ = ParseNamePattern("{mother}x{father}")
+	n.SetPattern(p)
+	for id := uint64(11); id < 60; id++ {
+		n.AddOffspring(id, id-1, id-2)
+	}
+	if name := n.Name(59); name != "55x56x56x57" {
+		t.Fatalf("expected 55x56x56x57, got %q", name)
+	}
+}
+
+func TestParseNamePatternRejectsBadPlaceholders(t *testing.T) {
+	for _, pattern := range []string{"{mother", "{sister}-{id}"} {
+		if _, err := ParseNamePattern(pattern); err == nil {
+			t.Errorf("%q: expected an error", pattern)
+		}
+	}
+}
+
 func TestRemoveLastAgent(t *testing.T) {
 	cfg := testConfig()
 	w := New(cfg)
@@ -154,6 +290,58 @@ func TestRemoveLastAgent(t *testing.T) {
 	}
 }
 
+func TestAddAgentReusesCleanSlot(t *testing.T) {
+	cfg := testConfig()
+	w := New(cfg)
+	a := w.Agents
+	perception := cfg.NumSubstrates + cfg.NumResourceTypes + cfg.NumPrototypes
+
+	// A female full of state dies and frees slot 0.
+	idx := w.AddAgent()
+	a.Sex[idx] = SexFemale
+	a.Age[idx] = 40
+	a.Decision[idx] = 5
+	a.LastOpponentAction[idx] = 3
+	a.GametesCount[idx] = 7
+	a.FertilizedCount[idx] = 4
+	a.CarriedEggs[idx] = 2
+	a.TimeInStage[idx] = 9
+	a.TimeInInteraction[idx] = 3
+	a.MemoryLastPerceived[idx*perception+1] = 2
+	a.MemoryNumPerceived[idx*perception+1] = 6
+	a.MemoryLastInteracted[idx*perception+2] = 1
+	a.MemoryNumInteracted[idx*perception+2] = 3
+	a.MemoryLastBehavior[idx*cfg.NumBehaviors+1] = 0
+	a.MemoryNumBehavior[idx*cfg.NumBehaviors+1] = 8
+	a.MorphologyCont[idx*cfg.NumLoci] = 1.5
+	a.MorphologyDisc[idx*cfg.NumLoci] = 2
+	a.VDecision[idx*cfg.NumBehaviors+1] = 10
+	w.RemoveAgent(idx)
+
+	idx = w.AddAgent()
+	if idx != 0 {
+		t.Fatalf("expected slot 0 to be reused, got %d", idx)
+	}
+	if a.Sex[idx] != SexUndefined || a.Age[idx] != 0 || a.Decision[idx] != 0 || a.LastOpponentAction[idx] != 0 {
+		t.Fatal("identity and behavioral state should be reset")
+	}
+	if a.GametesCount[idx] != 0 || a.FertilizedCount[idx] != 0 || a.CarriedEggs[idx] != 0 {
+		t.Fatalf("expected no gametes or eggs, got %d, %d and %d",
+			a.GametesCount[idx], a.FertilizedCount[idx], a.CarriedEggs[idx])
+	}
+	if a.TimeInStage[idx] != 0 || a.TimeInInteraction[idx] != 0 {
+		t.Fatal("time counters should be reset")
+	}
+	if a.MemoryLastPerceived[idx*perception+1] != -1 || a.MemoryNumPerceived[idx*perception+1] != 0 ||
+		a.MemoryLastInteracted[idx*perception+2] != -1 || a.MemoryNumInteracted[idx*perception+2] != 0 ||
+		a.MemoryLastBehavior[idx*cfg.NumBehaviors+1] != -1 || a.MemoryNumBehavior[idx*cfg.NumBehaviors+1] != 0 {
+		t.Fatal("memory should be reset to never perceived, interacted with or performed")
+	}
+	if a.MorphologyCont[idx*cfg.NumLoci] != 0 || a.MorphologyDisc[idx*cfg.NumLoci] != 0 || a.VDecision[idx*cfg.NumBehaviors+1] != 0 {
+		t.Fatal("morphology and decision vectors should be reset")
+	}
+}
+
 func TestSubstrateMap(t *testing.T) {
 	m := NewSubstrateMap(10, 10)
 
@@ -264,7 +452,7 @@ func setupTestDB(t *testing.T) *storage.DB {
 func TestLoadWorld(t *testing.T) {
 	db := setupTestDB(t)
 
-	w, err := Load(db, 1)
+	w, err := Load(db, 1, 1)
 	if err != nil {
 		t.Fatalf("Load: %v", err)
 	}
@@ -295,6 +483,12 @@ func TestLoadWorld(t *testing.T) {
 		t.Fatalf("expected 20x20 grid, got %dx%d", w.Config.GridWidth, w.Config.GridHeight)
 	}
 
+	// Founders keep their names, as generation 0.
+	if id := w.Agents.ID[3]; w.Names.Name(id) != "agentD" || w.Names.Generation(id) != 0 {
+		t.Fatalf("expected founder agentD of generation 0, got %q of %d",
+			w.Names.Name(id), w.Names.Generation(id))
+	}
+
 	// Verify resources loaded.
 	if w.Resources.Count != 2 {
 		t.Fatalf("expected 2 resources, got %d", w.Resources.Count)
@@ -328,3 +522,197 @@ func TestLoadWorld(t *testing.T) {
 		t.Fatalf("expected agent 9 age=45, got %d", w.Agents.Age[9])
 	}
 }
+
+func TestLoadIndexesPrototypesWithinSex(t *testing.T) {
+	db := setupTestDB(t)
+
+	// AlphaF has DB ID 2 but is the first female prototype.
+	femaleID := int64(2)
+	storage.NewEnvironmentRepo(db).PlaceAgent(&storage.EnvironmentAgent{
+		EnvironmentID: 1, Name: "queen", PosX: 3, PosY: 3, PrototypeID: &femaleID, Sex: "F",
+	})
+
+	w, err := Load(db, 1, 1)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	idx := w.Agents.Count - 1
+	if w.Agents.PrototypeID[idx] != 0 {
+		t.Fatalf("expected female prototype index 0, got %d", w.Agents.PrototypeID[idx])
+	}
+	if w.Agents.StageID[0] != 1 {
+		t.Fatalf("expected Larva stage index 1, got %d", w.Agents.StageID[0])
+	}
+	if p, ok := w.Index.Perceiver(nil, &femaleID); !ok || p != 3 {
+		t.Fatalf("expected female perceiver index 3 (2 stages + 1 male), got %d", p)
+	}
+	if got := w.Index.PrototypeDBID(SexFemale, 0); got != femaleID {
+		t.Fatalf("expected female prototype DB ID %d, got %d", femaleID, got)
+	}
+}
+
+func TestLoadMapsResourceTypesToNutrients(t *testing.T) {
+	db := setupTestDB(t)
+
+	// A third type linked to the last nutrient, and an oviposition site with none.
+	nutID := int64(4)
+	rtRepo := storage.NewResourceTypeRepo(db)
+	rtRepo.Create(&storage.ResourceType{Name: "Carrion", NutrientID: &nutID, SortOrder: 3})
+	rtRepo.Create(&storage.ResourceType{Name: "Nest", IsOviposition: true, SortOrder: 4})
+	storage.NewEnvironmentRepo(db).PlaceResource(&storage.EnvironmentResource{
+		EnvironmentID: 1, ResourceTypeID: 4, Name: "nest1",
+		PosX: 3, PosY: 3, Quality: 6, Level: 7, MaxLevel: 12, RegenRate: 1,
+	})
+
+	w, err := Load(db, 1, 1)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	want := []int32{0, 1, 3, -1}
+	for i, n := range want {
+		if w.ResourceNutrient[i] != n {
+			t.Errorf("resource type %d: expected nutrient %d, got %d", i, n, w.ResourceNutrient[i])
+		}
+		if w.ResourceOviposition[i] != (i == 3) {
+			t.Errorf("resource type %d: unexpected oviposition flag %v", i, w.ResourceOviposition[i])
+		}
+	}
+	// The site starts empty, whatever level it was placed with.
+	site := w.Resources.Count - 1
+	if w.Resources.TypeID[site] != 3 || w.Resources.Level[site] != 0 || w.Resources.MaxLevel[site] != 12 {
+		t.Fatalf("expected an empty site of capacity 12, got type %d level %d/%d",
+			w.Resources.TypeID[site], w.Resources.Level[site], w.Resources.MaxLevel[site])
+	}
+}
+
+func TestLoadSubstrateMapUsesIndices(t *testing.T) {
+	db := setupTestDB(t)
+
+	// ID 6 sorts first, so it becomes substrate index 0.
+	storage.NewSubstrateRepo(db).Create("Mud", 0, false, 0)
+	db.Conn.Exec("INSERT INTO substrate_map_rows (environment_id, y_coord, map_data) VALUES (1, 0, '6,1,0')")
+
+	w, err := Load(db, 1, 1)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for x, want := range []int{0, 1, -1} {
+		if got := w.Substrates.SubstrateAt(float64(x), 0); got != want {
+			t.Errorf("cell (%d,0): expected substrate %d, got %d", x, want, got)
+		}
+	}
+	// agentA stands at (0,0).
+	if w.Agents.Substrate[0] != 0 {
+		t.Fatalf("expected founder on substrate 0, got %d", w.Agents.Substrate[0])
+	}
+}
+
+func TestLoadRejectsUnknownSubstrateInMap(t *testing.T) {
+	db := setupTestDB(t)
+	db.Conn.Exec("INSERT INTO substrate_map_rows (environment_id, y_coord, map_data) VALUES (1, 0, '1,42')")
+
+	if _, err := Load(db, 1, 1); err == nil {
+		t.Fatal("expected an error for an unknown substrate ID")
+	}
+}
+
+func TestLoadSeedsFounderGenotypes(t *testing.T) {
+	db := setupTestDB(t)
+
+	// A discrete locus sorted after the three continuous ones.
+	spotsID, _ := storage.NewLocusRepo(db).Create(&storage.Locus{
+		Name: "Spots", DominantValue: 3, RecessiveValue: 1, DefaultExpression: "0", SortOrder: 4,
+	})
+	envRepo := storage.NewEnvironmentRepo(db)
+	envRepo.SetAlleleFrequency(&storage.AlleleFrequency{EnvironmentID: 1, LocusID: 1, DominantFrequency: 1})
+	envRepo.SetAlleleFrequency(&storage.AlleleFrequency{EnvironmentID: 1, LocusID: 2, DominantFrequency: 0})
+	envRepo.SetAlleleFrequency(&storage.AlleleFrequency{EnvironmentID: 1, LocusID: spotsID, DominantFrequency: 1})
+	// agentA (ID 1) is heterozygous at Locus2 despite its zero frequency.
+	envRepo.SetAgentAllele(&storage.AgentAllele{AgentID: 1, LocusID: 2, Allele: 1, Dominant: true})
+
+	w, err := Load(db, 1, 1)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	a := w.Agents
+	stride := w.Config.NumLoci * 2
+	for i := 0; i < a.Count; i++ {
+		base := i * stride
+		for allele := 0; allele < 2; allele++ {
+			if a.GenotypeCont[base+allele] != 1 || a.DominanceCont[base+allele] != 1 {
+				t.Fatalf("agent %d Locus1 allele %d: expected dominant 1, got %f (dom %d)",
+					i, allele, a.GenotypeCont[base+allele], a.DominanceCont[base+allele])
+			}
+			if a.GenotypeDisc[base+allele] != 0 {
+				t.Fatalf("agent %d: continuous locus wrote a discrete allele", i)
+			}
+			spots := base + 3*2 + allele
+			if a.GenotypeDisc[spots] != 3 || a.DominanceDisc[spots] != 1 || a.GenotypeCont[spots] != 0 {
+				t.Fatalf("agent %d Spots allele %d: expected discrete dominant 3, got disc %d cont %f",
+					i, allele, a.GenotypeDisc[spots], a.GenotypeCont[spots])
+			}
+		}
+		wantMat := 0.5
+		if i == 0 {
+			wantMat = 1
+		}
+		if a.GenotypeCont[base+2] != 0.5 || a.GenotypeCont[base+3] != wantMat {
+			t.Fatalf("agent %d Locus2: expected (0.5, %v), got (%v, %v)",
+				i, wantMat, a.GenotypeCont[base+2], a.GenotypeCont[base+3])
+		}
+	}
+}
+
+func TestLoadRejectsAlleleFrequencyForUnknownLocus(t *testing.T) {
+	db := setupTestDB(t)
+	// Bypass the foreign key to simulate a stale row.
+	db.Conn.Exec("PRAGMA foreign_keys = OFF")
+	db.Conn.Exec("INSERT INTO environment_allele_frequencies (environment_id, locus_id, dominant_frequency) VALUES (1, 42, 0.3)")
+
+	if _, err := Load(db, 1, 1); err == nil {
+		t.Fatal("expected an error for an allele frequency of an unknown locus")
+	}
+}
+
+func TestExpression(t *testing.T) {
+	tests := []struct {
+		name   string
+		e      Expression
+		domPat uint8
+		want   float64
+	}{
+		{"dominant", Expression{}, 1, 4},
+		{"dominant maternal", Expression{}, 0, 2},
+		{"additive", Expression{Model: ExpressionAdditive}, 1, 3},
+		{"partial", Expression{Model: ExpressionPartial, H: 0.75}, 1, 3.5},
+		{"partial maternal", Expression{Model: ExpressionPartial, H: 0.75}, 0, 2.5},
+		{"overdominant", Expression{Model: ExpressionOverdominant, H: 1}, 1, 5},
+	}
+	for _, tt := range tests {
+		// Paternal 4 and maternal 2, one of them dominant.
+		if got := tt.e.Cont(4, 2, tt.domPat, 1-tt.domPat); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+		// Homozygotes express their allele under every model.
+		if got := tt.e.Cont(3, 3, 1, 0); got != 3 {
+			t.Errorf("%s homozygote: expected 3, got %v", tt.name, got)
+		}
+	}
+
+	if got := (Expression{Model: ExpressionPartial, H: 0.5}).Disc(5, 2, 1, 0); got != 3 {
+		t.Fatalf("discrete partial: expected 3, got %d", got)
+	}
+	if got := (Expression{}).Disc(5, 2, 0, 0); got != 3 {
+		t.Fatalf("discrete codominance: expected 3, got %d", got)
+	}
+	if _, err := ParseExpressionModel("epistatic"); err == nil {
+		t.Fatal("expected an error for an unknown model")
+	}
+	if m, err := ParseExpressionModel(""); err != nil || m != ExpressionDominant {
+		t.Fatalf("expected the dominant default, got %d (%v)", m, err)
+	}
+}